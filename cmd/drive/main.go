@@ -16,6 +16,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -24,6 +25,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/odeke-em/command"
 	"github.com/odeke-em/drive/config"
@@ -33,6 +35,48 @@ import (
 
 var context *config.Context
 
+// jsonOutput is global, it applies to every command e.g `drive -json ls`
+var jsonOutput = flag.Bool(drive.JSONKey, false, drive.DescJSON)
+
+// profile is global, it selects the credentials for every command
+// e.g `drive -profile work push`, overriding DRIVE_PROFILE
+var profile = flag.String(drive.ProfileKey, "", drive.DescProfile)
+
+// noPathIndex is global, it bypasses the path index for every command
+var noPathIndex = flag.Bool(drive.NoPathIndexKey, false, drive.DescNoPathIndex)
+
+func selectedProfile() string {
+	if *profile != "" {
+		return *profile
+	}
+	return os.Getenv(drive.DriveProfileEnvKey)
+}
+
+// newCommands is drive.New with the global flags applied to opts, exiting
+// if the commands can't be set up e.g the audit log can't be opened.
+// openedCommands are the commands created, to be closed before exiting.
+var openedCommands []*drive.Commands
+
+func newCommands(context *config.Context, opts *drive.Options) *drive.Commands {
+	if opts != nil {
+		opts.JSON = *jsonOutput
+		opts.NoPathIndex = *noPathIndex
+	}
+	g, err := drive.New(context, opts)
+	exitWithError(err)
+	openedCommands = append(openedCommands, g)
+	return g
+}
+
+func closeCommands() {
+	for _, g := range openedCommands {
+		if err := g.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	openedCommands = nil
+}
+
 func bindCommandWithAliases(key, description string, cmd command.Cmd, requiredFlags []string) {
 	command.On(key, description, cmd, requiredFlags)
 	aliases, ok := drive.Aliases[key]
@@ -51,8 +95,16 @@ func main() {
 	runtime.GOMAXPROCS(int(maxProcs))
 
 	bindCommandWithAliases(drive.AboutKey, drive.DescAbout, &aboutCmd{}, []string{})
+	bindCommandWithAliases(drive.ChownKey, drive.DescChown, &chownCmd{}, []string{})
+	bindCommandWithAliases(drive.ClashesKey, drive.DescClashes, &clashesCmd{}, []string{})
+	bindCommandWithAliases(drive.SearchKey, drive.DescSearch, &searchCmd{}, []string{})
+	bindCommandWithAliases(drive.DuKey, drive.DescDu, &duCmd{}, []string{})
+	bindCommandWithAliases(drive.CollectKey, drive.DescCollect, &collectCmd{}, []string{})
+	bindCommandWithAliases(drive.CommentsKey, drive.DescComments, &commentsCmd{}, []string{})
 	bindCommandWithAliases(drive.CopyKey, drive.DescCopy, &copyCmd{}, []string{})
+	bindCommandWithAliases(drive.DedupeKey, drive.DescDedupe, &dedupeCmd{}, []string{})
 	bindCommandWithAliases(drive.DiffKey, drive.DescDiff, &diffCmd{}, []string{})
+	bindCommandWithAliases(drive.DrivesKey, drive.DescDrives, &drivesCmd{}, []string{})
 	bindCommandWithAliases(drive.EmptyTrashKey, drive.DescEmptyTrash, &emptyTrashCmd{}, []string{})
 	bindCommandWithAliases(drive.FeaturesKey, drive.DescFeatures, &featuresCmd{}, []string{})
 	bindCommandWithAliases(drive.InitKey, drive.DescInit, &initCmd{}, []string{})
@@ -60,19 +112,29 @@ func main() {
 	bindCommandWithAliases(drive.HelpKey, drive.DescHelp, &helpCmd{}, []string{})
 
 	bindCommandWithAliases(drive.ListKey, drive.DescList, &listCmd{}, []string{})
+	bindCommandWithAliases(drive.MountKey, drive.DescMount, &mountCmd{}, []string{})
 	bindCommandWithAliases(drive.MoveKey, drive.DescMove, &moveCmd{}, []string{})
 	bindCommandWithAliases(drive.PullKey, drive.DescPull, &pullCmd{}, []string{})
+	bindCommandWithAliases(drive.SyncKey, drive.DescSync, &syncCmd{}, []string{})
 	bindCommandWithAliases(drive.PushKey, drive.DescPush, &pushCmd{}, []string{})
+	bindCommandWithAliases(drive.PropKey, drive.DescProp, &propCmd{}, []string{})
+	bindCommandWithAliases(drive.PromoteKey, drive.DescPromote, &promoteCmd{}, []string{})
 	bindCommandWithAliases(drive.PubKey, drive.DescPublish, &publishCmd{}, []string{})
+	bindCommandWithAliases(drive.ReapKey, drive.DescReap, &reapCmd{}, []string{})
+	bindCommandWithAliases(drive.RevisionsKey, drive.DescRevisions, &revisionsCmd{}, []string{})
 	bindCommandWithAliases(drive.RenameKey, drive.DescRename, &renameCmd{}, []string{})
 	bindCommandWithAliases(drive.QuotaKey, drive.DescQuota, &quotaCmd{}, []string{})
 	bindCommandWithAliases(drive.ShareKey, drive.DescShare, &shareCmd{}, []string{})
+	bindCommandWithAliases(drive.ShortcutKey, drive.DescShortcut, &shortcutCmd{}, []string{})
+	bindCommandWithAliases(drive.StarKey, drive.DescStar, &starCmd{}, []string{})
 	bindCommandWithAliases(drive.StatKey, drive.DescStat, &statCmd{}, []string{})
 	bindCommandWithAliases(drive.Md5sumKey, drive.DescMd5sum, &md5SumCmd{}, []string{})
 	bindCommandWithAliases(drive.UnshareKey, drive.DescUnshare, &unshareCmd{}, []string{})
+	bindCommandWithAliases(drive.UnstarKey, drive.DescUnstar, &unstarCmd{}, []string{})
 	bindCommandWithAliases(drive.TouchKey, drive.DescTouch, &touchCmd{}, []string{})
 	bindCommandWithAliases(drive.TrashKey, drive.DescTrash, &trashCmd{}, []string{})
 	bindCommandWithAliases(drive.UntrashKey, drive.DescUntrash, &untrashCmd{}, []string{})
+	bindCommandWithAliases(drive.UndoKey, drive.DescUndo, &undoCmd{}, []string{})
 	bindCommandWithAliases(drive.DeleteKey, drive.DescDelete, &deleteCmd{}, []string{})
 	bindCommandWithAliases(drive.UnpubKey, drive.DescUnpublish, &unpublishCmd{}, []string{})
 	bindCommandWithAliases(drive.VersionKey, drive.Version, &versionCmd{}, []string{})
@@ -83,6 +145,7 @@ func main() {
 
 	command.DefineHelp(&helpCmd{})
 	command.ParseAndRun()
+	closeCommands()
 }
 
 type helpCmd struct {
@@ -106,7 +169,7 @@ func (cmd *featuresCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 func (cmd *featuresCmd) Run(args []string) {
 	context, path := discoverContext(args)
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		Path: path,
 	}).About(drive.AboutFeatures))
 }
@@ -122,14 +185,30 @@ func (cmd *versionCmd) Run(args []string) {
 	exitWithError(nil)
 }
 
-type initCmd struct{}
+type initCmd struct {
+	profile               *string
+	deviceAuth            *bool
+	serviceAccount        *string
+	serviceAccountSubject *string
+}
 
 func (cmd *initCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.profile = fs.String(drive.ProfileKey, "", drive.DescProfile)
+	cmd.deviceAuth = fs.Bool(drive.CLIOptionDeviceAuth, false, drive.DescDeviceAuth)
+	cmd.serviceAccount = fs.String(drive.CLIOptionServiceAccount, "", drive.DescServiceAccount)
+	cmd.serviceAccountSubject = fs.String(drive.CLIOptionServiceAccountSubject, "", drive.DescServiceAccountSubject)
 	return fs
 }
 
 func (cmd *initCmd) Run(args []string) {
-	exitWithError(drive.New(initContext(args), nil).Init())
+	if *cmd.profile != "" {
+		*profile = *cmd.profile
+	}
+	exitWithError(newCommands(initContext(args), &drive.Options{
+		DeviceAuth:            *cmd.deviceAuth,
+		ServiceAccountKeyPath: *cmd.serviceAccount,
+		ServiceAccountSubject: *cmd.serviceAccountSubject,
+	}).Init())
 }
 
 type deInitCmd struct {
@@ -148,32 +227,40 @@ func (cmd *deInitCmd) Run(args []string) {
 		Path:     path,
 	}
 
-	exitWithError(drive.New(context, opts).DeInit())
+	exitWithError(newCommands(context, opts).DeInit())
 }
 
-type quotaCmd struct{}
+type quotaCmd struct {
+	largest          *int
+	nativeSizePolicy *string
+}
 
 func (cmd *quotaCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.largest = fs.Int(drive.CLIOptionQuotaLargest, drive.DefaultQuotaLargest, drive.DescQuotaLargest)
+	cmd.nativeSizePolicy = fs.String(drive.CLIOptionNativeSizePolicy, drive.NativeSizeExclude, drive.DescNativeSizePolicy)
 	return fs
 }
 
 func (cmd *quotaCmd) Run(args []string) {
 	context, path := discoverContext(args)
-	exitWithError(drive.New(context, &drive.Options{
-		Path: path,
-	}).About(drive.AboutQuota))
+	exitWithError(newCommands(context, &drive.Options{
+		Path:             path,
+		NativeSizePolicy: *cmd.nativeSizePolicy,
+	}).Quota(*cmd.largest))
 }
 
 type openCmd struct {
 	byId    *bool
 	local   *bool
 	browser *bool
+	link    *string
 }
 
 func (cmd *openCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "open by id instead of path")
 	cmd.local = fs.Bool(drive.CLIOptionFileBrowser, true, "open file with the local file manager")
 	cmd.browser = fs.Bool(drive.CLIOptionWebBrowser, true, "open file in default browser")
+	cmd.link = fs.String(drive.CLIOptionLink, drive.LinkView, drive.DescOpenLink)
 	return fs
 }
 
@@ -197,15 +284,23 @@ func (cmd *openCmd) Run(args []string) {
 		openType |= drive.FileManagerOpen
 	}
 
-	exitWithError(drive.New(context, &opts).Open(openType))
+	linkTypes, err := drive.ParseLinkTypes(*cmd.link)
+	exitWithError(err)
+	if len(linkTypes) != 1 {
+		exitWithError(fmt.Errorf("open: expecting one link type, got %q", *cmd.link))
+	}
+
+	exitWithError(newCommands(context, &opts).Open(openType, linkTypes[0]))
 }
 
 type urlCmd struct {
 	byId *bool
+	link *string
 }
 
 func (cmd *urlCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve url by id instead of path")
+	cmd.link = fs.String(drive.CLIOptionLink, drive.LinkView, drive.DescUrlLink)
 	return fs
 }
 
@@ -217,7 +312,10 @@ func (cmd *urlCmd) Run(args []string) {
 		Sources: sources,
 	}
 
-	exitWithError(drive.New(context, &opts).Url(*cmd.byId))
+	linkTypes, err := drive.ParseLinkTypes(*cmd.link)
+	exitWithError(err)
+
+	exitWithError(newCommands(context, &opts).Url(*cmd.byId, linkTypes))
 }
 
 type listCmd struct {
@@ -244,6 +342,10 @@ type listCmd struct {
 	exactOwner   *string
 	notOwner     *string
 	sort         *string
+
+	followShortcuts *bool
+	starred         *bool
+	query           *string
 }
 
 func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -269,6 +371,9 @@ func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.exactOwner = fs.String(drive.CLIOptionExactOwner, "", drive.DescExactOwner)
 	cmd.notOwner = fs.String(drive.CLIOptionNotOwner, "", drive.DescNotOwner)
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "list by id instead of path")
+	cmd.followShortcuts = fs.Bool(drive.CLIOptionFollowShortcuts, false, drive.DescFollowShortcuts)
+	cmd.starred = fs.Bool(drive.CLIOptionStarred, false, drive.DescStarred)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQueryFilter)
 
 	return fs
 }
@@ -326,14 +431,20 @@ func (cmd *listCmd) Run(args []string) {
 		TypeMask:  typeMask,
 		Quiet:     *cmd.quiet,
 		Meta:      &meta,
+
+		FollowShortcuts: *cmd.followShortcuts,
 	}
 
-	if *cmd.shared {
-		exitWithError(drive.New(context, &options).ListShared())
+	if *cmd.query != "" {
+		exitWithError(newCommands(context, &options).ListByQuery(*cmd.query))
+	} else if *cmd.starred {
+		exitWithError(newCommands(context, &options).ListStarred())
+	} else if *cmd.shared {
+		exitWithError(newCommands(context, &options).ListShared())
 	} else if *cmd.matches {
-		exitWithError(drive.New(context, &options).ListMatches())
+		exitWithError(newCommands(context, &options).ListMatches())
 	} else {
-		exitWithError(drive.New(context, &options).List(*cmd.byId))
+		exitWithError(newCommands(context, &options).List(*cmd.byId))
 	}
 }
 
@@ -373,12 +484,52 @@ func (cmd *md5SumCmd) Run(args []string) {
 	}
 
 	if *cmd.byId {
-		exitWithError(drive.New(context, &opts).StatById())
+		exitWithError(newCommands(context, &opts).StatById())
 	} else {
-		exitWithError(drive.New(context, &opts).Stat())
+		exitWithError(newCommands(context, &opts).Stat())
 	}
 }
 
+type starCmd struct {
+	byId  *bool
+	quiet *bool
+}
+
+func (cmd *starCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "star by id instead of path")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *starCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Star(*cmd.byId))
+}
+
+type unstarCmd struct {
+	byId  *bool
+	quiet *bool
+}
+
+func (cmd *unstarCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "unstar by id instead of path")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *unstarCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Unstar(*cmd.byId))
+}
+
 type statCmd struct {
 	byId      *bool
 	depth     *int
@@ -417,9 +568,9 @@ func (cmd *statCmd) Run(args []string) {
 	}
 
 	if *cmd.byId {
-		exitWithError(drive.New(context, &opts).StatById())
+		exitWithError(newCommands(context, &opts).StatById())
 	} else {
-		exitWithError(drive.New(context, &opts).Stat())
+		exitWithError(newCommands(context, &opts).Stat())
 	}
 }
 
@@ -482,7 +633,7 @@ func (cmd *indexCmd) Run(args []string) {
 		IgnoreNameClashes: *cmd.ignoreNameClashes,
 	}
 
-	dr := drive.New(context, options)
+	dr := newCommands(context, options)
 
 	fetchFn := dr.Fetch
 	if byId {
@@ -523,6 +674,21 @@ type pullCmd struct {
 	ignoreNameClashes *bool
 	skipMimeKey       *string
 	explicitlyExport  *bool
+	downloadWorkers   *int
+	downloadChunkSize *string
+	modConflictPolicy *string
+	downloadRate      *string
+	exportLayout      *string
+	revision          *string
+	followShortcuts   *bool
+	starred           *bool
+	verify            *bool
+	query             *string
+	symlinks          *string
+	preserve          *string
+	decrypt           *bool
+	keyFile           *string
+	nativeSizePolicy  *string
 
 	verbose *bool
 }
@@ -539,6 +705,7 @@ func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
 	cmd.ignoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
 	cmd.exportsDir = fs.String("export-dir", "", "directory to place exports")
+	cmd.exportLayout = fs.String(drive.CLIOptionExportLayout, drive.ExportLayoutNested, drive.DescExportLayout)
 	cmd.matches = fs.Bool(drive.MatchesKey, false, "search by prefix")
 	cmd.piped = fs.Bool("piped", false, "if true, read content from stdin")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
@@ -547,12 +714,35 @@ func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.skipMimeKey = fs.String(drive.CLIOptionSkipMime, "", drive.DescSkipMime)
 	cmd.explicitlyExport = fs.Bool(drive.CLIOptionExplicitlyExport, false, drive.DescExplicitylPullExports)
 	cmd.verbose = fs.Bool(drive.CLIOptionVerboseKey, false, drive.DescVerbose)
+	cmd.downloadWorkers = fs.Int(drive.CLIOptionDownloadWorkers, drive.DefaultDownloadWorkers, drive.DescDownloadWorkers)
+	cmd.downloadChunkSize = fs.String(drive.CLIOptionDownloadChunkSize, drive.DefaultDownloadChunkSize, drive.DescDownloadChunkSize)
+	cmd.modConflictPolicy = fs.String(drive.CLIOptionModConflictPolicy, os.Getenv(drive.DriveConflictPolicyEnvKey), drive.DescModConflictPolicy)
+	cmd.downloadRate = fs.String(drive.CLIOptionLimitDownloadRate, os.Getenv(drive.DriveDownloadRateEnvKey), drive.DescLimitDownloadRate)
+	cmd.revision = fs.String(drive.CLIOptionRevision, "", drive.DescRevision)
+	cmd.followShortcuts = fs.Bool(drive.CLIOptionFollowShortcuts, false, drive.DescFollowShortcuts)
+	cmd.starred = fs.Bool(drive.CLIOptionStarred, false, drive.DescStarred)
+	cmd.verify = fs.Bool(drive.CLIOptionVerifyTransfers, false, drive.DescVerifyTransfers)
+	cmd.symlinks = fs.String(drive.CLIOptionSymlinks, drive.SymlinksFollow, drive.DescSymlinks)
+	cmd.preserve = fs.String(drive.CLIOptionPreserve, "mtime", drive.DescPreserve)
+	cmd.decrypt = fs.Bool(drive.CLIOptionDecrypt, false, drive.DescDecrypt)
+	cmd.keyFile = fs.String(drive.CLIOptionEncryptionKeyFile, "", drive.DescEncryptionKeyFile)
+	cmd.nativeSizePolicy = fs.String(drive.CLIOptionNativeSizePolicy, drive.NativeSizeExclude, drive.DescNativeSizePolicy)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQueryFilter)
 
 	return fs
 }
 
 func (cmd *pullCmd) Run(args []string) {
 	sources, context, path := preprocessArgsByToggle(args, (*cmd.byId || *cmd.matches))
+	exitWithError(drive.CheckSymlinksPolicy(*cmd.symlinks))
+	preserveMask, err := drive.ParsePreserve(*cmd.preserve)
+	exitWithError(err)
+
+	downloadChunkSize, err := drive.ParseByteSize(*cmd.downloadChunkSize)
+	exitWithError(err)
+
+	downloadRate, err := drive.ParseRate(*cmd.downloadRate)
+	exitWithError(err)
 
 	excludes := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.excludeOps, ",")...)
 	excludeCrudMask := drive.CrudAtoi(excludes...)
@@ -570,6 +760,7 @@ func (cmd *pullCmd) Run(args []string) {
 	options := &drive.Options{
 		Exports:           uniqOrderedStr(exports),
 		ExportsDir:        strings.Trim(*cmd.exportsDir, " "),
+		ExportLayout:      *cmd.exportLayout,
 		Force:             *cmd.force,
 		Hidden:            *cmd.hidden,
 		IgnoreChecksum:    *cmd.ignoreChecksum,
@@ -586,17 +777,69 @@ func (cmd *pullCmd) Run(args []string) {
 		ExplicitlyExport:  *cmd.explicitlyExport,
 		Meta:              &meta,
 		Verbose:           *cmd.verbose,
-	}
-
-	if *cmd.matches {
-		exitWithError(drive.New(context, options).PullMatches())
+		DownloadWorkers:   *cmd.downloadWorkers,
+		DownloadChunkSize: downloadChunkSize,
+		ModConflictPolicy: *cmd.modConflictPolicy,
+		DownloadRateLimit: downloadRate,
+		Revision:          *cmd.revision,
+		FollowShortcuts:   *cmd.followShortcuts,
+		Verify:            *cmd.verify,
+		Symlinks:          *cmd.symlinks,
+		PreserveMask:      preserveMask,
+		Decrypt:           *cmd.decrypt,
+		EncryptionKeyPath: *cmd.keyFile,
+		NativeSizePolicy:  *cmd.nativeSizePolicy,
+	}
+
+	if *cmd.revision != "" {
+		exitWithError(newCommands(context, options).PullRevision(*cmd.byId))
+	} else if *cmd.query != "" {
+		exitWithError(newCommands(context, options).PullByQuery(*cmd.query))
+	} else if *cmd.starred {
+		exitWithError(newCommands(context, options).PullStarred())
+	} else if *cmd.matches {
+		exitWithError(newCommands(context, options).PullMatches())
 	} else if *cmd.piped {
-		exitWithError(drive.New(context, options).PullPiped(*cmd.byId))
+		exitWithError(newCommands(context, options).PullPiped(*cmd.byId))
 	} else {
-		exitWithError(drive.New(context, options).Pull(*cmd.byId))
+		exitWithError(newCommands(context, options).Pull(*cmd.byId))
 	}
 }
 
+type syncCmd struct {
+	force          *bool
+	hidden         *bool
+	noPrompt       *bool
+	noClobber      *bool
+	ignoreChecksum *bool
+	quiet          *bool
+}
+
+func (cmd *syncCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.force = fs.Bool(drive.ForceKey, false, "forces a sync even if no changes present")
+	cmd.hidden = fs.Bool(drive.HiddenKey, false, "allows syncing of hidden paths")
+	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "shows no prompt before applying the sync")
+	cmd.noClobber = fs.Bool(drive.CLIOptionNoClobber, false, "prevents overwriting of old content")
+	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *syncCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, false)
+	exitWithError(newCommands(context, &drive.Options{
+		Force:          *cmd.force,
+		Hidden:         *cmd.hidden,
+		IgnoreChecksum: *cmd.ignoreChecksum,
+		NoPrompt:       *cmd.noPrompt,
+		NoClobber:      *cmd.noClobber,
+		Path:           path,
+		Quiet:          *cmd.quiet,
+		Recursive:      true,
+		Sources:        sources,
+	}).Sync())
+}
+
 type pushCmd struct {
 	noClobber   *bool
 	hidden      *bool
@@ -611,6 +854,7 @@ type pushCmd struct {
 	// ocr when set indicates that Optical Character Recognition should be
 	// attempted on .[gif, jpg, pdf, png] uploads
 	ocr               *bool
+	ocrLanguage       *string
 	ignoreChecksum    *bool
 	ignoreConflict    *bool
 	ignoreNameClashes *bool
@@ -619,6 +863,18 @@ type pushCmd struct {
 	excludeOps        *string
 	skipMimeKey       *string
 	verbose           *bool
+	modConflictPolicy *string
+	uploadRate        *string
+	watch             *bool
+	debounce          *time.Duration
+	pollInterval      *time.Duration
+	properties        *string
+	verify            *bool
+	symlinks          *string
+	preserve          *string
+	encrypt           *bool
+	keyFile           *string
+	gzip              *bool
 }
 
 func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -630,6 +886,7 @@ func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.mountedPush = fs.Bool("m", false, "allows pushing of mounted paths")
 	cmd.convert = fs.Bool("convert", false, "toggles conversion of the file to its appropriate Google Doc format")
 	cmd.ocr = fs.Bool("ocr", false, "if true, attempt OCR on gif, jpg, pdf and png uploads")
+	cmd.ocrLanguage = fs.String(drive.CLIOptionOCRLanguage, "", drive.DescOCRLanguage)
 	cmd.piped = fs.Bool("piped", false, "if true, read content from stdin")
 	cmd.ignoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
 	cmd.ignoreConflict = fs.Bool(drive.CLIOptionIgnoreConflict, false, drive.DescIgnoreConflict)
@@ -639,6 +896,18 @@ func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.excludeOps = fs.String(drive.CLIOptionExcludeOperations, "", drive.DescExcludeOps)
 	cmd.skipMimeKey = fs.String(drive.CLIOptionSkipMime, "", drive.DescSkipMime)
 	cmd.verbose = fs.Bool(drive.CLIOptionVerboseKey, false, drive.DescVerbose)
+	cmd.modConflictPolicy = fs.String(drive.CLIOptionModConflictPolicy, os.Getenv(drive.DriveConflictPolicyEnvKey), drive.DescModConflictPolicy)
+	cmd.uploadRate = fs.String(drive.CLIOptionLimitUploadRate, os.Getenv(drive.DriveUploadRateEnvKey), drive.DescLimitUploadRate)
+	cmd.watch = fs.Bool(drive.CLIOptionWatch, false, drive.DescPushWatch)
+	cmd.debounce = fs.Duration(drive.CLIOptionDebounce, drive.DefaultDebounce, drive.DescDebounce)
+	cmd.pollInterval = fs.Duration(drive.CLIOptionPollInterval, drive.DefaultPushWatchInterval, drive.DescPollInterval)
+	cmd.properties = fs.String(drive.CLIOptionProperty, "", drive.DescPushProperty)
+	cmd.verify = fs.Bool(drive.CLIOptionVerifyTransfers, false, drive.DescVerifyTransfers)
+	cmd.symlinks = fs.String(drive.CLIOptionSymlinks, drive.SymlinksFollow, drive.DescSymlinks)
+	cmd.preserve = fs.String(drive.CLIOptionPreserve, "mtime", drive.DescPreserve)
+	cmd.encrypt = fs.Bool(drive.CLIOptionEncrypt, false, drive.DescEncrypt)
+	cmd.keyFile = fs.String(drive.CLIOptionEncryptionKeyFile, "", drive.DescEncryptionKeyFile)
+	cmd.gzip = fs.Bool(drive.CLIOptionGzip, false, drive.DescGzip)
 	return fs
 }
 
@@ -653,9 +922,11 @@ func (cmd *pushCmd) Run(args []string) {
 		options.Sources = sources
 
 		if *cmd.piped {
-			exitWithError(drive.New(context, options).PushPiped())
+			exitWithError(newCommands(context, options).PushPiped())
+		} else if *cmd.watch {
+			exitWithError(newCommands(context, options).PushWatch())
 		} else {
-			exitWithError(drive.New(context, options).Push())
+			exitWithError(newCommands(context, options).Push())
 		}
 	}
 }
@@ -689,13 +960,17 @@ func (cmd *touchCmd) Run(args []string) {
 	}
 
 	if *cmd.matches {
-		exitWithError(drive.New(context, &opts).TouchByMatch())
+		exitWithError(newCommands(context, &opts).TouchByMatch())
 	} else {
-		exitWithError(drive.New(context, &opts).Touch(*cmd.byId))
+		exitWithError(newCommands(context, &opts).Touch(*cmd.byId))
 	}
 }
 
 func (cmd *pushCmd) createPushOptions() *drive.Options {
+	exitWithError(drive.CheckSymlinksPolicy(*cmd.symlinks))
+	preserveMask, err := drive.ParsePreserve(*cmd.preserve)
+	exitWithError(err)
+
 	mask := drive.OptNone
 	if *cmd.convert {
 		mask |= drive.OptConvert
@@ -715,6 +990,12 @@ func (cmd *pushCmd) createPushOptions() *drive.Options {
 		exitWithError(fmt.Errorf("all CRUD operations forbidden yet asking to push"))
 	}
 
+	uploadRate, err := drive.ParseRate(*cmd.uploadRate)
+	exitWithError(err)
+
+	properties, err := drive.ParseProperties(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.properties, ",")...)...)
+	exitWithError(err)
+
 	return &drive.Options{
 		Force:             *cmd.force,
 		Hidden:            *cmd.hidden,
@@ -730,6 +1011,18 @@ func (cmd *pushCmd) createPushOptions() *drive.Options {
 		ExcludeCrudMask:   excludeCrudMask,
 		IgnoreNameClashes: *cmd.ignoreNameClashes,
 		Verbose:           *cmd.verbose,
+		ModConflictPolicy: *cmd.modConflictPolicy,
+		UploadRateLimit:   uploadRate,
+		Debounce:          *cmd.debounce,
+		PollInterval:      *cmd.pollInterval,
+		Properties:        properties,
+		Verify:            *cmd.verify,
+		OCRLanguage:       *cmd.ocrLanguage,
+		Symlinks:          *cmd.symlinks,
+		PreserveMask:      preserveMask,
+		Encrypt:           *cmd.encrypt,
+		EncryptionKeyPath: *cmd.keyFile,
+		Gzip:              *cmd.gzip,
 	}
 }
 
@@ -775,7 +1068,7 @@ func (cmd *pushCmd) pushMounted(args []string) {
 	options.Mount = mount
 	options.Sources = sources
 
-	exitWithError(drive.New(context, options).Push())
+	exitWithError(newCommands(context, options).Push())
 }
 
 type aboutCmd struct {
@@ -811,7 +1104,7 @@ func (cmd *aboutCmd) Run(args []string) {
 		mask = drive.AboutQuota | drive.AboutFeatures | drive.AboutFileSizes
 	}
 
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		Quiet: *cmd.quiet,
 	}).About(mask))
 }
@@ -836,7 +1129,7 @@ func (cmd *diffCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 func (cmd *diffCmd) Run(args []string) {
 	sources, context, path := preprocessArgs(args)
 
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		Recursive:         true,
 		Path:              path,
 		Hidden:            *cmd.hidden,
@@ -849,57 +1142,74 @@ func (cmd *diffCmd) Run(args []string) {
 }
 
 type publishCmd struct {
-	hidden *bool
-	quiet  *bool
-	byId   *bool
+	hidden   *bool
+	quiet    *bool
+	byId     *bool
+	role     *string
+	withLink *bool
+	expires  *string
 }
 
 type unpublishCmd struct {
-	hidden *bool
-	quiet  *bool
-	byId   *bool
+	hidden    *bool
+	quiet     *bool
+	byId      *bool
+	recursive *bool
+	expired   *bool
 }
 
 func (cmd *unpublishCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool(drive.HiddenKey, false, "allows pulling of hidden paths")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "unpublish by id instead of path")
+	cmd.recursive = fs.Bool("r", false, "also revoke the links to the descendants of folders")
+	cmd.expired = fs.Bool(drive.CLIOptionPubExpired, false, drive.DescPubExpired)
 	return fs
 }
 
 func (cmd *unpublishCmd) Run(args []string) {
 	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		Path:    path,
 		Sources: sources,
 		Quiet:   *cmd.quiet,
-	}).Unpublish(*cmd.byId))
+	}).Unpublish(&drive.UnpublishArgs{
+		ById:      *cmd.byId,
+		Recursive: *cmd.recursive,
+		Expired:   *cmd.expired,
+	}))
 }
 
 type emptyTrashCmd struct {
-	noPrompt *bool
-	quiet    *bool
+	noPrompt  *bool
+	quiet     *bool
+	olderThan *string
 }
 
 func (cmd *emptyTrashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "shows no prompt before emptying the trash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.olderThan = fs.String(drive.CLIOptionOlderThan, "", drive.DescEmptyTrashOlderThan)
 	return fs
 }
 
 func (cmd *emptyTrashCmd) Run(args []string) {
 	_, context, _ := preprocessArgs(args)
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		NoPrompt: *cmd.noPrompt,
 		Quiet:    *cmd.quiet,
-	}).EmptyTrash())
+	}).EmptyTrash(*cmd.olderThan))
 }
 
 type deleteCmd struct {
-	hidden  *bool
-	matches *bool
-	quiet   *bool
-	byId    *bool
+	hidden         *bool
+	matches        *bool
+	quiet          *bool
+	byId           *bool
+	auditLogPath   *string
+	auditLogRotate *bool
+	query          *string
+	force          *bool
 }
 
 func (cmd *deleteCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -907,30 +1217,54 @@ func (cmd *deleteCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.matches = fs.Bool(drive.MatchesKey, false, "search by prefix and delete")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "delete by id instead of path")
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQuery)
+	cmd.force = fs.Bool(drive.ForceKey, false, "with query, delete without prompting")
 	return fs
 }
 
 func (cmd *deleteCmd) Run(args []string) {
+	if *cmd.query != "" {
+		context, path := discoverContext(args)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Hidden:         *cmd.hidden,
+			Quiet:          *cmd.quiet,
+			Force:          *cmd.force,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+		}).DeleteByQuery(*cmd.query))
+		return
+	}
+
 	sources, context, path := preprocessArgsByToggle(args, *cmd.matches || *cmd.byId)
 
 	opts := drive.Options{
-		Path:    path,
-		Sources: sources,
-		Quiet:   *cmd.quiet,
+		Path:           path,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
 	}
 
 	if !*cmd.matches {
-		exitWithError(drive.New(context, &opts).Delete(*cmd.byId))
+		exitWithError(newCommands(context, &opts).Delete(*cmd.byId))
 	} else {
-		exitWithError(drive.New(context, &opts).DeleteByMatch())
+		exitWithError(newCommands(context, &opts).DeleteByMatch())
 	}
 }
 
 type trashCmd struct {
-	hidden  *bool
-	matches *bool
-	quiet   *bool
-	byId    *bool
+	hidden         *bool
+	matches        *bool
+	quiet          *bool
+	byId           *bool
+	auditLogPath   *string
+	auditLogRotate *bool
+	query          *string
+	force          *bool
+	list           *bool
 }
 
 func (cmd *trashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -938,22 +1272,51 @@ func (cmd *trashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.matches = fs.Bool(drive.MatchesKey, false, "search by prefix and trash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "trash by id instead of path")
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQuery)
+	cmd.force = fs.Bool(drive.ForceKey, false, "with query, trash without prompting")
+	cmd.list = fs.Bool(drive.CLIOptionTrashList, false, drive.DescTrashList)
 	return fs
 }
 
 func (cmd *trashCmd) Run(args []string) {
+	if *cmd.list {
+		context, path := discoverContext(nil)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:  path,
+			Quiet: *cmd.quiet,
+		}).ListTrash())
+		return
+	}
+
+	if *cmd.query != "" {
+		context, path := discoverContext(args)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Hidden:         *cmd.hidden,
+			Quiet:          *cmd.quiet,
+			Force:          *cmd.force,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+		}).TrashByQuery(*cmd.query))
+		return
+	}
+
 	sources, context, path := preprocessArgsByToggle(args, *cmd.matches || *cmd.byId)
 
 	opts := drive.Options{
-		Path:    path,
-		Sources: sources,
-		Quiet:   *cmd.quiet,
+		Path:           path,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
 	}
 
 	if !*cmd.matches {
-		exitWithError(drive.New(context, &opts).Trash(*cmd.byId))
+		exitWithError(newCommands(context, &opts).Trash(*cmd.byId))
 	} else {
-		exitWithError(drive.New(context, &opts).TrashByMatch())
+		exitWithError(newCommands(context, &opts).TrashByMatch())
 	}
 }
 
@@ -983,22 +1346,155 @@ func (cmd *newCmd) Run(args []string) {
 	opts.Meta = &meta
 
 	if *cmd.folder {
-		exitWithError(drive.New(context, &opts).NewFolder())
+		exitWithError(newCommands(context, &opts).NewFolder())
 	} else {
-		exitWithError(drive.New(context, &opts).NewFile())
+		exitWithError(newCommands(context, &opts).NewFile())
 	}
 }
 
+type collectCmd struct {
+	quiet          *bool
+	recursive      *bool
+	rule           *string
+	conflictPolicy *string
+	auditLogPath   *string
+	auditLogRotate *bool
+	planPath       *string
+}
+
+func (cmd *collectCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.recursive = fs.Bool("r", false, "also collect from all descendant folders")
+	cmd.rule = fs.String(drive.CLIOptionWatchRule, ".", "regular expression that names of files have to match to be collected")
+	cmd.conflictPolicy = fs.String(drive.CLIOptionConflictPolicy, drive.ConflictSkip, drive.DescConflictPolicy)
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.planPath = fs.String(drive.CLIOptionPlan, "", drive.DescPlan)
+	return fs
+}
+
+func (cmd *collectCmd) Run(args []string) {
+	argc := len(args)
+	if argc < 2 {
+		exitWithError(fmt.Errorf("collect: expecting <src> [src...] <dest>"))
+	}
+
+	sources, context, path := preprocessArgs(args[:argc-1])
+	destRels, err := relativePaths(context.AbsPathOf(""), args[argc-1])
+	exitWithError(err)
+
+	exitWithError(newCommands(context, &drive.Options{
+		Path:           path,
+		Sources:        append(sources, destRels[0]),
+		Quiet:          *cmd.quiet,
+		Recursive:      *cmd.recursive,
+		ConflictPolicy: *cmd.conflictPolicy,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
+		PlanPath:       *cmd.planPath,
+	}).Collect(*cmd.rule))
+}
+
 type copyCmd struct {
-	quiet     *bool
-	recursive *bool
-	byId      *bool
+	quiet                  *bool
+	recursive              *bool
+	byId                   *bool
+	preserveIndexableText  *bool
+	partition              *string
+	maxChildren            *int
+	shareWith              *string
+	role                   *string
+	dedupeIdentical        *bool
+	pruneEmptyDirs         *bool
+	uniqueNames            *bool
+	maxFileSize            *string
+	oversizePolicy         *string
+	auditLogPath           *string
+	auditLogRotate         *bool
+	destRoot               *string
+	waitReady              *bool
+	readyTimeout           *time.Duration
+	preserveLinkSharing    *bool
+	throttleOnLowQuota     *string
+	includeShared          *bool
+	checkWritable          *bool
+	trashSourceAfter       *time.Duration
+	preserveRestrictions   *bool
+	intoNewest             *bool
+	intoOldest             *bool
+	pausable               *bool
+	backlink               *bool
+	verifyCopies           *bool
+	verifyReport           *string
+	starIf                 *string
+	checkExtensions        *bool
+	strictExtensions       *bool
+	destTemplate           *string
+	prefetch               *bool
+	prefetchWorkers        *int
+	preserveFolderMetadata *bool
+	snapshotPath           *string
+	snapshotDeletions      *bool
+	treeDiff               *bool
+	treeDiffDepth          *int
+	nativeSizePolicy       *string
+	copyWorkers            *int
+	dryRun                 *bool
+	planPath               *string
+	toProfile              *string
+	followShortcuts        *bool
+	ignoreNameClashes      *bool
 }
 
 func (cmd *copyCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.recursive = fs.Bool("r", false, "recursive copying")
+	cmd.toProfile = fs.String(drive.CLIOptionToProfile, "", drive.DescToProfile)
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "copy by id instead of path")
+	cmd.preserveIndexableText = fs.Bool(drive.CLIOptionPreserveIndexableText, false, drive.DescPreserveIndexableText)
+	cmd.partition = fs.String(drive.CLIOptionPartition, "", drive.DescPartition)
+	cmd.maxChildren = fs.Int(drive.CLIOptionMaxChildren, drive.DefaultMaxChildren, drive.DescMaxChildren)
+	cmd.shareWith = fs.String(drive.CLIOptionShareWith, "", drive.DescShareWith)
+	cmd.role = fs.String(drive.RoleKey, "", drive.DescCopyShareRole)
+	cmd.dedupeIdentical = fs.Bool(drive.CLIOptionDedupeIdentical, false, drive.DescDedupeIdentical)
+	cmd.pruneEmptyDirs = fs.Bool(drive.CLIOptionPruneEmptyDirs, false, drive.DescPruneEmptyDirs)
+	cmd.uniqueNames = fs.Bool(drive.CLIOptionUniqueNames, false, drive.DescUniqueNames)
+	cmd.maxFileSize = fs.String(drive.CLIOptionMaxFileSize, "", drive.DescMaxFileSize)
+	cmd.oversizePolicy = fs.String(drive.CLIOptionOversizePolicy, drive.OversizeSkip, drive.DescOversizePolicy)
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.destRoot = fs.String(drive.CLIOptionDestRoot, "", drive.DescDestRoot)
+	cmd.waitReady = fs.Bool(drive.CLIOptionWaitReady, false, drive.DescWaitReady)
+	cmd.readyTimeout = fs.Duration(drive.CLIOptionReadyTimeout, drive.DefaultReadyTimeout, drive.DescReadyTimeout)
+	cmd.planPath = fs.String(drive.CLIOptionPlan, "", drive.DescPlan)
+	cmd.preserveLinkSharing = fs.Bool(drive.CLIOptionPreserveLinkSharing, false, drive.DescPreserveLinkSharing)
+	cmd.throttleOnLowQuota = fs.String(drive.CLIOptionThrottleOnLowQuota, "", drive.DescThrottleOnLowQuota)
+	cmd.includeShared = fs.Bool(drive.CLIOptionIncludeShared, false, drive.DescIncludeShared)
+	cmd.checkWritable = fs.Bool(drive.CLIOptionCheckWritable, false, drive.DescCheckWritable)
+	cmd.trashSourceAfter = fs.Duration(drive.CLIOptionTrashSourceAfter, 0, drive.DescTrashSourceAfter)
+	cmd.preserveRestrictions = fs.Bool(drive.CLIOptionPreserveRestrictions, false, drive.DescPreserveRestrictions)
+	cmd.intoNewest = fs.Bool(drive.CLIOptionIntoNewest, false, drive.DescIntoNewest)
+	cmd.intoOldest = fs.Bool(drive.CLIOptionIntoOldest, false, drive.DescIntoOldest)
+	cmd.pausable = fs.Bool(drive.CLIOptionPausable, false, drive.DescPausable)
+	cmd.backlink = fs.Bool(drive.CLIOptionBacklink, false, drive.DescBacklink)
+	cmd.verifyCopies = fs.Bool(drive.CLIOptionVerifyCopies, false, drive.DescVerifyCopies)
+	cmd.verifyReport = fs.String(drive.CLIOptionVerifyReport, "", drive.DescVerifyReport)
+	cmd.starIf = fs.String(drive.CLIOptionStarIf, "", drive.DescStarIf)
+	cmd.checkExtensions = fs.Bool(drive.CLIOptionCheckExtensions, false, drive.DescCheckExtensions)
+	cmd.strictExtensions = fs.Bool(drive.CLIOptionStrictExtensions, false, drive.DescStrictExtensions)
+	cmd.destTemplate = fs.String(drive.CLIOptionDestTemplate, "", drive.DescDestTemplate)
+	cmd.prefetch = fs.Bool(drive.CLIOptionPrefetch, false, drive.DescPrefetch)
+	cmd.prefetchWorkers = fs.Int(drive.CLIOptionPrefetchWorkers, drive.DefaultPrefetchWorkers, drive.DescPrefetchWorkers)
+	cmd.preserveFolderMetadata = fs.Bool(drive.CLIOptionPreserveFolderMetadata, false, drive.DescPreserveFolderMetadata)
+	cmd.snapshotPath = fs.String(drive.CLIOptionSnapshotPath, "", drive.DescSnapshotPath)
+	cmd.snapshotDeletions = fs.Bool(drive.CLIOptionSnapshotDeletions, false, drive.DescSnapshotDeletions)
+	cmd.treeDiff = fs.Bool(drive.CLIOptionTreeDiff, false, drive.DescTreeDiff)
+	cmd.treeDiffDepth = fs.Int(drive.CLIOptionTreeDiffDepth, drive.DefaultTreeDiffDepth, drive.DescTreeDiffDepth)
+	cmd.nativeSizePolicy = fs.String(drive.CLIOptionNativeSizePolicy, drive.NativeSizeExclude, drive.DescNativeSizePolicy)
+	cmd.copyWorkers = fs.Int(drive.CLIOptionCopyWorkers, drive.DefaultCopyWorkers, drive.DescCopyWorkers)
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.followShortcuts = fs.Bool(drive.CLIOptionFollowShortcuts, false, drive.DescFollowShortcuts)
+	cmd.ignoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
 	return fs
 }
 
@@ -1014,29 +1510,130 @@ func (cmd *copyCmd) Run(args []string) {
 
 	dest := args[end]
 
-	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+	// Under a dest root, relative paths are resolved remotely rather than against the cwd
+	underRoot := *cmd.destRoot != ""
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId || underRoot)
 
 	// Unshift by the end path
 	sources = sources[:len(sources)-1]
-	destRels, err := relativePaths(context.AbsPathOf(""), dest)
-	exitWithError(err)
+	if !underRoot {
+		destRels, err := relativePaths(context.AbsPathOf(""), dest)
+		exitWithError(err)
+		dest = destRels[0]
+	}
 
-	dest = destRels[0]
 	sources = append(sources, dest)
 
-	exitWithError(drive.New(context, &drive.Options{
-		Path:      path,
-		Sources:   sources,
-		Recursive: *cmd.recursive,
-		Quiet:     *cmd.quiet,
-	}).Copy(*cmd.byId))
+	meta := map[string][]string{
+		drive.EmailsKey: uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.shareWith, ",")...)),
+		drive.RoleKey:   drive.NonEmptyTrimmedStrings(*cmd.role),
+	}
+
+	maxFileSize := int64(0)
+	if *cmd.maxFileSize != "" {
+		var err error
+		maxFileSize, err = drive.ParseByteSize(*cmd.maxFileSize)
+		exitWithError(err)
+	}
+
+	options := &drive.Options{
+		Meta:                   &meta,
+		Path:                   path,
+		Sources:                sources,
+		Recursive:              *cmd.recursive,
+		Quiet:                  *cmd.quiet,
+		PreserveIndexableText:  *cmd.preserveIndexableText,
+		Partition:              *cmd.partition,
+		MaxChildren:            *cmd.maxChildren,
+		DedupeIdentical:        *cmd.dedupeIdentical,
+		PruneEmptyDirs:         *cmd.pruneEmptyDirs,
+		UniqueNames:            *cmd.uniqueNames,
+		MaxFileSize:            maxFileSize,
+		OversizePolicy:         *cmd.oversizePolicy,
+		AuditLogPath:           *cmd.auditLogPath,
+		AuditLogRotate:         *cmd.auditLogRotate,
+		DestRoot:               *cmd.destRoot,
+		WaitReady:              *cmd.waitReady,
+		ReadyTimeout:           *cmd.readyTimeout,
+		PreserveLinkSharing:    *cmd.preserveLinkSharing,
+		ThrottleOnLowQuota:     *cmd.throttleOnLowQuota,
+		IncludeShared:          *cmd.includeShared,
+		CheckWritable:          *cmd.checkWritable,
+		TrashSourceAfter:       *cmd.trashSourceAfter,
+		PreserveRestrictions:   *cmd.preserveRestrictions,
+		DestPick:               destPick(*cmd.intoNewest, *cmd.intoOldest),
+		Pausable:               *cmd.pausable,
+		Backlink:               *cmd.backlink,
+		VerifyCopies:           *cmd.verifyCopies || *cmd.verifyReport != "",
+		VerifyReportPath:       *cmd.verifyReport,
+		StarIf:                 *cmd.starIf,
+		CheckExtensions:        *cmd.checkExtensions,
+		StrictExtensions:       *cmd.strictExtensions,
+		DestTemplate:           *cmd.destTemplate,
+		Prefetch:               *cmd.prefetch,
+		PrefetchWorkers:        *cmd.prefetchWorkers,
+		PreserveFolderMetadata: *cmd.preserveFolderMetadata,
+		SnapshotPath:           *cmd.snapshotPath,
+		SnapshotDeletions:      *cmd.snapshotDeletions,
+		TreeDiff:               *cmd.treeDiff,
+		TreeDiffDepth:          *cmd.treeDiffDepth,
+		NativeSizePolicy:       *cmd.nativeSizePolicy,
+		CopyWorkers:            *cmd.copyWorkers,
+		DryRun:                 *cmd.dryRun,
+		PlanPath:               *cmd.planPath,
+		FollowShortcuts:        *cmd.followShortcuts,
+		IgnoreNameClashes:      *cmd.ignoreNameClashes,
+	}
+
+	if *cmd.toProfile != "" {
+		exitWithError(newCommands(context, options).CopyToProfile(*cmd.toProfile))
+		return
+	}
+	exitWithError(newCommands(context, options).Copy(*cmd.byId))
+}
+
+type dedupeCmd struct {
+	quiet     *bool
+	pageSize  *int64
+	statePath *string
+	auto      *string
+	resolve   *bool
+	force     *bool
+}
+
+func (cmd *dedupeCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.pageSize = fs.Int64("p", 100, "number of results per page fetched and checkpointed")
+	cmd.statePath = fs.String(drive.CLIOptionStatePath, "", drive.DescStatePath)
+	cmd.auto = fs.String(drive.CLIOptionDedupeAuto, "", drive.DescDedupeAuto)
+	cmd.resolve = fs.Bool(drive.CLIOptionDedupeResolve, false, drive.DescDedupeResolve)
+	cmd.force = fs.Bool(drive.ForceKey, false, "resolve the duplicates without prompting")
+	return fs
+}
+
+func (cmd *dedupeCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:              path,
+		Sources:           sources,
+		PageSize:          *cmd.pageSize,
+		Quiet:             *cmd.quiet,
+		StatePath:         *cmd.statePath,
+		DedupeStrategy:    *cmd.auto,
+		DedupeInteractive: *cmd.resolve,
+		Force:             *cmd.force,
+	}).Dedupe())
 }
 
 type untrashCmd struct {
-	hidden  *bool
-	matches *bool
-	quiet   *bool
-	byId    *bool
+	hidden         *bool
+	matches        *bool
+	quiet          *bool
+	byId           *bool
+	auditLogPath   *string
+	auditLogRotate *bool
+	query          *string
+	force          *bool
 }
 
 func (cmd *untrashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1044,22 +1641,41 @@ func (cmd *untrashCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.matches = fs.Bool(drive.MatchesKey, false, "search by prefix and untrash")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "untrash by id instead of path")
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQuery)
+	cmd.force = fs.Bool(drive.ForceKey, false, "with query, untrash without prompting")
 	return fs
 }
 
 func (cmd *untrashCmd) Run(args []string) {
+	if *cmd.query != "" {
+		context, path := discoverContext(args)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Hidden:         *cmd.hidden,
+			Quiet:          *cmd.quiet,
+			Force:          *cmd.force,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+		}).UntrashByQuery(*cmd.query))
+		return
+	}
+
 	sources, context, path := preprocessArgsByToggle(args, *cmd.byId || *cmd.matches)
 
 	opts := drive.Options{
-		Path:    path,
-		Sources: sources,
-		Quiet:   *cmd.quiet,
+		Path:           path,
+		Sources:        sources,
+		Quiet:          *cmd.quiet,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
 	}
 
 	if !*cmd.matches {
-		exitWithError(drive.New(context, &opts).Untrash(*cmd.byId))
+		exitWithError(newCommands(context, &opts).Untrash(*cmd.byId))
 	} else {
-		exitWithError(drive.New(context, &opts).UntrashByMatch())
+		exitWithError(newCommands(context, &opts).UntrashByMatch())
 	}
 }
 
@@ -1067,16 +1683,24 @@ func (cmd *publishCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.hidden = fs.Bool(drive.HiddenKey, false, "allows publishing of hidden paths")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "publish by id instead of path")
+	cmd.role = fs.String(drive.RoleKey, "reader", drive.DescPubRole)
+	cmd.withLink = fs.Bool(drive.CLIOptionWithLink, false, drive.DescPubWithLink)
+	cmd.expires = fs.String(drive.CLIOptionPubExpires, "", drive.DescPubExpires)
 	return fs
 }
 
 func (cmd *publishCmd) Run(args []string) {
 	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
-	exitWithError(drive.New(context, &drive.Options{
+	exitWithError(newCommands(context, &drive.Options{
 		Path:    path,
 		Sources: sources,
 		Quiet:   *cmd.quiet,
-	}).Publish(*cmd.byId))
+	}).Publish(&drive.PublishArgs{
+		ById:     *cmd.byId,
+		Role:     *cmd.role,
+		WithLink: *cmd.withLink,
+		Expires:  *cmd.expires,
+	}))
 }
 
 type unshareCmd struct {
@@ -1084,6 +1708,11 @@ type unshareCmd struct {
 	accountType *string
 	quiet       *bool
 	byId        *bool
+	role        *string
+	emails      *string
+	withLink    *bool
+	recursive   *bool
+	query       *string
 }
 
 func (cmd *unshareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1091,6 +1720,11 @@ func (cmd *unshareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "disables the prompt")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "unshare by id instead of path")
+	cmd.role = fs.String(drive.RoleKey, "", "role to revoke. Possible values: "+drive.DescRoles)
+	cmd.emails = fs.String(drive.EmailsKey, "", "emails or domains to revoke access from")
+	cmd.withLink = fs.Bool(drive.CLIOptionWithLink, false, drive.DescWithLink)
+	cmd.recursive = fs.Bool("r", false, drive.DescShareRecursive)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQuery)
 	return fs
 }
 
@@ -1098,79 +1732,687 @@ func (cmd *unshareCmd) Run(args []string) {
 	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
 
 	meta := map[string][]string{
-		"accountType": uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.accountType, ",")...)),
+		"accountType":   uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.accountType, ",")...)),
+		drive.RoleKey:   uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.role, ",")...)),
+		drive.EmailsKey: uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.emails, ",")...)),
 	}
 
-	exitWithError(drive.New(context, &drive.Options{
-		Meta:     &meta,
-		Path:     path,
-		Sources:  sources,
-		NoPrompt: *cmd.noPrompt,
-		Quiet:    *cmd.quiet,
-	}).Unshare(*cmd.byId))
+	g := newCommands(context, &drive.Options{
+		Meta:      &meta,
+		Path:      path,
+		Sources:   sources,
+		NoPrompt:  *cmd.noPrompt,
+		Quiet:     *cmd.quiet,
+		Recursive: *cmd.recursive,
+		WithLink:  *cmd.withLink,
+	})
+	if *cmd.query != "" {
+		exitWithError(g.UnshareByQuery(*cmd.query))
+	} else {
+		exitWithError(g.Unshare(*cmd.byId))
+	}
 }
 
 type moveCmd struct {
-	quiet *bool
-	byId  *bool
+	quiet             *bool
+	byId              *bool
+	force             *bool
+	maxChildren       *int
+	layout            *string
+	olderThan         *string
+	newerThan         *string
+	watch             *bool
+	rule              *string
+	pollInterval      *time.Duration
+	breadcrumb        *bool
+	auditLogPath      *string
+	auditLogRotate    *bool
+	destRoot          *string
+	merge             *bool
+	planPath          *string
+	checkWritable     *bool
+	intoNewest        *bool
+	intoOldest        *bool
+	maxPathLength     *int
+	retryFailed       *int
+	pruneAfterMove    *bool
+	estimateCost      *bool
+	treeDiff          *bool
+	treeDiffDepth     *int
+	destId            *bool
+	dryRun            *bool
+	batchMoves        *bool
+	ignoreNameClashes *bool
 }
 
 func (cmd *moveCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "move by id instead of path")
+	cmd.maxChildren = fs.Int(drive.CLIOptionMaxChildren, drive.DefaultMaxChildren, drive.DescMaxChildren)
+	cmd.force = fs.Bool(drive.ForceKey, false, "coerces moving even if there is a clash at the destination")
+	cmd.layout = fs.String(drive.CLIOptionLayout, "", drive.DescLayout)
+	cmd.olderThan = fs.String(drive.CLIOptionOlderThan, "", drive.DescOlderThan)
+	cmd.newerThan = fs.String(drive.CLIOptionNewerThan, "", drive.DescNewerThan)
+	cmd.watch = fs.Bool(drive.CLIOptionWatch, false, drive.DescWatch)
+	cmd.rule = fs.String(drive.CLIOptionWatchRule, ".", drive.DescWatchRule)
+	cmd.pollInterval = fs.Duration(drive.CLIOptionPollInterval, drive.DefaultPollInterval, drive.DescPollInterval)
+	cmd.breadcrumb = fs.Bool(drive.CLIOptionBreadcrumb, false, drive.DescBreadcrumb)
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.destRoot = fs.String(drive.CLIOptionDestRoot, "", drive.DescDestRoot)
+	cmd.merge = fs.Bool(drive.CLIOptionMerge, false, drive.DescMerge)
+	cmd.planPath = fs.String(drive.CLIOptionPlan, "", drive.DescPlan)
+	cmd.checkWritable = fs.Bool(drive.CLIOptionCheckWritable, false, drive.DescCheckWritable)
+	cmd.intoNewest = fs.Bool(drive.CLIOptionIntoNewest, false, drive.DescIntoNewest)
+	cmd.intoOldest = fs.Bool(drive.CLIOptionIntoOldest, false, drive.DescIntoOldest)
+	cmd.maxPathLength = fs.Int(drive.CLIOptionMaxPathLength, 0, drive.DescMaxPathLength)
+	cmd.retryFailed = fs.Int(drive.CLIOptionRetryFailedAtEnd, 0, drive.DescRetryFailedAtEnd)
+	cmd.pruneAfterMove = fs.Bool(drive.CLIOptionPruneAfterMove, false, drive.DescPruneAfterMove)
+	cmd.estimateCost = fs.Bool(drive.CLIOptionEstimateCost, false, drive.DescEstimateCost)
+	cmd.treeDiff = fs.Bool(drive.CLIOptionTreeDiff, false, drive.DescTreeDiff)
+	cmd.treeDiffDepth = fs.Int(drive.CLIOptionTreeDiffDepth, drive.DefaultTreeDiffDepth, drive.DescTreeDiffDepth)
+	cmd.destId = fs.Bool(drive.CLIOptionDestId, false, drive.DescDestId)
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.batchMoves = fs.Bool(drive.CLIOptionBatchMoves, false, drive.DescBatchMoves)
+	cmd.ignoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
 	return fs
 }
 
 func (cmd *moveCmd) Run(args []string) {
+	if *cmd.layout != "" {
+		_, context, path := preprocessArgsByToggle(args, true)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			Breadcrumb:     *cmd.breadcrumb,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+			PlanPath:       *cmd.planPath,
+		}).SyncMove(*cmd.layout))
+		return
+	}
+
 	argc := len(args)
 	if argc < 1 {
 		exitWithError(fmt.Errorf("move: expecting a path or more"))
 	}
-	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+
+	if *cmd.watch {
+		if argc != 2 {
+			exitWithError(fmt.Errorf("move: watch expects <folder> <dest>"))
+		}
+		paths, context, path := preprocessArgs(args)
+		if len(paths) != 2 {
+			exitWithError(fmt.Errorf("move: cannot watch and move into the same folder"))
+		}
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			PollInterval:   *cmd.pollInterval,
+			Breadcrumb:     *cmd.breadcrumb,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+		}).WatchMove(paths[0], *cmd.rule, paths[1]))
+		return
+	}
+
+	// Under a dest root, relative paths are resolved remotely rather than against the cwd
+	underRoot := *cmd.destRoot != ""
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId || underRoot)
 
 	// Unshift by the end path
 	sources = sources[:len(sources)-1]
 
 	dest := args[argc-1]
+	if *cmd.destId && !strings.HasPrefix(dest, drive.DestIdPrefix) {
+		dest = drive.DestIdPrefix + dest
+	}
+	// Ids aren't paths to be resolved against the cwd
+	if !underRoot && !strings.HasPrefix(dest, drive.DestIdPrefix) {
+		destRels, err := relativePaths(context.AbsPathOf(""), dest)
+		exitWithError(err)
+		dest = destRels[0]
+	}
+
+	sources = append(sources, dest)
+
+	exitWithError(newCommands(context, &drive.Options{
+		Path:              path,
+		Sources:           sources,
+		Force:             *cmd.force,
+		Quiet:             *cmd.quiet,
+		MaxChildren:       *cmd.maxChildren,
+		OlderThan:         *cmd.olderThan,
+		NewerThan:         *cmd.newerThan,
+		Breadcrumb:        *cmd.breadcrumb,
+		AuditLogPath:      *cmd.auditLogPath,
+		AuditLogRotate:    *cmd.auditLogRotate,
+		DestRoot:          *cmd.destRoot,
+		Merge:             *cmd.merge,
+		PlanPath:          *cmd.planPath,
+		CheckWritable:     *cmd.checkWritable,
+		DestPick:          destPick(*cmd.intoNewest, *cmd.intoOldest),
+		MaxPathLength:     *cmd.maxPathLength,
+		RetryFailedAtEnd:  *cmd.retryFailed,
+		PruneAfterMove:    *cmd.pruneAfterMove,
+		EstimateCost:      *cmd.estimateCost,
+		TreeDiff:          *cmd.treeDiff,
+		TreeDiffDepth:     *cmd.treeDiffDepth,
+		DryRun:            *cmd.dryRun,
+		BatchMoves:        *cmd.batchMoves,
+		IgnoreNameClashes: *cmd.ignoreNameClashes,
+	}).Move(*cmd.byId))
+}
+
+type promoteCmd struct {
+	quiet          *bool
+	levels         *int
+	conflictPolicy *string
+	auditLogPath   *string
+	auditLogRotate *bool
+	planPath       *string
+}
+
+func (cmd *promoteCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.levels = fs.Int(drive.CLIOptionLevels, 1, "number of levels to move items up by")
+	cmd.conflictPolicy = fs.String(drive.CLIOptionConflictPolicy, drive.ConflictSkip, drive.DescConflictPolicy)
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.planPath = fs.String(drive.CLIOptionPlan, "", drive.DescPlan)
+	return fs
+}
+
+func (cmd *promoteCmd) Run(args []string) {
+	if len(args) < 1 {
+		exitWithError(fmt.Errorf("promote: expecting a folder or more"))
+	}
+
+	folders, context, path := preprocessArgs(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:           path,
+		Sources:        folders,
+		Quiet:          *cmd.quiet,
+		ConflictPolicy: *cmd.conflictPolicy,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
+		PlanPath:       *cmd.planPath,
+	}).Promote(*cmd.levels))
+}
+
+type reapCmd struct {
+	force          *bool
+	quiet          *bool
+	auditLogPath   *string
+	auditLogRotate *bool
+}
+
+func (cmd *reapCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.force = fs.Bool(drive.ForceKey, false, "trash the sources that are due without prompting")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	return fs
+}
+
+func (cmd *reapCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:           path,
+		Force:          *cmd.force,
+		Quiet:          *cmd.quiet,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
+	}).Reap())
+}
+
+type revisionsCmd struct {
+	byId      *bool
+	pin       *string
+	unpin     *string
+	del       *string
+	prune     *bool
+	keep      *int
+	olderThan *string
+	force     *bool
+	quiet     *bool
+	noPrompt  *bool
+}
+
+func (cmd *revisionsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve the files by id instead of path")
+	cmd.pin = fs.String(drive.CLIOptionRevisionsPin, "", drive.DescRevisionsPin)
+	cmd.unpin = fs.String(drive.CLIOptionRevisionsUnpin, "", drive.DescRevisionsUnpin)
+	cmd.del = fs.String(drive.CLIOptionRevisionsDelete, "", drive.DescRevisionsDelete)
+	cmd.prune = fs.Bool(drive.CLIOptionRevisionsPrune, false, drive.DescRevisionsPrune)
+	cmd.keep = fs.Int(drive.CLIOptionRevisionsKeep, 1, drive.DescRevisionsKeep)
+	cmd.olderThan = fs.String(drive.CLIOptionOlderThan, "", drive.DescOlderThan)
+	cmd.force = fs.Bool(drive.ForceKey, false, "delete revisions without prompting")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "shows no prompt before deleting revisions")
+	return fs
+}
+
+func (cmd *revisionsCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+
+	g := newCommands(context, &drive.Options{
+		Path:     path,
+		Sources:  sources,
+		Force:    *cmd.force,
+		Quiet:    *cmd.quiet,
+		NoPrompt: *cmd.noPrompt,
+	})
+
+	pins := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.pin, ",")...)
+	unpins := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.unpin, ",")...)
+	deletes := drive.NonEmptyTrimmedStrings(strings.Split(*cmd.del, ",")...)
+
+	switch {
+	case len(pins) >= 1 || len(unpins) >= 1:
+		if len(pins) >= 1 {
+			exitWithError(g.PinRevisions(pins, true, *cmd.byId))
+		}
+		if len(unpins) >= 1 {
+			exitWithError(g.PinRevisions(unpins, false, *cmd.byId))
+		}
+	case len(deletes) >= 1 || *cmd.prune:
+		exitWithError(g.PruneRevisions(deletes, *cmd.keep, *cmd.olderThan, *cmd.byId))
+	default:
+		exitWithError(g.Revisions(*cmd.byId))
+	}
+}
+
+type shortcutCmd struct {
+	byId  *bool
+	quiet *bool
+}
+
+func (cmd *shortcutCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve the targets by id instead of path")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *shortcutCmd) Run(args []string) {
+	if len(args) < 2 {
+		exitWithError(fmt.Errorf("shortcut: expected <target> [target...] <dest>"))
+	}
+
+	end := len(args) - 1
+	dest := args[end]
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+
+	// The dest is always a path, even when the targets are ids
 	destRels, err := relativePaths(context.AbsPathOf(""), dest)
 	exitWithError(err)
+	sources = append(sources[:len(sources)-1], destRels[0])
 
-	sources = append(sources, destRels[0])
+	exitWithError(newCommands(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Shortcut(*cmd.byId))
+}
 
-	exitWithError(drive.New(context, &drive.Options{
+type chownCmd struct {
+	byId      *bool
+	recursive *bool
+	force     *bool
+	noPrompt  *bool
+	quiet     *bool
+}
+
+func (cmd *chownCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve the items by id instead of path")
+	cmd.recursive = fs.Bool("r", false, "also transfer the ownership of the descendants of folders")
+	cmd.force = fs.Bool(drive.ForceKey, false, "transfer without prompting")
+	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "shows no prompt before transferring")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *chownCmd) Run(args []string) {
+	if len(args) < 2 {
+		exitWithError(fmt.Errorf("chown: expected <new-owner-email> <path...>"))
+	}
+
+	email := args[0]
+	sources, context, path := preprocessArgsByToggle(args[1:], *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:      path,
+		Sources:   sources,
+		Recursive: *cmd.recursive,
+		Force:     *cmd.force,
+		NoPrompt:  *cmd.noPrompt,
+		Quiet:     *cmd.quiet,
+	}).Chown(email, *cmd.byId))
+}
+
+type propCmd struct {
+	byId    *bool
+	private *bool
+	quiet   *bool
+}
+
+func (cmd *propCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve the item by id instead of path")
+	cmd.private = fs.Bool(drive.CLIOptionPrivate, false, drive.DescPropPrivate)
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *propCmd) Run(args []string) {
+	if len(args) < 2 {
+		exitWithError(fmt.Errorf("prop: expected <set|get|del> <path> [key[=value]...]"))
+	}
+
+	action := args[0]
+	sources, context, path := preprocessArgsByToggle(args[1:2], *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
 		Path:    path,
 		Sources: sources,
 		Quiet:   *cmd.quiet,
-	}).Move(*cmd.byId))
+	}).Prop(action, args[2:], *cmd.private, *cmd.byId))
 }
 
-type renameCmd struct {
+type clashesCmd struct {
+	fix       *bool
+	strategy  *string
+	recursive *bool
+	force     *bool
+	quiet     *bool
+}
+
+func (cmd *clashesCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.fix = fs.Bool(drive.CLIOptionClashesFix, false, drive.DescClashesFix)
+	cmd.strategy = fs.String(drive.CLIOptionClashesStrategy, drive.ClashFixRename, drive.DescClashesStrategy)
+	cmd.recursive = fs.Bool("r", true, "also look for clashes in the descendants of folders")
+	cmd.force = fs.Bool(drive.ForceKey, false, "fix the clashes without prompting")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *clashesCmd) Run(args []string) {
+	sources, context, path := preprocessArgs(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:      path,
+		Sources:   sources,
+		Recursive: *cmd.recursive,
+		Force:     *cmd.force,
+		Quiet:     *cmd.quiet,
+	}).Clashes(*cmd.fix, *cmd.strategy))
+}
+
+type searchCmd struct {
+	sortBy *string
+	max    *int
+	hidden *bool
+	quiet  *bool
+}
+
+func (cmd *searchCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.sortBy = fs.String(drive.SortKey, drive.SearchByRelevance, drive.DescSearchSort)
+	cmd.max = fs.Int(drive.CLIOptionSearchMax, 100, drive.DescSearchMax)
+	cmd.hidden = fs.Bool(drive.HiddenKey, false, "also search hidden items")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *searchCmd) Run(args []string) {
+	// The terms aren't paths, the context is that of the current directory
+	context, path := discoverContext(nil)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:   path,
+		Hidden: *cmd.hidden,
+		Quiet:  *cmd.quiet,
+	}).Search(args, *cmd.sortBy, *cmd.max))
+}
+
+type duCmd struct {
+	byId             *bool
+	depth            *int
+	format           *string
+	quiet            *bool
+	nativeSizePolicy *string
+}
+
+func (cmd *duCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "report on the items by their ids")
+	cmd.depth = fs.Int(drive.CLIOptionDuDepth, 1, drive.DescDuDepth)
+	cmd.format = fs.String(drive.CLIOptionDuFormat, "", drive.DescDuFormat)
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.nativeSizePolicy = fs.String(drive.CLIOptionNativeSizePolicy, drive.NativeSizeExclude, drive.DescNativeSizePolicy)
+	return fs
+}
+
+func (cmd *duCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:             path,
+		Sources:          sources,
+		NativeSizePolicy: *cmd.nativeSizePolicy,
+		// Progress logs would end up among the rows of the report
+		Quiet: *cmd.quiet || *cmd.format != "",
+	}).Du(*cmd.byId, *cmd.depth, *cmd.format))
+}
+
+type commentsCmd struct {
+	byId     *bool
+	openOnly *bool
+	export   *string
+	quiet    *bool
+}
+
+func (cmd *commentsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.byId = fs.Bool(drive.CLIOptionId, false, "resolve the files by id instead of path")
+	cmd.openOnly = fs.Bool(drive.CLIOptionCommentsOpen, false, drive.DescCommentsOpen)
+	cmd.export = fs.String(drive.CLIOptionCommentsExport, "", drive.DescCommentsExport)
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *commentsCmd) Run(args []string) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Comments(*cmd.byId, *cmd.openOnly, *cmd.export))
+}
+
+type mountCmd struct {
+	quiet *bool
+}
+
+func (cmd *mountCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *mountCmd) Run(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		exitWithError(fmt.Errorf("mount: expecting <mountpoint> [remote folder]"))
+	}
+	mountPoint, err := filepath.Abs(args[0])
+	exitWithError(err)
+
+	sources, context, path := preprocessArgs(args[1:])
+	exitWithError(newCommands(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.quiet,
+	}).Mount(mountPoint))
+}
+
+type drivesCmd struct{}
+
+func (cmd *drivesCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	return fs
+}
+
+func (cmd *drivesCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path: path,
+	}).Drives())
+}
+
+type undoCmd struct {
 	force *bool
 	quiet *bool
-	byId  *bool
+}
+
+func (cmd *undoCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.force = fs.Bool(drive.ForceKey, false, "undo without prompting")
+	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *undoCmd) Run(args []string) {
+	context, path := discoverContext(args)
+	exitWithError(newCommands(context, &drive.Options{
+		Path:  path,
+		Force: *cmd.force,
+		Quiet: *cmd.quiet,
+	}).Undo())
+}
+
+type renameCmd struct {
+	force          *bool
+	quiet          *bool
+	byId           *bool
+	nameMap        *string
+	auditLogPath   *string
+	auditLogRotate *bool
+	destRoot       *string
+	planPath       *string
+	caseFold       *bool
+	recursive      *bool
+	trash          *bool
+	reformatDate   *string
+	dateTo         *string
+	maxPathLength  *int
+	dryRun         *bool
+	regex          *string
 }
 
 func (cmd *renameCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.force = fs.Bool(drive.ForceKey, false, "coerce rename even if remote already exists")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "unshare by id instead of path")
+	cmd.nameMap = fs.String(drive.CLIOptionNameMap, "", drive.DescNameMap)
+	cmd.auditLogPath = fs.String(drive.CLIOptionAuditLog, "", drive.DescAuditLog)
+	cmd.auditLogRotate = fs.Bool(drive.CLIOptionAuditLogRotate, false, drive.DescAuditLogRotate)
+	cmd.destRoot = fs.String(drive.CLIOptionDestRoot, "", drive.DescDestRoot)
+	cmd.planPath = fs.String(drive.CLIOptionPlan, "", drive.DescPlan)
+	cmd.caseFold = fs.Bool(drive.CLIOptionCaseFold, false, drive.DescCaseFold)
+	cmd.recursive = fs.Bool("r", false, "with case-fold or reformat-date, also go through all descendant folders")
+	cmd.trash = fs.Bool(drive.CLIOptionCaseFoldTrash, false, drive.DescCaseFoldTrash)
+	cmd.reformatDate = fs.String(drive.CLIOptionReformatDate, "", drive.DescReformatDate)
+	cmd.dateTo = fs.String(drive.CLIOptionReformatDateTo, "", drive.DescReformatDateTo)
+	cmd.maxPathLength = fs.Int(drive.CLIOptionMaxPathLength, 0, drive.DescMaxPathLength)
+	cmd.dryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.regex = fs.String(drive.CLIOptionRenameRegex, "", drive.DescRenameRegex)
 	return fs
 }
 
 func (cmd *renameCmd) Run(args []string) {
+	if *cmd.caseFold {
+		folders, context, path := preprocessArgs(args)
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Sources:        folders,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			Recursive:      *cmd.recursive,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+			PlanPath:       *cmd.planPath,
+			MaxPathLength:  *cmd.maxPathLength,
+			DryRun:         *cmd.dryRun,
+		}).CaseFold(*cmd.trash))
+		return
+	}
+
+	if *cmd.reformatDate != "" {
+		folders, context, path := preprocessArgsByToggle(args, *cmd.destRoot != "")
+		if len(folders) < 1 {
+			folders = []string{"."}
+		}
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Sources:        folders,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			Recursive:      *cmd.recursive,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+			DestRoot:       *cmd.destRoot,
+			PlanPath:       *cmd.planPath,
+			MaxPathLength:  *cmd.maxPathLength,
+			DryRun:         *cmd.dryRun,
+		}).ReformatDates(*cmd.reformatDate, *cmd.dateTo))
+		return
+	}
+
+	if *cmd.regex != "" {
+		sources, context, path := preprocessArgsByToggle(args, *cmd.destRoot != "")
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Sources:        sources,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+			DestRoot:       *cmd.destRoot,
+			PlanPath:       *cmd.planPath,
+			MaxPathLength:  *cmd.maxPathLength,
+			DryRun:         *cmd.dryRun,
+		}).RenameByRegex(*cmd.regex))
+		return
+	}
+
+	if *cmd.nameMap != "" {
+		folders, context, path := preprocessArgsByToggle(args, *cmd.destRoot != "")
+		if len(folders) < 1 {
+			folders = []string{"."}
+		}
+		exitWithError(newCommands(context, &drive.Options{
+			Path:           path,
+			Force:          *cmd.force,
+			Quiet:          *cmd.quiet,
+			AuditLogPath:   *cmd.auditLogPath,
+			AuditLogRotate: *cmd.auditLogRotate,
+			DestRoot:       *cmd.destRoot,
+			PlanPath:       *cmd.planPath,
+			MaxPathLength:  *cmd.maxPathLength,
+			DryRun:         *cmd.dryRun,
+		}).RenameByMap(*cmd.nameMap, folders[0], *cmd.byId))
+		return
+	}
+
 	argc := len(args)
 	if argc < 2 {
 		exitWithError(fmt.Errorf("rename: expecting <src> <dest>"))
 	}
 	rest, last := args[:argc-1], args[argc-1]
-	sources, context, path := preprocessArgsByToggle(rest, *cmd.byId)
+	sources, context, path := preprocessArgsByToggle(rest, *cmd.byId || *cmd.destRoot != "")
 
 	sources = append(sources, last)
-	exitWithError(drive.New(context, &drive.Options{
-		Path:    path,
-		Sources: sources,
-		Force:   *cmd.force,
-		Quiet:   *cmd.quiet,
+	exitWithError(newCommands(context, &drive.Options{
+		Path:           path,
+		Sources:        sources,
+		Force:          *cmd.force,
+		Quiet:          *cmd.quiet,
+		AuditLogPath:   *cmd.auditLogPath,
+		AuditLogRotate: *cmd.auditLogRotate,
+		DestRoot:       *cmd.destRoot,
+		PlanPath:       *cmd.planPath,
+		MaxPathLength:  *cmd.maxPathLength,
+		DryRun:         *cmd.dryRun,
 	}).Rename(*cmd.byId))
 }
 
@@ -1183,6 +2425,11 @@ type shareCmd struct {
 	noPrompt    *bool
 	notify      *bool
 	quiet       *bool
+	list        *bool
+	recursive   *bool
+	format      *string
+	withLink    *bool
+	query       *string
 }
 
 func (cmd *shareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1194,12 +2441,27 @@ func (cmd *shareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "disables the prompt")
 	cmd.quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.byId = fs.Bool(drive.CLIOptionId, false, "share by id instead of path")
+	cmd.list = fs.Bool(drive.CLIOptionShareList, false, drive.DescShareList)
+	cmd.recursive = fs.Bool("r", false, drive.DescShareRecursive)
+	cmd.format = fs.String(drive.CLIOptionShareFormat, "", drive.DescShareFormat)
+	cmd.withLink = fs.Bool(drive.CLIOptionWithLink, false, drive.DescWithLink)
+	cmd.query = fs.String(drive.CLIOptionQuery, "", drive.DescQuery)
 	return fs
 }
 
 func (cmd *shareCmd) Run(args []string) {
 	sources, context, path := preprocessArgsByToggle(args, *cmd.byId)
 
+	if *cmd.list {
+		exitWithError(newCommands(context, &drive.Options{
+			Path:      path,
+			Sources:   sources,
+			Recursive: *cmd.recursive,
+			Quiet:     *cmd.quiet,
+		}).ListPermissions(*cmd.byId, *cmd.format))
+		return
+	}
+
 	meta := map[string][]string{
 		drive.EmailMessageKey: []string{*cmd.message},
 		drive.EmailsKey:       uniqOrderedStr(drive.NonEmptyTrimmedStrings(strings.Split(*cmd.emails, ",")...)),
@@ -1212,14 +2474,21 @@ func (cmd *shareCmd) Run(args []string) {
 		mask = drive.Notify
 	}
 
-	exitWithError(drive.New(context, &drive.Options{
-		Meta:     &meta,
-		Path:     path,
-		Sources:  sources,
-		TypeMask: mask,
-		NoPrompt: *cmd.noPrompt,
-		Quiet:    *cmd.quiet,
-	}).Share(*cmd.byId))
+	g := newCommands(context, &drive.Options{
+		Meta:      &meta,
+		Path:      path,
+		Sources:   sources,
+		TypeMask:  mask,
+		NoPrompt:  *cmd.noPrompt,
+		Quiet:     *cmd.quiet,
+		Recursive: *cmd.recursive,
+		WithLink:  *cmd.withLink,
+	})
+	if *cmd.query != "" {
+		exitWithError(g.ShareByQuery(*cmd.query))
+	} else {
+		exitWithError(g.Share(*cmd.byId))
+	}
 }
 
 func initContext(args []string) *config.Context {
@@ -1227,7 +2496,7 @@ func initContext(args []string) *config.Context {
 	var gdPath string
 	var firstInit bool
 
-	gdPath, firstInit, context, err = config.Initialize(getContextPath(args))
+	gdPath, firstInit, context, err = config.Initialize(getContextPath(args), selectedProfile())
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill)
@@ -1245,9 +2514,22 @@ func initContext(args []string) *config.Context {
 	return context
 }
 
+// destPick resolves the -into-newest and -into-oldest flags, which are exclusive.
+func destPick(intoNewest, intoOldest bool) string {
+	switch {
+	case intoNewest && intoOldest:
+		exitWithError(fmt.Errorf("only one of -%s and -%s can be set", drive.CLIOptionIntoNewest, drive.CLIOptionIntoOldest))
+	case intoNewest:
+		return drive.DestPickNewest
+	case intoOldest:
+		return drive.DestPickOldest
+	}
+	return ""
+}
+
 func discoverContext(args []string) (*config.Context, string) {
 	var err error
-	context, err = config.Discover(getContextPath(args))
+	context, err = config.Discover(getContextPath(args), selectedProfile())
 	exitWithError(err)
 	relPath := ""
 	if len(args) > 0 {
@@ -1289,10 +2571,18 @@ func uniqOrderedStr(sources []string) []string {
 }
 
 func exitWithError(err error) {
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+	if err == nil {
+		return
+	}
+	if *jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(map[string]string{
+			"result": drive.AuditResultError,
+			"error":  err.Error(),
+		})
+	}
+	fmt.Fprintln(os.Stderr, err)
+	closeCommands()
+	os.Exit(1)
 }
 
 func relativePaths(root string, args ...string) ([]string, error) {
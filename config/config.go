@@ -52,7 +52,13 @@ type Context struct {
 	ClientId     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	RefreshToken string `json:"refresh_token"`
-	AbsPath      string `json:"-"`
+	// ServiceAccountKey is the JSON key of the service account to authenticate
+	// as instead of a user, ServiceAccountSubject the user it impersonates if any.
+	ServiceAccountKey     json.RawMessage `json:"service_account_key,omitempty"`
+	ServiceAccountSubject string          `json:"service_account_subject,omitempty"`
+	AbsPath               string          `json:"-"`
+	// Profile names the credentials in use, those of the default profile if unset.
+	Profile string `json:"-"`
 }
 
 type Index struct {
@@ -63,6 +69,9 @@ type Index struct {
 	ModTime     int64  `json:"mtime"`
 	Version     int64  `json:"version"`
 	IndexTime   int64  `json:"itime"`
+	// Blocks is the block map of the content of large files as last
+	// synced, the checksums of its content-defined blocks in order.
+	Blocks []string `json:"blocks,omitempty"`
 }
 
 type MountPoint struct {
@@ -100,7 +109,10 @@ func (c *Context) AbsPathOf(fileOrDirPath string) string {
 
 func (c *Context) Read() (err error) {
 	var data []byte
-	if data, err = ioutil.ReadFile(credentialsPath(c.AbsPath)); err != nil {
+	if data, err = ioutil.ReadFile(credentialsPath(c.AbsPath, c.Profile)); err != nil {
+		if os.IsNotExist(err) && c.Profile != "" {
+			err = fmt.Errorf("profile %q isn't initialized in %s", c.Profile, c.AbsPath)
+		}
 		return
 	}
 	return json.Unmarshal(data, c)
@@ -281,14 +293,17 @@ func (c *Context) Write() (err error) {
 	if data, err = json.Marshal(c); err != nil {
 		return
 	}
-	return ioutil.WriteFile(credentialsPath(c.AbsPath), data, 0600)
+	return ioutil.WriteFile(credentialsPath(c.AbsPath, c.Profile), data, 0600)
 }
 
 func (c *Context) DeInitialize(prompter func(...interface{}) bool, returnOnAnyError bool) (err error) {
 	rootDir := c.AbsPathOf("")
 	pathsToRemove := []string{
-		credentialsPath(rootDir),
-		DbSuffixedPath(rootDir),
+		credentialsPath(rootDir, c.Profile),
+	}
+	// The rest is shared by the profiles, only the default one takes it along
+	if c.Profile == "" {
+		pathsToRemove = append(pathsToRemove, DbSuffixedPath(rootDir))
 	}
 
 	for _, p := range pathsToRemove {
@@ -325,7 +340,11 @@ func (c *Context) OpenDB() (db *bolt.DB, err error) {
 
 // Discovers the gd directory, if no gd directory or credentials
 // could be found for the path, returns ErrNoContext.
-func Discover(currentAbsPath string) (context *Context, err error) {
+func Discover(currentAbsPath, profile string) (context *Context, err error) {
+	if err = checkProfile(profile); err != nil {
+		return
+	}
+
 	p := currentAbsPath
 	found := false
 	for {
@@ -344,14 +363,18 @@ func Discover(currentAbsPath string) (context *Context, err error) {
 	if !found {
 		return nil, ErrNoDriveContext
 	}
-	context = &Context{AbsPath: p}
+	context = &Context{AbsPath: p, Profile: profile}
 	if err = context.Read(); err != nil {
 		return nil, err
 	}
 	return
 }
 
-func Initialize(absPath string) (pathGD string, firstInit bool, c *Context, err error) {
+func Initialize(absPath, profile string) (pathGD string, firstInit bool, c *Context, err error) {
+	if err = checkProfile(profile); err != nil {
+		return
+	}
+
 	pathGD = gdPath(absPath)
 	sInfo, sErr := os.Stat(pathGD)
 	if sErr != nil {
@@ -369,7 +392,7 @@ func Initialize(absPath string) (pathGD string, firstInit bool, c *Context, err
 	if err = os.MkdirAll(pathGD, 0755); err != nil {
 		return
 	}
-	c = &Context{AbsPath: absPath}
+	c = &Context{AbsPath: absPath, Profile: profile}
 	err = c.Write()
 	return
 }
@@ -378,8 +401,20 @@ func gdPath(absPath string) string {
 	return path.Join(absPath, GDDirSuffix)
 }
 
-func credentialsPath(absPath string) string {
-	return path.Join(gdPath(absPath), "credentials.json")
+// credentialsPath is the path of the credentials of profile, which
+// are kept side by side e.g credentials.json and credentials.work.json
+func credentialsPath(absPath, profile string) string {
+	if profile == "" {
+		return path.Join(gdPath(absPath), "credentials.json")
+	}
+	return path.Join(gdPath(absPath), fmt.Sprintf("credentials.%s.json", profile))
+}
+
+func checkProfile(profile string) error {
+	if strings.ContainsAny(profile, "/\\") || strings.HasPrefix(profile, ".") {
+		return fmt.Errorf("%q is not a valid profile name", profile)
+	}
+	return nil
 }
 
 func DbSuffixedPath(dir string) string {
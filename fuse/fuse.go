@@ -0,0 +1,106 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuse serves a filesystem over the Linux FUSE kernel protocol.
+//
+// It speaks just enough of the protocol for a filesystem of folders and
+// regular files to be browsed, read and written: there are no links,
+// permissions, extended attributes or locks. A filesystem is made of Nodes,
+// each either a Dir or a File, and the Handles of the Files opened. Nodes
+// are told apart by identity, so the same item should be the same Node for
+// as long as it is in use. Errors of type syscall.Errno are passed on as
+// they are, the others become ENOENT, EEXIST or EACCES where os says they
+// are about that and EIO otherwise.
+//
+// The protocol is spoken here rather than through a FUSE binding as none is
+// vendored with drive's dependencies, and what drive mount needs of it is
+// small enough to keep in the tree.
+package fuse
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrUnsupported is returned when mounting on systems other than Linux.
+var ErrUnsupported = errors.New("fuse: mounting is only supported on Linux")
+
+// Attr are the attributes of a Node.
+type Attr struct {
+	Size uint64
+	// Mode is the type and permissions of the node, os.ModeDir for folders
+	Mode  os.FileMode
+	Mtime time.Time
+	Ctime time.Time
+}
+
+// Dirent is an entry of a folder's listing.
+type Dirent struct {
+	Name  string
+	IsDir bool
+}
+
+type Node interface {
+	Attr() (Attr, error)
+}
+
+type Dir interface {
+	Node
+	Lookup(name string) (Node, error)
+	ReadDir() ([]Dirent, error)
+	Mkdir(name string) (Node, error)
+	// Create makes a new empty file called name and opens it for writing.
+	Create(name string) (Node, Handle, error)
+	// Remove removes the file or, if dir is set, the empty folder called name.
+	Remove(name string, dir bool) error
+	// Rename moves the item called oldName to newDir as newName,
+	// replacing any item that is there already.
+	Rename(oldName string, newDir Dir, newName string) error
+}
+
+type File interface {
+	Node
+	Open(write bool) (Handle, error)
+	// Truncate truncates the file by its name rather than a Handle.
+	Truncate(size uint64) error
+}
+
+// Handle is an opened File. Flush is called each time a descriptor of it is
+// closed and Release once the last of them is, which can be a while after.
+type Handle interface {
+	ReadAt(p []byte, off int64) (int, error)
+	WriteAt(p []byte, off int64) (int, error)
+	Truncate(size uint64) error
+	Flush() error
+	Release() error
+}
+
+func errno(err error) syscall.Errno {
+	en, isErrno := err.(syscall.Errno)
+	switch {
+	case err == nil:
+		return 0
+	case isErrno:
+		return en
+	case os.IsNotExist(err):
+		return syscall.ENOENT
+	case os.IsExist(err):
+		return syscall.EEXIST
+	case os.IsPermission(err):
+		return syscall.EACCES
+	}
+	return syscall.EIO
+}
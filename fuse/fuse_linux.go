@@ -0,0 +1,764 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Opcodes of the requests that are served, see linux/fuse.h
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opSetattr     = 4
+	opMkdir       = 9
+	opUnlink      = 10
+	opRmdir       = 11
+	opRename      = 12
+	opOpen        = 14
+	opRead        = 15
+	opWrite       = 16
+	opStatfs      = 17
+	opRelease     = 18
+	opFsync       = 20
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opFsyncdir    = 30
+	opAccess      = 34
+	opCreate      = 35
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+	opPoll        = 40
+)
+
+const (
+	protoMajor = 7
+	protoMinor = 31
+
+	// maxWrite is the most that is written in one request
+	maxWrite   = 128 * 1024
+	bufferSize = maxWrite + 4096
+
+	initBigWrites = 1 << 5
+	setattrSize   = 1 << 3
+	setattrFh     = 1 << 6
+
+	inHeaderSize  = 40
+	outHeaderSize = 16
+	attrSize      = 88
+
+	rootId = 1
+
+	// attrTTL is how long the kernel caches attributes and names
+	attrTTL = time.Second
+)
+
+// order is the byte order of the host, which the kernel speaks in.
+var order = hostOrder()
+
+func hostOrder() binary.ByteOrder {
+	probe := uint16(1)
+	if *(*byte)(unsafe.Pointer(&probe)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// Conn is a mounted filesystem's connection to the kernel.
+type Conn struct {
+	dev        *os.File
+	mountpoint string
+	uid, gid   uint32
+
+	mu sync.Mutex
+	// nodes are the nodes that the kernel knows about by id
+	nodes  map[uint64]*nodeRef
+	ids    map[Node]uint64
+	nextId uint64
+	// handles are the open files and the listings of the open folders
+	handles    map[uint64]interface{}
+	nextHandle uint64
+	// served is sent the outcome of serving once unmounted
+	served chan error
+}
+
+type nodeRef struct {
+	node    Node
+	lookups uint64
+}
+
+// Mount mounts the filesystem with root at mountpoint as fsName and serves
+// it until it is unmounted. Requests are served concurrently, so the nodes
+// have to be safe for that. Mounting takes privileges that only root has
+// unless it is done by fusermount.
+//
+// The process serving the mount shouldn't open files in it with os.Open and
+// the like if GOMAXPROCS is 1: the Go runtime registers the files it opens
+// for polling, which has the kernel ask the server whether they can be
+// polled while the only thread that could answer waits on it.
+func Mount(mountpoint, fsName string, root Dir) (*Conn, error) {
+	fi, err := os.Stat(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("fuse: mountpoint %s is not a folder", mountpoint)
+	}
+
+	c := &Conn{
+		mountpoint: mountpoint,
+		uid:        uint32(os.Getuid()),
+		gid:        uint32(os.Getgid()),
+		nodes:      make(map[uint64]*nodeRef),
+		ids:        make(map[Node]uint64),
+		nextId:     rootId + 1,
+		handles:    make(map[uint64]interface{}),
+		served:     make(chan error, 1),
+	}
+	c.nodes[rootId] = &nodeRef{node: root, lookups: 1}
+	c.ids[root] = rootId
+
+	// The device is read from in blocking mode, the Go runtime's polling of it
+	// errs until it is mounted and leaves reads failing or waiting forever
+	fd, err := syscall.Open("/dev/fuse", syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: "/dev/fuse", Err: err}
+	}
+	dev := os.NewFile(uintptr(fd), "/dev/fuse")
+	opts := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d", dev.Fd(), c.uid, c.gid)
+	err = syscall.Mount(fsName, mountpoint, "fuse."+fsName, syscall.MS_NOSUID|syscall.MS_NODEV, opts)
+	if err == syscall.EPERM {
+		dev.Close()
+		dev, err = fusermount(mountpoint, fsName)
+	}
+	if err != nil {
+		if dev != nil {
+			dev.Close()
+		}
+		return nil, fmt.Errorf("fuse: mounting %s: %v", mountpoint, err)
+	}
+	c.dev = dev
+
+	go func() {
+		c.served <- c.serve()
+	}()
+	return c, nil
+}
+
+// fusermount mounts with the setuid fusermount helper, which passes
+// back the opened device over a socket.
+func fusermount(mountpoint, fsName string) (*os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	ours := os.NewFile(uintptr(fds[0]), "fusermount-ours")
+	theirs := os.NewFile(uintptr(fds[1]), "fusermount-theirs")
+	defer ours.Close()
+	defer theirs.Close()
+
+	bin, err := exec.LookPath("fusermount3")
+	if err != nil {
+		if bin, err = exec.LookPath("fusermount"); err != nil {
+			return nil, fmt.Errorf("fusermount is needed to mount without root: %v", err)
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin, "-o", "nosuid,nodev,fsname="+fsName+",subtype="+fsName, "--", mountpoint)
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.ExtraFiles = []*os.File{theirs}
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v %s", bin, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	buf := make([]byte, 4)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(int(ours.Fd()), buf, oob, 0)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) < 1 {
+		return nil, fmt.Errorf("%s passed back no device: %v", bin, err)
+	}
+	rights, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(rights) < 1 {
+		return nil, fmt.Errorf("%s passed back no device: %v", bin, err)
+	}
+	return os.NewFile(uintptr(rights[0]), "/dev/fuse"), nil
+}
+
+// Unmount unmounts the filesystem at mountpoint, which ends its Serve.
+func Unmount(mountpoint string) error {
+	err := syscall.Unmount(mountpoint, 0)
+	if err != syscall.EPERM {
+		return err
+	}
+	for _, bin := range []string{"fusermount3", "fusermount"} {
+		if path, lookErr := exec.LookPath(bin); lookErr == nil {
+			out, runErr := exec.Command(path, "-u", mountpoint).CombinedOutput()
+			if runErr != nil {
+				return fmt.Errorf("%s: %v %s", bin, runErr, bytes.TrimSpace(out))
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.dev.Close()
+}
+
+// Wait waits for the filesystem to be unmounted, returning
+// what went wrong serving it if anything did.
+func (c *Conn) Wait() error {
+	err := <-c.served
+	c.served <- err
+	return err
+}
+
+func (c *Conn) serve() error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := c.dev.Read(buf)
+		if err != nil {
+			if pe, ok := err.(*os.PathError); ok {
+				err = pe.Err
+			}
+			switch err {
+			case syscall.EINTR, syscall.EAGAIN, syscall.ENOENT:
+				// The request was interrupted before it was read
+				continue
+			case syscall.ENODEV, io.EOF:
+				return nil
+			}
+			return err
+		}
+		if n < inHeaderSize {
+			return fmt.Errorf("fuse: short request of %d bytes", n)
+		}
+
+		req := &request{
+			opcode: order.Uint32(buf[4:]),
+			unique: order.Uint64(buf[8:]),
+			nodeId: order.Uint64(buf[16:]),
+			body:   append([]byte(nil), buf[inHeaderSize:n]...),
+		}
+		switch req.opcode {
+		case opInit:
+			c.init(req)
+		case opDestroy:
+			c.reply(req, 0)
+			return nil
+		case opForget, opBatchForget, opInterrupt:
+			// These aren't replied to
+			c.forget(req)
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.respond(req)
+			}()
+		}
+	}
+}
+
+type request struct {
+	opcode uint32
+	unique uint64
+	nodeId uint64
+	body   []byte
+}
+
+// name reads the NUL terminated name that is at the start of b,
+// returning it and what follows it.
+func name(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return string(b), nil
+	}
+	return string(b[:i]), b[i+1:]
+}
+
+func (c *Conn) reply(req *request, errno syscall.Errno, parts ...[]byte) {
+	size := outHeaderSize
+	for _, part := range parts {
+		size += len(part)
+	}
+	out := make([]byte, outHeaderSize, size)
+	order.PutUint32(out[0:], uint32(size))
+	order.PutUint32(out[4:], uint32(-int32(errno)))
+	order.PutUint64(out[8:], req.unique)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	// An error is only returned if the request was interrupted meanwhile
+	c.dev.Write(out)
+}
+
+func (c *Conn) init(req *request) {
+	if len(req.body) < 16 || order.Uint32(req.body[0:]) != protoMajor {
+		c.reply(req, syscall.EPROTO)
+		return
+	}
+	maxReadahead := order.Uint32(req.body[8:])
+	kernelFlags := order.Uint32(req.body[12:])
+
+	out := make([]byte, 64)
+	order.PutUint32(out[0:], protoMajor)
+	order.PutUint32(out[4:], protoMinor)
+	order.PutUint32(out[8:], maxReadahead)
+	order.PutUint32(out[12:], kernelFlags&initBigWrites)
+	order.PutUint16(out[16:], 16) // max_background
+	order.PutUint16(out[18:], 12) // congestion_threshold
+	order.PutUint32(out[20:], maxWrite)
+	order.PutUint32(out[24:], 1) // time_gran
+	c.reply(req, 0, out)
+}
+
+func (c *Conn) forget(req *request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch req.opcode {
+	case opForget:
+		if len(req.body) >= 8 {
+			c.unref(req.nodeId, order.Uint64(req.body))
+		}
+	case opBatchForget:
+		if len(req.body) < 8 {
+			return
+		}
+		count := int(order.Uint32(req.body))
+		for b := req.body[8:]; count > 0 && len(b) >= 16; count, b = count-1, b[16:] {
+			c.unref(order.Uint64(b), order.Uint64(b[8:]))
+		}
+	}
+}
+
+func (c *Conn) unref(id, lookups uint64) {
+	ref, ok := c.nodes[id]
+	if !ok || id == rootId {
+		return
+	}
+	if ref.lookups > lookups {
+		ref.lookups -= lookups
+		return
+	}
+	delete(c.nodes, id)
+	delete(c.ids, ref.node)
+}
+
+// ref returns the id of node, which the kernel now knows about once more.
+func (c *Conn) ref(node Node) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.ids[node]
+	if !ok {
+		id = c.nextId
+		c.nextId += 1
+		c.ids[node] = id
+		c.nodes[id] = &nodeRef{node: node}
+	}
+	c.nodes[id].lookups += 1
+	return id
+}
+
+func (c *Conn) node(id uint64) Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ref, ok := c.nodes[id]; ok {
+		return ref.node
+	}
+	return nil
+}
+
+func (c *Conn) addHandle(h interface{}) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextHandle += 1
+	c.handles[c.nextHandle] = h
+	return c.nextHandle
+}
+
+func (c *Conn) handle(fh uint64) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handles[fh]
+}
+
+func (c *Conn) dropHandle(fh uint64) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.handles[fh]
+	delete(c.handles, fh)
+	return h
+}
+
+func durationParts(d time.Duration) (uint64, uint32) {
+	return uint64(d / time.Second), uint32(d % time.Second)
+}
+
+func (c *Conn) attr(id uint64, node Node) ([]byte, error) {
+	a, err := node.Attr()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := uint32(a.Mode.Perm())
+	nlink := uint32(1)
+	if a.Mode.IsDir() {
+		mode |= syscall.S_IFDIR
+		nlink = 2
+	} else {
+		mode |= syscall.S_IFREG
+	}
+	ctime := a.Ctime
+	if ctime.IsZero() {
+		ctime = a.Mtime
+	}
+
+	b := make([]byte, attrSize)
+	order.PutUint64(b[0:], id)
+	order.PutUint64(b[8:], a.Size)
+	order.PutUint64(b[16:], (a.Size+511)/512)
+	order.PutUint64(b[24:], uint64(a.Mtime.Unix()))
+	order.PutUint64(b[32:], uint64(a.Mtime.Unix()))
+	order.PutUint64(b[40:], uint64(ctime.Unix()))
+	order.PutUint32(b[48:], uint32(a.Mtime.Nanosecond()))
+	order.PutUint32(b[52:], uint32(a.Mtime.Nanosecond()))
+	order.PutUint32(b[56:], uint32(ctime.Nanosecond()))
+	order.PutUint32(b[60:], mode)
+	order.PutUint32(b[64:], nlink)
+	order.PutUint32(b[68:], c.uid)
+	order.PutUint32(b[72:], c.gid)
+	order.PutUint32(b[80:], 4096) // blksize
+	return b, nil
+}
+
+func (c *Conn) attrOut(id uint64, node Node) ([]byte, error) {
+	attr, err := c.attr(id, node)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 16, 16+attrSize)
+	secs, nsecs := durationParts(attrTTL)
+	order.PutUint64(out[0:], secs)
+	order.PutUint32(out[8:], nsecs)
+	return append(out, attr...), nil
+}
+
+// entryOut describes node, which the kernel then knows about.
+func (c *Conn) entryOut(node Node) ([]byte, error) {
+	id := c.ref(node)
+	attr, err := c.attr(id, node)
+	if err != nil {
+		c.mu.Lock()
+		c.unref(id, 1)
+		c.mu.Unlock()
+		return nil, err
+	}
+	out := make([]byte, 40, 40+attrSize)
+	secs, nsecs := durationParts(attrTTL)
+	order.PutUint64(out[0:], id)
+	order.PutUint64(out[16:], secs)
+	order.PutUint64(out[24:], secs)
+	order.PutUint32(out[32:], nsecs)
+	order.PutUint32(out[36:], nsecs)
+	return append(out, attr...), nil
+}
+
+func openOut(fh uint64) []byte {
+	out := make([]byte, 16)
+	order.PutUint64(out[0:], fh)
+	return out
+}
+
+// direntsFrom packs the entries from offset on that fit in size bytes.
+func (c *Conn) direntsFrom(entries []dirEntry, offset uint64, size int) []byte {
+	var out []byte
+	for i := int(offset); i < len(entries); i++ {
+		e := entries[i]
+		recLen := (24 + len(e.name) + 7) &^ 7
+		if len(out)+recLen > size {
+			break
+		}
+		rec := make([]byte, recLen)
+		order.PutUint64(rec[0:], e.ino)
+		order.PutUint64(rec[8:], uint64(i+1))
+		order.PutUint32(rec[16:], uint32(len(e.name)))
+		order.PutUint32(rec[20:], e.typ)
+		copy(rec[24:], e.name)
+		out = append(out, rec...)
+	}
+	return out
+}
+
+type dirEntry struct {
+	ino  uint64
+	typ  uint32
+	name string
+}
+
+const (
+	direntDir = 4
+	direntReg = 8
+)
+
+// listing lists dir along with . and .. as it is opened.
+func (c *Conn) listing(id uint64, dir Dir) ([]dirEntry, error) {
+	dirents, err := dir.ReadDir()
+	if err != nil {
+		return nil, err
+	}
+	entries := []dirEntry{{ino: id, typ: direntDir, name: "."}, {ino: id, typ: direntDir, name: ".."}}
+	for i, d := range dirents {
+		// Listed items needn't be known to the kernel, so they're given
+		// inode numbers that won't be mistaken for the ids of those that are
+		e := dirEntry{ino: 1<<63 + uint64(i), typ: direntReg, name: d.Name}
+		if d.IsDir {
+			e.typ = direntDir
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (c *Conn) respond(req *request) {
+	out, err := c.handleRequest(req)
+	if err != nil {
+		c.reply(req, errno(err))
+		return
+	}
+	if out == nil {
+		c.reply(req, 0)
+		return
+	}
+	c.reply(req, 0, out)
+}
+
+func (c *Conn) handleRequest(req *request) ([]byte, error) {
+	node := c.node(req.nodeId)
+	if node == nil {
+		return nil, syscall.ESTALE
+	}
+	dir, isDir := node.(Dir)
+	file, isFile := node.(File)
+	body := req.body
+
+	switch req.opcode {
+	case opLookup:
+		if !isDir {
+			return nil, syscall.ENOTDIR
+		}
+		childName, _ := name(body)
+		child, err := dir.Lookup(childName)
+		if err != nil {
+			return nil, err
+		}
+		return c.entryOut(child)
+
+	case opGetattr:
+		return c.attrOut(req.nodeId, node)
+
+	case opSetattr:
+		if len(body) < 24 {
+			return nil, syscall.EINVAL
+		}
+		// Only sizes are kept, other attributes can't be set and are left be
+		if valid := order.Uint32(body[0:]); valid&setattrSize != 0 {
+			if !isFile {
+				return nil, syscall.EISDIR
+			}
+			size := order.Uint64(body[16:])
+			var err error
+			if h, ok := c.handle(order.Uint64(body[8:])).(Handle); ok && valid&setattrFh != 0 {
+				err = h.Truncate(size)
+			} else {
+				err = file.Truncate(size)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return c.attrOut(req.nodeId, node)
+
+	case opMkdir:
+		if !isDir {
+			return nil, syscall.ENOTDIR
+		}
+		childName, _ := name(body[8:])
+		child, err := dir.Mkdir(childName)
+		if err != nil {
+			return nil, err
+		}
+		return c.entryOut(child)
+
+	case opCreate:
+		if !isDir {
+			return nil, syscall.ENOTDIR
+		}
+		childName, _ := name(body[16:])
+		child, h, err := dir.Create(childName)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := c.entryOut(child)
+		if err != nil {
+			h.Release()
+			return nil, err
+		}
+		return append(entry, openOut(c.addHandle(h))...), nil
+
+	case opUnlink, opRmdir:
+		if !isDir {
+			return nil, syscall.ENOTDIR
+		}
+		childName, _ := name(body)
+		return nil, dir.Remove(childName, req.opcode == opRmdir)
+
+	case opRename:
+		if !isDir || len(body) < 8 {
+			return nil, syscall.ENOTDIR
+		}
+		newDir, ok := c.node(order.Uint64(body)).(Dir)
+		if !ok {
+			return nil, syscall.ENOTDIR
+		}
+		oldName, rest := name(body[8:])
+		newName, _ := name(rest)
+		return nil, dir.Rename(oldName, newDir, newName)
+
+	case opOpen:
+		if !isFile {
+			return nil, syscall.EISDIR
+		}
+		flags := order.Uint32(body)
+		h, err := file.Open(flags&syscall.O_ACCMODE != syscall.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+		return openOut(c.addHandle(h)), nil
+
+	case opRead:
+		if len(body) < 24 {
+			return nil, syscall.EINVAL
+		}
+		h, ok := c.handle(order.Uint64(body)).(Handle)
+		if !ok {
+			return nil, syscall.EBADF
+		}
+		buf := make([]byte, order.Uint32(body[16:]))
+		n, err := h.ReadAt(buf, int64(order.Uint64(body[8:])))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		return buf[:n], nil
+
+	case opWrite:
+		if len(body) < 40 {
+			return nil, syscall.EINVAL
+		}
+		h, ok := c.handle(order.Uint64(body)).(Handle)
+		if !ok {
+			return nil, syscall.EBADF
+		}
+		data := body[40:]
+		if size := int(order.Uint32(body[16:])); size < len(data) {
+			data = data[:size]
+		}
+		n, err := h.WriteAt(data, int64(order.Uint64(body[8:])))
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 8)
+		order.PutUint32(out, uint32(n))
+		return out, nil
+
+	case opFlush, opFsync:
+		h, ok := c.handle(order.Uint64(body)).(Handle)
+		if !ok {
+			return nil, syscall.EBADF
+		}
+		return nil, h.Flush()
+
+	case opRelease:
+		if h, ok := c.dropHandle(order.Uint64(body)).(Handle); ok {
+			return nil, h.Release()
+		}
+		return nil, nil
+
+	case opOpendir:
+		if !isDir {
+			return nil, syscall.ENOTDIR
+		}
+		entries, err := c.listing(req.nodeId, dir)
+		if err != nil {
+			return nil, err
+		}
+		return openOut(c.addHandle(entries)), nil
+
+	case opReaddir:
+		if len(body) < 24 {
+			return nil, syscall.EINVAL
+		}
+		entries, ok := c.handle(order.Uint64(body)).([]dirEntry)
+		if !ok {
+			return nil, syscall.EBADF
+		}
+		return c.direntsFrom(entries, order.Uint64(body[8:]), int(order.Uint32(body[16:]))), nil
+
+	case opReleasedir:
+		c.dropHandle(order.Uint64(body))
+		return nil, nil
+
+	case opFsyncdir, opAccess:
+		return nil, nil
+
+	case opPoll:
+		// The kernel remembers that polls aren't served and stops asking
+		return nil, syscall.ENOSYS
+
+	case opStatfs:
+		out := make([]byte, 80)
+		order.PutUint32(out[40:], 4096) // bsize
+		order.PutUint32(out[44:], 255)  // namelen
+		order.PutUint32(out[48:], 4096) // frsize
+		return out, nil
+	}
+	return nil, syscall.ENOSYS
+}
@@ -0,0 +1,323 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package fuse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// memFS is an in memory filesystem, guarded by a single lock.
+type memFS struct {
+	sync.Mutex
+}
+
+type memDir struct {
+	fs       *memFS
+	children map[string]Node
+}
+
+type memFile struct {
+	fs      *memFS
+	content []byte
+}
+
+type memHandle struct {
+	f *memFile
+}
+
+func (d *memDir) Attr() (Attr, error) {
+	return Attr{Mode: os.ModeDir | 0755}, nil
+}
+
+func (d *memDir) Lookup(name string) (Node, error) {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	if child, ok := d.children[name]; ok {
+		return child, nil
+	}
+	return nil, syscall.ENOENT
+}
+
+func (d *memDir) ReadDir() ([]Dirent, error) {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	var dirents []Dirent
+	for name, child := range d.children {
+		_, isDir := child.(*memDir)
+		dirents = append(dirents, Dirent{Name: name, IsDir: isDir})
+	}
+	return dirents, nil
+}
+
+func (d *memDir) Mkdir(name string) (Node, error) {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	if _, ok := d.children[name]; ok {
+		return nil, os.ErrExist
+	}
+	child := &memDir{fs: d.fs, children: make(map[string]Node)}
+	d.children[name] = child
+	return child, nil
+}
+
+func (d *memDir) Create(name string) (Node, Handle, error) {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	child := &memFile{fs: d.fs}
+	d.children[name] = child
+	return child, &memHandle{f: child}, nil
+}
+
+func (d *memDir) Remove(name string, dir bool) error {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	child, ok := d.children[name]
+	if !ok {
+		return syscall.ENOENT
+	}
+	if sub, isDir := child.(*memDir); isDir != dir {
+		return syscall.EISDIR
+	} else if isDir && len(sub.children) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	delete(d.children, name)
+	return nil
+}
+
+func (d *memDir) Rename(oldName string, newDir Dir, newName string) error {
+	d.fs.Lock()
+	defer d.fs.Unlock()
+	child, ok := d.children[oldName]
+	if !ok {
+		return syscall.ENOENT
+	}
+	delete(d.children, oldName)
+	newDir.(*memDir).children[newName] = child
+	return nil
+}
+
+func (f *memFile) Attr() (Attr, error) {
+	f.fs.Lock()
+	defer f.fs.Unlock()
+	return Attr{Size: uint64(len(f.content)), Mode: 0644, Mtime: time.Unix(1500000000, 0)}, nil
+}
+
+func (f *memFile) Open(write bool) (Handle, error) {
+	return &memHandle{f: f}, nil
+}
+
+func (f *memFile) Truncate(size uint64) error {
+	f.fs.Lock()
+	defer f.fs.Unlock()
+	if int(size) <= len(f.content) {
+		f.content = f.content[:size]
+	} else {
+		f.content = append(f.content, make([]byte, int(size)-len(f.content))...)
+	}
+	return nil
+}
+
+func (h *memHandle) ReadAt(p []byte, off int64) (int, error) {
+	h.f.fs.Lock()
+	defer h.f.fs.Unlock()
+	if off >= int64(len(h.f.content)) {
+		return 0, nil
+	}
+	return copy(p, h.f.content[off:]), nil
+}
+
+func (h *memHandle) WriteAt(p []byte, off int64) (int, error) {
+	h.f.fs.Lock()
+	defer h.f.fs.Unlock()
+	if end := int(off) + len(p); end > len(h.f.content) {
+		h.f.content = append(h.f.content, make([]byte, end-len(h.f.content))...)
+	}
+	return copy(h.f.content[off:], p), nil
+}
+
+func (h *memHandle) Truncate(size uint64) error {
+	return h.f.Truncate(size)
+}
+
+func (h *memHandle) Flush() error   { return nil }
+func (h *memHandle) Release() error { return nil }
+
+// openBlocking opens name without the Go runtime registering it for polling,
+// as the test serves the mount that it opens files in, see Mount.
+func openBlocking(name string, flag int, perm os.FileMode) (*os.File, error) {
+	fd, err := syscall.Open(name, flag|syscall.O_CLOEXEC, uint32(perm))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+func readFile(name string) ([]byte, error) {
+	f, err := openBlocking(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	f, err := openBlocking(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// mountMem mounts an in memory filesystem, skipping the test
+// if filesystems can't be mounted here.
+func mountMem(t *testing.T) (string, *memDir, func()) {
+	mountpoint, err := ioutil.TempDir("", "fusetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		os.Remove(mountpoint)
+		t.Skip("no /dev/fuse")
+	}
+
+	root := &memDir{fs: &memFS{}, children: make(map[string]Node)}
+	c, err := Mount(mountpoint, "fusetest", root)
+	if err != nil {
+		os.Remove(mountpoint)
+		t.Skipf("can't mount here: %v", err)
+	}
+
+	return mountpoint, root, func() {
+		if err := Unmount(mountpoint); err != nil {
+			t.Errorf("unmount: %v", err)
+		}
+		if err := c.Wait(); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		c.Close()
+		os.Remove(mountpoint)
+	}
+}
+
+func TestMount(t *testing.T) {
+	mountpoint, root, unmount := mountMem(t)
+	defer unmount()
+
+	at := func(p ...string) string {
+		return filepath.Join(append([]string{mountpoint}, p...)...)
+	}
+
+	if err := os.Mkdir(at("docs"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := writeFile(at("docs", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	content, err := readFile(at("docs", "a.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("read: got %q, %v want %q", content, err, "hello")
+	}
+
+	fi, err := os.Stat(at("docs", "a.txt"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if fi.Size() != 5 || fi.Mode() != 0644 || !fi.ModTime().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("stat: got size %d mode %v mtime %v", fi.Size(), fi.Mode(), fi.ModTime())
+	}
+
+	f, err := openBlocking(at("docs", "a.txt"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(", world")
+	f.Close()
+	if err := os.Truncate(at("docs", "a.txt"), 8); err != nil {
+		t.Errorf("truncate: %v", err)
+	}
+	if content, _ := readFile(at("docs", "a.txt")); string(content) != "hello, w" {
+		t.Errorf("append and truncate: got %q", content)
+	}
+
+	if err := os.Rename(at("docs", "a.txt"), at("b.txt")); err != nil {
+		t.Errorf("rename: %v", err)
+	}
+	root.fs.Lock()
+	_, ok := root.children["b.txt"].(*memFile)
+	root.fs.Unlock()
+	if !ok {
+		t.Errorf("rename: b.txt isn't in the root")
+	}
+
+	infos, err := ioutil.ReadDir(mountpoint)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"b.txt", "docs"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("readdir: got %v want %v", names, want)
+	}
+
+	if err := os.Remove(at("b.txt")); err != nil {
+		t.Errorf("unlink: %v", err)
+	}
+	if err := os.Remove(at("docs")); err != nil {
+		t.Errorf("rmdir: %v", err)
+	}
+	if _, err := os.Stat(at("docs")); !os.IsNotExist(err) {
+		t.Errorf("stat after rmdir: got %v want not exist", err)
+	}
+}
+
+func TestReaddirPaging(t *testing.T) {
+	mountpoint, root, unmount := mountMem(t)
+	defer unmount()
+
+	// More entries than fit in one reply
+	root.fs.Lock()
+	for i := 0; i < 500; i++ {
+		root.children[fmt.Sprintf("file-%03d-with-a-long-enough-name", i)] = &memFile{fs: root.fs}
+	}
+	root.fs.Unlock()
+	infos, err := ioutil.ReadDir(mountpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 500 {
+		t.Errorf("got %d entries want 500", len(infos))
+	}
+}
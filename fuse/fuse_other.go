@@ -0,0 +1,36 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package fuse
+
+type Conn struct{}
+
+func Mount(mountpoint, fsName string, root Dir) (*Conn, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *Conn) Wait() error {
+	return ErrUnsupported
+}
+
+func (c *Conn) Close() error {
+	return nil
+}
+
+func Unmount(mountpoint string) error {
+	return ErrUnsupported
+}
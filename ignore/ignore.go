@@ -0,0 +1,301 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ignore matches paths against the patterns of gitignore style files
+// e.g .driveignore, which can be in any folder and apply to what is under it.
+//
+// Patterns are as in gitignore: blank lines and those starting with # are
+// skipped, ! negates a pattern, a trailing / only matches folders, a / at the
+// start or in the middle anchors a pattern to its file's folder whereas other
+// patterns match names at any depth, * and ? match within a name, [a-z] is a
+// class and ** matches across folders e.g "**/build", "logs/**", "a/**/b".
+// The last pattern to match a path decides whether it is ignored and nothing
+// under an ignored folder can be re-included.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type rule struct {
+	// base is the folder of the file that the rule is from, relative to the root
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Matcher matches paths relative to root against the rules of the files
+// named fileName in root and its descendants. Files are read the first
+// time a path under their folder is matched. It is safe for concurrent use
+// and a nil *Matcher matches nothing.
+type Matcher struct {
+	root     string
+	fileName string
+	// SkipRootFile when set leaves the file in root for the caller to
+	// handle, any rules for root are then added with Add.
+	SkipRootFile bool
+	// OnError if set is told of the files that couldn't be read or parsed,
+	// whose rules are then left out.
+	OnError func(error)
+
+	mu sync.Mutex
+	// rules are by the folders that they are from
+	rules  map[string][]*rule
+	loaded map[string]bool
+}
+
+func New(root, fileName string) *Matcher {
+	return &Matcher{
+		root:     root,
+		fileName: fileName,
+		rules:    make(map[string][]*rule),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// Add adds the patterns, as if they were the lines of a file in the folder
+// base relative to the root e.g "" for the root itself or "docs/drafts".
+func (m *Matcher) Add(base string, patterns []string) error {
+	base = cleanRel(base)
+
+	var parsed []*rule
+	for i, p := range patterns {
+		r, err := parse(base, p)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if r != nil {
+			parsed = append(parsed, r)
+		}
+	}
+
+	m.mu.Lock()
+	m.rules[base] = append(m.rules[base], parsed...)
+	m.mu.Unlock()
+	return nil
+}
+
+func cleanRel(p string) string {
+	p = strings.Trim(filepath.ToSlash(p), "/")
+	if p == "" || p == "." {
+		return ""
+	}
+	return path.Clean(p)
+}
+
+// Match reports whether p, relative to the root, is ignored.
+// isDir is whether p is a folder, which patterns ending in / need.
+func (m *Matcher) Match(p string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	p = cleanRel(p)
+	if p == "" {
+		return false
+	}
+
+	segments := strings.Split(p, "/")
+	// An ignored folder takes everything under it along
+	for i := 1; i < len(segments); i++ {
+		if m.decide(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.decide(p, isDir)
+}
+
+// decide applies the rules of the folders above p, outermost first, so
+// that the last rule to match p is that of the innermost file.
+func (m *Matcher) decide(p string, isDir bool) bool {
+	ignored := false
+	dir := path.Dir(p)
+	if dir == "." {
+		dir = ""
+	}
+
+	var bases []string
+	for base := dir; ; base = path.Dir(base) {
+		if base == "." {
+			base = ""
+		}
+		bases = append([]string{base}, bases...)
+		if base == "" {
+			break
+		}
+	}
+
+	for _, base := range bases {
+		for _, r := range m.rulesOf(base) {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			rel := p
+			if base != "" {
+				rel = strings.TrimPrefix(p, base+"/")
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (m *Matcher) rulesOf(base string) []*rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded[base] {
+		m.loaded[base] = true
+		if base != "" || !m.SkipRootFile {
+			if err := m.load(base); err != nil && m.OnError != nil {
+				m.OnError(err)
+			}
+		}
+	}
+	return m.rules[base]
+}
+
+// load reads the file in the folder base, if there is one. The lock is held.
+func (m *Matcher) load(base string) error {
+	p := filepath.Join(m.root, filepath.FromSlash(base), m.fileName)
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var parsed []*rule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		r, err := parse(base, scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s: line %d: %v", p, line, err)
+		}
+		if r != nil {
+			parsed = append(parsed, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	m.rules[base] = append(m.rules[base], parsed...)
+	return nil
+}
+
+// parse parses the line of a file in the folder base, returning
+// a nil rule for lines that are blank or comments.
+func parse(base, line string) (*rule, error) {
+	line = strings.TrimRight(line, "\r")
+	// Trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	r := &rule{base: base, pattern: line}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil, nil
+	}
+
+	// A slash anywhere but at the end anchors the pattern to base
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := translate(line)
+	if err != nil {
+		return nil, err
+	}
+	if anchored || strings.HasPrefix(expr, "(?:.*/)?") {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	if r.re, err = regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("%q: %v", r.pattern, err)
+	}
+	return r, nil
+}
+
+// translate turns a pattern into the regular expression that it stands for.
+func translate(pattern string) (string, error) {
+	var expr strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			atStart := i == 0 || pattern[i-1] == '/'
+			end := i + 2
+			switch {
+			case atStart && end < len(pattern) && pattern[end] == '/':
+				// "**/" is any number of folders, including none
+				expr.WriteString("(?:.*/)?")
+				i = end
+			case atStart && end == len(pattern):
+				// A trailing "**" is everything inside
+				expr.WriteString(".*")
+				i = end - 1
+			default:
+				expr.WriteString("[^/]*")
+				i = end - 1
+			}
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("%q: unterminated [", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			expr.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i += 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String(), nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type matchCase struct {
+	path  string
+	isDir bool
+	want  bool
+}
+
+func testMatcher(t *testing.T, rules map[string][]string) *Matcher {
+	root, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	m := New(root, ".driveignore")
+	for base, patterns := range rules {
+		if err := m.Add(base, patterns); err != nil {
+			t.Fatalf("Add(%q, %q): %v", base, patterns, err)
+		}
+	}
+	return m
+}
+
+func checkMatches(t *testing.T, m *Matcher, cases []matchCase) {
+	t.Helper()
+	for _, c := range cases {
+		if got := m.Match(c.path, c.isDir); got != c.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules map[string][]string
+		cases []matchCase
+	}{
+		{
+			name:  "unanchored names match at any depth",
+			rules: map[string][]string{"": {"*.o", "tmp"}},
+			cases: []matchCase{
+				{"a.o", false, true},
+				{"src/lib/a.o", false, true},
+				{"a.oo", false, false},
+				{"tmp", true, true},
+				{"src/tmp", false, true},
+				{"tmpfile", false, false},
+			},
+		},
+		{
+			name:  "a leading / anchors to the file's folder",
+			rules: map[string][]string{"": {"/build"}, "docs": {"/drafts"}},
+			cases: []matchCase{
+				{"build", true, true},
+				{"build/out.bin", false, true},
+				{"src/build", true, false},
+				{"docs/drafts", true, true},
+				{"drafts", true, false},
+				{"docs/old/drafts", true, false},
+			},
+		},
+		{
+			name:  "a / in the middle anchors too",
+			rules: map[string][]string{"": {"doc/*.txt"}},
+			cases: []matchCase{
+				{"doc/a.txt", false, true},
+				{"src/doc/a.txt", false, false},
+				{"doc/sub/a.txt", false, false},
+			},
+		},
+		{
+			name:  "leading **",
+			rules: map[string][]string{"": {"**/cache"}},
+			cases: []matchCase{
+				{"cache", true, true},
+				{"a/cache", true, true},
+				{"a/b/c/cache", false, true},
+				{"a/cached", false, false},
+			},
+		},
+		{
+			name:  "trailing **",
+			rules: map[string][]string{"": {"logs/**"}},
+			cases: []matchCase{
+				{"logs", true, false},
+				{"logs/a.log", false, true},
+				{"logs/2015/01/a.log", false, true},
+				{"src/logs/a.log", false, false},
+			},
+		},
+		{
+			name:  "** in the middle",
+			rules: map[string][]string{"": {"a/**/b"}},
+			cases: []matchCase{
+				{"a/b", false, true},
+				{"a/x/b", false, true},
+				{"a/x/y/b", false, true},
+				{"a/x/bb", false, false},
+				{"c/a/x/b", false, false},
+			},
+		},
+		{
+			name:  "** within a name is *",
+			rules: map[string][]string{"": {"a**b"}},
+			cases: []matchCase{
+				{"ab", false, true},
+				{"axxb", false, true},
+				{"a/b", false, false},
+			},
+		},
+		{
+			name:  "a trailing / only matches folders",
+			rules: map[string][]string{"": {"out/"}},
+			cases: []matchCase{
+				{"out", true, true},
+				{"out", false, false},
+				{"src/out", true, true},
+				{"src/out", false, false},
+				{"out/main.o", false, true},
+			},
+		},
+		{
+			name:  "! re-includes what an earlier pattern ignored",
+			rules: map[string][]string{"": {"*.log", "!keep.log"}},
+			cases: []matchCase{
+				{"a.log", false, true},
+				{"keep.log", false, false},
+				{"sub/keep.log", false, false},
+			},
+		},
+		{
+			name:  "the last pattern to match wins",
+			rules: map[string][]string{"": {"!keep.log", "*.log"}},
+			cases: []matchCase{
+				{"keep.log", false, true},
+			},
+		},
+		{
+			name:  "nothing under an ignored folder can be re-included",
+			rules: map[string][]string{"": {"vendor/", "!vendor/keep.go"}},
+			cases: []matchCase{
+				{"vendor", true, true},
+				{"vendor/keep.go", false, true},
+			},
+		},
+		{
+			name:  "rules of an inner folder come after those above it",
+			rules: map[string][]string{"": {"*.pdf"}, "docs": {"!final.pdf"}},
+			cases: []matchCase{
+				{"final.pdf", false, true},
+				{"docs/final.pdf", false, false},
+				{"docs/sub/final.pdf", false, false},
+				{"docs/draft.pdf", false, true},
+			},
+		},
+		{
+			name:  "escaped # and ! are literal",
+			rules: map[string][]string{"": {"# a comment", `\#notes`, `\!important`}},
+			cases: []matchCase{
+				{"#notes", false, true},
+				{"notes", false, false},
+				{"# a comment", false, false},
+				{"!important", false, true},
+				{"important", false, false},
+			},
+		},
+		{
+			name:  "escaped trailing space is kept",
+			rules: map[string][]string{"": {`a\ `, "b  "}},
+			cases: []matchCase{
+				{"a ", false, true},
+				{"a", false, false},
+				{"b", false, true},
+			},
+		},
+		{
+			name:  "? and classes match one character",
+			rules: map[string][]string{"": {"?.txt", "[0-9].md", "[!a-z].csv"}},
+			cases: []matchCase{
+				{"a.txt", false, true},
+				{"ab.txt", false, false},
+				{"7.md", false, true},
+				{"x.md", false, false},
+				{"1.csv", false, true},
+				{"x.csv", false, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkMatches(t, testMatcher(t, tt.rules), tt.cases)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	m := testMatcher(t, nil)
+	if err := m.Add("", []string{"ok", "[a-"}); err == nil {
+		t.Errorf("Add: expected an error for an unterminated class")
+	}
+}
+
+func TestFilesAreLoaded(t *testing.T) {
+	m := testMatcher(t, nil)
+	docs := filepath.Join(m.root, "docs")
+	if err := os.MkdirAll(docs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(dir, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, ".driveignore"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(m.root, "*.bak\n")
+	write(docs, "# drafts stay local\r\n/drafts/\n!keep.bak\n")
+
+	checkMatches(t, m, []matchCase{
+		{"a.bak", false, true},
+		{"docs/keep.bak", false, false},
+		{"docs/drafts/x.txt", false, true},
+		{"drafts/x.txt", false, false},
+	})
+
+	var errs []error
+	bad := New(m.root, ".badignore")
+	bad.OnError = func(err error) { errs = append(errs, err) }
+	if err := ioutil.WriteFile(filepath.Join(m.root, ".badignore"), []byte("[oops\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if bad.Match("a", false) || len(errs) != 1 {
+		t.Errorf("got %v errors %v, want the bad file reported once", len(errs), errs)
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("anything", false) {
+		t.Errorf("a nil Matcher matched")
+	}
+}
@@ -117,9 +117,14 @@ func quotaInformation(logy *log.Logger, about *drive.About) {
 
 	if len(about.QuotaBytesByService) >= 1 {
 		logy.Logln("\n* Space used by Google Services *")
-		logy.Logf("%-36s %-36s\n", "Service", "Bytes")
+		logy.Logf("%-36s %-20s %s\n", "Service", "Bytes", "Share of used")
 		for _, quotaService := range about.QuotaBytesByService {
-			logy.Logf("%-36s %-36s\n", quotaService.ServiceName, prettyBytes(quotaService.BytesUsed))
+			share := 0.0
+			if about.QuotaBytesUsedAggregate > 0 {
+				share = 100 * float64(quotaService.BytesUsed) / float64(about.QuotaBytesUsedAggregate)
+			}
+			logy.Logf("%-36s %-20s %.1f%%\n", serviceName(quotaService.ServiceName),
+				prettyBytes(quotaService.BytesUsed), share)
 		}
 		logy.Logf("%-36s %-36s\n", "Space used by all Google Apps",
 			prettyBytes(about.QuotaBytesUsedAggregate))
@@ -127,6 +132,19 @@ func quotaInformation(logy *log.Logger, about *drive.About) {
 	logy.Logln()
 }
 
+// serviceName is the display name of a Google service sharing the quota.
+func serviceName(name string) string {
+	switch name {
+	case "DRIVE":
+		return "Drive"
+	case "GMAIL":
+		return "Gmail"
+	case "PHOTOS":
+		return "Photos"
+	}
+	return name
+}
+
 func (g *Commands) QuotaStatus(query int64) (status int, err error) {
 	if query < 0 {
 		return Unknown, err
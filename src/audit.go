@@ -0,0 +1,148 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	AuditCopy    = "copy"
+	AuditMove    = "move"
+	AuditRename  = "rename"
+	AuditTrash   = "trash"
+	AuditUntrash = "untrash"
+	AuditDelete  = "delete"
+
+	AuditResultOk    = "ok"
+	AuditResultError = "error"
+)
+
+// auditEntry is a single line of the audit log.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Actor     string    `json:"actor,omitempty"`
+	SourceId  string    `json:"sourceId,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Dest      string    `json:"dest,omitempty"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// auditLog appends an entry, as a line of JSON, for every mutation
+// made. It is safe for use by concurrently running operations.
+type auditLog struct {
+	sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	actor   string
+	actorer sync.Once
+}
+
+// openAuditLog opens the audit log at p for appending. With rotate set,
+// an existing log is first set aside, suffixed with the time of rotation,
+// so that each run starts off a fresh log.
+func openAuditLog(p string, rotate bool) (*auditLog, error) {
+	if rotate {
+		rotatedPath := fmt.Sprintf("%s.%s", p, time.Now().Format("20060102T150405"))
+		if err := os.Rename(p, rotatedPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, config.O_RWForAll)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// close syncs the entries appended to disk and closes the log.
+func (al *auditLog) close() error {
+	if al == nil {
+		return nil
+	}
+	al.Lock()
+	defer al.Unlock()
+
+	syncErr := al.f.Sync()
+	if err := al.f.Close(); err != nil {
+		return err
+	}
+	return syncErr
+}
+
+// Close releases what the commands hold open, syncing the audit log to disk.
+// It is to be called once done with them, entries are no longer audited after.
+func (g *Commands) Close() error {
+	al := g.auditLog
+	g.auditLog = nil
+	if err := al.close(); err != nil {
+		return fmt.Errorf("audit log %s: %v", g.opts.AuditLogPath, err)
+	}
+	return nil
+}
+
+// audit records the outcome of op on src, emitting it too if emitting results.
+// It is a no-op if neither an audit log nor an emitter is set.
+func (g *Commands) audit(op string, src *File, srcPath, dest string, err error) {
+	al := g.auditLog
+	// Planned operations aren't made so there is nothing to audit
+	if (al == nil && g.emitter == nil) || g.plan != nil {
+		return
+	}
+
+	entry := auditEntry{
+		Time:      time.Now().UTC(),
+		Operation: op,
+		Source:    srcPath,
+		Dest:      dest,
+		Result:    AuditResultOk,
+	}
+	if src != nil {
+		entry.SourceId = src.Id
+	}
+	if err != nil {
+		entry.Result = AuditResultError
+		entry.Error = err.Error()
+	}
+
+	g.emitter.emit(&entry)
+	if al == nil {
+		return
+	}
+
+	al.actorer.Do(func() {
+		if about, aboutErr := g.rem.About(); aboutErr == nil && about.User != nil {
+			al.actor = about.User.EmailAddress
+		}
+	})
+	entry.Actor = al.actor
+
+	al.Lock()
+	defer al.Unlock()
+
+	if encErr := al.enc.Encode(&entry); encErr != nil {
+		g.log.LogErrf("audit: %s %s: %v\n", op, srcPath, encErr)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLogClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "audit.log")
+	al, err := openAuditLog(p, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Skip looking up the actor, there is no remote to ask
+	al.actorer.Do(func() {})
+
+	g := &Commands{auditLog: al, opts: &Options{AuditLogPath: p}}
+	g.audit(AuditCopy, &File{Id: "src"}, "/docs/a.txt", "/backup/a.txt", nil)
+	if err := g.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// Closing again and auditing once closed are no-ops
+	if err := g.Close(); err != nil {
+		t.Errorf("Close again: %v", err)
+	}
+	g.audit(AuditCopy, &File{Id: "late"}, "/late", "", nil)
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry auditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("%q: %v", data, err)
+	}
+	if entry.Source != "/docs/a.txt" || entry.SourceId != "src" || entry.Dest != "/backup/a.txt" {
+		t.Errorf("got %+v, want the source path and id recorded", entry)
+	}
+}
+
+func TestNewFailsWithoutAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	unwritable := filepath.Join(dir, "missing", "audit.log")
+	if g, err := New(nil, &Options{Force: true, AuditLogPath: unwritable}); err == nil || g != nil {
+		t.Errorf("New with audit log %s: got %v, %v want an error", unwritable, g, err)
+	}
+
+	p := filepath.Join(dir, "audit.log")
+	g, err := New(nil, &Options{Force: true, AuditLogPath: p})
+	if err != nil {
+		t.Fatalf("New with audit log %s: %v", p, err)
+	}
+	if err := g.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
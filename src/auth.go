@@ -0,0 +1,157 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// OAuth 2.0 device authorization flow endpoints, for machines without a browser.
+	DeviceCodeURL  = "https://oauth2.googleapis.com/device/code"
+	DeviceTokenURL = "https://oauth2.googleapis.com/token"
+
+	DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// deviceCode is a code for the user to enter at VerificationURL, on any device,
+// to authorize the device that polls for a token with DeviceCode.
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Interval        int64  `json:"interval"`
+}
+
+type deviceToken struct {
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func postForm(uri string, form url.Values, v interface{}) error {
+	resp, err := http.PostForm(uri, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// RetrieveRefreshTokenByDevice gets a refresh token through the device
+// authorization flow: the user is given a short code to enter at a URL from
+// any device with a browser, while the token is polled for until they do.
+func RetrieveRefreshTokenByDevice(context *config.Context) (string, error) {
+	code := &deviceCode{}
+	err := postForm(DeviceCodeURL, url.Values{
+		"client_id": {context.ClientId},
+		"scope":     {DriveScope},
+	}, code)
+	if err != nil {
+		return "", err
+	}
+	if code.DeviceCode == "" {
+		return "", fmt.Errorf("device code: none was given, the client may not be allowed the device flow")
+	}
+
+	fmt.Printf("On any device, visit\n%s\nand enter the code %s\n", code.VerificationURL, code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		token := &deviceToken{}
+		err := postForm(DeviceTokenURL, url.Values{
+			"client_id":     {context.ClientId},
+			"client_secret": {context.ClientSecret},
+			"device_code":   {code.DeviceCode},
+			"grant_type":    {DeviceGrantType},
+		}, token)
+		if err != nil {
+			return "", err
+		}
+
+		switch token.Error {
+		case "":
+			return token.RefreshToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", fmt.Errorf("device authorization: %s %s", token.Error, token.ErrorDescription)
+		}
+	}
+	return "", fmt.Errorf("device authorization: the code expired before it was entered")
+}
+
+// serviceAccountClient authenticates as the service account whose key is in
+// the context, impersonating its subject if set i.e with domain-wide delegation.
+func serviceAccountClient(configContext *config.Context) (*http.Client, error) {
+	jwtConfig, err := google.JWTConfigFromJSON(configContext.ServiceAccountKey, DriveScope)
+	if err != nil {
+		return nil, fmt.Errorf("service account key: %v", err)
+	}
+	jwtConfig.Subject = configContext.ServiceAccountSubject
+	return jwtConfig.Client(context.Background()), nil
+}
+
+// initServiceAccount sets the context up to authenticate as the service
+// account whose JSON key is at keyPath, impersonating subject if set.
+func (g *Commands) initServiceAccount(keyPath, subject string) error {
+	key, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	g.context.ServiceAccountKey = key
+	g.context.ServiceAccountSubject = subject
+	g.context.RefreshToken = ""
+
+	// Fail early on a bad key rather than on the first push or pull
+	client, err := serviceAccountClient(g.context)
+	if err != nil {
+		return err
+	}
+	if _, err := newRemote(client).About(); err != nil {
+		return fmt.Errorf("service account: %v", err)
+	}
+
+	return g.context.Write()
+}
+
+// failingTransport fails every request with err, for clients that couldn't be set up.
+type failingTransport struct {
+	err error
+}
+
+func (ft failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, ft.err
+}
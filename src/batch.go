@@ -0,0 +1,281 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	DriveBatchURL = "https://www.googleapis.com/batch/drive/v2"
+	// MaxBatchSize is the most calls that Drive takes in a single batch
+	MaxBatchSize = 100
+)
+
+// batchCall is a single call made as part of a batch.
+type batchCall struct {
+	method string
+	// path is relative to the base path of the API e.g "files/<id>/parents"
+	path string
+	body interface{}
+}
+
+// batch makes calls, MaxBatchSize at a time, in batch requests. The error of each
+// call is returned at its index. Calls within a batch can be made in any order.
+func (r *Remote) batch(calls []*batchCall) []error {
+	errs := make([]error, len(calls))
+	for start := 0; start < len(calls); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		if err := r.batchOnce(calls[start:end], errs[start:end]); err != nil {
+			for i := start; i < end; i++ {
+				errs[i] = err
+			}
+		}
+	}
+	return errs
+}
+
+func (r *Remote) batchOnce(calls []*batchCall, errs []error) error {
+	base, err := url.Parse(r.service.BasePath)
+	if err != nil {
+		return err
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for i, call := range calls {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-ID", fmt.Sprintf("<%d>", i))
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(part, "%s %s%s\r\n", call.method, base.Path, call.path)
+		if call.body == nil {
+			fmt.Fprintf(part, "\r\n")
+			continue
+		}
+		callBody, err := json.Marshal(call.body)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(part, "Content-Type: application/json\r\n\r\n%s\r\n", callBody)
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", DriveBatchURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("batch: %v", err)
+	}
+
+	answered := make([]bool, len(calls))
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+
+		// Responses are identified as "<response-i>" for the call identified as "<i>"
+		contentId := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		i, convErr := strconv.Atoi(strings.TrimPrefix(contentId, "response-"))
+		if convErr != nil || i < 0 || i >= len(calls) {
+			continue
+		}
+
+		callResp, err := http.ReadResponse(bufio.NewReader(part), req)
+		if err != nil {
+			errs[i] = err
+		} else {
+			errs[i] = googleapi.CheckResponse(callResp)
+			callResp.Body.Close()
+		}
+		answered[i] = true
+	}
+
+	for i, ok := range answered {
+		if !ok {
+			errs[i] = fmt.Errorf("batch: no response for %s %s", calls[i].method, calls[i].path)
+		}
+	}
+	return nil
+}
+
+// parentChange is an insertion or removal of a parent of a file.
+type parentChange struct {
+	fileId   string
+	parentId string
+}
+
+// parentBatch queues up the changes to the parents of files, to be made in
+// batches. Removals are made after insertions, and only for the files whose
+// insertions, if any, were made, so that no file is left without a parent.
+type parentBatch struct {
+	sync.Mutex
+	inserts []*parentChange
+	removes []*parentChange
+	// flushed are told of the outcome of the changes to files, by file id
+	flushed map[string][]func(error)
+	// afterFlush are run once the changes are made and flushed is told
+	afterFlush []func(failures map[string]error)
+}
+
+// whenFlushed queues fn to be told of the outcome of the changes to the
+// parents of the file with id fileId once they are made. It returns false,
+// not queuing fn, if no changes to its parents are queued.
+func (pb *parentBatch) whenFlushed(fileId string, fn func(error)) bool {
+	pb.Lock()
+	defer pb.Unlock()
+
+	queued := false
+	for _, changes := range [][]*parentChange{pb.inserts, pb.removes} {
+		for _, change := range changes {
+			queued = queued || change.fileId == fileId
+		}
+	}
+	if !queued {
+		return false
+	}
+	if pb.flushed == nil {
+		pb.flushed = make(map[string][]func(error))
+	}
+	pb.flushed[fileId] = append(pb.flushed[fileId], fn)
+	return true
+}
+
+// whenAllFlushed queues fn to be run, in the order queued, once all the
+// changes are made. It is passed the failures keyed by file id, which it
+// may add to. fn runs with the batch locked, so it mustn't queue changes.
+func (pb *parentBatch) whenAllFlushed(fn func(failures map[string]error)) {
+	pb.Lock()
+	defer pb.Unlock()
+	pb.afterFlush = append(pb.afterFlush, fn)
+}
+
+// batchingMutator queues up parent changes in its batch to be made
+// on flushing it, making every other mutation via mutator right away.
+type batchingMutator struct {
+	mutator
+	batch *parentBatch
+}
+
+func (bm *batchingMutator) insertParent(fileId, parentId string) error {
+	bm.batch.Lock()
+	defer bm.batch.Unlock()
+	bm.batch.inserts = append(bm.batch.inserts, &parentChange{fileId: fileId, parentId: parentId})
+	return nil
+}
+
+func (bm *batchingMutator) removeParent(fileId, parentId string) error {
+	bm.batch.Lock()
+	defer bm.batch.Unlock()
+	bm.batch.removes = append(bm.batch.removes, &parentChange{fileId: fileId, parentId: parentId})
+	return nil
+}
+
+// flush makes the queued changes in batches, journaling those made, and
+// returns the errors of those that failed keyed by the ids of their files.
+// Those waiting on the changes to a file are then told of their outcome,
+// and then those waiting on all of the changes are run.
+func (pb *parentBatch) flush(r *Remote, j *journal) map[string]error {
+	pb.Lock()
+	defer pb.Unlock()
+
+	failures := make(map[string]error)
+
+	var calls []*batchCall
+	for _, change := range pb.inserts {
+		calls = append(calls, &batchCall{
+			method: "POST",
+			path:   fmt.Sprintf("files/%s/parents", url.QueryEscape(change.fileId)),
+			body:   map[string]string{"id": change.parentId},
+		})
+	}
+	for i, err := range r.batch(calls) {
+		change := pb.inserts[i]
+		if err != nil {
+			failures[change.fileId] = err
+			continue
+		}
+		j.record(PlanOpInsertParent, []string{change.fileId, change.parentId}, PlanOpRemoveParent, change.fileId, change.parentId)
+	}
+
+	calls = calls[:0]
+	var removes []*parentChange
+	for _, change := range pb.removes {
+		if _, failed := failures[change.fileId]; failed {
+			continue
+		}
+		removes = append(removes, change)
+		calls = append(calls, &batchCall{
+			method: "DELETE",
+			path:   fmt.Sprintf("files/%s/parents/%s", url.QueryEscape(change.fileId), url.QueryEscape(change.parentId)),
+		})
+	}
+	for i, err := range r.batch(calls) {
+		change := removes[i]
+		if err != nil {
+			failures[change.fileId] = err
+			continue
+		}
+		j.record(PlanOpRemoveParent, []string{change.fileId, change.parentId}, PlanOpInsertParent, change.fileId, change.parentId)
+	}
+
+	for fileId, fns := range pb.flushed {
+		for _, fn := range fns {
+			fn(failures[fileId])
+		}
+	}
+	for _, fn := range pb.afterFlush {
+		fn(failures)
+	}
+	pb.inserts, pb.removes, pb.flushed, pb.afterFlush = nil, nil, nil, nil
+	return failures
+}
@@ -0,0 +1,178 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// caseFoldClash is a set of items in a folder whose names only differ by
+// case. keep is the most recently modified of them, the one left as is.
+type caseFoldClash struct {
+	parentPath string
+	keep       *File
+	others     []*File
+	// newNames are the names that others get renamed to, if renaming
+	newNames []string
+}
+
+// CaseFold finds items in the folders in opts.Sources, and in their descendants
+// if opts.Recursive is set, whose names only differ by case and would hence
+// collide on case-insensitive filesystems. For each set of such items the most
+// recently modified keeps its name whereas the others are renamed apart, or
+// trashed if trash is set.
+func (g *Commands) CaseFold(trash bool) error {
+	var clashes []*caseFoldClash
+	var composedError error = nil
+
+	for _, folderPath := range g.opts.Sources {
+		folder, err := g.rem.FindByPath(folderPath)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("case-fold: %s: %v", folderPath, err))
+			continue
+		}
+		if folder == nil || !folder.IsDir {
+			composedError = reComposeError(composedError, fmt.Sprintf("case-fold: %s: %v", folderPath, ErrPathNotDir))
+			continue
+		}
+		clashes = append(clashes, g.findCaseFoldClashes(folder, folderPath)...)
+	}
+
+	if len(clashes) < 1 {
+		g.log.Logln("No names that only differ by case")
+		return composedError
+	}
+
+	action := "rename"
+	if trash {
+		action = "trash"
+	}
+
+	for _, clash := range clashes {
+		g.log.Logf("%s:\n  keep   %s\n", clash.parentPath, clash.keep.Name)
+		for i, other := range clash.others {
+			if trash {
+				g.log.Logf("  trash  %s\n", other.Name)
+			} else {
+				g.log.Logf("  rename %s -> %s\n", other.Name, clash.newNames[i])
+			}
+		}
+	}
+
+	// Nothing is changed when only planning, so there is nothing to confirm
+	if !g.opts.Force && g.plan == nil {
+		if !g.opts.canPrompt() {
+			message := fmt.Sprintf("case-fold: noPrompt is set, use `%s` to %s the items above", ForceKey, action)
+			return reComposeError(composedError, message)
+		}
+		if !promptForChanges() {
+			return composedError
+		}
+	}
+
+	for _, clash := range clashes {
+		for i, other := range clash.others {
+			otherPath := path.Join(clash.parentPath, other.Name)
+
+			var err error
+			if trash {
+				err = g.mut.Trash(other.Id)
+				g.audit(AuditTrash, other, otherPath, "", err)
+			} else {
+				err = g.rename(other, clash.parentPath, clash.newNames[i])
+			}
+
+			if err != nil {
+				message := fmt.Sprintf("case-fold: %s: %v", otherPath, err)
+				composedError = reComposeError(composedError, message)
+			}
+		}
+	}
+
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (g *Commands) findCaseFoldClashes(folder *File, folderPath string) (clashes []*caseFoldClash) {
+	var children, subFolders []*File
+	byFolded := make(map[string][]*File)
+	taken := make(map[string]bool)
+
+	for child := range g.rem.findChildren(folder.Id, false) {
+		folded := strings.ToLower(child.Name)
+		if _, seen := byFolded[folded]; !seen {
+			children = append(children, child)
+		}
+		byFolded[folded] = append(byFolded[folded], child)
+		taken[folded] = true
+		if child.IsDir {
+			subFolders = append(subFolders, child)
+		}
+	}
+
+	for _, first := range children {
+		group := byFolded[strings.ToLower(first.Name)]
+		if len(group) < 2 {
+			continue
+		}
+
+		keepIndex := 0
+		for i, f := range group {
+			if f.ModTime.After(group[keepIndex].ModTime) {
+				keepIndex = i
+			}
+		}
+
+		clash := &caseFoldClash{parentPath: folderPath, keep: group[keepIndex]}
+		for i, f := range group {
+			if i == keepIndex {
+				continue
+			}
+			clash.others = append(clash.others, f)
+			clash.newNames = append(clash.newNames, caseFoldFreeName(f.Name, taken))
+		}
+		clashes = append(clashes, clash)
+	}
+
+	if g.opts.Recursive {
+		for _, sub := range subFolders {
+			clashes = append(clashes, g.findCaseFoldClashes(sub, path.Join(folderPath, sub.Name))...)
+		}
+	}
+	return clashes
+}
+
+// caseFoldFreeName numbers name, before its extension, with the lowest number
+// that makes it clash with none of the taken case folded names, and takes it.
+func caseFoldFreeName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if folded := strings.ToLower(candidate); !taken[folded] {
+			taken[folded] = true
+			return candidate
+		}
+	}
+}
@@ -40,6 +40,10 @@ type dirList struct {
 }
 
 func (d *dirList) Name() string {
+	// A document converted on push is known by the name of its local file
+	if d.local != nil && d.remote != nil {
+		return d.local.Name
+	}
 	if d.remote != nil {
 		return d.remote.Name
 	}
@@ -213,6 +217,10 @@ type changeListResolve struct {
 	local  *File
 	remote *File
 	push   bool
+	// branch is the remote folders descended through to get to remote
+	branch *shortcutBranch
+	// walk is the real paths of the local folders descended through to get to local
+	walk *symlinkWalk
 }
 
 func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []*Change, err error) {
@@ -239,13 +247,35 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 	if anyMatch(g.opts.IgnoreRegexp, matchChecks...) {
 		return
 	}
+	if r != nil && r.IsDir {
+		if cycle := clr.branch.cycle(r); cycle != "" {
+			g.log.LogErrf("%s: not following the shortcut back around %s\n", base, cycle)
+			return
+		}
+	}
+	if g.opts.Ignores.Match(base, (l != nil && l.IsDir) || (r != nil && r.IsDir)) {
+		return
+	}
+	scope := g.syncScope(base, clr.push)
+	if scope == syncOut {
+		return
+	}
+	// Symlinks pushed to be preserved are left alone if skipping symlinks
+	if g.opts.Symlinks == SymlinksSkip && r != nil && r.Properties[SymlinkTargetKey] != "" {
+		return
+	}
+	// Preserved symlinks with the same targets are in sync, their times
+	// can't be set locally without changing those of their targets.
+	if l != nil && r != nil && l.SymlinkTarget != "" && r.Properties[SymlinkTargetKey] == l.SymlinkTarget {
+		return
+	}
 
 	explicitlyRequested := g.opts.ExplicitlyExport && hasExportLinks(r) && len(g.opts.Exports) >= 1
 
 	if clr.push {
 		// Handle the case of doc files for which we don't have a direct download
 		// url but have exportable links. These files should not be clobbered on push
-		if hasExportLinks(r) {
+		if hasExportLinks(r) && !(g.convertedFrom(l, r) && convertedStale(l, r)) {
 			return cl, clashes, nil
 		}
 		change = &Change{Path: base, Src: l, Dest: r, Parent: dir, g: g}
@@ -276,7 +306,8 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 		return cl, clashes, nil
 	}
 
-	if change.Op() != OpNone {
+	// Folders only on the way to those synced are left as they are
+	if change.Op() != OpNone && scope != syncThrough {
 		cl = append(cl, change)
 	}
 
@@ -294,11 +325,15 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 
 	// look-up for children
 	var localChildren chan *File
+	walk := clr.walk
 	if l == nil || !l.IsDir {
 		localChildren = make(chan *File)
 		close(localChildren)
 	} else {
-		localChildren, err = list(g.context, base, g.opts.Hidden, g.opts.IgnoreRegexp)
+		if realPath, evalErr := filepath.EvalSymlinks(g.context.AbsPathOf(base)); evalErr == nil {
+			walk = walk.descend(realPath)
+		}
+		localChildren, err = list(g.context, base, g.opts.Hidden, g.opts.IgnoreRegexp, g.opts.Symlinks, walk, g.log)
 		if err != nil {
 			return
 		}
@@ -312,6 +347,9 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 		close(remoteChildren)
 	}
 	dirlist, clashingFiles := merge(remoteChildren, localChildren, g.opts.IgnoreNameClashes)
+	if clr.push {
+		dirlist = g.pairConverted(dirlist)
+	}
 
 	if !g.opts.IgnoreNameClashes && len(clashingFiles) >= 1 {
 		if rootLike(base) {
@@ -345,13 +383,18 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 
 	clashesMap := make(map[int][]*Change)
 
+	branch := clr.branch
+	if r != nil {
+		branch = branch.descend(r)
+	}
+
 	for j := 0; j < chunkCount; j += 1 {
 		end := i + chunkSize
 		if end >= srcLen {
 			end = srcLen
 		}
 
-		go g.changeSlice(clashesMap, j, &wg, clr.push, &cl, base, dirlist[i:end])
+		go g.changeSlice(clashesMap, j, &wg, clr.push, &cl, base, branch, walk, dirlist[i:end])
 
 		i += chunkSize
 	}
@@ -368,7 +411,7 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 	return cl, clashes, err
 }
 
-func (g *Commands) changeSlice(clashesMap map[int][]*Change, id int, wg *sync.WaitGroup, push bool, cl *[]*Change, p string, dlist []*dirList) {
+func (g *Commands) changeSlice(clashesMap map[int][]*Change, id int, wg *sync.WaitGroup, push bool, cl *[]*Change, p string, branch *shortcutBranch, walk *symlinkWalk, dlist []*dirList) {
 	defer wg.Done()
 	for _, l := range dlist {
 		// Avoiding path.Join which normalizes '/+' to '/'
@@ -385,6 +428,8 @@ func (g *Commands) changeSlice(clashesMap map[int][]*Change, id int, wg *sync.Wa
 			base:   joined,
 			remote: l.remote,
 			local:  l.local,
+			branch: branch,
+			walk:   walk,
 		}
 
 		childChanges, childClashes, cErr := g.resolveChangeListRecv(clr)
@@ -456,16 +501,18 @@ func merge(remotes, locals chan *File, ignoreClashes bool) (merged []*dirList, c
 	return
 }
 
-func reduceToSize(changes []*Change, destMask destination) (srcSize, destSize int64) {
+// reduceToSize sums up the sizes of the sources and destinations of changes,
+// as per sizeOf if set.
+func reduceToSize(changes []*Change, destMask destination, sizeOf func(*File) (int64, bool)) (srcSize, destSize int64) {
 	fromSrc := (destMask & SelectSrc) != 0
 	fromDest := (destMask & SelectDest) != 0
 
 	for _, c := range changes {
 		if fromSrc && c.Src != nil {
-			srcSize += c.Src.Size
+			srcSize += sizeWith(sizeOf, c.Src)
 		}
 		if fromDest && c.Dest != nil {
-			destSize += c.Dest.Size
+			destSize += sizeWith(sizeOf, c.Dest)
 		}
 	}
 	return
@@ -558,7 +605,7 @@ func _warnChangeStopper(logy *log.Logger, items []*Change, perItemPrefix, fmt_ s
 	}
 }
 
-func opChangeCount(changes []*Change) map[Operation]sizeCounter {
+func opChangeCount(changes []*Change, sizeOf func(*File) (int64, bool)) map[Operation]sizeCounter {
 	opMap := map[Operation]sizeCounter{}
 
 	for _, c := range changes {
@@ -569,10 +616,10 @@ func opChangeCount(changes []*Change) map[Operation]sizeCounter {
 		counter := opMap[op]
 		counter.count += 1
 		if c.Src != nil && !c.Src.IsDir {
-			counter.src += c.Src.Size
+			counter.src += sizeWith(sizeOf, c.Src)
 		}
 		if c.Dest != nil && !c.Dest.IsDir {
-			counter.dest += c.Dest.Size
+			counter.dest += sizeWith(sizeOf, c.Dest)
 		}
 		opMap[op] = counter
 	}
@@ -585,6 +632,8 @@ type changeListArg struct {
 	changes   []*Change
 	noPrompt  bool
 	noClobber bool
+	// sizeOf if set sizes the files changed, else their reported sizes are used
+	sizeOf func(*File) (int64, bool)
 }
 
 func previewChanges(clArgs *changeListArg, reduce bool, opMap map[Operation]sizeCounter) {
@@ -618,7 +667,7 @@ func printChangeList(clArg *changeListArg) (bool, *map[Operation]sizeCounter) {
 		return true, nil
 	}
 
-	opMap := opChangeCount(clArg.changes)
+	opMap := opChangeCount(clArg.changes, clArg.sizeOf)
 	previewChanges(clArg, true, opMap)
 
 	return promptForChanges(), &opMap
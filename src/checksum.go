@@ -0,0 +1,174 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+)
+
+// verifyAttempts is the number of times that a file is transferred with
+// -verify before a mismatch of its checksums is given up on.
+const verifyAttempts = 3
+
+const (
+	checksumsSection  = "Checksums"
+	mismatchesSection = "Checksum mismatches"
+
+	checksumsVerified   = "verified"
+	checksumsMismatched = "mismatched"
+	checksumsUnverified = "unverified"
+)
+
+type checksumMismatchError struct {
+	local, remote string
+}
+
+func (e *checksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch: local %s, remote %s", e.local, e.remote)
+}
+
+func isChecksumMismatch(err error) bool {
+	_, ok := err.(*checksumMismatchError)
+	return ok
+}
+
+// localMd5 returns the md5 checksum of the content of the file at p.
+func localMd5(p string) (string, error) {
+	fh, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer fh.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// verifyChecksum compares the md5 checksum of the file at fsPath with that
+// of remote, returning a *checksumMismatchError if they differ. ok is false
+// if remote has no checksum to compare with e.g it is a Google Doc.
+func verifyChecksum(fsPath string, remote *File) (ok bool, err error) {
+	if remote == nil || remote.Md5Checksum == "" {
+		return false, nil
+	}
+
+	local, err := localMd5(fsPath)
+	if err != nil {
+		return false, err
+	}
+	if local != remote.Md5Checksum {
+		return true, &checksumMismatchError{local: local, remote: remote.Md5Checksum}
+	}
+	return true, nil
+}
+
+// startVerifying sets up the tally of verified transfers if -verify is set.
+func (g *Commands) startVerifying() {
+	if g.opts.Verify {
+		g.report = newReport()
+	}
+}
+
+// finishVerifying summarizes the verified transfers, returning an error
+// if any of them were given up on because of mismatched checksums.
+func (g *Commands) finishVerifying() error {
+	if !g.opts.Verify {
+		return nil
+	}
+
+	g.report.summarize(g.log)
+	if n := g.report.tally(checksumsSection, checksumsMismatched); n > 0 {
+		return fmt.Errorf("verify: %d file(s) don't match their checksums", n)
+	}
+	return nil
+}
+
+// verified tallies the outcome of verifying the transfer of relPath.
+func (g *Commands) verified(relPath string, ok bool, err error) {
+	switch {
+	case isChecksumMismatch(err):
+		g.report.count(checksumsSection, checksumsMismatched)
+		g.report.warn(mismatchesSection, "%s: %v", relPath, err)
+	case err == nil && ok:
+		g.report.count(checksumsSection, checksumsVerified)
+	case err == nil:
+		g.report.count(checksumsSection, checksumsUnverified)
+	}
+}
+
+// downloadVerified downloads the file described by dlArg and, with -verify,
+// checks its checksum against that of src, downloading it again on mismatch.
+func (g *Commands) downloadVerified(relPath string, dlArg *downloadArg, src *File) (err error) {
+	if !g.opts.Verify {
+		return g.singleDownload(dlArg)
+	}
+
+	ok := false
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		if err = g.singleDownload(dlArg); err != nil {
+			return err
+		}
+		if ok, err = verifyChecksum(dlArg.path, src); !isChecksumMismatch(err) {
+			break
+		}
+		if attempt < verifyAttempts {
+			g.log.LogErrf("%s: %v, downloading it again\n", relPath, err)
+		}
+		// The progress of the first attempt is all that is tracked
+		dlArg.ackByteProgress = false
+	}
+
+	g.verified(relPath, ok, err)
+	return err
+}
+
+// upsertVerified uploads the file described by args and, with -verify,
+// checks the checksum of the upload against that of the local file,
+// uploading its content again on mismatch.
+func (g *Commands) upsertVerified(relPath string, args *upsertOpt) (rem *File, err error) {
+	rem, err = g.rem.UpsertByComparison(args)
+	// Preserved symlinks are uploaded with their targets as their content, and
+	// compressed files with content whose checksum isn't known locally. That of
+	// encrypted files is the one sealed in their properties, opened with the key
+	if !g.opts.Verify || err != nil || rem == nil || rem.IsDir || args.src.SymlinkTarget != "" ||
+		(args.compress && g.rem.crypt == nil) {
+		return rem, err
+	}
+
+	ok := false
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		if ok, err = verifyChecksum(args.fsAbsPath, rem); !isChecksumMismatch(err) || attempt == verifyAttempts {
+			break
+		}
+		g.log.LogErrf("%s: %v, uploading it again\n", relPath, err)
+
+		// Replace the content of the upload, whatever it has
+		args.src.Id = rem.Id
+		args.dest = rem
+		args.nonStatable = true
+		if rem, err = g.rem.UpsertByComparison(args); err != nil {
+			return rem, err
+		}
+	}
+
+	g.verified(relPath, ok, err)
+	return rem, err
+}
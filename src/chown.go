@@ -0,0 +1,201 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// consentRequiredReason is the reason Drive fails direct ownership transfers
+	// with when the new owner has to accept them, e.g across consumer accounts.
+	consentRequiredReason = "consentRequiredForOwnershipTransfer"
+)
+
+// patchPermission patches the permission permId of the file with fileId to
+// fields, as is, since false values can't be sent via Permissions.Patch.
+func (r *Remote) patchPermission(fileId, permId string, params url.Values, fields map[string]interface{}) error {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	uri := r.service.BasePath + "files/" + url.QueryEscape(fileId) + "/permissions/" + url.QueryEscape(permId)
+	if len(params) >= 1 {
+		uri += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("PATCH", uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return googleapi.CheckResponse(resp)
+}
+
+func consentRequired(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == consentRequiredReason {
+			return true
+		}
+	}
+	return false
+}
+
+// transferOwnership makes email the owner of the file with fileId. Where
+// Drive requires the new owner's consent, email is made the pending owner
+// instead and pending is set; the transfer completes once they accept it.
+func (r *Remote) transferOwnership(fileId, email string) (pending bool, err error) {
+	perms, err := r.listPermissions(fileId)
+	if err != nil {
+		return false, err
+	}
+
+	permId := ""
+	for _, perm := range perms {
+		if strings.EqualFold(perm.EmailAddress, email) {
+			if perm.Role == "owner" {
+				return false, nil
+			}
+			permId = perm.Id
+			break
+		}
+	}
+	if permId == "" {
+		// The new owner first needs access, which they are given quietly
+		perm, err := r.insertPermissions(&permission{
+			fileId:      fileId,
+			value:       email,
+			role:        Writer,
+			accountType: User,
+		})
+		if err != nil {
+			return false, err
+		}
+		permId = perm.Id
+	}
+
+	params := url.Values{}
+	params.Set("transferOwnership", "true")
+	err = r.patchPermission(fileId, permId, params, map[string]interface{}{"role": "owner"})
+	if err == nil || !consentRequired(err) {
+		return false, err
+	}
+
+	err = r.patchPermission(fileId, permId, nil, map[string]interface{}{
+		"role":         "writer",
+		"pendingOwner": true,
+	})
+	return err == nil, err
+}
+
+// Chown transfers the ownership of the items in opts.Sources, and of their
+// descendants if opts.Recursive is set, to the user with email. Transfers
+// that need the new owner's consent are left pending and reported as such.
+func (g *Commands) Chown(email string, byId bool) error {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return fmt.Errorf("chown: expected the email of the new owner, instead got: %q", email)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var files []*File
+	var paths []string
+	var composedError error = nil
+
+	var add func(f *File, p string)
+	add = func(f *File, p string) {
+		files = append(files, f)
+		paths = append(paths, p)
+		if !f.IsDir || !g.opts.Recursive {
+			return
+		}
+		for child := range g.rem.findChildren(f.Id, false) {
+			add(child, path.Join(p, child.Name))
+		}
+	}
+
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("chown: %s: %v", p, err))
+			continue
+		}
+		relToRootPath := p
+		if byId {
+			relToRootPath = filepath.Join(g.opts.Path, f.Name)
+		}
+		add(f, relToRootPath)
+	}
+
+	if len(files) < 1 {
+		return composedError
+	}
+
+	g.log.Logf("Transfer ownership to %s of:\n", email)
+	for _, p := range paths {
+		g.log.Logf("  %s\n", p)
+	}
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			message := fmt.Sprintf("chown: noPrompt is set, use `%s` to transfer the ownership of the items above", ForceKey)
+			return reComposeError(composedError, message)
+		}
+		if !promptForChanges() {
+			return composedError
+		}
+	}
+
+	g.report = newReport()
+	for i, f := range files {
+		pending, err := g.rem.transferOwnership(f.Id, email)
+		switch {
+		case err != nil:
+			g.report.warn("Failed transfers", "%s: %v", paths[i], err)
+			composedError = reComposeError(composedError, fmt.Sprintf("chown: %s: %v", paths[i], err))
+		case pending:
+			g.report.note("Awaiting acceptance by "+email, "%s", paths[i])
+		default:
+			g.report.count("Transferred", g.parentPather(paths[i]))
+		}
+	}
+	g.report.summarize(g.log)
+	return composedError
+}
@@ -0,0 +1,251 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	ClashFixRename     = "rename"
+	ClashFixTrashOlder = "trash-older"
+	ClashFixMerge      = "merge"
+)
+
+var clashFixStrategies = []string{ClashFixRename, ClashFixTrashOlder, ClashFixMerge}
+
+// nameClash is a set of items in a folder that have the same name. keep is
+// the most recently modified of them, the one that fixing leaves as is.
+type nameClash struct {
+	parentPath string
+	keep       *File
+	others     []*File
+	// newNames are the names that others get renamed to, if renaming
+	newNames []string
+}
+
+func (nc *nameClash) path() string {
+	return path.Join(nc.parentPath, nc.keep.Name)
+}
+
+// allDirs tells whether all the items in the clash are folders.
+func (nc *nameClash) allDirs() bool {
+	if !nc.keep.IsDir {
+		return false
+	}
+	for _, other := range nc.others {
+		if !other.IsDir {
+			return false
+		}
+	}
+	return true
+}
+
+func validClashFixStrategy(strategy string) bool {
+	for _, s := range clashFixStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// Clashes lists the items in the folders in opts.Sources, and in their
+// descendants if opts.Recursive is set, that share their names with siblings
+// and thus can't be told apart by path. If fix is set, each set of such items
+// is then resolved with strategy, the most recently modified keeping its name:
+// the others are renamed apart, trashed, or if they are all folders, merged
+// into the kept one.
+func (g *Commands) Clashes(fix bool, strategy string) error {
+	if fix && !validClashFixStrategy(strategy) {
+		return fmt.Errorf("clashes: unknown strategy %q, expecting one of %s", strategy, sepJoin(", ", clashFixStrategies...))
+	}
+
+	var clashes []*nameClash
+	var composedError error = nil
+
+	for _, folderPath := range g.opts.Sources {
+		folder, err := g.rem.FindByPath(folderPath)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("clashes: %s: %v", folderPath, err))
+			continue
+		}
+		if folder == nil || !folder.IsDir {
+			composedError = reComposeError(composedError, fmt.Sprintf("clashes: %s: %v", folderPath, ErrPathNotDir))
+			continue
+		}
+		clashes = append(clashes, g.findNameClashes(folder, folderPath, g.opts.Recursive)...)
+	}
+
+	if len(clashes) < 1 {
+		g.log.Logln("No clashing names")
+		return composedError
+	}
+
+	for _, clash := range clashes {
+		g.logNameClash(clash)
+	}
+
+	if !fix {
+		return composedError
+	}
+
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			message := fmt.Sprintf("clashes: noPrompt is set, use `%s` to fix the clashes above", ForceKey)
+			return reComposeError(composedError, message)
+		}
+		if !promptForChanges(fmt.Sprintf("Fix the clashes above with %s? [Y/n]:", strategy)) {
+			return composedError
+		}
+	}
+
+	for _, clash := range clashes {
+		if err := g.fixNameClash(clash, strategy); err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) logNameClash(clash *nameClash) {
+	g.log.Logf("\n%s x %d\n", clash.path(), len(clash.others)+1)
+	for _, f := range append([]*File{clash.keep}, clash.others...) {
+		kind := "file"
+		if f.IsDir {
+			kind = "folder"
+		}
+		g.log.Logf("  %-6s %s %v %s\n", kind, f.Id, f.ModTime, prettyBytes(f.Size))
+	}
+}
+
+// findNameClashes finds the sets of children of folder that have the same name.
+func (g *Commands) findNameClashes(folder *File, folderPath string, recursive bool) (clashes []*nameClash) {
+	var children, subFolders []*File
+	byName := make(map[string][]*File)
+	// Names are taken case insensitively so that renamed items
+	// don't go on to clash on case-insensitive filesystems.
+	taken := make(map[string]bool)
+
+	for child := range g.rem.findChildren(folder.Id, false) {
+		if _, seen := byName[child.Name]; !seen {
+			children = append(children, child)
+		}
+		byName[child.Name] = append(byName[child.Name], child)
+		taken[strings.ToLower(child.Name)] = true
+		if child.IsDir {
+			subFolders = append(subFolders, child)
+		}
+	}
+
+	for _, first := range children {
+		group := byName[first.Name]
+		if len(group) < 2 {
+			continue
+		}
+
+		keepIndex := 0
+		for i, f := range group {
+			if f.ModTime.After(group[keepIndex].ModTime) {
+				keepIndex = i
+			}
+		}
+
+		clash := &nameClash{parentPath: folderPath, keep: group[keepIndex]}
+		for i, f := range group {
+			if i == keepIndex {
+				continue
+			}
+			clash.others = append(clash.others, f)
+			clash.newNames = append(clash.newNames, caseFoldFreeName(f.Name, taken))
+		}
+		clashes = append(clashes, clash)
+	}
+
+	if recursive {
+		for _, sub := range subFolders {
+			clashes = append(clashes, g.findNameClashes(sub, path.Join(folderPath, sub.Name), recursive)...)
+		}
+	}
+	return clashes
+}
+
+// fixNameClash resolves clash with strategy. Clashes of files can't be
+// merged, so they are renamed apart instead.
+func (g *Commands) fixNameClash(clash *nameClash, strategy string) error {
+	if strategy == ClashFixMerge {
+		if clash.allDirs() {
+			return g.mergeNameClash(clash)
+		}
+		g.log.LogErrf("%s: only folders can be merged, renaming instead\n", clash.path())
+		strategy = ClashFixRename
+	}
+
+	var composedError error = nil
+	for i, other := range clash.others {
+		var err error
+		if strategy == ClashFixTrashOlder {
+			err = g.mut.Trash(other.Id)
+			g.audit(AuditTrash, other, clash.path(), "", err)
+		} else {
+			err = g.rename(other, clash.parentPath, clash.newNames[i])
+		}
+
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("clashes: %s (%s): %v", clash.path(), other.Id, err))
+		}
+	}
+	return composedError
+}
+
+// mergeNameClash moves the children of the other folders into the kept one,
+// trashing each of them once emptied. Since the children moved in can clash
+// with those already there, the kept folder's clashes are then merged too.
+func (g *Commands) mergeNameClash(clash *nameClash) error {
+	var composedError error = nil
+
+	for _, other := range clash.others {
+		emptied := true
+		for child := range g.rem.findChildren(other.Id, false) {
+			err := g.mut.insertParent(child.Id, clash.keep.Id)
+			if err == nil {
+				err = g.mut.removeParent(child.Id, other.Id)
+			}
+			if err != nil {
+				emptied = false
+				message := fmt.Sprintf("clashes: merging %s (%s): %s: %v", clash.path(), other.Id, child.Name, err)
+				composedError = reComposeError(composedError, message)
+			}
+		}
+
+		if !emptied {
+			continue
+		}
+		err := g.mut.Trash(other.Id)
+		g.audit(AuditTrash, other, clash.path(), "", err)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("clashes: %s (%s): %v", clash.path(), other.Id, err))
+		}
+	}
+
+	for _, inner := range g.findNameClashes(clash.keep, clash.path(), false) {
+		if err := g.fixNameClash(inner, ClashFixMerge); err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+	return composedError
+}
@@ -0,0 +1,217 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	ConflictSkip     = "skip"
+	ConflictRename   = "rename"
+	ConflictKeepBoth = "keep-both"
+)
+
+// collectSt is the state of a collection into a single folder.
+type collectSt struct {
+	dest     *File
+	destPath string
+	rule     *regexp.Regexp
+	// names are the names taken in dest, case folded
+	names map[string]bool
+	// md5s are the checksums of the content already in dest
+	md5s map[string]string
+}
+
+// Collect gathers the files whose names match rule from the source folders,
+// which may be shell patterns e.g "reports/*", into the folder that is the last
+// of opts.Sources. Files whose content is already in dest are left in place as
+// duplicates, whereas name clashes are resolved as per opts.ConflictPolicy.
+// Items are moved, so an interrupted Collect resumes when run again.
+func (g *Commands) Collect(rule string) error {
+	argc := len(g.opts.Sources)
+	if argc < 2 {
+		return fmt.Errorf("collect: expected <src> [src...] <dest>, instead got: %v", g.opts.Sources)
+	}
+
+	switch g.opts.ConflictPolicy {
+	case "", ConflictSkip, ConflictRename, ConflictKeepBoth:
+	default:
+		return fmt.Errorf("collect: unknown conflict policy %q, expecting %s, %s or %s",
+			g.opts.ConflictPolicy, ConflictSkip, ConflictRename, ConflictKeepBoth)
+	}
+
+	ruleRegexp, err := regexp.Compile(rule)
+	if err != nil {
+		return fmt.Errorf("collect: rule: %v", err)
+	}
+
+	sources, destPath := g.opts.Sources[:argc-1], g.opts.Sources[argc-1]
+	dest, err := g.remoteMkdirAll(destPath)
+	if err != nil {
+		return fmt.Errorf("collect: dest: %s: %v", destPath, err)
+	}
+
+	st := &collectSt{
+		dest:     dest,
+		destPath: destPath,
+		rule:     ruleRegexp,
+		names:    make(map[string]bool),
+		md5s:     make(map[string]string),
+	}
+	if !isPlaceholder(dest.Id) {
+		for child := range g.rem.findChildren(dest.Id, false) {
+			st.take(child, path.Join(destPath, child.Name))
+		}
+	}
+
+	g.report = newReport()
+	var composedError error = nil
+
+	for _, pattern := range sources {
+		srcPaths, err := g.expandGlob(pattern)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("collect: %v", err))
+			continue
+		}
+
+		for _, srcPath := range srcPaths {
+			if strings.HasPrefix(destPath+"/", srcPath+"/") {
+				message := fmt.Sprintf("collect: %s cannot be collected into its descendant %s", srcPath, destPath)
+				composedError = reComposeError(composedError, message)
+				continue
+			}
+			if err := g.collectFrom(st, srcPath); err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("collect: %s: %v", srcPath, err))
+			}
+		}
+	}
+
+	g.report.summarize(g.log)
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (st *collectSt) take(f *File, p string) {
+	st.names[strings.ToLower(f.Name)] = true
+	if f.Md5Checksum != "" {
+		st.md5s[f.Md5Checksum] = p
+	}
+}
+
+func (g *Commands) collectFrom(st *collectSt, srcPath string) error {
+	src, err := g.rem.FindByPath(srcPath)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return ErrPathNotExists
+	}
+
+	if !src.IsDir {
+		parent, err := g.rem.FindByPath(g.parentPather(srcPath))
+		if err != nil {
+			return err
+		}
+		return g.collectFile(st, src, srcPath, parent)
+	}
+
+	return g.collectFolder(st, src, srcPath)
+}
+
+func (g *Commands) collectFolder(st *collectSt, folder *File, folderPath string) error {
+	if folder.Id == st.dest.Id {
+		return nil
+	}
+
+	var children []*File
+	for child := range g.rem.findChildren(folder.Id, false) {
+		children = append(children, child)
+	}
+
+	var composedError error = nil
+	for _, child := range children {
+		childPath := path.Join(folderPath, child.Name)
+
+		var err error
+		switch {
+		case child.IsDir && g.opts.Recursive:
+			err = g.collectFolder(st, child, childPath)
+		case !child.IsDir:
+			err = g.collectFile(st, child, childPath, folder)
+		}
+
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", childPath, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) collectFile(st *collectSt, f *File, srcPath string, parent *File) (err error) {
+	if !st.rule.MatchString(f.Name) {
+		return nil
+	}
+	if parent.Id == st.dest.Id {
+		return nil
+	}
+
+	if existing, dup := st.md5s[f.Md5Checksum]; dup && f.Md5Checksum != "" {
+		g.report.note("Duplicates left in place", "%s is the same as %s", srcPath, existing)
+		return nil
+	}
+
+	name := f.Name
+	if st.names[strings.ToLower(name)] {
+		switch g.opts.ConflictPolicy {
+		case ConflictRename:
+			name = caseFoldFreeName(name, st.names)
+		case ConflictKeepBoth:
+		default:
+			g.report.warn("Name clashes left in place", "%s", srcPath)
+			return nil
+		}
+	}
+
+	defer func() {
+		g.audit(AuditMove, f, srcPath, st.destPath, err)
+	}()
+
+	g.plan.knowPath(f.Id, srcPath)
+	g.plan.knowPath(parent.Id, g.parentPather(srcPath))
+	g.plan.knowPath(st.dest.Id, st.destPath)
+
+	if err = g.mut.insertParent(f.Id, st.dest.Id); err != nil {
+		return err
+	}
+	if err = g.mut.removeParent(f.Id, parent.Id); err != nil {
+		return err
+	}
+	if name != f.Name {
+		if _, err = g.mut.rename(f.Id, name); err != nil {
+			return err
+		}
+		g.report.note("Renamed apart", "%s as %s", srcPath, name)
+	}
+
+	st.take(&File{Name: name, Md5Checksum: f.Md5Checksum}, path.Join(st.destPath, name))
+	g.report.count("Collected", g.parentPather(srcPath))
+	return nil
+}
@@ -15,17 +15,21 @@
 package drive
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/cheggaaa/pb"
 	"github.com/mattn/go-isatty"
 	expirable "github.com/odeke-em/cache"
 	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/drive/ignore"
 	"github.com/odeke-em/log"
 )
 
@@ -35,6 +39,10 @@ var (
 
 const (
 	DriveIgnoreSuffix = ".driveignore"
+	// DriveIgnoreGitignoreSyntax as the first line of the .driveignore in the root
+	// makes it take gitignore patterns instead of regular expressions. Those
+	// in other folders always take gitignore patterns.
+	DriveIgnoreGitignoreSyntax = "# syntax: gitignore"
 )
 
 type Options struct {
@@ -46,11 +54,17 @@ type Options struct {
 	// ExportsDir is the directory to put the exported Google Docs + Sheets.
 	// If not provided, will export them to the same dir as the source files are
 	ExportsDir string
+	// ExportLayout is how exports are laid out, in ExportsDir or beside the
+	// documents: "nested", "flat" or "by-format". See ExportLayoutNested.
+	ExportLayout string
 	// Force once set always converts NoChange into an Addition
 	Force bool
 	// Hidden discovers hidden paths if set
 	Hidden       bool
 	IgnoreRegexp *regexp.Regexp
+	// Ignores matches the paths ignored as per the gitignore
+	// style patterns of the .driveignore files at any level.
+	Ignores *ignore.Matcher
 	// IgnoreChecksum when set avoids the step
 	// of comparing checksums as a final check.
 	IgnoreChecksum bool
@@ -76,6 +90,12 @@ type Options struct {
 	TypeMask int
 	// Piped when set means to infer content to or from stdin
 	Piped bool
+	// JSON when set emits the results, a line of JSON per item, to
+	// stdout for scripts to consume and sends the logs to stderr.
+	JSON bool
+	// NoPathIndex when set resolves every path from the API
+	// instead of from the ids cached in the path index.
+	NoPathIndex bool
 	// Quiet when set toggles only logging of errors to stderrs as
 	// well as reading from stdin in this case stdout is not logged to
 	Quiet             bool
@@ -86,6 +106,217 @@ type Options struct {
 	Md5sum            bool
 	indexingOnly      bool
 	Verbose           bool
+	// PreserveIndexableText when set touches copies of OCR-able files
+	// e.g images and PDFs, to nudge Drive into re-indexing their text.
+	PreserveIndexableText bool
+	// Partition routes copied files into subfolders of their destination
+	// derived from each file e.g by "alpha", "date" or a template like "{year}/{month}".
+	Partition string
+	// MaxChildren is the number of children that a destination folder of
+	// a move or copy is allowed to end up with. A non-positive value turns off the check.
+	MaxChildren int
+	// DedupeIdentical when set makes copy link files whose content is
+	// identical to an already made copy, instead of copying them again.
+	DedupeIdentical bool
+	// OlderThan and NewerThan bound the modification times of the sources
+	// to operate on, either as ages e.g "1y", "30d" or as timestamps e.g "2015-06-30".
+	OlderThan string
+	NewerThan string
+	// DestResolver if set is consulted by Copy and Move to compute the
+	// destination of each source, overriding the destination given in Sources.
+	// An empty result keeps that static destination.
+	DestResolver func(src *File, srcPath string) (destPath string)
+	// PruneEmptyDirs when set trashes the folders of a copy that end up empty.
+	// By default empty source folders are preserved as empty folders.
+	PruneEmptyDirs bool
+	// PollInterval is how often long running watches check for changes
+	PollInterval time.Duration
+	// Debounce is how long push -watch waits for the local tree to be
+	// left alone before pushing the changes made to it.
+	Debounce time.Duration
+	// Revision if set makes Pull fetch this revision of the sources,
+	// beside their local copies, instead of their current content.
+	Revision string
+	// FollowShortcuts when set makes paths and listings resolve shortcuts
+	// to the items they point to, so that these are pulled, listed and copied.
+	FollowShortcuts bool
+	// WithLink when set makes Share grant access only to those that have
+	// the link and Unshare only revoke such access.
+	WithLink bool
+	// UniqueNames when set names each copy after its source's name suffixed
+	// with the source's id, so that copies never clash with each other.
+	UniqueNames bool
+	// DedupeStrategy if set makes Dedupe resolve every cluster of
+	// duplicates with it, e.g keeping the newest and trashing the others.
+	DedupeStrategy string
+	// DedupeInteractive when set makes Dedupe prompt for the
+	// strategy to resolve each cluster of duplicates with.
+	DedupeInteractive bool
+	// Verify when set makes Pull and Push check the md5 checksum of each
+	// transferred file against that on Drive, transferring it again on mismatch.
+	Verify bool
+	// Properties are the public properties that Push stamps
+	// each pushed file with e.g the host it was pushed from.
+	Properties map[string]string
+	// OCRLanguage is the ISO 639-1 code of the language
+	// that Push has Drive OCR images and PDFs in e.g "en".
+	OCRLanguage string
+	// Symlinks is how push and pull treat symlinks,
+	// SymlinksFollow if unset, SymlinksSkip or SymlinksPreserve.
+	Symlinks string
+	// PreserveMask are the local attributes of files that push keeps
+	// and pull restores, PreserveMode and PreserveXattr on top of
+	// the modification times that are always kept.
+	PreserveMask int
+	// Encrypt when set makes Push encrypt the names and content
+	// of files, and Decrypt makes Pull decrypt those it encrypted.
+	Encrypt bool
+	Decrypt bool
+	// EncryptionKeyPath is the file the encryption key is
+	// kept in, that in the .gd folder of the context if unset.
+	EncryptionKeyPath string
+	// Gzip when set makes Push compress the content of files
+	// that aren't already compressed as it uploads them.
+	Gzip bool
+	// Breadcrumb when set makes Move record the original path
+	// of each moved file in its private "originalPath" property.
+	Breadcrumb bool
+	// StatePath is the file that long running operations checkpoint
+	// their progress to, so that they can be resumed if interrupted.
+	StatePath string
+	// AuditLogPath if set is the file that a line of JSON is appended to for
+	// every copy, move, rename and trash made. With AuditLogRotate set, an
+	// existing audit log is set aside so that each run gets a fresh one.
+	AuditLogPath   string
+	AuditLogRotate bool
+	// DestRoot if set is the path or id of the folder that Move, Copy and
+	// Rename resolve relative paths under. Paths with a leading "/" bypass it.
+	DestRoot string
+	// WaitReady when set makes Copy wait, for at most ReadyTimeout, for
+	// Drive to be done processing each copy e.g transcoding videos.
+	WaitReady    bool
+	ReadyTimeout time.Duration
+	// PreserveLinkSharing when set gives each copy the same "anyone with the
+	// link" access as its source. Sharing with specific users isn't carried over.
+	PreserveLinkSharing bool
+	// PreserveFolderMetadata when set makes Copy give the folders that it
+	// creates the description and starred state of their source folders.
+	PreserveFolderMetadata bool
+	// PruneAfterMove when set makes Move trash the folders that sources were
+	// moved out of, and their ancestors, that were left empty.
+	PruneAfterMove bool
+	// CopyWorkers is the most children of folders that Copy copies at a time.
+	CopyWorkers int
+	// NativeSizePolicy is how Google native files, which report no size,
+	// count towards size filters, totals, estimates and orderings: "exclude",
+	// "nominal=SIZE" or "export". They are excluded by default.
+	NativeSizePolicy string
+	// DownloadWorkers is the most ranges of a large file that Pull downloads at a time.
+	DownloadWorkers int
+	// DownloadChunkSize is the size of the ranges that large files are downloaded in.
+	DownloadChunkSize int64
+	// UploadRateLimit and DownloadRateLimit are the most bytes per second that
+	// transfers, together, go at in either direction. 0 doesn't limit them.
+	UploadRateLimit   int64
+	DownloadRateLimit int64
+	// DeviceAuth when set makes Init authorize through the OAuth device flow,
+	// with a code entered on another device, for machines without a browser.
+	DeviceAuth bool
+	// ServiceAccountKeyPath is the JSON key of the service account that Init
+	// sets up authentication as, instead of a user via the OAuth flow.
+	ServiceAccountKeyPath string
+	// ServiceAccountSubject is the user that the service account impersonates,
+	// which needs domain-wide delegation. The service account itself if unset.
+	ServiceAccountSubject string
+	// BatchMoves when set makes Move change the parents of the items moved in
+	// batches of up to MaxBatchSize, once all the moves are otherwise done.
+	BatchMoves bool
+	// DryRun when set makes Move, Copy and Rename only plan their operations,
+	// like with PlanPath, and print them with the paths and ids involved.
+	DryRun bool
+	// TreeDiff when set makes Move and Copy only plan their operations, like
+	// with PlanPath, and log the trees affected as they are and would be
+	// after, up to TreeDiffDepth levels down.
+	TreeDiff      bool
+	TreeDiffDepth int
+	// EstimateCost when set makes Move log the estimated number of API calls
+	// that it will make, by type, and ask for them to be confirmed.
+	EstimateCost bool
+	// SnapshotPath if set makes Copy incremental, only copying the files added
+	// or changed since the last copy as per the snapshot kept at this path.
+	SnapshotPath string
+	// SnapshotDeletions when set makes Copy trash the copies of the files
+	// that were removed from the sources since the last copy.
+	SnapshotDeletions bool
+	// RetryFailedAtEnd is how many times Move retries the sources that failed
+	// to move, once all the others have been moved. 0 turns it off.
+	RetryFailedAtEnd int
+	// Prefetch when set makes Copy list the source trees, PrefetchWorkers
+	// folders at a time, before copying starts.
+	Prefetch        bool
+	PrefetchWorkers int
+	// DestTemplate if set is the path, relative to the destination, that Copy
+	// routes each file to e.g "{year}/{month}/{name}" instead of mirroring
+	// the source's folders.
+	DestTemplate string
+	// CheckExtensions when set makes Copy report files whose name extensions
+	// don't match the type of their content e.g a .pdf that is a PNG image.
+	CheckExtensions bool
+	// StrictExtensions when set makes Copy also not copy such files.
+	StrictExtensions bool
+	// StarIf if set is the rule that sources have to match for their copies to
+	// be starred e.g "size>10MB,name~\.pdf$".
+	StarIf string
+	// VerifyCopies when set makes Copy read back every copy once done, check it
+	// against its source and report a reconciliation of what was copied.
+	VerifyCopies bool
+	// VerifyReportPath if set is where the reconciliation is written as JSON.
+	VerifyReportPath string
+	// MaxPathLength if set is the most characters that the full path of an
+	// item renamed or moved, or of any of its descendants, can end up with.
+	MaxPathLength int
+	// Backlink when set makes Copy record the id of each copy as a property
+	// on its source and the id of the source as a property on the copy.
+	Backlink bool
+	// Pausable when set lets Copy be paused and resumed by signalling the process.
+	Pausable bool
+	// DestPick is which of many same-named folders at a destination path
+	// Move and Copy go into, either "newest" or "oldest" by creation time.
+	DestPick string
+	// PreserveRestrictions when set makes Copy apply the download and sharing
+	// restrictions of each source to its copy.
+	PreserveRestrictions bool
+	// TrashSourceAfter if set makes Copy tag the source of each verified copy
+	// to be trashed by Reap once this grace period is over.
+	TrashSourceAfter time.Duration
+	// CheckWritable when set makes Move and Copy check that items can be
+	// added to the destination before starting, instead of failing midway.
+	CheckWritable bool
+	// IncludeShared when set makes Copy get as complete a copy as it can of
+	// folders with items shared by others, reuploading the content of files
+	// that can't be copied and skipping those that can't be read.
+	IncludeShared bool
+	// ThrottleOnLowQuota if set is the free storage, either a size e.g "5GB" or
+	// a percentage of the quota e.g "5%", below which copies are slowed down.
+	ThrottleOnLowQuota string
+	// ConflictPolicy is how items whose names clash at the
+	// destination are dealt with e.g "skip", "rename", "keep-both".
+	ConflictPolicy string
+	// ModConflictPolicy is how push and pull settle files changed both locally
+	// and remotely since they were last synced e.g "fail", "local-wins",
+	// "remote-wins" or "keep-both", which keeps the destination's version
+	// alongside under a conflict name.
+	ModConflictPolicy string
+	// Merge when set makes Move merge a folder into a same-named folder at
+	// its destination, moving its children over, instead of failing on the clash.
+	Merge bool
+	// PlanPath if set makes Move, Copy and Rename write out the operations
+	// they would make to this file, or stdout if "-", instead of making them.
+	PlanPath string
+	// MaxFileSize is the size in bytes above which source files are not
+	// copied, either skipped or failed on as per OversizePolicy. 0 turns it off.
+	MaxFileSize    int64
+	OversizePolicy string
 }
 
 type Commands struct {
@@ -94,21 +325,50 @@ type Commands struct {
 	opts    *Options
 	log     *log.Logger
 
-	progress      *pb.ProgressBar
+	progress      *transferProgress
 	mkdirAllCache *expirable.OperationCache
+	report        *report
+	partitioner   partitioner
+	copyDedupe    *copyDedupe
+	auditLog      *auditLog
+	quotaGovernor *quotaGovernor
+	pauseGate     *pauseGate
+	copyLedger    *copyLedger
+	starRule      starRule
+	destTemplate  *destTemplate
+	treeIndex     *treeIndex
+	copySnapshot  *copySnapshot
+	nativeSizer   *nativeSizer
+	copyWorkers   workerSlots
+	readyWatcher  *readyWatcher
+	journal       *journal
+	emitter       *emitter
+	// conflictCopies are the versions to set aside before the changes are played
+	conflictCopies []*conflictCopy
+	// conversions are the Google Docs types, by extension,
+	// that push -convert converts local files to
+	conversions map[string]string
+	// syncRules limit what pull and push mirror, nil if they mirror everything
+	syncRules *syncRules
+	// mut makes the remote mutations, it is the plan if only planning
+	mut  mutator
+	plan *plan
 }
 
 func (opts *Options) canPrompt() bool {
 	if opts == nil || !opts.StdoutIsTty {
 		return false
 	}
-	if opts.Quiet {
+	// Prompts would be mixed in with the results being emitted
+	if opts.Quiet || opts.JSON {
 		return false
 	}
 	return !opts.NoPrompt
 }
 
-func New(context *config.Context, opts *Options) *Commands {
+// New sets up the commands for the drive of context. It fails if the audit
+// log asked for can't be opened, as what is done would then go unaudited.
+func New(context *config.Context, opts *Options) (*Commands, error) {
 	var r *Remote
 	if context != nil {
 		r = NewRemoteContext(context)
@@ -116,7 +376,16 @@ func New(context *config.Context, opts *Options) *Commands {
 
 	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
 
-	logger := log.New(stdin, stdout, stderr)
+	// In quiet mode only errors are logged, a nil writer
+	// turns the informational stdout logging into a no-op.
+	var logOut io.Writer = stdout
+	if opts != nil && opts.Quiet {
+		logOut = nil
+	} else if opts != nil && opts.JSON {
+		logOut = stderr
+	}
+
+	logger := log.New(stdin, logOut, stderr)
 
 	if opts != nil {
 		// should always start with /
@@ -124,35 +393,116 @@ func New(context *config.Context, opts *Options) *Commands {
 
 		if !opts.Force {
 			ignoresPath := filepath.Join(context.AbsPath, DriveIgnoreSuffix)
-			ignoreRegexp, regErr := combineIgnores(ignoresPath)
+			gitignoreSyntax := hasGitignoreSyntax(ignoresPath)
+			ignoreRegexp, regErr := combineIgnores(ignoresPath, gitignoreSyntax)
 
 			if regErr != nil {
 				logger.LogErrf("combining ignores from path %s and internally: %v\n", ignoresPath, regErr)
 			}
 
 			opts.IgnoreRegexp = ignoreRegexp
+
+			opts.Ignores = ignore.New(context.AbsPath, DriveIgnoreSuffix)
+			opts.Ignores.SkipRootFile = !gitignoreSyntax
+			opts.Ignores.OnError = func(err error) {
+				logger.LogErrf("ignores: %v\n", err)
+			}
 		}
 
 		opts.StdoutIsTty = isatty.IsTerminal(stdout.Fd())
+	}
 
-		if opts.Quiet {
-			stdout = nil
+	var auditor *auditLog
+	if opts != nil && opts.AuditLogPath != "" {
+		var auditErr error
+		if auditor, auditErr = openAuditLog(opts.AuditLogPath, opts.AuditLogRotate); auditErr != nil {
+			return nil, fmt.Errorf("audit log %s: %v", opts.AuditLogPath, auditErr)
 		}
 	}
 
-	return &Commands{
+	g := &Commands{
 		context:       context,
 		rem:           r,
 		opts:          opts,
 		log:           logger,
 		mkdirAllCache: expirable.New(),
+		auditLog:      auditor,
+		mut:           r,
 	}
+
+	if opts != nil && opts.JSON {
+		g.emitter = newEmitter(stdout)
+	}
+
+	if context != nil {
+		rcPath := filepath.Join(context.AbsPath, DriveRcSuffix)
+		rc, err := readDriveRc(rcPath)
+		if err != nil {
+			logger.LogErrf("%s: %v\n", rcPath, err)
+		}
+		if err := g.loadConversions(rc); err != nil {
+			logger.LogErrf("%v, using the default conversions\n", err)
+			g.loadConversions(nil)
+		}
+		if err := g.loadSyncRules(rc); err != nil {
+			logger.LogErrf("%v, mirroring everything\n", err)
+		}
+
+		if opts != nil {
+			r.uploadLimit = newRateLimiter(opts.UploadRateLimit)
+			r.downloadLimit = newRateLimiter(opts.DownloadRateLimit)
+			if opts.FollowShortcuts {
+				r.shortcuts = newShortcutFollower(logger)
+			}
+		}
+
+		if uploads, err := loadUploadSessions(uploadSessionsPath(context)); err != nil {
+			logger.LogErrf("upload sessions: %v, uploads won't be resumable\n", err)
+		} else {
+			r.uploads = uploads
+		}
+
+		if opts == nil || !opts.NoPathIndex {
+			if index, err := openPathIndex(pathIndexPath(context)); err != nil {
+				logger.LogErrf("path index: %v, paths will be resolved without it\n", err)
+			} else {
+				r.index = index
+			}
+		}
+
+		g.journal = newJournal(g.journalPath(), logger)
+		g.mut = &journaledMutator{mutator: r, rem: r, j: g.journal}
+	}
+
+	if opts != nil && (opts.PlanPath != "" || opts.TreeDiff || opts.DryRun) {
+		g.plan = newPlan()
+		g.mut = g.plan
+	}
+	return g, nil
+}
+
+// hasGitignoreSyntax reports whether the ignores file at p
+// starts off with DriveIgnoreGitignoreSyntax.
+func hasGitignoreSyntax(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	return scanner.Scan() && strings.TrimSpace(scanner.Text()) == DriveIgnoreGitignoreSyntax
 }
 
-func combineIgnores(ignoresPath string) (*regexp.Regexp, error) {
-	clauses, err := readCommentedFile(ignoresPath, "#")
-	if err != nil && !os.IsNotExist(err) {
-		return nil, err
+func combineIgnores(ignoresPath string, gitignoreSyntax bool) (*regexp.Regexp, error) {
+	var clauses []string
+	// Its patterns are then matched by Options.Ignores rather than as regexps
+	if !gitignoreSyntax {
+		var err error
+		clauses, err = readCommentedFile(ignoresPath, "#")
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
 	}
 
 	clauses = append(clauses, internalIgnores()...)
@@ -167,26 +517,24 @@ func combineIgnores(ignoresPath string) (*regexp.Regexp, error) {
 	return regExComp, nil
 }
 
+// taskStart starts off the progress of transfers totalling tasks bytes.
 func (g *Commands) taskStart(tasks int64) {
-	if tasks > 0 {
-		g.progress = newProgressBar(tasks)
+	if tasks > 0 && !g.opts.Quiet {
+		g.progress = newTransferProgress(tasks, false, g.opts.StdoutIsTty, g.log.Logf)
 	}
 }
 
-func newProgressBar(total int64) *pb.ProgressBar {
-	pbf := pb.New64(total)
-	pbf.Start()
-	return pbf
+// taskStartCount starts off the progress of tasks items.
+func (g *Commands) taskStartCount(tasks int64) {
+	if tasks > 0 && !g.opts.Quiet {
+		g.progress = newTransferProgress(tasks, true, g.opts.StdoutIsTty, g.log.Logf)
+	}
 }
 
 func (g *Commands) taskAdd(n int64) {
-	if g.progress != nil {
-		g.progress.Add64(n)
-	}
+	g.progress.add(n)
 }
 
 func (g *Commands) taskFinish() {
-	if g.progress != nil {
-		g.progress.Finish()
-	}
+	g.progress.stop()
 }
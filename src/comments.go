@@ -0,0 +1,258 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	CommentsFormatJSON     = "json"
+	CommentsFormatMarkdown = "md"
+
+	commentStatusResolved = "resolved"
+)
+
+// commentReply is a reply to a comment as listed, emitted and exported.
+type commentReply struct {
+	Author  string    `json:"author"`
+	Content string    `json:"content"`
+	Verb    string    `json:"verb,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// commentEntry is a comment, along with its replies, as listed, emitted and exported.
+type commentEntry struct {
+	Path     string          `json:"path"`
+	Id       string          `json:"id"`
+	Author   string          `json:"author"`
+	Quoted   string          `json:"quoted,omitempty"`
+	Content  string          `json:"content"`
+	Resolved bool            `json:"resolved"`
+	Created  time.Time       `json:"created"`
+	Replies  []*commentReply `json:"replies,omitempty"`
+}
+
+func authorName(u *drive.User) string {
+	if u == nil {
+		return ""
+	}
+	return u.DisplayName
+}
+
+// comments lists the comments on the file with fileId, along with their replies.
+func (r *Remote) comments(fileId string) ([]*drive.Comment, error) {
+	var comments []*drive.Comment
+	pageToken := ""
+	for {
+		req := r.service.Comments.List(fileId).MaxResults(100)
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+		page, err := req.Do()
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page.Items...)
+		if page.NextPageToken == "" {
+			return comments, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func newCommentEntry(p string, c *drive.Comment) *commentEntry {
+	entry := &commentEntry{
+		Path:     p,
+		Id:       c.CommentId,
+		Author:   authorName(c.Author),
+		Content:  c.Content,
+		Resolved: c.Status == commentStatusResolved,
+		Created:  parseTimeAndRound(c.CreatedDate),
+	}
+	if c.Context != nil {
+		entry.Quoted = c.Context.Value
+	}
+	for _, reply := range c.Replies {
+		if reply.Deleted {
+			continue
+		}
+		entry.Replies = append(entry.Replies, &commentReply{
+			Author:  authorName(reply.Author),
+			Content: reply.Content,
+			Verb:    reply.Verb,
+			Created: parseTimeAndRound(reply.CreatedDate),
+		})
+	}
+	return entry
+}
+
+// Comments lists the comments, and their replies, on each of the files in
+// opts.Sources, only those not yet resolved if openOnly is set. If format
+// is set the comments are also exported, as JSON or Markdown, to a file beside
+// the local copy of each file e.g "proposal.comments.md".
+func (g *Commands) Comments(byId, openOnly bool, format string) error {
+	switch format {
+	case "", CommentsFormatJSON, CommentsFormatMarkdown:
+	default:
+		return fmt.Errorf("comments: unknown format %q, expecting %s or %s",
+			format, CommentsFormatJSON, CommentsFormatMarkdown)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var composedError error = nil
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("comments: %s: %v", p, err))
+			continue
+		}
+		relToRootPath := p
+		if byId {
+			relToRootPath = filepath.Join(g.opts.Path, f.Name)
+		}
+
+		comments, err := g.rem.comments(f.Id)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("comments: %s: %v", relToRootPath, err))
+			continue
+		}
+
+		var entries []*commentEntry
+		for _, c := range comments {
+			if c.Deleted || (openOnly && c.Status == commentStatusResolved) {
+				continue
+			}
+			entries = append(entries, newCommentEntry(relToRootPath, c))
+		}
+
+		for _, entry := range entries {
+			if g.emitter != nil {
+				g.emitter.emit(entry)
+				continue
+			}
+			g.logComment(entry)
+		}
+
+		if format == "" {
+			continue
+		}
+		if err := g.exportComments(relToRootPath, entries, format); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("comments: %s: %v", relToRootPath, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) logComment(entry *commentEntry) {
+	status := "open"
+	if entry.Resolved {
+		status = commentStatusResolved
+	}
+	g.log.Logf("%s %s %s (%s)\n", entry.Path, entry.Author, entry.Created.Local().Format("2006-01-02 15:04"), status)
+	if entry.Quoted != "" {
+		g.log.Logf("  > %s\n", entry.Quoted)
+	}
+	g.log.Logf("  %s\n", entry.Content)
+	for _, reply := range entry.Replies {
+		g.log.Logf("    %s: %s\n", reply.Author, replyText(reply))
+	}
+}
+
+// replyText is the content of reply, noting if it resolved or reopened the comment.
+func replyText(reply *commentReply) string {
+	if reply.Verb == "" {
+		return reply.Content
+	}
+	if reply.Content == "" {
+		return fmt.Sprintf("[%s]", reply.Verb)
+	}
+	return fmt.Sprintf("%s [%s]", reply.Content, reply.Verb)
+}
+
+// exportComments writes entries, the comments on the file at relToRootPath,
+// beside its local copy as format.
+func (g *Commands) exportComments(relToRootPath string, entries []*commentEntry, format string) error {
+	exportPath := g.context.AbsPathOf(relToRootPath) + ".comments." + format
+	if err := os.MkdirAll(filepath.Dir(exportPath), os.ModeDir|0755); err != nil {
+		return err
+	}
+
+	fh, err := os.Create(exportPath)
+	if err != nil {
+		return err
+	}
+
+	if format == CommentsFormatJSON {
+		enc := json.NewEncoder(fh)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []*commentEntry{}
+		}
+		err = enc.Encode(entries)
+	} else {
+		err = writeCommentsMarkdown(fh, relToRootPath, entries)
+	}
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		g.log.Logf("Exported %d comments on %s to %s\n", len(entries), relToRootPath, exportPath)
+	}
+	return err
+}
+
+func writeCommentsMarkdown(w io.Writer, relToRootPath string, entries []*commentEntry) error {
+	var md []string
+	md = append(md, fmt.Sprintf("# Comments on %s\n", filepath.Base(relToRootPath)))
+	for _, entry := range entries {
+		heading := fmt.Sprintf("## %s, %s", entry.Author, entry.Created.Local().Format("2006-01-02 15:04"))
+		if entry.Resolved {
+			heading += " (resolved)"
+		}
+		md = append(md, heading+"\n")
+		if entry.Quoted != "" {
+			md = append(md, quoteMarkdown(entry.Quoted)+"\n")
+		}
+		md = append(md, entry.Content+"\n")
+		for _, reply := range entry.Replies {
+			md = append(md, fmt.Sprintf("- **%s**: %s", reply.Author, replyText(reply)))
+		}
+		if len(entry.Replies) >= 1 {
+			md = append(md, "")
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(md, "\n")+"\n")
+	return err
+}
+
+func quoteMarkdown(s string) string {
+	return "> " + strings.Replace(s, "\n", "\n> ", -1)
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CompressionKey marks the files whose content push compressed, with
+	// CompressionGzip, which pull decompresses as it downloads them.
+	CompressionKey  = "compression"
+	CompressionGzip = "gzip"
+
+	GzipMimeType = "application/gzip"
+)
+
+// incompressibleExts are the extensions of files whose content is
+// already compressed, which -gzip uploads as it is.
+var incompressibleExts = map[string]bool{
+	"7z": true, "bz2": true, "gz": true, "tgz": true, "xz": true, "zip": true, "zst": true, "rar": true,
+	"jpg": true, "jpeg": true, "png": true, "gif": true, "webp": true, "heic": true,
+	"mp3": true, "m4a": true, "ogg": true, "flac": true,
+	"mp4": true, "m4v": true, "mkv": true, "mov": true, "avi": true, "webm": true,
+	"docx": true, "xlsx": true, "pptx": true, "odt": true, "ods": true, "odp": true, "apk": true, "jar": true,
+}
+
+func compressible(name string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	return !incompressibleExts[ext]
+}
+
+func compressed(f *File) bool {
+	return f != nil && f.Properties[CompressionKey] == CompressionGzip
+}
+
+// uncompressed gives f the checksum and size of its content from before
+// it was compressed. Those of encrypted files are only known once they
+// are decrypted, as their content is only pulled as it is without it.
+func uncompressed(f *File) *File {
+	if !compressed(f) || encrypted(f) {
+		return f
+	}
+	return plainContent(f)
+}
+
+func plainContent(f *File) *File {
+	f.Md5Checksum = f.Properties[PlainMd5Key]
+	if size, err := strconv.ParseInt(f.Properties[PlainSizeKey], 10, 64); err == nil {
+		f.Size = size
+	}
+	return f
+}
+
+// plainProperties are props with the checksum and size of src's content,
+// which remotes are compared against after it is compressed or encrypted.
+func plainProperties(src *File, props map[string]string) map[string]string {
+	stamped := map[string]string{
+		PlainMd5Key:  md5Checksum(src),
+		PlainSizeKey: fmt.Sprintf("%d", src.Size),
+	}
+	for key, value := range props {
+		stamped[key] = value
+	}
+	return stamped
+}
+
+// gzipReader compresses the content read from src as it is read,
+// only starting to once read from as the content may go unsent.
+type gzipReader struct {
+	src io.Reader
+	pr  *io.PipeReader
+}
+
+func (gz *gzipReader) Read(p []byte) (int, error) {
+	if gz.pr == nil {
+		var pw *io.PipeWriter
+		gz.pr, pw = io.Pipe()
+		go func() {
+			zw := gzip.NewWriter(pw)
+			_, err := io.Copy(zw, gz.src)
+			if closeErr := zw.Close(); err == nil {
+				err = closeErr
+			}
+			pw.CloseWithError(err)
+		}()
+	}
+	return gz.pr.Read(p)
+}
+
+// gunzipReadCloser decompresses the content of rc as it is read.
+type gunzipReadCloser struct {
+	*gzip.Reader
+	rc io.ReadCloser
+}
+
+func gunzipReader(rc io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return &gunzipReadCloser{Reader: zr, rc: rc}, nil
+}
+
+func (gz *gunzipReadCloser) Close() error {
+	gz.Reader.Close()
+	return gz.rc.Close()
+}
+
+// transferredContent is the content of the pulled file src as read
+// from blob, decrypted and decompressed as push set it to be.
+func (g *Commands) transferredContent(src *File, blob io.ReadCloser) (io.ReadCloser, error) {
+	if encrypted(src) {
+		if g.rem.crypt == nil {
+			// Without -decrypt it is pulled as it is
+			return blob, nil
+		}
+		var err error
+		if blob, err = g.rem.crypt.decryptReader(blob); err != nil {
+			return nil, err
+		}
+	}
+	if compressed(src) {
+		return gunzipReader(blob)
+	}
+	return blob, nil
+}
+
+// transformed reports whether the pulled content of src isn't as it is on Drive.
+func (g *Commands) transformed(src *File) bool {
+	return compressed(src) && !encrypted(src) || encrypted(src) && g.rem.crypt != nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ConflictFail       = "fail"
+	ConflictLocalWins  = "local-wins"
+	ConflictRemoteWins = "remote-wins"
+)
+
+// conflictCopy is a version of a file, changed both locally and remotely
+// since it was last synced, that is set aside under a conflict name so
+// that the other version can take its place.
+type conflictCopy struct {
+	path   string
+	file   *File
+	remote bool
+	name   string
+}
+
+func (g *Commands) checkModConflictPolicy() error {
+	switch g.opts.ModConflictPolicy {
+	case "", ConflictFail, ConflictLocalWins, ConflictRemoteWins, ConflictKeepBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict policy %q, expecting %s, %s, %s or %s", g.opts.ModConflictPolicy,
+		ConflictFail, ConflictLocalWins, ConflictRemoteWins, ConflictKeepBoth)
+}
+
+// applyModConflictPolicy settles the conflicts as per opts.ModConflictPolicy,
+// returning the changes to go ahead with. Conflicts are left unsettled if
+// the policy is to fail on them.
+func (g *Commands) applyModConflictPolicy(conflicts []*Change, push bool) (settled []*Change, ok bool) {
+	policy := g.opts.ModConflictPolicy
+	if policy == "" || policy == ConflictFail {
+		return nil, false
+	}
+
+	// The source's version is the local one when pushing, the remote one when pulling
+	srcWins := (push && policy == ConflictLocalWins) || (!push && policy == ConflictRemoteWins)
+
+	stamp := time.Now().Format("2006-01-02")
+	for _, ch := range conflicts {
+		switch policy {
+		case ConflictKeepBoth:
+			g.conflictCopies = append(g.conflictCopies, &conflictCopy{
+				path:   ch.Path,
+				file:   ch.Dest,
+				remote: push,
+				name:   conflictName(ch.Dest.Name, stamp),
+			})
+			// The destination's version is set aside so the source's is added anew
+			ch.Dest = nil
+			settled = append(settled, ch)
+		default:
+			if !srcWins {
+				g.log.Logf("%s: conflict, keeping the %s version as per `-%s %s`\n",
+					ch.Path, sideOf(!push), CLIOptionModConflictPolicy, policy)
+				continue
+			}
+			ch.IgnoreConflict = true
+			settled = append(settled, ch)
+		}
+	}
+
+	for _, cc := range g.conflictCopies {
+		g.log.Logf("%s: conflict, the %s version will be kept as %s\n", cc.path, sideOf(cc.remote), cc.name)
+	}
+	return settled, true
+}
+
+func sideOf(remote bool) string {
+	if remote {
+		return "remote"
+	}
+	return "local"
+}
+
+// conflictName suffixes name, before its extension, as a conflicting copy
+// e.g "notes (conflict 2024-05-01).txt".
+func conflictName(name, stamp string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(name, ext), stamp, ext)
+}
+
+// setAsideConflicts renames the versions of conflicting files that are to be
+// kept under their conflict names, clearing the way for the changes to be played.
+func (g *Commands) setAsideConflicts() error {
+	var composedError error = nil
+
+	for _, cc := range g.conflictCopies {
+		var err error
+		if cc.remote {
+			err = g.rename(cc.file, g.parentPather(cc.path), cc.name)
+		} else {
+			err = setAsideLocally(g.context.AbsPathOf(cc.path), cc.name)
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: conflict: %v", cc.path, err))
+		}
+	}
+
+	g.conflictCopies = nil
+	return composedError
+}
+
+// setAsideLocally renames the file at absPath to name, numbering name
+// if a file by it exists already e.g from an earlier conflict that day.
+func setAsideLocally(absPath, name string) error {
+	dir := path.Dir(absPath)
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	target := path.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			break
+		}
+		target = path.Join(dir, fmt.Sprintf("%s %d%s", base, i, ext))
+	}
+	return os.Rename(absPath, target)
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DriveRcConvertSection is the section of the .driverc that maps
+	// extensions to the Google Docs types that push -convert makes of them
+	// e.g "md = docs", or "none" to upload files of the extension as is.
+	DriveRcConvertSection = "convert"
+
+	ConvertNone = "none"
+)
+
+// defaultConversions are the extensions that push -convert converts
+// and the Google Docs types that files of them become.
+var defaultConversions = map[string]string{
+	"doc":  "application/vnd.google-apps.document",
+	"docx": "application/vnd.google-apps.document",
+	"odt":  "application/vnd.google-apps.document",
+	"rtf":  "application/vnd.google-apps.document",
+	"csv":  "application/vnd.google-apps.spreadsheet",
+	"ods":  "application/vnd.google-apps.spreadsheet",
+	"xls":  "application/vnd.google-apps.spreadsheet",
+	"xlsx": "application/vnd.google-apps.spreadsheet",
+	"odp":  "application/vnd.google-apps.presentation",
+	"ppt":  "application/vnd.google-apps.presentation",
+	"pptx": "application/vnd.google-apps.presentation",
+}
+
+// convertKinds are the names that Google Docs types go by in the .driverc.
+var convertKinds = map[string]string{
+	"docs":         "application/vnd.google-apps.document",
+	"document":     "application/vnd.google-apps.document",
+	"sheet":        "application/vnd.google-apps.spreadsheet",
+	"sheets":       "application/vnd.google-apps.spreadsheet",
+	"spreadsheet":  "application/vnd.google-apps.spreadsheet",
+	"slides":       "application/vnd.google-apps.presentation",
+	"presentation": "application/vnd.google-apps.presentation",
+	"drawing":      "application/vnd.google-apps.drawing",
+}
+
+// loadConversions sets up the conversions that push -convert makes, the
+// defaults as amended by the convert section of the .driverc.
+func (g *Commands) loadConversions(rc driveRc) error {
+	conversions := make(map[string]string)
+	for ext, mimeType := range defaultConversions {
+		conversions[ext] = mimeType
+	}
+
+	for ext, kind := range rc.section(DriveRcConvertSection) {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		if strings.ToLower(kind) == ConvertNone {
+			delete(conversions, ext)
+			continue
+		}
+		mimeType, known := convertKinds[strings.ToLower(kind)]
+		if !known {
+			return fmt.Errorf("%s: [%s] %s = %s: expecting docs, sheet, slides, drawing or %s",
+				DriveRcSuffix, DriveRcConvertSection, ext, kind, ConvertNone)
+		}
+		conversions[ext] = mimeType
+	}
+
+	g.conversions = conversions
+	return nil
+}
+
+// convertTarget returns the Google Docs type that the local file called
+// name is converted to on push, or "" if it is to be uploaded as is.
+func (g *Commands) convertTarget(name string) string {
+	if !convert(g.opts.TypeMask) {
+		return ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if ext == "" {
+		return ""
+	}
+	return g.conversions[ext]
+}
+
+// convertedName is the name that a document converted from
+// the file called name has, which leaves out its extension.
+func convertedName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// pairConverted pairs up the local files that are converted on push with the
+// documents that they were converted to, which are named without extensions,
+// so that they are recognized as already pushed instead of clashing or being
+// converted all over again.
+func (g *Commands) pairConverted(dirlist []*dirList) []*dirList {
+	if !convert(g.opts.TypeMask) {
+		return dirlist
+	}
+
+	remoteOnly := make(map[string]int)
+	for i, d := range dirlist {
+		if d.local == nil && d.remote != nil {
+			remoteOnly[d.remote.Name] = i
+		}
+	}
+
+	paired := make(map[int]bool)
+	for _, d := range dirlist {
+		if d.local == nil || d.remote != nil || d.local.IsDir {
+			continue
+		}
+		target := g.convertTarget(d.local.Name)
+		if target == "" {
+			continue
+		}
+		i, ok := remoteOnly[convertedName(d.local.Name)]
+		if !ok || paired[i] || dirlist[i].remote.MimeType != target {
+			continue
+		}
+		d.remote = dirlist[i].remote
+		paired[i] = true
+	}
+
+	if len(paired) < 1 {
+		return dirlist
+	}
+
+	var kept []*dirList
+	for i, d := range dirlist {
+		if !paired[i] {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// convertedFrom reports whether the remote document r was converted from the local file l.
+func (g *Commands) convertedFrom(l, r *File) bool {
+	if l == nil || r == nil || l.IsDir {
+		return false
+	}
+	target := g.convertTarget(l.Name)
+	return target != "" && target == r.MimeType && convertedName(l.Name) == r.Name
+}
+
+// convertedStale reports whether the local file l was modified since it was
+// last converted to r, going by the second since Drive keeps no finer times.
+func convertedStale(l, r *File) bool {
+	return l.ModTime.Truncate(time.Second).After(r.ModTime.Truncate(time.Second))
+}
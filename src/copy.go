@@ -17,10 +17,18 @@ package drive
 import (
 	"errors"
 	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 var ErrPathNotDir = errors.New("not a directory")
 
+// DefaultCopyWorkers is the default number of items in folders copied at a time
+const DefaultCopyWorkers = 4
+
 type copyArgs struct {
 	destPath string
 	src      *File
@@ -33,20 +41,119 @@ func (g *Commands) Copy(byId bool) error {
 		return fmt.Errorf("expecting src [src1....] dest got: %v", g.opts.Sources)
 	}
 
+	// Ids aren't paths to be scoped, only the dest is
+	scoped := g.opts.Sources
+	if byId {
+		scoped = scoped[argc-1:]
+	}
+	if err := g.scopeToDestRoot(scoped); err != nil {
+		return err
+	}
+
+	if !byId {
+		sources, err := g.expandSources(g.opts.Sources[:argc-1])
+		if err != nil {
+			return fmt.Errorf("copy: %v", err)
+		}
+		g.opts.Sources = append(sources, g.opts.Sources[argc-1])
+		argc = len(g.opts.Sources)
+	}
+
+	partition, err := parsePartitioner(g.opts.Partition)
+	if err != nil {
+		return err
+	}
+	if _, err := g.copyShareRole(); err != nil {
+		return err
+	}
+	switch g.opts.OversizePolicy {
+	case "", OversizeSkip, OversizeError:
+	default:
+		return fmt.Errorf("copy: unknown oversize policy %q, expecting %s or %s", g.opts.OversizePolicy, OversizeSkip, OversizeError)
+	}
+	if g.opts.ThrottleOnLowQuota != "" {
+		if g.quotaGovernor, err = newQuotaGovernor(g.opts.ThrottleOnLowQuota); err != nil {
+			return fmt.Errorf("copy: %s: %v", CLIOptionThrottleOnLowQuota, err)
+		}
+	}
+	g.partitioner = partition
+	g.report = newReport()
+	if g.opts.VerifyCopies {
+		g.copyLedger = &copyLedger{}
+	}
+	if g.opts.DestTemplate != "" {
+		if g.destTemplate, err = parseDestTemplate(g.opts.Sources[argc-1], g.opts.DestTemplate); err != nil {
+			return err
+		}
+	}
+	if err = g.prepareNativeSizer(); err != nil {
+		return err
+	}
+	if g.opts.StarIf != "" {
+		if g.starRule, err = parseStarRule(g.opts.StarIf, g.sizeOf); err != nil {
+			return err
+		}
+	}
+	if g.opts.DedupeIdentical {
+		g.copyDedupe = newCopyDedupe()
+	}
+	// Planned copies are made one after the other so that the plan is deterministic
+	if g.plan == nil {
+		g.copyWorkers = newWorkerSlots(g.opts.CopyWorkers - 1)
+	}
+	g.startReadyWatcher()
+	defer g.finishReadyWatcher()
+	if g.opts.SnapshotPath != "" {
+		if g.copySnapshot, err = loadCopySnapshot(g.opts.SnapshotPath); err != nil {
+			return fmt.Errorf("copy: snapshot: %v", err)
+		}
+	}
+
 	g.log.Logln("Processing...")
+	if g.opts.UniqueNames {
+		g.log.Logf("Naming copies as %s\n", uniqueName("<name>.<ext>", "<source id>"))
+	}
 
-	spin := g.playabler()
-	spin.play()
-	defer spin.stop()
+	// Planned copies are quick to make, there is no need to pause them
+	if g.opts.Pausable && g.plan == nil {
+		stopWatching, err := g.watchPauseSignals()
+		if err != nil {
+			return fmt.Errorf("copy: %v", err)
+		}
+		defer stopWatching()
+	}
+
+	// Planned copies aren't made so there is no progress to render
+	if !g.opts.Quiet && g.plan == nil {
+		g.progress = newTransferProgress(0, false, g.opts.StdoutIsTty, g.log.Logf)
+	}
+	defer g.taskFinish()
 
 	end := argc - 1
 	sources, dest := g.opts.Sources[:end], g.opts.Sources[end]
 
-	destFile, err := g.rem.FindByPath(dest)
+	if err := g.pickDest(dest); err != nil {
+		return fmt.Errorf("destination: %v", err)
+	}
+
+	destFile, err := g.findDest(dest)
 	if err != nil && err != ErrPathNotExists {
 		return fmt.Errorf("destination: %s err: %v", dest, err)
 	}
 
+	srcResolver := g.rem.FindByPath
+	if byId {
+		srcResolver = g.rem.FindById
+	}
+
+	if err := g.checkDestWritable(dest, destFile); err != nil {
+		return fmt.Errorf("copy: dest: %v", err)
+	}
+
+	if err := g.copyChildLimitCheck(sources, dest, destFile, srcResolver); err != nil {
+		return err
+	}
+
 	multiPaths := len(sources) > 1
 	if multiPaths {
 		if destFile != nil && !destFile.IsDir {
@@ -58,9 +165,14 @@ func (g *Commands) Copy(byId bool) error {
 		}
 	}
 
-	srcResolver := g.rem.FindByPath
-	if byId {
-		srcResolver = g.rem.FindById
+	if g.opts.Prefetch {
+		var roots []*File
+		for _, srcPath := range sources {
+			if root, err := srcResolver(srcPath); err == nil {
+				roots = append(roots, root)
+			}
+		}
+		g.treeIndex = g.prefetchTrees(roots, g.opts.PrefetchWorkers)
 	}
 
 	done := make(chan bool)
@@ -73,70 +185,643 @@ func (g *Commands) Copy(byId bool) error {
 			continue
 		}
 
-		waitCount += 1
+		toPath := dest
+		if g.opts.DestResolver != nil {
+			if resolved := g.opts.DestResolver(srcFile, srcPath); resolved != "" {
+				toPath = resolved
+			}
+		}
 
-		go func(fromPath, toPath string, fromFile *File) {
-			_, copyErr := g.copy(fromFile, toPath)
+		g.plan.knowPath(srcFile.Id, srcPath)
+
+		copier := func(fromPath, toPath string, fromFile *File) {
+			copied, copyErr := g.copy(fromFile, fromPath, toPath)
+			g.copyLedger.record(fromFile, toPath, copied, copyErr)
 			if copyErr != nil {
 				g.log.LogErrf("%s: %v\n", fromPath, copyErr)
 			}
+		}
+
+		// Planned copies are made one after the other so that the plan is deterministic
+		if g.plan != nil {
+			copier(srcPath, toPath, srcFile)
+			continue
+		}
+
+		waitCount += 1
+
+		go func(fromPath, toPath string, fromFile *File) {
+			copier(fromPath, toPath, fromFile)
 			done <- true
-		}(srcPath, dest, srcFile)
+		}(srcPath, toPath, srcFile)
 	}
 
 	for i := uint64(0); i < waitCount; i += 1 {
 		<-done
 	}
 
-	return nil
+	g.finishReadyWatcher()
+	g.taskFinish()
+
+	var snapshotErr error
+	if g.copySnapshot != nil {
+		snapshotErr = g.settleSnapshot()
+	}
+
+	// Planned copies aren't made so there is nothing to verify
+	var verifyErr error
+	if g.copyLedger != nil && g.plan == nil {
+		verifyErr = g.reconcile()
+	}
+	g.report.summarize(g.log)
+
+	if err := g.flushPlan(); err != nil {
+		return err
+	}
+	if snapshotErr != nil {
+		return snapshotErr
+	}
+	return verifyErr
+}
+
+func (g *Commands) copy(src *File, srcPath, destPath string) (*File, error) {
+	return g.copyRecursive(src, srcPath, destPath, nil)
 }
 
-func (g *Commands) copy(src *File, destPath string) (*File, error) {
+// copyRecursive copies src, at srcPath, to destPath. branch is the folders that the
+// recursion descended through to get to src, since following a shortcut back to one
+// of them, or to a folder that is its own descendant, would copy in an infinite loop.
+func (g *Commands) copyRecursive(src *File, srcPath, destPath string, branch *shortcutBranch) (*File, error) {
 	if src == nil {
 		return nil, fmt.Errorf("non existant src")
 	}
 
 	if !src.IsDir {
-		if !src.Copyable {
+		if !src.Copyable && !g.opts.IncludeShared {
+			if g.opts.PreserveRestrictions && downloadRestricted(src) {
+				g.report.warn("Restrictions that block copying", "%s: %s", destPath, restrictionDownload)
+			}
 			return nil, fmt.Errorf("%s is non-copyable", src.Name)
 		}
+		g.noteNativeSize(src)
+		size, sized := g.sizeOf(src)
+		if g.opts.MaxFileSize > 0 && sized && size > g.opts.MaxFileSize {
+			return nil, g.oversized(src, size, destPath)
+		}
+		prev, unchanged := g.snapshotSkip(src)
+		if unchanged {
+			return nil, nil
+		}
+		if g.opts.CheckExtensions || g.opts.StrictExtensions {
+			if err := g.checkExtension(src, destPath); err != nil {
+				return nil, err
+			}
+		}
 
 		destDir, destBase := g.pathSplitter(destPath)
-		destParent, destParErr := g.remoteMkdirAll(destDir)
+		if g.destTemplate != nil {
+			destDir, destBase = g.pathSplitter(g.destTemplate.eval(src))
+			g.report.count("Destination folders", destDir)
+		} else {
+			destFile, destErr := g.rem.FindByPath(destPath)
+			if destErr != nil && destErr != ErrPathNotExists {
+				return nil, destErr
+			}
+			if destFile != nil && destFile.IsDir {
+				destDir = destPath
+				destBase = src.Name
+			}
+		}
+
+		if g.opts.UniqueNames {
+			destBase = uniqueName(destBase, src.Id)
+		}
+
+		if g.partitioner != nil {
+			bucket := g.partitioner(src)
+			destDir = path.Join(destDir, bucket)
+			g.report.count("Partition distribution", bucket)
+		}
 
+		destParent, destParErr := g.remoteMkdirAll(destDir)
 		if destParErr != nil {
 			return nil, destParErr
 		}
 
-		parentId := destParent.Id
-		destFile, destErr := g.rem.FindByPath(destPath)
-		if destErr != nil && destErr != ErrPathNotExists {
-			return nil, destErr
+		destPath = path.Join(destDir, destBase)
+
+		var origin *copyOrigin
+		if g.copyDedupe != nil && src.Md5Checksum != "" {
+			var first bool
+			if origin, first = g.copyDedupe.claim(src.Md5Checksum); !first {
+				linked, linkErr := g.linkToOrigin(origin, destParent.Id, destPath)
+				if linked != nil || linkErr != nil {
+					g.audit(AuditCopy, src, srcPath, destPath, linkErr)
+					return linked, linkErr
+				}
+				// The original copy failed so this one has to be made
+				origin = nil
+			}
+		}
+
+		g.pauseGate.wait()
+		g.throttleOnLowQuota(size)
+
+		g.plan.knowPath(destParent.Id, destDir)
+		fp := g.progress.start(destPath, size)
+		var copied *File
+		var copyErr error
+		if src.Copyable {
+			copied, copyErr = g.mut.copy(destBase, destParent.Id, src)
+		} else {
+			copyErr = fmt.Errorf("%s is non-copyable", src.Name)
+		}
+		if g.opts.IncludeShared {
+			if copyErr == nil {
+				g.report.count(sharedStrategiesTitle, SharedStrategyCopied)
+			} else {
+				copied, copyErr = g.copyShared(src, destBase, destParent.Id, destPath, copyErr)
+			}
+		}
+		if copied != nil {
+			g.plan.knowPath(copied.Id, destPath)
 		}
-		if destFile != nil && destFile.IsDir {
-			parentId = destFile.Id
-			destBase = src.Name
+		if copyErr == nil {
+			fp.add(size)
+			g.taskAdd(size)
 		}
-		return g.rem.copy(destBase, parentId, src)
+		fp.finish(copyErr)
+		g.audit(AuditCopy, src, srcPath, destPath, copyErr)
+		if origin != nil {
+			g.copyDedupe.settle(origin, copied, destParent.Id)
+		}
+		if copyErr != nil {
+			return nil, copyErr
+		}
+		g.snapshotCopied(src, copied, destPath, prev)
+		g.afterCopy(src, copied, destPath)
+		return copied, nil
 	}
 
-	destFile, destErr := g.remoteMkdirAll(destPath)
-	if destErr != nil {
-		return nil, destErr
+	if cycle := branch.cycle(src); cycle != "" {
+		g.report.warn("Folder cycles skipped", "%s: %s", destPath, cycle)
+		return nil, fmt.Errorf("%s leads back to itself: %s", src.Name, cycle)
 	}
 
-	children := g.rem.findChildren(src.Id, false)
+	// Files are routed by the template so the source's folders aren't mirrored
+	var destFile *File
+	if g.destTemplate == nil {
+		var existing *File
+		if g.opts.PreserveFolderMetadata {
+			existing, _ = g.rem.FindByPath(destPath)
+		}
 
-	for child := range children {
-		// TODO: add concurrency after retry scheme is added
-		// because could suffer from rate limit restrictions
+		var destErr error
+		if destFile, destErr = g.remoteMkdirAll(destPath); destErr != nil {
+			return nil, destErr
+		}
+
+		// Folders that were already there keep their own metadata
+		if g.opts.PreserveFolderMetadata && existing == nil {
+			g.preserveFolderMetadata(src, destFile, destPath)
+		}
+	}
+
+	children := g.copyChildren(src.Id)
+	branch = branch.descend(src)
+
+	copiedCount := uint64(0)
+	copyChild := func(child *File) {
 		chName := sepJoin("/", destPath, child.Name)
-		_, chErr := g.copy(child, chName)
+		chFile, chErr := g.copyRecursive(child, sepJoin("/", srcPath, child.Name), chName, branch)
+		g.copyLedger.record(child, chName, chFile, chErr)
 
 		if chErr != nil {
 			g.log.LogErrf("copy: %s: %v\n", chName, chErr)
+		} else if chFile != nil {
+			atomic.AddUint64(&copiedCount, 1)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for child := range children {
+		// Children are handed to idle workers, otherwise copied right here
+		// so that folders waiting on their children never hold up workers.
+		if !g.copyWorkers.tryAcquire() {
+			copyChild(child)
+			continue
 		}
+		wg.Add(1)
+		go func(child *File) {
+			defer wg.Done()
+			defer g.copyWorkers.release()
+			copyChild(child)
+		}(child)
+	}
+	wg.Wait()
+
+	if copiedCount < 1 && g.opts.PruneEmptyDirs && destFile != nil {
+		return g.pruneIfEmpty(destFile, destPath)
 	}
 
 	return destFile, nil
 }
+
+// preserveFolderMetadata gives the folder created at destPath
+// the description and starred state of its source folder src.
+func (g *Commands) preserveFolderMetadata(src, created *File, destPath string) {
+	g.plan.knowPath(created.Id, destPath)
+
+	if src.Description != "" {
+		if err := g.mut.describe(created.Id, src.Description); err != nil {
+			g.report.warn("Folder descriptions not preserved", "%s: %v", destPath, err)
+		} else {
+			g.report.note("Folder descriptions preserved", "%s", destPath)
+		}
+	}
+
+	if src.Labels != nil && src.Labels.Starred {
+		if err := g.mut.star(created.Id); err != nil {
+			g.report.warn("Folder stars not preserved", "%s: %v", destPath, err)
+		} else {
+			g.report.note("Folder stars preserved", "%s", destPath)
+		}
+	}
+}
+
+// workerSlots bounds the number of children being copied concurrently.
+// Rate limited copies are backed off from, see Remote.copy.
+type workerSlots chan bool
+
+func newWorkerSlots(n int) workerSlots {
+	if n < 1 {
+		return nil
+	}
+	return make(workerSlots, n)
+}
+
+// tryAcquire takes up a slot if one is free. No slots are ever free in nil slots.
+func (ws workerSlots) tryAcquire() bool {
+	select {
+	case ws <- true:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ws workerSlots) release() {
+	<-ws
+}
+
+// pruneIfEmpty trashes the copied folder at destPath if it ended up
+// empty, in which case a nil file is returned for it.
+func (g *Commands) pruneIfEmpty(destDir *File, destPath string) (*File, error) {
+	// A planned folder can only have had planned children
+	if !isPlaceholder(destDir.Id) {
+		nonEmpty, err := g.rem.hasChildren(destDir.Id)
+		if err != nil || nonEmpty {
+			return destDir, err
+		}
+	}
+
+	err := g.mut.Trash(destDir.Id)
+	g.audit(AuditTrash, destDir, destPath, "", err)
+	if err != nil {
+		return destDir, fmt.Errorf("pruning empty folder: %v", err)
+	}
+
+	g.mkdirAllCache.Remove(destPath)
+	g.report.note("Pruned empty folders", "%s", destPath)
+	return nil, nil
+}
+
+// copyOrigin is the first copy made of content with a given md5
+// that later identical files get linked to instead of being copied.
+type copyOrigin struct {
+	ready   chan bool
+	file    *File
+	parents map[string]*originLink
+}
+
+// originLink is the linking of an origin's copy to a parent, which those
+// linking it to the same parent wait on rather than link it again.
+type originLink struct {
+	done chan bool
+	err  error
+}
+
+func linkedOrigin() *originLink {
+	link := &originLink{done: make(chan bool)}
+	close(link.done)
+	return link
+}
+
+type copyDedupe struct {
+	sync.Mutex
+	origins map[string]*copyOrigin
+}
+
+func newCopyDedupe() *copyDedupe {
+	return &copyDedupe{
+		origins: make(map[string]*copyOrigin),
+	}
+}
+
+// claim returns the origin for md5 and whether the caller is its first
+// claimant, in which case the caller has to make the copy and settle it.
+func (cd *copyDedupe) claim(md5 string) (*copyOrigin, bool) {
+	cd.Lock()
+	defer cd.Unlock()
+
+	origin, ok := cd.origins[md5]
+	if ok {
+		return origin, false
+	}
+
+	origin = &copyOrigin{
+		ready:   make(chan bool),
+		parents: make(map[string]*originLink),
+	}
+	cd.origins[md5] = origin
+	return origin, true
+}
+
+func (cd *copyDedupe) settle(origin *copyOrigin, copied *File, parentId string) {
+	cd.Lock()
+	origin.file = copied
+	if copied != nil {
+		origin.parents[parentId] = linkedOrigin()
+	}
+	cd.Unlock()
+
+	close(origin.ready)
+}
+
+// linkToOrigin adds parentId as a parent of the origin's copy. A nil file
+// and error are returned if the original copy failed to be made. The link
+// is reserved under the lock and made outside it, so that linking doesn't
+// hold up the copies of other content.
+func (g *Commands) linkToOrigin(origin *copyOrigin, parentId, destPath string) (*File, error) {
+	<-origin.ready
+
+	// The origin's file is set once, before it is ready
+	if origin.file == nil {
+		return nil, nil
+	}
+
+	g.copyDedupe.Lock()
+	link, reserved := origin.parents[parentId]
+	if !reserved {
+		link = &originLink{done: make(chan bool)}
+		origin.parents[parentId] = link
+	}
+	g.copyDedupe.Unlock()
+
+	if reserved {
+		<-link.done
+	} else {
+		link.err = g.mut.insertParent(origin.file.Id, parentId)
+		close(link.done)
+	}
+	if link.err != nil {
+		return nil, link.err
+	}
+
+	g.report.note("Deduplicated copies", "%s linked to %s", destPath, origin.file.Name)
+	return origin.file, nil
+}
+
+// copyChildLimitCheck projects the number of items that will land directly
+// in the destination folder and checks it against the child limit.
+func (g *Commands) copyChildLimitCheck(sources []string, dest string, destFile *File, srcResolver func(string) (*File, error)) error {
+	if g.opts.MaxChildren <= 0 || (destFile != nil && !destFile.IsDir) {
+		return nil
+	}
+
+	incoming := len(sources)
+	if len(sources) == 1 {
+		// A lone folder gets its children copied straight into dest.
+		src, srcErr := srcResolver(sources[0])
+		if srcErr != nil || src == nil || !src.IsDir {
+			return nil
+		}
+		incoming = 0
+		for _ = range g.rem.findChildren(src.Id, false) {
+			incoming += 1
+		}
+	}
+
+	return g.checkChildLimit(destFile, dest, incoming)
+}
+
+// oversized reports src, of size, as too large to copy to destPath. Only
+// under the error policy is it failed on, otherwise it is quietly skipped.
+func (g *Commands) oversized(src *File, size int64, destPath string) error {
+	if g.opts.OversizePolicy != OversizeError {
+		g.report.note("Skipped files over the max file size", "%s (%s)", destPath, prettyBytes(size))
+		return nil
+	}
+
+	g.report.warn("Files over the max file size", "%s (%s)", destPath, prettyBytes(size))
+	return fmt.Errorf("%s is %s, over the max file size of %s", src.Name, prettyBytes(size), prettyBytes(g.opts.MaxFileSize))
+}
+
+// uniqueName suffixes name, before its extension, with the id
+// of the source so that no two copies of different sources clash.
+func uniqueName(name, srcId string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(name, ext), srcId, ext)
+}
+
+// afterCopy runs the post-copy steps for a freshly made copy of src.
+func (g *Commands) afterCopy(src, copied *File, destPath string) {
+	g.checkIndexableText(src, copied, destPath)
+	g.shareCopy(copied, destPath)
+	if g.opts.PreserveLinkSharing {
+		g.preserveLinkSharing(src, copied, destPath)
+	}
+	if g.opts.PreserveRestrictions {
+		g.preserveRestrictions(src, copied, destPath)
+	}
+	if g.opts.Backlink {
+		g.backlink(src, copied, destPath)
+	}
+	if g.starRule != nil {
+		g.starIf(src, copied, destPath)
+	}
+	if g.opts.TrashSourceAfter > 0 {
+		g.tagForReaping(src, copied, destPath)
+	}
+	g.waitReady(copied, destPath)
+}
+
+func (g *Commands) copyShareEmails() []string {
+	if g.opts.Meta == nil {
+		return nil
+	}
+	return (*g.opts.Meta)[EmailsKey]
+}
+
+func (g *Commands) copyShareRole() (Role, error) {
+	if g.opts.Meta == nil {
+		return Reader, nil
+	}
+	roles := (*g.opts.Meta)[RoleKey]
+	if len(roles) < 1 {
+		return Reader, nil
+	}
+
+	role := reverseRoleResolve(roles[0])
+	switch role {
+	case Reader, Commenter, Writer:
+		if strings.ToLower(roles[0]) == role.String() {
+			return role, nil
+		}
+	}
+	return Reader, fmt.Errorf("copy: unsupported role %q to share copies with, expecting reader, commenter or writer", roles[0])
+}
+
+// shareCopy grants the users given to share copies with their role on copied.
+func (g *Commands) shareCopy(copied *File, destPath string) {
+	emails := g.copyShareEmails()
+	if len(emails) < 1 {
+		return
+	}
+
+	role, _ := g.copyShareRole()
+	for _, email := range emails {
+		perm := permission{
+			fileId:      copied.Id,
+			value:       email,
+			role:        role,
+			accountType: User,
+		}
+		if _, err := g.mut.insertPermissions(&perm); err != nil {
+			g.report.warn("Failed shares", "%s with %s: %v", destPath, email, err)
+			continue
+		}
+		g.report.note("Shared copies", "%s with %s as %s", destPath, email, role.String())
+	}
+}
+
+// preserveLinkSharing grants the same link based access to copied as src has
+// i.e to anyone, or anyone in a domain, who has the link. Any other sharing,
+// e.g with specific users, is left out.
+func (g *Commands) preserveLinkSharing(src, copied *File, destPath string) {
+	perms, err := g.rem.listPermissions(src.Id)
+	if err != nil {
+		g.report.warn("Link sharing not preserved", "%s: %v", destPath, err)
+		return
+	}
+
+	for _, perm := range perms {
+		if !perm.WithLink || (perm.Type != "anyone" && perm.Type != "domain") {
+			continue
+		}
+
+		role := reverseRoleResolve(perm.Role)
+		for _, additional := range perm.AdditionalRoles {
+			if additional == "commenter" {
+				role = Commenter
+			}
+		}
+
+		linkPerm := permission{
+			fileId:      copied.Id,
+			value:       perm.Domain,
+			role:        role,
+			accountType: reverseAccountTypeResolve(perm.Type),
+			withLink:    true,
+		}
+
+		audience := "anyone"
+		if perm.Type == "domain" {
+			audience = fmt.Sprintf("anyone at %s", perm.Domain)
+		}
+
+		if _, err := g.mut.insertPermissions(&linkPerm); err != nil {
+			g.report.warn("Link sharing not preserved", "%s: %s with the link: %v", destPath, audience, err)
+			continue
+		}
+		g.report.note("Link sharing preserved", "%s: %s with the link as %s", destPath, audience, role.String())
+	}
+}
+
+// backlink records the id of copied on src and that of src on copied, so that
+// either can be traced to the other later on. A source copied more than once
+// is linked to its latest copy.
+func (g *Commands) backlink(src, copied *File, destPath string) {
+	if err := g.mut.setAppProperty(src.Id, CopyIdKey, copied.Id); err != nil {
+		g.report.warn("Sources not linked to their copies", "source of %s: %v", destPath, err)
+	} else {
+		g.report.count("Backlinks", "sources linked to copies")
+	}
+
+	if err := g.mut.setAppProperty(copied.Id, SourceIdKey, src.Id); err != nil {
+		g.report.warn("Copies not linked to their sources", "%s: %v", destPath, err)
+	} else {
+		g.report.count("Backlinks", "copies linked to sources")
+	}
+}
+
+const (
+	restrictionDownload = "viewers can't download, print or copy"
+	restrictionSharing  = "only owners can share"
+)
+
+func downloadRestricted(f *File) bool {
+	return f.Labels != nil && f.Labels.Restricted
+}
+
+// preserveRestrictions applies the download and sharing restrictions of src to
+// copied. Read-only locks on content can't be read or set with this version of
+// the Drive API hence aren't carried over.
+func (g *Commands) preserveRestrictions(src, copied *File, destPath string) {
+	var restrictions []string
+	if downloadRestricted(src) {
+		restrictions = append(restrictions, restrictionDownload)
+	}
+	if !src.WritersCanShare {
+		restrictions = append(restrictions, restrictionSharing)
+	}
+	if len(restrictions) < 1 {
+		return
+	}
+
+	described := strings.Join(restrictions, ", ")
+	if err := g.mut.restrict(copied.Id, downloadRestricted(src), src.WritersCanShare); err != nil {
+		g.report.warn("Restrictions not applied", "%s: %s: %v", destPath, described, err)
+		return
+	}
+	g.report.note("Restrictions applied", "%s: %s", destPath, described)
+}
+
+// indexableByOCR reports whether Drive derives the searchable text of
+// files of this mimeType by OCR. That text is not guaranteed to be carried
+// over to copies, which are re-indexed by Drive in its own time.
+func indexableByOCR(mimeType string) bool {
+	return mimeType == "application/pdf" || strings.HasPrefix(mimeType, "image/")
+}
+
+func (g *Commands) checkIndexableText(src, copied *File, destPath string) {
+	if !indexableByOCR(src.MimeType) {
+		return
+	}
+
+	if !g.opts.PreserveIndexableText {
+		g.report.warn("Copies that may have lost their searchable text", "%s", destPath)
+		return
+	}
+
+	// Touching the copy updates its metadata which in turn
+	// queues it up for re-indexing by Drive.
+	if _, err := g.mut.Touch(copied.Id); err != nil {
+		g.report.warn("Copies that may have lost their searchable text", "%s: re-index failed: %v", destPath, err)
+		return
+	}
+	g.report.note("Copies queued for re-indexing", "%s", destPath)
+}
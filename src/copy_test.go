@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingLinker counts the parents inserted, each taking until released.
+type blockingLinker struct {
+	mutator
+	sync.Mutex
+	inserts int
+	release chan bool
+}
+
+func (bl *blockingLinker) insertParent(fileId, parentId string) error {
+	bl.Lock()
+	bl.inserts += 1
+	bl.Unlock()
+	<-bl.release
+	return nil
+}
+
+func TestLinkToOriginOutsideLock(t *testing.T) {
+	bl := &blockingLinker{release: make(chan bool)}
+	g := &Commands{copyDedupe: newCopyDedupe(), mut: bl, report: newReport()}
+
+	origin, first := g.copyDedupe.claim("md5")
+	if !first {
+		t.Fatalf("the first claim should be first")
+	}
+	g.copyDedupe.settle(origin, &File{Id: "copy", Name: "a.txt"}, "parent")
+
+	var wg sync.WaitGroup
+	linked := make([]*File, 3)
+	for i := range linked {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if linked[i], err = g.linkToOrigin(origin, "other", "/other/a.txt"); err != nil {
+				t.Errorf("linkToOrigin: %v", err)
+			}
+		}(i)
+	}
+
+	// Other content is claimed while the link is being made
+	for inserting := false; !inserting; time.Sleep(time.Millisecond) {
+		bl.Lock()
+		inserting = bl.inserts > 0
+		bl.Unlock()
+	}
+	claimed := make(chan bool)
+	go func() {
+		g.copyDedupe.claim("other md5")
+		close(claimed)
+	}()
+	select {
+	case <-claimed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("claiming was held up by linking")
+	}
+
+	close(bl.release)
+	wg.Wait()
+	if bl.inserts != 1 {
+		t.Errorf("got %d parents inserted, want 1", bl.inserts)
+	}
+	for i, f := range linked {
+		if f == nil || f.Id != "copy" {
+			t.Errorf("#%d: got %v, want the origin's copy", i, f)
+		}
+	}
+
+	// Linking to a parent that the copy is already in makes no call
+	if f, err := g.linkToOrigin(origin, "parent", "/parent/b.txt"); err != nil || f == nil || bl.inserts != 1 {
+		t.Errorf("got %v, %v with %d inserts, want the copy without inserting", f, err, bl.inserts)
+	}
+}
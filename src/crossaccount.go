@@ -0,0 +1,238 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// CopyToProfile copies the items in opts.Sources, folders along with their
+// contents, into the folder at the last of opts.Sources on the account that
+// profile is initialized with. File content is streamed from one account to
+// the other. Google Docs have no content of their own to stream, so they are
+// shared with the other account, just for as long as it takes it to make its
+// own copy. Items already at the destination are left as they are, so an
+// interrupted copy picks up where it stopped when run again. The mutations
+// on both accounts are planned along with each other with -dry-run or -plan,
+// and otherwise journaled each in the journal of its account's profile.
+func (g *Commands) CopyToProfile(profile string) error {
+	argc := len(g.opts.Sources)
+	if argc < 2 {
+		return fmt.Errorf("copy: expecting src [src1....] dest got: %v", g.opts.Sources)
+	}
+	if profile == g.context.Profile {
+		return fmt.Errorf("copy: the items are already on the account of profile %q", profile)
+	}
+	if unsupported := acrossUnsupported(g.opts); len(unsupported) > 0 {
+		return fmt.Errorf("copy: -%s can't be used when copying to another profile, only -r, -%s, -%s, -%s and -%s can",
+			strings.Join(unsupported, ", -"), CLIOptionDryRun, CLIOptionPlan, CLIOptionAuditLog, QuietKey)
+	}
+
+	destContext, err := config.Discover(g.context.AbsPath, profile)
+	if err != nil {
+		return fmt.Errorf("copy: profile %q: %v", profile, err)
+	}
+	dg, err := New(destContext, &Options{
+		Quiet:       g.opts.Quiet,
+		StdoutIsTty: g.opts.StdoutIsTty,
+		NoPathIndex: g.opts.NoPathIndex,
+	})
+	if err != nil {
+		return fmt.Errorf("copy: profile %q: %v", profile, err)
+	}
+	if g.plan != nil {
+		dg.plan = g.plan
+		dg.mut = g.plan
+	}
+
+	about, err := dg.rem.About()
+	if err != nil {
+		return fmt.Errorf("copy: profile %q: %v", profile, err)
+	}
+	destEmail := ""
+	if about.User != nil {
+		destEmail = about.User.EmailAddress
+	}
+
+	sources, composedError := g.expandSources(g.opts.Sources[:argc-1])
+	destPath := g.opts.Sources[argc-1]
+	dest, err := dg.remoteMkdirAll(destPath)
+	if err != nil {
+		return fmt.Errorf("copy: %s:%s: %v", profile, destPath, err)
+	}
+
+	g.report = newReport()
+	for _, srcPath := range sources {
+		src, err := g.rem.FindByPath(srcPath)
+		if err == nil && src == nil {
+			err = ErrPathNotExists
+		}
+		if err == nil {
+			err = g.copyAcross(dg, destEmail, src, srcPath, dest, destPath)
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("copy: %s: %v", srcPath, err))
+		}
+	}
+
+	g.report.summarize(g.log)
+	if err := g.flushPlan(); err != nil {
+		return err
+	}
+	return composedError
+}
+
+// acrossUnsupported are the flags set in opts that copying
+// to another profile doesn't honor, which are hence refused.
+func acrossUnsupported(opts *Options) []string {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{CLIOptionPreserveIndexableText, opts.PreserveIndexableText},
+		{CLIOptionPartition, opts.Partition != ""},
+		{CLIOptionShareWith, opts.Meta != nil && len((*opts.Meta)[EmailsKey]) > 0},
+		{CLIOptionDedupeIdentical, opts.DedupeIdentical},
+		{CLIOptionPruneEmptyDirs, opts.PruneEmptyDirs},
+		{CLIOptionUniqueNames, opts.UniqueNames},
+		{CLIOptionMaxFileSize, opts.MaxFileSize > 0},
+		{CLIOptionDestRoot, opts.DestRoot != ""},
+		{CLIOptionWaitReady, opts.WaitReady},
+		{CLIOptionPreserveLinkSharing, opts.PreserveLinkSharing},
+		{CLIOptionThrottleOnLowQuota, opts.ThrottleOnLowQuota != ""},
+		{CLIOptionIncludeShared, opts.IncludeShared},
+		{CLIOptionCheckWritable, opts.CheckWritable},
+		{CLIOptionTrashSourceAfter, opts.TrashSourceAfter > 0},
+		{CLIOptionPreserveRestrictions, opts.PreserveRestrictions},
+		{CLIOptionIntoNewest, opts.DestPick == DestPickNewest},
+		{CLIOptionIntoOldest, opts.DestPick == DestPickOldest},
+		{CLIOptionPausable, opts.Pausable},
+		{CLIOptionBacklink, opts.Backlink},
+		{CLIOptionVerifyCopies, opts.VerifyCopies},
+		{CLIOptionStarIf, opts.StarIf != ""},
+		{CLIOptionCheckExtensions, opts.CheckExtensions || opts.StrictExtensions},
+		{CLIOptionDestTemplate, opts.DestTemplate != ""},
+		{CLIOptionPrefetch, opts.Prefetch},
+		{CLIOptionPreserveFolderMetadata, opts.PreserveFolderMetadata},
+		{CLIOptionSnapshotPath, opts.SnapshotPath != ""},
+		{CLIOptionTreeDiff, opts.TreeDiff},
+		{CLIOptionFollowShortcuts, opts.FollowShortcuts},
+	}
+
+	var unsupported []string
+	for _, flag := range flags {
+		if flag.set {
+			unsupported = append(unsupported, flag.name)
+		}
+	}
+	return unsupported
+}
+
+// copyAcross copies src into the folder parent, at parentPath on the account of dg.
+func (g *Commands) copyAcross(dg *Commands, destEmail string, src *File, srcPath string, parent *File, parentPath string) (err error) {
+	destPath := path.Join(parentPath, src.Name)
+
+	existing, findErr := dg.rem.FindByPath(destPath)
+	if findErr != nil && findErr != ErrPathNotExists {
+		return findErr
+	}
+
+	if src.IsDir {
+		if !g.opts.Recursive {
+			return fmt.Errorf("%s is a folder, copy it with -r", srcPath)
+		}
+		folder := existing
+		if folder == nil || !folder.IsDir {
+			if folder, err = dg.remoteMkdirAll(destPath); err != nil {
+				return err
+			}
+		}
+
+		g.plan.knowPath(folder.Id, destPath)
+
+		var composedError error = nil
+		for child := range g.rem.findChildren(src.Id, false) {
+			childPath := path.Join(srcPath, child.Name)
+			if err := g.copyAcross(dg, destEmail, child, childPath, folder, destPath); err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", childPath, err))
+			}
+		}
+		return composedError
+	}
+
+	if existing != nil {
+		g.report.note("Already at the destination", "%s", destPath)
+		return nil
+	}
+
+	defer func() {
+		g.audit(AuditCopy, src, srcPath, fmt.Sprintf("%s:%s", dg.context.Profile, destPath), err)
+	}()
+
+	if src.BlobAt == "" {
+		_, err = g.copyAcrossByShare(dg, destEmail, src, parent.Id)
+	} else {
+		_, err = g.copyAcrossByStream(dg, src, parent.Id)
+	}
+	if err != nil {
+		return err
+	}
+
+	g.report.count("Copied across", parentPath)
+	return nil
+}
+
+func (g *Commands) copyAcrossByStream(dg *Commands, src *File, parentId string) (*File, error) {
+	// Planned copies aren't made so there is no content to stream
+	if g.plan != nil {
+		return dg.mut.insertContent(nil, src.Name, parentId, src, false)
+	}
+
+	body, err := g.rem.Download(src.Id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return dg.mut.insertContent(body, src.Name, parentId, src, false)
+}
+
+// copyAcrossByShare has the account of dg copy src, which it is
+// given read access to only for as long as it takes to copy it.
+func (g *Commands) copyAcrossByShare(dg *Commands, destEmail string, src *File, parentId string) (*File, error) {
+	if destEmail == "" {
+		return nil, fmt.Errorf("can't share with the other account, its email address is unknown")
+	}
+
+	perm, err := g.mut.insertPermissions(&permission{
+		fileId:      src.Id,
+		value:       destEmail,
+		role:        Reader,
+		accountType: User,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	copied, err := dg.mut.copy(src.Name, parentId, src)
+	if unshareErr := g.mut.deletePermission(src.Id, perm.Id); unshareErr != nil {
+		g.log.LogErrf("copy: %s is still shared with %s: %v\n", src.Name, destEmail, unshareErr)
+	}
+	return copied, err
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"time"
+	"unicode"
+)
+
+// dateReformatter rewrites the dates in names laid out as per the Go time
+// layout from e.g "01-02-2006" into the layout to e.g "2006-01-02".
+type dateReformatter struct {
+	from, to string
+	// minLen and maxLen bound the lengths of dates laid out as per from,
+	// these vary for layouts with elements like "January" or "2".
+	minLen, maxLen int
+}
+
+func newDateReformatter(from, to string) (*dateReformatter, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("reformat-date: expecting both a from and a to layout e.g 01-02-2006 and 2006-01-02")
+	}
+	sample := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	if _, err := time.Parse(from, sample.Format(from)); err != nil {
+		return nil, fmt.Errorf("reformat-date: %q is not a time layout: %v", from, err)
+	}
+
+	dr := &dateReformatter{from: from, to: to, minLen: len(from), maxLen: len(from)}
+	// The longest and shortest months and days of the month
+	for _, t := range []time.Time{
+		time.Date(2006, time.September, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2006, time.May, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if n := len(t.Format(from)); n < dr.minLen {
+			dr.minLen = n
+		} else if n > dr.maxLen {
+			dr.maxLen = n
+		}
+	}
+	return dr, nil
+}
+
+// isDateBoundary reports whether a date can start or end next to r
+// without being a part of a longer number or word.
+func isDateBoundary(r byte) bool {
+	return !unicode.IsDigit(rune(r)) && !unicode.IsLetter(rune(r))
+}
+
+// reformat returns name with every date in the from layout rewritten
+// in the to layout, and whether it found any dates to rewrite.
+func (dr *dateReformatter) reformat(name string) (string, bool) {
+	var reformatted []byte
+	changed := false
+
+	for i := 0; i < len(name); {
+		if i > 0 && !isDateBoundary(name[i-1]) {
+			reformatted = append(reformatted, name[i])
+			i += 1
+			continue
+		}
+
+		matched := 0
+		// The longest date is tried first so that e.g "2" doesn't stop short of "12"
+		for n := dr.maxLen; n >= dr.minLen && matched == 0; n-- {
+			end := i + n
+			if end > len(name) || (end < len(name) && !isDateBoundary(name[end])) {
+				continue
+			}
+			t, err := time.Parse(dr.from, name[i:end])
+			if err != nil {
+				continue
+			}
+			reformatted = append(reformatted, t.Format(dr.to)...)
+			matched = n
+		}
+
+		if matched == 0 {
+			reformatted = append(reformatted, name[i])
+			i += 1
+			continue
+		}
+		changed = true
+		i += matched
+	}
+
+	return string(reformatted), changed
+}
+
+// ReformatDates renames the items in the folders in opts.Sources, and in their
+// descendants if opts.Recursive is set, whose names contain dates laid out as
+// per the time layout from, rewriting those dates in the time layout to. Names
+// without a matching date are left as they are.
+func (g *Commands) ReformatDates(from, to string) error {
+	dr, err := newDateReformatter(from, to)
+	if err != nil {
+		return err
+	}
+
+	if err := g.scopeToDestRoot(g.opts.Sources); err != nil {
+		return err
+	}
+
+	var composedError error = nil
+	seen, renamed := 0, 0
+	for _, folderPath := range g.opts.Sources {
+		folder, err := g.rem.FindByPath(folderPath)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: %v", folderPath, err))
+			continue
+		}
+		if folder == nil || !folder.IsDir {
+			composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: %v", folderPath, ErrPathNotDir))
+			continue
+		}
+
+		s, r, err := g.reformatDatesIn(dr, folder, folderPath)
+		seen, renamed = seen+s, renamed+r
+		if err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+
+	g.log.Logf("%d of %d names had their dates reformatted\n", renamed, seen)
+
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (g *Commands) reformatDatesIn(dr *dateReformatter, folder *File, folderPath string) (seen, renamed int, composedError error) {
+	var children []*File
+	for child := range g.rem.findChildren(folder.Id, false) {
+		children = append(children, child)
+	}
+
+	for _, child := range children {
+		seen += 1
+		childPath := path.Join(folderPath, child.Name)
+
+		if newName, changed := dr.reformat(child.Name); changed && newName != child.Name {
+			if err := g.rename(child, folderPath, newName); err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: %v", childPath, err))
+			} else {
+				renamed += 1
+				g.log.Logf("%s -> %s\n", childPath, newName)
+				// Planned renames aren't made so the old path still holds
+				if g.plan == nil {
+					childPath = path.Join(folderPath, newName)
+				}
+			}
+		}
+
+		if child.IsDir && g.opts.Recursive {
+			s, r, err := g.reformatDatesIn(dr, child, childPath)
+			seen, renamed = seen+s, renamed+r
+			if err != nil {
+				composedError = reComposeError(composedError, err.Error())
+			}
+		}
+	}
+	return seen, renamed, composedError
+}
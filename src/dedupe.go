@@ -0,0 +1,432 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	DedupeStateSuffix = "dedupe.db"
+
+	dedupeQueueBucket    = "dedupe-queue"
+	dedupeQueuedBucket   = "dedupe-queued"
+	dedupeClustersBucket = "dedupe-clusters"
+	dedupeMetaBucket     = "dedupe-meta"
+
+	dedupeRootKey    = "root"
+	dedupeFoldersKey = "folders"
+	dedupeFilesKey   = "files"
+
+	// dedupeProgressEvery is the number of pages between progress reports
+	dedupeProgressEvery = 20
+	// dedupeClustersPerPage is the number of clusters listed per page
+	dedupeClustersPerPage = 10
+)
+
+// dedupeFolder is a folder that is yet to be, or is partially, scanned.
+// PageToken is the cursor of the next page of its children to be scanned.
+type dedupeFolder struct {
+	Id        string `json:"id"`
+	Path      string `json:"path"`
+	PageToken string `json:"pageToken,omitempty"`
+}
+
+type dedupeEntry struct {
+	Id       string    `json:"id"`
+	ParentId string    `json:"parentId,omitempty"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+	// Properties are those of the file, such as those stamped on it by push
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+func byteify(s string) []byte {
+	return []byte(s)
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func (g *Commands) dedupeStatePath() string {
+	if g.opts.StatePath != "" {
+		return g.opts.StatePath
+	}
+	return path.Join(g.context.AbsPathOf(""), config.GDDirSuffix, DedupeStateSuffix)
+}
+
+// Dedupe clusters the files under the source path by their md5 checksums
+// and reports the clusters of duplicates, which are then resolved if a strategy
+// or interactive resolution is set. The scan is checkpointed a page of
+// children at a time into a state file, which keeps memory usage flat for huge
+// folders and lets an interrupted scan be resumed by running Dedupe again.
+func (g *Commands) Dedupe() error {
+	if strategy := g.opts.DedupeStrategy; strategy != "" && !validDedupeStrategy(strategy) {
+		return fmt.Errorf("dedupe: unknown strategy %q, expecting one of %s", strategy, sepJoin(", ", dedupeStrategies...))
+	}
+
+	rootPath := "/"
+	if len(g.opts.Sources) >= 1 {
+		rootPath = g.opts.Sources[0]
+	}
+
+	statePath := g.dedupeStatePath()
+	db, err := bolt.Open(statePath, config.O_RWForAll, nil)
+	if err != nil {
+		return fmt.Errorf("dedupe: state %s: %v", statePath, err)
+	}
+
+	err = g.dedupe(db, rootPath)
+	db.Close()
+
+	if err != nil {
+		return err
+	}
+	// Only a completed scan's state is done with
+	return os.Remove(statePath)
+}
+
+func (g *Commands) dedupe(db *bolt.DB, rootPath string) error {
+	if err := g.dedupeInit(db, rootPath); err != nil {
+		return err
+	}
+
+	if err := g.dedupeScan(db); err != nil {
+		return fmt.Errorf("dedupe: %v\nRun dedupe again to resume from where it stopped", err)
+	}
+
+	if g.opts.DedupeInteractive {
+		return g.dedupeInteractive(db)
+	}
+	if err := g.dedupeReport(db); err != nil {
+		return err
+	}
+	if g.opts.DedupeStrategy == "" {
+		return nil
+	}
+	return g.dedupeAuto(db)
+}
+
+// dedupeInit seeds a fresh state with rootPath or checks that
+// the state being resumed belongs to a scan of rootPath.
+func (g *Commands) dedupeInit(db *bolt.DB, rootPath string) error {
+	var scannedRoot string
+	db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(byteify(dedupeMetaBucket)); meta != nil {
+			scannedRoot = string(meta.Get(byteify(dedupeRootKey)))
+		}
+		return nil
+	})
+
+	if scannedRoot != "" {
+		if scannedRoot != rootPath {
+			return fmt.Errorf("dedupe: state %s belongs to a scan of %s, remove it to scan %s",
+				g.dedupeStatePath(), scannedRoot, rootPath)
+		}
+		g.log.Logf("Resuming the scan of %s\n", rootPath)
+		return nil
+	}
+
+	root, err := g.rem.FindByPath(rootPath)
+	if err != nil {
+		return fmt.Errorf("dedupe: %s: %v", rootPath, err)
+	}
+	if root == nil || !root.IsDir {
+		return fmt.Errorf("dedupe: %s: %v", rootPath, ErrPathNotDir)
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{dedupeQueueBucket, dedupeQueuedBucket, dedupeClustersBucket, dedupeMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(byteify(name)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(byteify(dedupeMetaBucket)).Put(byteify(dedupeRootKey), byteify(rootPath)); err != nil {
+			return err
+		}
+		return enqueueDedupeFolder(tx, &dedupeFolder{Id: root.Id, Path: rootPath})
+	})
+}
+
+// enqueueDedupeFolder queues folder to be scanned unless it has been queued
+// before, as a folder with several parents is reached through each of them.
+func enqueueDedupeFolder(tx *bolt.Tx, folder *dedupeFolder) error {
+	// States saved before folders were recorded lack the bucket
+	queued, err := tx.CreateBucketIfNotExists(byteify(dedupeQueuedBucket))
+	if err != nil {
+		return err
+	}
+	if queued.Get(byteify(folder.Id)) != nil {
+		return nil
+	}
+	if err := queued.Put(byteify(folder.Id), byteify(folder.Path)); err != nil {
+		return err
+	}
+
+	queue := tx.Bucket(byteify(dedupeQueueBucket))
+	seq, err := queue.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(folder)
+	if err != nil {
+		return err
+	}
+	return queue.Put(sequenceKey(seq), data)
+}
+
+func dedupeCounter(meta *bolt.Bucket, key string) uint64 {
+	if data := meta.Get(byteify(key)); len(data) == 8 {
+		return binary.BigEndian.Uint64(data)
+	}
+	return 0
+}
+
+func incrementDedupeCounter(meta *bolt.Bucket, key string, n uint64) (uint64, error) {
+	count := dedupeCounter(meta, key) + n
+	return count, meta.Put(byteify(key), sequenceKey(count))
+}
+
+func (g *Commands) dedupeScan(db *bolt.DB) error {
+	pageSize := g.opts.PageSize
+	pages := 0
+
+	for {
+		var key []byte
+		folder := &dedupeFolder{}
+		pending := 0
+
+		err := db.View(func(tx *bolt.Tx) error {
+			queue := tx.Bucket(byteify(dedupeQueueBucket))
+			pending = queue.Stats().KeyN
+			k, v := queue.Cursor().First()
+			if k == nil {
+				return nil
+			}
+			key = append([]byte{}, k...)
+			return json.Unmarshal(v, folder)
+		})
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return nil
+		}
+
+		children, nextPageToken, err := g.rem.listChildrenPage(folder.Id, folder.PageToken, pageSize)
+		if err != nil {
+			return fmt.Errorf("%s: %v", folder.Path, err)
+		}
+
+		var files, scannedFolders, scannedFiles uint64
+		// The page is checkpointed atomically so that a resumed scan
+		// neither skips nor double counts any of its children.
+		err = db.Update(func(tx *bolt.Tx) error {
+			clusters := tx.Bucket(byteify(dedupeClustersBucket))
+			for _, child := range children {
+				childPath := path.Join(folder.Path, child.Name)
+				if child.IsDir {
+					if err := enqueueDedupeFolder(tx, &dedupeFolder{Id: child.Id, Path: childPath}); err != nil {
+						return err
+					}
+					continue
+				}
+
+				files += 1
+				if child.Md5Checksum == "" {
+					continue
+				}
+
+				var entries []*dedupeEntry
+				if data := clusters.Get(byteify(child.Md5Checksum)); data != nil {
+					if err := json.Unmarshal(data, &entries); err != nil {
+						return err
+					}
+				}
+				// A file with several parents is listed in each of
+				// them, but it is one file rather than duplicates.
+				if dedupeEntryIndex(entries, child.Id) >= 0 {
+					continue
+				}
+				entries = append(entries, &dedupeEntry{
+					Id:         child.Id,
+					ParentId:   folder.Id,
+					Path:       childPath,
+					Size:       child.Size,
+					ModTime:    child.ModTime,
+					Properties: child.Properties,
+				})
+				data, err := json.Marshal(entries)
+				if err != nil {
+					return err
+				}
+				if err := clusters.Put(byteify(child.Md5Checksum), data); err != nil {
+					return err
+				}
+			}
+
+			queue := tx.Bucket(byteify(dedupeQueueBucket))
+			meta := tx.Bucket(byteify(dedupeMetaBucket))
+			if scannedFiles, err = incrementDedupeCounter(meta, dedupeFilesKey, files); err != nil {
+				return err
+			}
+			scannedFolders = dedupeCounter(meta, dedupeFoldersKey)
+
+			if nextPageToken != "" {
+				folder.PageToken = nextPageToken
+				data, err := json.Marshal(folder)
+				if err != nil {
+					return err
+				}
+				return queue.Put(key, data)
+			}
+
+			if scannedFolders, err = incrementDedupeCounter(meta, dedupeFoldersKey, 1); err != nil {
+				return err
+			}
+			return queue.Delete(key)
+		})
+		if err != nil {
+			return err
+		}
+
+		pages += 1
+		if pages%dedupeProgressEvery == 0 {
+			g.log.Logf("Scanned %d folders and %d files, %d folders pending\n", scannedFolders, scannedFiles, pending)
+		}
+	}
+}
+
+func dedupeEntryIndex(entries []*dedupeEntry, id string) int {
+	for i, entry := range entries {
+		if entry.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// dedupeClusters calls fn with each cluster of files that have the same md5
+// checksum and size, in the order of their checksums, until fn returns an error.
+func dedupeClusters(db *bolt.DB, fn func(md5 string, cluster []*dedupeEntry) error) error {
+	return db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(byteify(dedupeClustersBucket)).ForEach(func(md5, data []byte) error {
+			var entries []*dedupeEntry
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return err
+			}
+
+			bySize := make(map[int64][]*dedupeEntry)
+			var sizes []int64
+			for _, entry := range entries {
+				if _, seen := bySize[entry.Size]; !seen {
+					sizes = append(sizes, entry.Size)
+				}
+				bySize[entry.Size] = append(bySize[entry.Size], entry)
+			}
+
+			for _, size := range sizes {
+				if cluster := bySize[size]; len(cluster) >= 2 {
+					if err := fn(string(md5), cluster); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// logDedupeCluster logs the files in cluster, marking the one that
+// would be kept if strategy is set.
+func (g *Commands) logDedupeCluster(md5 string, cluster []*dedupeEntry, strategy string) {
+	keep := -1
+	if strategy != "" {
+		keep = dedupeKeeper(cluster, strategy)
+	}
+
+	g.log.Logf("\n%s %s x %d\n", md5, prettyBytes(cluster[0].Size), len(cluster))
+	for i, entry := range cluster {
+		mark := " "
+		if i == keep {
+			mark = "*"
+		}
+		g.log.Logf(" %s%d) %s%s\n", mark, i+1, entry.Path, prettyProperties(entry.Properties))
+	}
+}
+
+func (g *Commands) dedupeReport(db *bolt.DB) error {
+	var folders, files uint64
+	db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(byteify(dedupeMetaBucket))
+		folders = dedupeCounter(meta, dedupeFoldersKey)
+		files = dedupeCounter(meta, dedupeFilesKey)
+		return nil
+	})
+	g.log.Logf("Scanned %d folders and %d files\n", folders, files)
+
+	clusterCount := 0
+	reclaimable := int64(0)
+
+	// Huge accounts have more clusters than fit on a screen
+	paginate, listing := g.opts.canPrompt(), true
+
+	err := dedupeClusters(db, func(md5 string, cluster []*dedupeEntry) error {
+		clusterCount += 1
+		reclaimable += cluster[0].Size * int64(len(cluster)-1)
+
+		if listing {
+			g.logDedupeCluster(md5, cluster, g.opts.DedupeStrategy)
+			if paginate && clusterCount%dedupeClustersPerPage == 0 {
+				listing = nextPage()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	g.log.Logf("\n%d clusters of duplicates, %s reclaimable\n", clusterCount, prettyBytes(reclaimable))
+	return nil
+}
+
+// prettyProperties formats properties as " [key=value, ...]" ordered by key,
+// or as "" if there are none.
+func prettyProperties(props map[string]string) string {
+	if len(props) < 1 {
+		return ""
+	}
+	var pairs []string
+	for key, value := range props {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return " [" + strings.Join(pairs, ", ") + "]"
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/odeke-em/drive/config"
+)
+
+// openDedupeState opens a fresh dedupe state, removed by calling done.
+func openDedupeState(t *testing.T) (db *bolt.DB, done func()) {
+	dir, err := ioutil.TempDir("", "dedupe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = bolt.Open(filepath.Join(dir, DedupeStateSuffix), config.O_RWForAll, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDedupeScanVisitsFoldersOnce(t *testing.T) {
+	fd := newFakeDrive()
+	a := fd.add("root", "a", nil, true)
+	b := fd.add("root", "b", nil, true)
+	shared := fd.add(a.Id, "shared", nil, true)
+	fd.link(shared, b.Id)
+	fd.add(shared.Id, "x.txt", []byte("x"), false)
+
+	g := commandsOn(fd, &Options{})
+	db, done := openDedupeState(t)
+	defer done()
+
+	if err := g.dedupeInit(db, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.dedupeScan(db); err != nil {
+		t.Fatal(err)
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(byteify(dedupeMetaBucket))
+		folders, files := dedupeCounter(meta, dedupeFoldersKey), dedupeCounter(meta, dedupeFilesKey)
+		if folders != 4 || files != 1 {
+			t.Errorf("scanned %d folders and %d files, want 4 and 1", folders, files)
+		}
+		return nil
+	})
+}
+
+// trashRecorder records the ids trashed.
+type trashRecorder struct {
+	mutator
+	trashed []string
+}
+
+func (tr *trashRecorder) Trash(id string) error {
+	tr.trashed = append(tr.trashed, id)
+	return nil
+}
+
+func TestDedupeKeepsFileWithTwoParents(t *testing.T) {
+	fd := newFakeDrive()
+	a := fd.add("root", "a", nil, true)
+	b := fd.add(a.Id, "b", nil, true)
+	x := fd.add(a.Id, "x.txt", []byte("x"), false)
+	fd.link(x, b.Id)
+	x.Md5Checksum = "md5x"
+	y := fd.add(b.Id, "y.txt", []byte("x"), false)
+	y.Md5Checksum = "md5x"
+
+	g := commandsOn(fd, &Options{})
+	tr := &trashRecorder{}
+	g.mut = tr
+	db, done := openDedupeState(t)
+	defer done()
+
+	if err := g.dedupeInit(db, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.dedupeScan(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var clusters [][]*dedupeEntry
+	dedupeClusters(db, func(md5 string, cluster []*dedupeEntry) error {
+		clusters = append(clusters, cluster)
+		return nil
+	})
+	if len(clusters) != 1 || len(clusters[0]) != 2 {
+		t.Fatalf("got clusters %v, want one of x.txt and y.txt", clusters)
+	}
+
+	g.resolveDedupeCluster(clusters[0], DedupeKeepShallowest)
+	if len(tr.trashed) != 1 || tr.trashed[0] != y.Id {
+		t.Errorf("trashed %v, want just y.txt %s", tr.trashed, y.Id)
+	}
+
+	// Clusters scanned before files were told apart by id
+	// list the kept file once for each of its parents.
+	tr.trashed = nil
+	twice := []*dedupeEntry{
+		{Id: x.Id, ParentId: a.Id, Path: "/a/x.txt"},
+		{Id: x.Id, ParentId: b.Id, Path: "/a/b/x.txt"},
+	}
+	g.resolveDedupeCluster(twice, DedupeReplaceWithShortcut)
+	if len(tr.trashed) != 0 {
+		t.Errorf("trashed %v, want the kept file left alone", tr.trashed)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	DedupeKeepNewest          = "keep-newest"
+	DedupeKeepShallowest      = "keep-shallowest-path"
+	DedupeReplaceWithShortcut = "replace-with-shortcut"
+)
+
+var dedupeStrategies = []string{DedupeKeepNewest, DedupeKeepShallowest, DedupeReplaceWithShortcut}
+
+// dedupeStrategyShortKeys are the answers to the prompt of interactive resolution.
+var dedupeStrategyShortKeys = map[string]string{
+	"n": DedupeKeepNewest,
+	"p": DedupeKeepShallowest,
+	"s": DedupeReplaceWithShortcut,
+}
+
+const (
+	dedupeTrashedSection  = "Trashed duplicates"
+	dedupeReplacedSection = "Duplicates replaced with shortcuts"
+	dedupeFailedSection   = "Failed to resolve"
+)
+
+func validDedupeStrategy(strategy string) bool {
+	for _, s := range dedupeStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+func pathDepth(p string) int {
+	return strings.Count(strings.Trim(p, "/"), "/")
+}
+
+// dedupeKeeper returns the index of the file in cluster that strategy keeps:
+// the most recently modified one for keep-newest and otherwise the one that
+// is nearest the root, which shortcuts then point to. Ties go to the other
+// criterion, then to the shortest path.
+func dedupeKeeper(cluster []*dedupeEntry, strategy string) int {
+	newer := func(a, b *dedupeEntry) bool { return a.ModTime.After(b.ModTime) }
+	shallower := func(a, b *dedupeEntry) bool { return pathDepth(a.Path) < pathDepth(b.Path) }
+
+	first, second := shallower, newer
+	if strategy == DedupeKeepNewest {
+		first, second = newer, shallower
+	}
+
+	keep := 0
+	for i, entry := range cluster[1:] {
+		cur := cluster[keep]
+		switch {
+		case first(entry, cur):
+		case first(cur, entry):
+			continue
+		case second(entry, cur):
+		case second(cur, entry):
+			continue
+		case len(entry.Path) < len(cur.Path):
+		default:
+			continue
+		}
+		keep = i + 1
+	}
+	return keep
+}
+
+// dedupeAuto resolves every cluster with opts.DedupeStrategy
+// after a confirmation, unless forced.
+func (g *Commands) dedupeAuto(db *bolt.DB) error {
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			return fmt.Errorf("dedupe: noPrompt is set, use `%s` to resolve the duplicates above with %s",
+				ForceKey, g.opts.DedupeStrategy)
+		}
+		if !promptForChanges(fmt.Sprintf("Resolve the duplicates above with %s, keeping those marked *? [Y/n]:", g.opts.DedupeStrategy)) {
+			return nil
+		}
+	}
+
+	g.report = newReport()
+	err := dedupeClusters(db, func(md5 string, cluster []*dedupeEntry) error {
+		g.resolveDedupeCluster(cluster, g.opts.DedupeStrategy)
+		return nil
+	})
+	g.report.summarize(g.log)
+	return err
+}
+
+var errDedupeQuit = fmt.Errorf("dedupe: quit")
+
+// dedupeInteractive lists the clusters one at a time, prompting
+// for the strategy to resolve each of them with.
+func (g *Commands) dedupeInteractive(db *bolt.DB) error {
+	if !g.opts.canPrompt() {
+		return fmt.Errorf("dedupe: noPrompt is set, use `-%s` to resolve the duplicates without prompting", CLIOptionDedupeAuto)
+	}
+
+	g.report = newReport()
+	question := fmt.Sprintf("Resolve with [n] %s, [p] %s, [s] %s, skip with [enter] or [%s]uit: ",
+		DedupeKeepNewest, DedupeKeepShallowest, DedupeReplaceWithShortcut, QuitShortKey)
+
+	err := dedupeClusters(db, func(md5 string, cluster []*dedupeEntry) error {
+		g.logDedupeCluster(md5, cluster, "")
+
+		answer := strings.ToLower(prompt(os.Stdin, os.Stdout, question))
+		if answer == QuitShortKey {
+			return errDedupeQuit
+		}
+		if strategy, ok := dedupeStrategyShortKeys[answer]; ok {
+			g.resolveDedupeCluster(cluster, strategy)
+		}
+		return nil
+	})
+	g.report.summarize(g.log)
+
+	if err == errDedupeQuit {
+		return nil
+	}
+	return err
+}
+
+// resolveDedupeCluster keeps the file in cluster chosen by strategy and trashes
+// the others, replacing them with shortcuts to the kept one if so set.
+func (g *Commands) resolveDedupeCluster(cluster []*dedupeEntry, strategy string) {
+	keep := cluster[dedupeKeeper(cluster, strategy)]
+
+	for _, entry := range cluster {
+		// Trashing another path of the file kept would trash it
+		if entry.Id == keep.Id {
+			continue
+		}
+
+		if strategy == DedupeReplaceWithShortcut {
+			if entry.ParentId == "" {
+				g.report.warn(dedupeFailedSection, "%s: its folder is unknown, scan again to replace it", entry.Path)
+				continue
+			}
+			if _, err := g.rem.createShortcut(path.Base(entry.Path), entry.ParentId, keep.Id); err != nil {
+				g.report.warn(dedupeFailedSection, "%s: %v", entry.Path, err)
+				continue
+			}
+		}
+
+		err := g.mut.Trash(entry.Id)
+		g.audit(AuditTrash, &File{Id: entry.Id, Size: entry.Size}, entry.Path, "", err)
+		switch {
+		case err != nil:
+			g.report.warn(dedupeFailedSection, "%s: %v", entry.Path, err)
+		case strategy == DedupeReplaceWithShortcut:
+			g.report.note(dedupeReplacedSection, "%s -> %s", entry.Path, keep.Path)
+		default:
+			g.report.note(dedupeTrashedSection, "%s (kept %s)", entry.Path, keep.Path)
+		}
+	}
+}
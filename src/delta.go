@@ -0,0 +1,197 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// DeltaThreshold is the size from which the block maps of files are kept
+	// in the index, so that edits that keep their size are told apart from touches.
+	DeltaThreshold = 8 * 1024 * 1024
+
+	deltaMinBlockSize = 16 * 1024
+	deltaMaxBlockSize = 256 * 1024
+	// deltaBoundaryMask makes blocks about 64KB past the minimum on average
+	deltaBoundaryMask = 1<<16 - 1
+)
+
+// gearTable maps each byte to a pseudo-random value for the rolling hash.
+// It is generated from a fixed seed so that block maps are stable.
+var gearTable = func() (table [256]uint64) {
+	seed := uint64(0)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// blockBoundary returns the length of the first block of data, cut where
+// the rolling hash of the latest bytes matches deltaBoundaryMask, or all of
+// data if there is no such place.
+func blockBoundary(data []byte) int {
+	hash := uint64(0)
+	for i, b := range data {
+		hash = (hash << 1) + gearTable[b]
+		if i+1 >= deltaMinBlockSize && hash&deltaBoundaryMask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// blockMap splits the content read from r into content-defined blocks and
+// returns the checksums of the blocks. Since blocks are cut by content rather
+// than at fixed offsets, an edit only changes the blocks around it.
+func blockMap(r io.Reader) ([]string, error) {
+	var blocks []string
+
+	buf := make([]byte, deltaMaxBlockSize)
+	n, eof := 0, false
+	for {
+		if !eof {
+			m, err := io.ReadFull(r, buf[n:])
+			n += m
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if n == 0 {
+			return blocks, nil
+		}
+
+		cut := blockBoundary(buf[:n])
+		sum := md5.Sum(buf[:cut])
+		// Half of the checksum is plenty to tell the blocks of a file apart
+		blocks = append(blocks, fmt.Sprintf("%x", sum[:8]))
+		n = copy(buf, buf[cut:n])
+	}
+}
+
+func localBlockMap(fsPath string) ([]string, error) {
+	fh, err := os.Open(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return blockMap(fh)
+}
+
+// changedBlocks returns the number of blocks in current that aren't in cached.
+func changedBlocks(cached, current []string) int {
+	known := make(map[string]bool)
+	for _, block := range cached {
+		known[block] = true
+	}
+
+	changed := 0
+	for _, block := range current {
+		if !known[block] {
+			changed += 1
+		}
+	}
+	return changed
+}
+
+func sameBlocks(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// indexBlocks sets the block map of the file at fsPath on index if it is large enough.
+func indexBlocks(index *config.Index, fsPath string, size int64) error {
+	if size < DeltaThreshold {
+		return nil
+	}
+	blocks, err := localBlockMap(fsPath)
+	if err != nil {
+		return err
+	}
+	index.Blocks = blocks
+	return nil
+}
+
+// createBlockIndex is createIndex for files with a local copy at fsPath,
+// whose block map is kept as well if it is large enough.
+func (g *Commands) createBlockIndex(f *File, fsPath string) error {
+	if f == nil {
+		return config.ErrDerefNilIndex
+	}
+	index := f.ToIndex()
+	if !f.IsDir {
+		if err := indexBlocks(index, fsPath, f.Size); err != nil {
+			g.log.LogErrf("blockMap %s: %v\n", fsPath, err)
+		}
+	}
+	return g.context.SerializeIndex(index)
+}
+
+// localChanged tells whether the content of the local copy of remote at fsPath
+// has changed since they were last synced, by comparing its block map with the
+// one in the index. known is false if there is no block map to compare with.
+func (g *Commands) localChanged(relPath, fsPath string, remote *File) (changed, known bool) {
+	if remote == nil || remote.IsDir || remote.Id == "" {
+		return false, false
+	}
+	index := g.deserializeIndex(remote.Id)
+	if index == nil || len(index.Blocks) < 1 {
+		return false, false
+	}
+
+	blocks, err := localBlockMap(fsPath)
+	if err != nil {
+		return false, false
+	}
+	if sameBlocks(index.Blocks, blocks) {
+		return false, true
+	}
+	if g.opts.Verbose {
+		g.log.Logf("%s: %d of %d blocks changed\n", relPath, changedBlocks(index.Blocks, blocks), len(blocks))
+	}
+	return true, true
+}
+
+// remoteChanged tells whether the content of remote has changed since it was
+// last synced, by comparing its checksum with the one in the index. known is
+// false if it wasn't synced with a block map or has no checksum.
+func (g *Commands) remoteChanged(remote *File) (changed, known bool) {
+	if remote == nil || remote.IsDir || remote.Id == "" || remote.Md5Checksum == "" {
+		return false, false
+	}
+	index := g.deserializeIndex(remote.Id)
+	if index == nil || len(index.Blocks) < 1 || index.Md5Checksum == "" {
+		return false, false
+	}
+	return index.Md5Checksum != remote.Md5Checksum, true
+}
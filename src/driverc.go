@@ -0,0 +1,77 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	DriveRcSuffix = ".driverc"
+)
+
+// driveRc is the settings in the .driverc at the root of a drive,
+// keyed by section and then by key. A .driverc is made up of
+//
+//	# comments
+//	[section]
+//	key = value
+//
+// and keys outside of any section are in the "" section.
+type driveRc map[string]map[string]string
+
+// readDriveRc reads the .driverc at p. A missing .driverc has no settings.
+func readDriveRc(p string) (driveRc, error) {
+	rc := make(driveRc)
+
+	lines, err := readCommentedFile(p, "#")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rc, nil
+		}
+		return nil, err
+	}
+
+	section := ""
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("%s: line %d: expecting key = value, got %q", p, i+1, line)
+		}
+		if rc[section] == nil {
+			rc[section] = make(map[string]string)
+		}
+		rc[section][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return rc, nil
+}
+
+// section returns the settings in the named section, which
+// is empty if the .driverc or the section don't exist.
+func (rc driveRc) section(name string) map[string]string {
+	if rc == nil {
+		return nil
+	}
+	return rc[name]
+}
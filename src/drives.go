@@ -0,0 +1,255 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+)
+
+// SharedDrivePrefix marks a path segment as the name of a shared drive
+// e.g `td:Marketing/Assets` is the folder Assets in the shared drive Marketing.
+const SharedDrivePrefix = "td:"
+
+// sharedDrive is a shared drive, formerly a Team Drive, that the user is a member of.
+type sharedDrive struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// sharedDrives lists the shared drives that the user is a member of. The Drive
+// client in use predates shared drives so the listing is requested directly.
+func (r *Remote) sharedDrives() ([]*sharedDrive, error) {
+	var drives []*sharedDrive
+	pageToken := ""
+	for {
+		params := url.Values{}
+		params.Set("maxResults", "100")
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		resp, err := r.client.Get(r.service.BasePath + "drives?" + params.Encode())
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items         []*sharedDrive `json:"items"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		if err := decodeResponse(resp, &page); err != nil {
+			return nil, err
+		}
+
+		drives = append(drives, page.Items...)
+		if page.NextPageToken == "" {
+			return drives, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// splitSharedDrivePath splits p at its last segment that names a shared drive,
+// returning the name of the drive and the segments of the path under it.
+func splitSharedDrivePath(p string) (name string, rest []string, ok bool) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(parts[i], SharedDrivePrefix) {
+			continue
+		}
+		name = strings.TrimPrefix(parts[i], SharedDrivePrefix)
+		for _, part := range parts[i+1:] {
+			if part != "" {
+				rest = append(rest, part)
+			}
+		}
+		return name, rest, name != ""
+	}
+	return "", nil, false
+}
+
+// sharedDriveIndex keeps the ids of shared drives by name and the ids of
+// the folders known to be in them, so that listings of their children
+// can be scoped to their drives as the API requires.
+type sharedDriveIndex struct {
+	sync.Mutex
+	// ids are the ids of the shared drives by name, nil until listed
+	ids map[string]string
+	// driveOf maps the ids of folders in shared drives to their drives' ids
+	driveOf map[string]string
+}
+
+func (si *sharedDriveIndex) mark(folderId, driveId string) {
+	if si == nil {
+		return
+	}
+	si.Lock()
+	defer si.Unlock()
+	if si.driveOf == nil {
+		si.driveOf = make(map[string]string)
+	}
+	si.driveOf[folderId] = driveId
+}
+
+func (si *sharedDriveIndex) drive(folderId string) string {
+	if si == nil {
+		return ""
+	}
+	si.Lock()
+	defer si.Unlock()
+	return si.driveOf[folderId]
+}
+
+// sharedDriveRoot returns the root folder of the shared drive called name.
+func (r *Remote) sharedDriveRoot(name string) (*File, error) {
+	r.drives.Lock()
+	if r.drives.ids == nil {
+		drives, err := r.sharedDrives()
+		if err != nil {
+			r.drives.Unlock()
+			return nil, err
+		}
+		r.drives.ids = make(map[string]string)
+		for _, d := range drives {
+			r.drives.ids[d.Name] = d.Id
+		}
+	}
+	id, ok := r.drives.ids[name]
+	r.drives.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no shared drive named %s", customQuote(name))
+	}
+	r.drives.mark(id, id)
+	return r.FindById(id)
+}
+
+// findBySharedDrivePath resolves p if it is in a shared drive, ok is false otherwise.
+func (r *Remote) findBySharedDrivePath(p string, trashed bool) (file *File, ok bool, err error) {
+	name, rest, ok := splitSharedDrivePath(p)
+	if !ok {
+		return nil, false, nil
+	}
+	root, err := r.sharedDriveRoot(name)
+	if err != nil || len(rest) < 1 {
+		return root, true, err
+	}
+	file, err = r.findByPathRecvRaw(root.Id, rest, trashed)
+	return file, true, err
+}
+
+// inSharedDrive tells whether any of the folders with ids is known to be in
+// a shared drive, where items can only have one parent.
+func (r *Remote) inSharedDrive(ids ...string) bool {
+	for _, id := range ids {
+		if r.drives.drive(id) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// markSharedFolders records the folders of files as being in the drive with
+// driveId as they are passed on.
+func (r *Remote) markSharedFolders(driveId string, files chan *File) chan *File {
+	marked := make(chan *File)
+	go func() {
+		defer close(marked)
+		for f := range files {
+			if f != nil && f.IsDir {
+				r.drives.mark(f.Id, driveId)
+			}
+			marked <- f
+		}
+	}()
+	return marked
+}
+
+var parentsQueryRegexp = regexp.MustCompile(`"((?:[^"\\]|\\.)+)" in parents`)
+
+// sharedDrivesTransport adds the parameters that requests to the Drive API
+// need to reach items in shared drives, which the client in use predates.
+type sharedDrivesTransport struct {
+	base   http.RoundTripper
+	drives *sharedDriveIndex
+}
+
+func (t *sharedDrivesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.URL.Host != "www.googleapis.com" || !strings.Contains(req.URL.Path, "/drive/v2/") {
+		return base.RoundTrip(req)
+	}
+
+	u := *req.URL
+	u.RawQuery = t.params(req.Method, u.Path, u.Query()).Encode()
+	scoped := new(http.Request)
+	*scoped = *req
+	scoped.URL = &u
+	return base.RoundTrip(scoped)
+}
+
+// params adds the shared drive parameters to those of a request.
+func (t *sharedDrivesTransport) params(method, urlPath string, params url.Values) url.Values {
+	params.Set("supportsAllDrives", "true")
+	if method != "GET" || !strings.HasSuffix(urlPath, "/files") {
+		return params
+	}
+
+	params.Set("includeItemsFromAllDrives", "true")
+	if m := parentsQueryRegexp.FindStringSubmatch(params.Get("q")); m != nil {
+		parentId := strings.Replace(strings.Replace(m[1], "\\\"", "\"", -1), "\\\\", "\\", -1)
+		if driveId := t.drives.drive(parentId); driveId != "" {
+			params.Set("corpora", "drive")
+			params.Set("driveId", driveId)
+		}
+	}
+	return params
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Drives lists the shared drives that the user is a member of.
+func (g *Commands) Drives() error {
+	drives, err := g.rem.sharedDrives()
+	if err != nil {
+		return fmt.Errorf("drives: %v", err)
+	}
+
+	for _, d := range drives {
+		if g.emitter != nil {
+			g.emitter.emit(d)
+			continue
+		}
+		g.log.Logf("%-20s %s\n", d.Id, d.Name)
+	}
+	return nil
+}
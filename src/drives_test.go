@@ -0,0 +1,140 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSharedDrivePath(t *testing.T) {
+	tests := []struct {
+		path string
+		name string
+		rest []string
+		ok   bool
+	}{
+		{path: "/td:Marketing", name: "Marketing", ok: true},
+		{path: "/td:Marketing/Assets/2020", name: "Marketing", rest: []string{"Assets", "2020"}, ok: true},
+		{path: "/work/td:Marketing/Assets/", name: "Marketing", rest: []string{"Assets"}, ok: true},
+		{path: "/td:A/td:B/c", name: "B", rest: []string{"c"}, ok: true},
+		{path: "/td:", ok: false},
+		{path: "/Marketing/Assets", ok: false},
+		{path: "/", ok: false},
+	}
+
+	for _, tt := range tests {
+		name, rest, ok := splitSharedDrivePath(tt.path)
+		if name != tt.name || !reflect.DeepEqual(rest, tt.rest) || ok != tt.ok {
+			t.Errorf("%s: got (%q, %q, %v) want (%q, %q, %v)", tt.path, name, rest, ok, tt.name, tt.rest, tt.ok)
+		}
+	}
+}
+
+type recordingTransport struct {
+	req *http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.req = req
+	return nil, fmt.Errorf("not sent")
+}
+
+func TestSharedDrivesTransport(t *testing.T) {
+	drives := &sharedDriveIndex{}
+	drives.mark("folder1", "drive1")
+
+	tests := []struct {
+		method string
+		url    string
+		want   url.Values
+	}{
+		{
+			method: "GET",
+			url:    "https://www.googleapis.com/drive/v2/files?q=" + url.QueryEscape(`"folder1" in parents and trashed=false`),
+			want: url.Values{
+				"q":                         {`"folder1" in parents and trashed=false`},
+				"supportsAllDrives":         {"true"},
+				"includeItemsFromAllDrives": {"true"},
+				"corpora":                   {"drive"},
+				"driveId":                   {"drive1"},
+			},
+		},
+		{
+			method: "GET",
+			url:    "https://www.googleapis.com/drive/v2/files?q=" + url.QueryEscape(`"root" in parents`),
+			want: url.Values{
+				"q":                         {`"root" in parents`},
+				"supportsAllDrives":         {"true"},
+				"includeItemsFromAllDrives": {"true"},
+			},
+		},
+		{
+			method: "PATCH",
+			url:    "https://www.googleapis.com/drive/v2/files/abc?addParents=folder1",
+			want: url.Values{
+				"addParents":        {"folder1"},
+				"supportsAllDrives": {"true"},
+			},
+		},
+		{
+			method: "POST",
+			url:    "https://www.googleapis.com/upload/drive/v2/files?uploadType=multipart",
+			want: url.Values{
+				"uploadType":        {"multipart"},
+				"supportsAllDrives": {"true"},
+			},
+		},
+		{
+			method: "GET",
+			url:    "https://accounts.google.com/o/oauth2/token?x=1",
+			want:   url.Values{"x": {"1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		base := &recordingTransport{}
+		transport := &sharedDrivesTransport{base: base, drives: drives}
+		req, err := http.NewRequest(tt.method, tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport.RoundTrip(req)
+
+		if got := base.req.URL.Query(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: got params %v want %v", tt.method, tt.url, got, tt.want)
+		}
+		if req.URL.String() != tt.url {
+			t.Errorf("%s: the original request was changed to %s", tt.url, req.URL)
+		}
+	}
+}
+
+func TestInSharedDrive(t *testing.T) {
+	r := &Remote{drives: &sharedDriveIndex{}}
+	r.drives.mark("shared", "drive1")
+	if !r.inSharedDrive("mine", "shared") {
+		t.Errorf("expected a shared drive folder to be found")
+	}
+	if r.inSharedDrive("mine", "root") {
+		t.Errorf("expected no shared drive folders")
+	}
+	if (&Remote{}).inSharedDrive("shared") {
+		t.Errorf("expected no shared drive folders without an index")
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DuFormatCSV = "csv"
+
+	// GoogleAppsMimeTypePrefix prefixes the mime types of Google-native
+	// documents, which report no size as they don't count against the quota
+	GoogleAppsMimeTypePrefix = "application/vnd.google-apps."
+)
+
+var duCSVHeader = []string{"path", "bytes", "files", "docs", "depth"}
+
+// duEntry is the usage of a folder: the bytes of the files under it, at any
+// depth, and how many files and Google-native documents there are.
+type duEntry struct {
+	Path  string
+	Bytes int64
+	Files int
+	Docs  int
+	Depth int
+}
+
+func (de *duEntry) record() []string {
+	return []string{
+		de.Path, fmt.Sprintf("%d", de.Bytes),
+		fmt.Sprintf("%d", de.Files), fmt.Sprintf("%d", de.Docs),
+		fmt.Sprintf("%d", de.Depth),
+	}
+}
+
+type byDuBytes []*duEntry
+
+func (b byDuBytes) Len() int      { return len(b) }
+func (b byDuBytes) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byDuBytes) Less(i, j int) bool {
+	if b[i].Bytes != b[j].Bytes {
+		return b[i].Bytes > b[j].Bytes
+	}
+	return b[i].Path < b[j].Path
+}
+
+func isGoogleNative(f *File) bool {
+	return f != nil && !f.IsDir && strings.HasPrefix(f.MimeType, GoogleAppsMimeTypePrefix)
+}
+
+// Du reports the storage used under each of opts.Sources, per folder down to
+// depth levels below the source, largest first, followed by the totals. With
+// format "csv", the report is written to stdout instead, for spreadsheets.
+// Google-native documents add to the bytes as per opts.NativeSizePolicy.
+func (g *Commands) Du(byId bool, depth int, format string) error {
+	if format != "" && format != DuFormatCSV {
+		return fmt.Errorf("du: unknown format %q, expecting %s", format, DuFormatCSV)
+	}
+	if err := g.prepareNativeSizer(); err != nil {
+		return fmt.Errorf("du: %v", err)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var composedError error = nil
+	var roots []*File
+	var rootPaths []string
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("du: %s: %v", p, err))
+			continue
+		}
+		relToRootPath := p
+		if byId {
+			relToRootPath = filepath.Join(g.opts.Path, f.Name)
+		}
+		roots = append(roots, f)
+		rootPaths = append(rootPaths, relToRootPath)
+	}
+
+	ti := g.prefetchTrees(roots, g.opts.PrefetchWorkers)
+
+	var entries []*duEntry
+	total := &duEntry{Path: "total"}
+	counted := make(map[string]bool)
+
+	// tally adds up the usage under folder, listing the folders
+	// no deeper than depth. Items with many parents count once.
+	var tally func(folder *File, folderPath string, level int) *duEntry
+	tally = func(folder *File, folderPath string, level int) *duEntry {
+		entry := &duEntry{Path: folderPath, Depth: level}
+		children, _ := ti.get(folder.Id)
+		for _, child := range children {
+			if counted[child.Id] {
+				continue
+			}
+			counted[child.Id] = true
+
+			if child.IsDir {
+				sub := tally(child, sepJoin("/", folderPath, child.Name), level+1)
+				entry.Bytes += sub.Bytes
+				entry.Files += sub.Files
+				entry.Docs += sub.Docs
+				continue
+			}
+			if isGoogleNative(child) {
+				entry.Docs += 1
+			} else {
+				entry.Files += 1
+			}
+			entry.Bytes += sizeWith(g.sizeOf, child)
+		}
+		if depth < 0 || level <= depth {
+			entries = append(entries, entry)
+		}
+		return entry
+	}
+
+	for i, root := range roots {
+		if counted[root.Id] {
+			continue
+		}
+		counted[root.Id] = true
+
+		var entry *duEntry
+		if root.IsDir {
+			entry = tally(root, rootPaths[i], 0)
+		} else {
+			entry = &duEntry{Path: rootPaths[i], Bytes: sizeWith(g.sizeOf, root)}
+			if isGoogleNative(root) {
+				entry.Docs = 1
+			} else {
+				entry.Files = 1
+			}
+			entries = append(entries, entry)
+		}
+		total.Bytes += entry.Bytes
+		total.Files += entry.Files
+		total.Docs += entry.Docs
+	}
+
+	sort.Sort(byDuBytes(entries))
+
+	if format == DuFormatCSV {
+		w := csv.NewWriter(os.Stdout)
+		defer w.Flush()
+		if err := w.Write(duCSVHeader); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := w.Write(entry.record()); err != nil {
+				return err
+			}
+		}
+		return composedError
+	}
+
+	for _, entry := range entries {
+		g.log.Logf("%-12s %8d files %6d docs  %s\n", prettyBytes(entry.Bytes), entry.Files, entry.Docs, entry.Path)
+	}
+	if len(roots) > 0 {
+		g.log.Logf("%-12s %8d files %6d docs  %s\n", prettyBytes(total.Bytes), total.Files, total.Docs, total.Path)
+	}
+	return composedError
+}
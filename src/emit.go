@@ -0,0 +1,144 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// fileEntry is the machine-readable form of a listed or stat'd item.
+type fileEntry struct {
+	Path        string            `json:"path"`
+	Id          string            `json:"id"`
+	Name        string            `json:"name"`
+	IsDir       bool              `json:"isDir"`
+	MimeType    string            `json:"mimeType,omitempty"`
+	Size        int64             `json:"size"`
+	Md5Checksum string            `json:"md5Checksum,omitempty"`
+	ModTime     time.Time         `json:"modTime"`
+	Shared      bool              `json:"shared"`
+	OwnerNames  []string          `json:"owners,omitempty"`
+	Version     int64             `json:"version,omitempty"`
+	Properties  map[string]string `json:"properties,omitempty"`
+}
+
+func newFileEntry(p string, f *File) *fileEntry {
+	return &fileEntry{
+		Path:        p,
+		Id:          f.Id,
+		Name:        f.Name,
+		IsDir:       f.IsDir,
+		MimeType:    f.MimeType,
+		Size:        f.Size,
+		Md5Checksum: f.Md5Checksum,
+		ModTime:     f.ModTime,
+		Shared:      f.Shared,
+		OwnerNames:  f.OwnerNames,
+		Version:     f.Version,
+		Properties:  f.Properties,
+	}
+}
+
+// changeEntry is the machine-readable outcome of a change pushed or pulled.
+type changeEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Change    string    `json:"change"`
+	Path      string    `json:"path"`
+	Id        string    `json:"id,omitempty"`
+	IsDir     bool      `json:"isDir"`
+	Size      int64     `json:"size"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var changeNames = map[Operation]string{
+	OpAdd:           "add",
+	OpDelete:        "delete",
+	OpMod:           "mod",
+	OpModConflict:   "conflict",
+	OpIndexAddition: "index",
+}
+
+// emitChange emits the outcome of the change c made by the command op,
+// e.g push, if emitting results.
+func (g *Commands) emitChange(op string, c *Change, err error) {
+	if g.emitter == nil || c == nil {
+		return
+	}
+
+	entry := changeEntry{
+		Time:      time.Now().UTC(),
+		Operation: op,
+		Change:    changeNames[c.Op()],
+		Path:      c.Path,
+		Result:    AuditResultOk,
+	}
+	// Deletions have no source, only the destination that they remove
+	f := c.Src
+	if f == nil {
+		f = c.Dest
+	}
+	if f != nil {
+		entry.Id, entry.IsDir = f.Id, f.IsDir
+		if !f.IsDir {
+			entry.Size = sizeWith(g.sizeOf, f)
+		}
+	}
+	if entry.Id == "" && c.Dest != nil {
+		entry.Id = c.Dest.Id
+	}
+	if err != nil {
+		entry.Result = AuditResultError
+		entry.Error = err.Error()
+	}
+
+	g.emitter.emit(&entry)
+}
+
+// emitter writes results, a line of JSON each, for scripts to consume
+// instead of the free-form logs. It is safe for concurrent use.
+type emitter struct {
+	sync.Mutex
+	enc *json.Encoder
+}
+
+func newEmitter(w io.Writer) *emitter {
+	return &emitter{enc: json.NewEncoder(w)}
+}
+
+// emit writes v as a line of JSON. It is a no-op on a nil emitter.
+func (e *emitter) emit(v interface{}) {
+	if e == nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.enc.Encode(v)
+}
+
+// printFile emits f as a fileEntry if emitting, otherwise it pretty prints it.
+func (g *Commands) printFile(f *File, opt attribute) {
+	if g.emitter != nil {
+		g.emitter.emit(newFileEntry(sepJoin("/", opt.parent, f.Name), f))
+		return
+	}
+	f.pretty(g.log, opt)
+}
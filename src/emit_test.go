@@ -0,0 +1,54 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEmitChange(t *testing.T) {
+	var buf bytes.Buffer
+	g := &Commands{emitter: newEmitter(&buf)}
+
+	g.emitChange(PushKey, &Change{Path: "/a.txt", Src: &File{Id: "a", Size: 10}}, nil)
+	g.emitChange(PullKey, &Change{Path: "/old", Dest: &File{Id: "old", IsDir: true}}, errors.New("denied"))
+	g.emitChange(PushKey, nil, nil)
+
+	want := []changeEntry{
+		{Operation: PushKey, Change: "add", Path: "/a.txt", Id: "a", Size: 10, Result: AuditResultOk},
+		{Operation: PullKey, Change: "delete", Path: "/old", Id: "old", IsDir: true, Result: AuditResultError, Error: "denied"},
+	}
+	dec := json.NewDecoder(&buf)
+	for i, w := range want {
+		var got changeEntry
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		got.Time = w.Time
+		if got != w {
+			t.Errorf("#%d: got %+v, want %+v", i, got, w)
+		}
+	}
+	if dec.More() {
+		t.Errorf("a nil change was emitted")
+	}
+
+	// Nothing is emitted unless emitting
+	g = &Commands{}
+	g.emitChange(PushKey, &Change{Path: "/a.txt", Src: &File{}}, nil)
+}
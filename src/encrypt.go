@@ -0,0 +1,434 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	EncryptionKeySuffix = "encryption.key"
+
+	// EncryptedKey marks the items whose names and content push encrypted,
+	// PlainMd5Key and PlainSizeKey are the checksum and size of the content
+	// before it was compressed, which pull compares local files against.
+	// Those of encrypted files are sealed together under PlainSealedKey.
+	EncryptedKey   = "encrypted"
+	PlainMd5Key    = "plainMd5"
+	PlainSizeKey   = "plainSize"
+	PlainSealedKey = "plainSealed"
+
+	encryptionMagic     = "drivenc1"
+	encryptionSaltSize  = 16
+	encryptionChunkSize = 64 * 1024
+	encryptionKeySize   = 32
+)
+
+var ErrUndecryptable = errors.New("can't be decrypted, it was encrypted with another key or is damaged")
+
+// nameEncoding encodes encrypted names in characters that
+// Drive and every filesystem take in titles and paths.
+var nameEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// cipherSuite encrypts the names and content of what is pushed and decrypts
+// them on pull. Content is sealed with AES-256-GCM in chunks, each file with
+// a key of its own derived from a random salt, and every chunk numbered and
+// the last flagged so that reordered or truncated content fails to open.
+// Names are sealed with a nonce derived from the name itself, so that a
+// name always encrypts alike and paths resolve remotely without a mapping
+// to keep. The checksum and size of the content are sealed too, as they
+// would otherwise tell which files are known ones. A nil *cipherSuite
+// leaves names and content as they are.
+type cipherSuite struct {
+	contentKey []byte
+	nameKey    []byte
+	names      cipher.AEAD
+	plain      cipher.AEAD
+}
+
+func deriveKey(master []byte, label string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newCipherSuite(master []byte) (*cipherSuite, error) {
+	names, err := newAEAD(deriveKey(master, "names"))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := newAEAD(deriveKey(master, "plain content"))
+	if err != nil {
+		return nil, err
+	}
+	return &cipherSuite{
+		contentKey: deriveKey(master, "content"),
+		nameKey:    deriveKey(master, "name nonces"),
+		names:      names,
+		plain:      plain,
+	}, nil
+}
+
+// encryptionKeyPath is where the key of the account of context is
+// kept unless set otherwise e.g .gd/work.encryption.key.
+func encryptionKeyPath(context *config.Context) string {
+	name := EncryptionKeySuffix
+	if context.Profile != "" {
+		name = fmt.Sprintf("%s.%s", context.Profile, EncryptionKeySuffix)
+	}
+	return path.Join(context.AbsPathOf(""), config.GDDirSuffix, name)
+}
+
+// parseEncryptionKey decodes a key written as hex, refusing anything but
+// random keys of encryptionKeySize bytes. Passphrases aren't taken as they
+// would be cheap to guess offline, names always encrypting alike.
+func parseEncryptionKey(hexKey string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("expecting a key of %d random bytes in hex e.g from `openssl rand -hex %d`",
+			encryptionKeySize, encryptionKeySize)
+	}
+	return key, nil
+}
+
+// loadEncryptionKey returns the key in the environment or else in the file at
+// keyPath, generating a random one there if there is none and create is set.
+func loadEncryptionKey(keyPath string, create bool) (key []byte, created bool, err error) {
+	if hexKey := os.Getenv(DriveEncryptionKeyEnvKey); hexKey != "" {
+		key, err = parseEncryptionKey(hexKey)
+		if err != nil {
+			err = fmt.Errorf("$%s: %v", DriveEncryptionKeyEnvKey, err)
+		}
+		return key, false, err
+	}
+
+	data, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) && create {
+		random := make([]byte, encryptionKeySize)
+		if _, err = rand.Read(random); err != nil {
+			return nil, false, err
+		}
+		data = []byte(hex.EncodeToString(random) + "\n")
+		if err = ioutil.WriteFile(keyPath, data, 0600); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if key, err = parseEncryptionKey(string(data)); err != nil {
+		return nil, false, fmt.Errorf("%s: %v", keyPath, err)
+	}
+	return key, created, nil
+}
+
+// prepareEncryption sets the remote up to encrypt what is pushed with
+// -encrypt and decrypt what is pulled with -decrypt.
+func (g *Commands) prepareEncryption() error {
+	if !g.opts.Encrypt && !g.opts.Decrypt {
+		return nil
+	}
+
+	keyPath := g.opts.EncryptionKeyPath
+	if keyPath == "" {
+		keyPath = encryptionKeyPath(g.context)
+	}
+	key, created, err := loadEncryptionKey(keyPath, g.opts.Encrypt)
+	if err != nil {
+		return reComposeError(err, fmt.Sprintf("encryption key, set it in %s or $%s", keyPath, DriveEncryptionKeyEnvKey))
+	}
+	if created {
+		g.log.LogErrf("generated the encryption key %s, back it up: what is pushed can't be decrypted without it\n", keyPath)
+	}
+
+	g.rem.crypt, err = newCipherSuite(key)
+	return err
+}
+
+func (cs *cipherSuite) encryptName(name string) string {
+	if cs == nil || name == "" {
+		return name
+	}
+	nonce := deriveKey(cs.nameKey, name)[:cs.names.NonceSize()]
+	sealed := cs.names.Seal(append([]byte{}, nonce...), nonce, []byte(name), nil)
+	return strings.ToLower(nameEncoding.EncodeToString(sealed))
+}
+
+// decryptName returns the name that title is the encryption of,
+// or title itself if it isn't one e.g it was pushed unencrypted.
+func (cs *cipherSuite) decryptName(title string) (string, bool) {
+	sealed, err := nameEncoding.DecodeString(strings.ToUpper(title))
+	nonceSize := cs.names.NonceSize()
+	if err != nil || len(sealed) < nonceSize+cs.names.Overhead() {
+		return title, false
+	}
+	name, err := cs.names.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return title, false
+	}
+	return string(name), true
+}
+
+// encryptPath encrypts each segment of remote path p.
+func (cs *cipherSuite) encryptPath(p string) string {
+	if cs == nil || rootLike(p) {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = cs.encryptName(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// decryptFile gives f its name, size and checksum from before it was
+// encrypted, leaving items that weren't encrypted as they are.
+func (cs *cipherSuite) decryptFile(f *File) *File {
+	if cs == nil || f == nil {
+		return f
+	}
+	if name, ok := cs.decryptName(f.Name); ok {
+		f.Name = name
+	}
+	if !encrypted(f) || f.IsDir {
+		return f
+	}
+	if _, sealed := f.Properties[PlainSealedKey]; sealed {
+		return cs.openPlainContent(f)
+	}
+	// Pushed before the checksum and size were sealed
+	return plainContent(f)
+}
+
+// decryptFiles decrypts the items of files, leaving out
+// those hidden by their decrypted names unless hidden is set.
+func (cs *cipherSuite) decryptFiles(files chan *File, hidden bool) chan *File {
+	if cs == nil {
+		return files
+	}
+	decrypted := make(chan *File)
+	go func() {
+		defer close(decrypted)
+		for f := range files {
+			if f = cs.decryptFile(f); f != nil && isHidden(f.Name, hidden) {
+				continue
+			}
+			decrypted <- f
+		}
+	}()
+	return decrypted
+}
+
+func encrypted(f *File) bool {
+	return f != nil && f.Properties[EncryptedKey] != ""
+}
+
+// encryptionProperties are the private properties that mark
+// src as encrypted, with its sealed checksum and size if a file.
+func (cs *cipherSuite) encryptionProperties(src *File, props map[string]string) (map[string]string, error) {
+	stamped := map[string]string{EncryptedKey: "true"}
+	for key, value := range props {
+		stamped[key] = value
+	}
+	if src.IsDir {
+		return stamped, nil
+	}
+	sealed, err := cs.sealPlainContent(src)
+	if err != nil {
+		return nil, err
+	}
+	stamped[PlainSealedKey] = sealed
+	return stamped, nil
+}
+
+// sealPlainContent seals the size and checksum of src's content, in
+// few enough bytes to fit in a property, with a nonce of its own.
+func (cs *cipherSuite) sealPlainContent(src *File) (string, error) {
+	md5, err := hex.DecodeString(md5Checksum(src))
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, 8, 8+len(md5))
+	binary.BigEndian.PutUint64(plain, uint64(src.Size))
+	plain = append(plain, md5...)
+
+	nonce := make([]byte, cs.plain.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := cs.plain.Seal(nonce, nonce, plain, []byte(PlainSealedKey))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openPlainContent gives f the size and checksum sealed in its properties.
+// Values that don't open leave f without a checksum to be compared with.
+func (cs *cipherSuite) openPlainContent(f *File) *File {
+	f.Md5Checksum = ""
+	sealed, err := base64.RawURLEncoding.DecodeString(f.Properties[PlainSealedKey])
+	nonceSize := cs.plain.NonceSize()
+	if err != nil || len(sealed) < nonceSize {
+		return f
+	}
+	plain, err := cs.plain.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(PlainSealedKey))
+	if err != nil || len(plain) < 8 {
+		return f
+	}
+	f.Size = int64(binary.BigEndian.Uint64(plain))
+	if md5 := plain[8:]; len(md5) > 0 {
+		f.Md5Checksum = hex.EncodeToString(md5)
+	}
+	return f
+}
+
+// chunkNonce numbers the chunk, flagging it if it is the last.
+func chunkNonce(aead cipher.AEAD, counter uint64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// chunkedCipher seals or opens the content read from src a chunk at a time.
+type chunkedCipher struct {
+	src       *bufio.Reader
+	closer    io.Closer
+	aead      cipher.AEAD
+	encrypt   bool
+	counter   uint64
+	buf       []byte
+	out       []byte
+	pending   []byte
+	done      bool
+	chunkSize int
+}
+
+func (cs *cipherSuite) encryptReader(src io.Reader) (io.Reader, error) {
+	salt := make([]byte, encryptionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(deriveKey(cs.contentKey, string(salt)))
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(encryptionMagic), salt...)
+	return &chunkedCipher{
+		src:       bufio.NewReaderSize(src, encryptionChunkSize),
+		aead:      aead,
+		encrypt:   true,
+		buf:       make([]byte, encryptionChunkSize),
+		pending:   header,
+		chunkSize: encryptionChunkSize,
+	}, nil
+}
+
+func (cs *cipherSuite) decryptReader(src io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(src, encryptionChunkSize)
+	header := make([]byte, len(encryptionMagic)+encryptionSaltSize)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		src.Close()
+		return nil, ErrUndecryptable
+	}
+	aead, err := newAEAD(deriveKey(cs.contentKey, string(header[len(encryptionMagic):])))
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	chunkSize := encryptionChunkSize + aead.Overhead()
+	return &chunkedCipher{
+		src:       br,
+		closer:    src,
+		aead:      aead,
+		buf:       make([]byte, chunkSize),
+		chunkSize: chunkSize,
+	}, nil
+}
+
+func (cc *chunkedCipher) next() error {
+	n, err := io.ReadFull(cc.src, cc.buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	last := n < cc.chunkSize
+	if !last {
+		if _, err := cc.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	nonce := chunkNonce(cc.aead, cc.counter, last)
+	cc.counter++
+	cc.done = last
+	if cc.encrypt {
+		cc.out = cc.aead.Seal(cc.out[:0], nonce, cc.buf[:n], nil)
+	} else if cc.out, err = cc.aead.Open(cc.out[:0], nonce, cc.buf[:n], nil); err != nil {
+		return ErrUndecryptable
+	}
+	cc.pending = cc.out
+	return nil
+}
+
+func (cc *chunkedCipher) Read(p []byte) (int, error) {
+	for len(cc.pending) < 1 {
+		if cc.done {
+			return 0, io.EOF
+		}
+		if err := cc.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, cc.pending)
+	cc.pending = cc.pending[n:]
+	return n, nil
+}
+
+func (cc *chunkedCipher) Close() error {
+	if cc.closer == nil {
+		return nil
+	}
+	return cc.closer.Close()
+}
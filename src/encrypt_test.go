@@ -0,0 +1,251 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func testCipherSuite(t *testing.T, hexKey string) *cipherSuite {
+	key, err := parseEncryptionKey(hexKey)
+	if err != nil {
+		t.Fatalf("parseEncryptionKey(%q): %v", hexKey, err)
+	}
+	cs, err := newCipherSuite(key)
+	if err != nil {
+		t.Fatalf("newCipherSuite: %v", err)
+	}
+	return cs
+}
+
+func encryptAll(t *testing.T, cs *cipherSuite, plain []byte) []byte {
+	r, err := cs.encryptReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("encryptReader: %v", err)
+	}
+	sealed, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+	return sealed
+}
+
+func decryptAll(cs *cipherSuite, sealed []byte) ([]byte, error) {
+	r, err := cs.decryptReader(ioutil.NopCloser(bytes.NewReader(sealed)))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	cases := []struct {
+		key string
+		ok  bool
+	}{
+		{testEncryptionKey, true},
+		{testEncryptionKey + "\n", true},
+		{strings.ToUpper(testEncryptionKey), true},
+		{"", false},
+		{"correct horse battery staple", false},
+		{testEncryptionKey[:62], false},
+		{testEncryptionKey + "00", false},
+		{"zz" + testEncryptionKey[2:], false},
+	}
+
+	for _, tc := range cases {
+		key, err := parseEncryptionKey(tc.key)
+		if ok := err == nil; ok != tc.ok {
+			t.Errorf("parseEncryptionKey(%q) err=%v, want ok=%v", tc.key, err, tc.ok)
+			continue
+		}
+		if tc.ok && len(key) != encryptionKeySize {
+			t.Errorf("parseEncryptionKey(%q) = %d bytes, want %d", tc.key, len(key), encryptionKeySize)
+		}
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	cs := testCipherSuite(t, testEncryptionKey)
+	sizes := []int{
+		0, 1,
+		encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1,
+		3 * encryptionChunkSize, 3*encryptionChunkSize + 7,
+	}
+
+	for _, size := range sizes {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := encryptAll(t, cs, plain)
+		chunks := (size + encryptionChunkSize - 1) / encryptionChunkSize
+		if chunks < 1 {
+			chunks = 1
+		}
+		wantLen := len(encryptionMagic) + encryptionSaltSize + size + chunks*cs.names.Overhead()
+		if len(sealed) != wantLen {
+			t.Errorf("size %d: sealed into %d bytes, want %d", size, len(sealed), wantLen)
+		}
+
+		got, err := decryptAll(cs, sealed)
+		if err != nil {
+			t.Errorf("size %d: decrypting: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestEncryptSaltsEachFile(t *testing.T) {
+	cs := testCipherSuite(t, testEncryptionKey)
+	plain := []byte("same content")
+	if bytes.Equal(encryptAll(t, cs, plain), encryptAll(t, cs, plain)) {
+		t.Errorf("same content encrypted alike twice")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	cs := testCipherSuite(t, testEncryptionKey)
+	plain := make([]byte, 2*encryptionChunkSize+100)
+	rand.Read(plain)
+	sealed := encryptAll(t, cs, plain)
+
+	header := len(encryptionMagic) + encryptionSaltSize
+	sealedChunk := encryptionChunkSize + cs.names.Overhead()
+
+	flipped := append([]byte{}, sealed...)
+	flipped[header+10] ^= 1
+
+	swapped := append([]byte{}, sealed[:header]...)
+	swapped = append(swapped, sealed[header+sealedChunk:header+2*sealedChunk]...)
+	swapped = append(swapped, sealed[header:header+sealedChunk]...)
+	swapped = append(swapped, sealed[header+2*sealedChunk:]...)
+
+	other := testCipherSuite(t, strings.Repeat("ab", encryptionKeySize))
+
+	cases := []struct {
+		name   string
+		cs     *cipherSuite
+		sealed []byte
+	}{
+		// A cut at a chunk boundary leaves a chunk not flagged as the last one
+		{"truncated at a chunk boundary", cs, sealed[:header+sealedChunk]},
+		{"truncated at the last chunk", cs, sealed[:header+2*sealedChunk]},
+		{"truncated mid chunk", cs, sealed[:header+sealedChunk+10]},
+		{"header only", cs, sealed[:header]},
+		{"bit flipped", cs, flipped},
+		{"chunks reordered", cs, swapped},
+		{"other key", other, sealed},
+		{"not encrypted", cs, plain},
+	}
+
+	for _, tc := range cases {
+		if _, err := decryptAll(tc.cs, tc.sealed); err != ErrUndecryptable {
+			t.Errorf("%s: err=%v, want %v", tc.name, err, ErrUndecryptable)
+		}
+	}
+}
+
+func TestEncryptNames(t *testing.T) {
+	cs := testCipherSuite(t, testEncryptionKey)
+	other := testCipherSuite(t, strings.Repeat("ab", encryptionKeySize))
+
+	for _, name := range []string{"a", "Report 2024.pdf", ".bashrc", "ünïcödé ñame", strings.Repeat("x", 255)} {
+		encrypted := cs.encryptName(name)
+		if encrypted != cs.encryptName(name) {
+			t.Errorf("%q: encrypted differently twice", name)
+		}
+		if encrypted != strings.ToLower(encrypted) || strings.ContainsAny(encrypted, "/.") {
+			t.Errorf("%q: encrypted into %q, which isn't safe as a path segment", name, encrypted)
+		}
+		if got, ok := cs.decryptName(encrypted); !ok || got != name {
+			t.Errorf("%q: decrypted into %q ok=%v", name, got, ok)
+		}
+		if _, ok := other.decryptName(encrypted); ok {
+			t.Errorf("%q: decrypted with another key", name)
+		}
+	}
+
+	if got, ok := cs.decryptName("notes.txt"); ok || got != "notes.txt" {
+		t.Errorf("plain name decrypted into %q ok=%v", got, ok)
+	}
+	if got := cs.encryptPath("/a/b"); got != "/"+cs.encryptName("a")+"/"+cs.encryptName("b") {
+		t.Errorf("encryptPath(/a/b) = %q", got)
+	}
+	var none *cipherSuite
+	if got := none.encryptPath("/a/b"); got != "/a/b" {
+		t.Errorf("nil suite encryptPath(/a/b) = %q", got)
+	}
+}
+
+func TestEncryptionPropertiesSealPlainContent(t *testing.T) {
+	cs := testCipherSuite(t, testEncryptionKey)
+	other := testCipherSuite(t, strings.Repeat("ab", encryptionKeySize))
+	const md5 = "9e107d9d372bb6826bd81d3542a419d6"
+	src := &File{Name: "taxes.pdf", Size: 43, Md5Checksum: md5}
+
+	props, err := cs.encryptionProperties(src, map[string]string{"mode": "644"})
+	if err != nil {
+		t.Fatalf("encryptionProperties: %v", err)
+	}
+	for _, key := range []string{PlainMd5Key, PlainSizeKey} {
+		if _, ok := props[key]; ok {
+			t.Errorf("%s stored in the clear", key)
+		}
+	}
+	for key, value := range props {
+		if strings.Contains(value, md5) || value == "43" {
+			t.Errorf("%s=%q gives the content away", key, value)
+		}
+		if len(key)+len(value) > 124 {
+			t.Errorf("%s=%q is too long for a property", key, value)
+		}
+	}
+	if again, _ := cs.encryptionProperties(src, nil); again[PlainSealedKey] == props[PlainSealedKey] {
+		t.Errorf("same content sealed alike twice, telling copies apart")
+	}
+
+	remote := func(props map[string]string) *File {
+		return &File{Name: cs.encryptName(src.Name), Size: 999, Md5Checksum: "ciphertext", Properties: props}
+	}
+	if got := cs.decryptFile(remote(props)); got.Md5Checksum != md5 || got.Size != src.Size || got.Name != src.Name {
+		t.Errorf("decrypted into %q size=%d md5=%q", got.Name, got.Size, got.Md5Checksum)
+	}
+	if got := other.decryptFile(remote(props)); got.Md5Checksum != "" {
+		t.Errorf("opened with another key into md5=%q", got.Md5Checksum)
+	}
+
+	tampered := map[string]string{EncryptedKey: "true"}
+	sealed := []byte(props[PlainSealedKey])
+	sealed[len(sealed)/2] ^= 1
+	tampered[PlainSealedKey] = string(sealed)
+	if got := cs.decryptFile(remote(tampered)); got.Md5Checksum != "" {
+		t.Errorf("tampered values opened into md5=%q", got.Md5Checksum)
+	}
+
+	dir, err := cs.encryptionProperties(&File{Name: "taxes", IsDir: true}, nil)
+	if _, ok := dir[PlainSealedKey]; err != nil || ok {
+		t.Errorf("folder properties %v err=%v", dir, err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// moveCost is the estimated number of API calls that a move makes, by type.
+type moveCost struct {
+	resolves  int
+	reparents int
+	removes   int
+	others    int
+}
+
+func (mc *moveCost) total() int {
+	return mc.resolves + mc.reparents + mc.removes + mc.others
+}
+
+// lookupCalls is the number of calls that looking up p takes,
+// one for each folder along it since each is listed for the next.
+func lookupCalls(p string) int {
+	if rootLike(p) {
+		return 1
+	}
+	return len(strings.Split(strings.Trim(path.Clean(p), "/"), "/"))
+}
+
+// estimateMoveCost plans the moves of opts and works out the API calls that
+// making them would take: the reparents and removes from the planned operations
+// and the resolves from the lookups of the paths involved. Planning itself only
+// reads, though those reads are made against the quota too.
+func (g *Commands) estimateMoveCost(opts []*moveOpt) *moveCost {
+	mut, pl, rep := g.mut, g.plan, g.report
+	estimate := newPlan()
+	g.mut, g.plan, g.report = estimate, estimate, nil
+	defer func() {
+		g.mut, g.plan, g.report = mut, pl, rep
+	}()
+
+	cost := &moveCost{}
+	for _, opt := range opts {
+		// Failures are reported by the move proper
+		g.move(opt)
+
+		// The dest is looked up, then the item at the new path to check for clashes
+		cost.resolves += lookupCalls(opt.dest) + lookupCalls(opt.dest) + 1
+		if !opt.byId {
+			// The old parent is looked up to check it against dest and to remove it
+			cost.resolves += 2 * lookupCalls(g.parentPather(opt.src))
+		}
+	}
+
+	for _, op := range estimate.ops {
+		switch op.op {
+		case PlanOpInsertParent:
+			cost.reparents += 1
+		case PlanOpRemoveParent:
+			cost.removes += 1
+		default:
+			cost.others += 1
+		}
+	}
+	return cost
+}
+
+// confirmMoveCost logs the estimated cost of the moves of opts and
+// unless opts.Force is set, asks for it to be confirmed.
+func (g *Commands) confirmMoveCost(opts []*moveOpt) (proceed bool, err error) {
+	cost := g.estimateMoveCost(opts)
+
+	g.log.Logf("Estimated API calls for %d moves\n", len(opts))
+	g.log.Logf("  %-10s %d\n", "resolves", cost.resolves)
+	g.log.Logf("  %-10s %d\n", "reparents", cost.reparents)
+	g.log.Logf("  %-10s %d\n", "removes", cost.removes)
+	if cost.others >= 1 {
+		g.log.Logf("  %-10s %d\n", "others", cost.others)
+	}
+	g.log.Logf("  %-10s %d\n", "total", cost.total())
+
+	// Nothing is changed when only planning, so there is nothing to confirm
+	if g.opts.Force || g.plan != nil {
+		return true, nil
+	}
+	if !g.opts.canPrompt() {
+		return false, fmt.Errorf("move: noPrompt is set, use `%s` to make the moves estimated above", ForceKey)
+	}
+	return promptForChanges(), nil
+}
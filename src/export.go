@@ -0,0 +1,185 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// ExportLayoutNested places the exports of a document in a folder of their own
+	// beside it e.g "report_exports/report.pdf". It is the default layout.
+	ExportLayoutNested = "nested"
+	// ExportLayoutFlat places the exports beside the document e.g "report.pdf".
+	ExportLayoutFlat = "flat"
+	// ExportLayoutByFormat places the exports in a folder per format e.g "pdf/report.pdf".
+	ExportLayoutByFormat = "by-format"
+)
+
+// exporter is the formats that documents of a Google Docs mime type
+// can be exported to, keyed by extension, with their mime types.
+type exporter struct {
+	formats map[string]string
+}
+
+// exporters is the registry of exporters by Google Docs mime type.
+var exporters = make(map[string]*exporter)
+
+// registerExporter registers the formats that documents of mimeType can be
+// exported to, adding to any registered before.
+func registerExporter(mimeType string, formats map[string]string) {
+	e, ok := exporters[mimeType]
+	if !ok {
+		e = &exporter{formats: make(map[string]string)}
+		exporters[mimeType] = e
+	}
+	for ext, exportMimeType := range formats {
+		e.formats[ext] = exportMimeType
+	}
+}
+
+func init() {
+	registerExporter("application/vnd.google-apps.document", map[string]string{
+		"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"epub": "application/epub+zip",
+		"html": "text/html",
+		"odt":  "application/vnd.oasis.opendocument.text",
+		"pdf":  "application/pdf",
+		"rtf":  "application/rtf",
+		"txt":  "text/plain",
+		"zip":  "application/zip",
+	})
+	registerExporter("application/vnd.google-apps.spreadsheet", map[string]string{
+		"csv":  "text/csv",
+		"ods":  "application/x-vnd.oasis.opendocument.spreadsheet",
+		"pdf":  "application/pdf",
+		"tsv":  "text/tab-separated-values",
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"zip":  "application/zip",
+	})
+	registerExporter("application/vnd.google-apps.presentation", map[string]string{
+		"odp":  "application/vnd.oasis.opendocument.presentation",
+		"pdf":  "application/pdf",
+		"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		"txt":  "text/plain",
+	})
+	registerExporter("application/vnd.google-apps.drawing", map[string]string{
+		"jpg": "image/jpeg",
+		"pdf": "application/pdf",
+		"png": "image/png",
+		"svg": "image/svg+xml",
+	})
+	registerExporter("application/vnd.google-apps.script", map[string]string{
+		"json": "application/vnd.google-apps.script+json",
+	})
+}
+
+// exportLink returns the URL that f is exported to the format ext from. Formats
+// that aren't registered for f's mime type are looked up by extension alone.
+func exportLink(f *File, ext string) (url, mimeType string, ok bool) {
+	if e, registered := exporters[f.MimeType]; registered {
+		mimeType = e.formats[ext]
+	}
+	if mimeType == "" {
+		mimeType = mimeTypeFromExt(ext)
+	}
+	url, ok = f.ExportLinks[mimeType]
+	return url, mimeType, ok
+}
+
+func checkExportLayout(layout string) error {
+	switch layout {
+	case "", ExportLayoutNested, ExportLayoutFlat, ExportLayoutByFormat:
+		return nil
+	}
+	return fmt.Errorf("unknown export layout %q, expecting %s, %s or %s",
+		layout, ExportLayoutNested, ExportLayoutFlat, ExportLayoutByFormat)
+}
+
+// exportPath is where the export of the document at destAbsPath to the
+// format ext goes, as per opts.ExportsDir and opts.ExportLayout.
+func (g *Commands) exportPath(f *File, destAbsPath, ext string) string {
+	dir := filepath.Dir(destAbsPath)
+	if g.opts.ExportsDir != "" {
+		dir = g.opts.ExportsDir
+	}
+	name := sepJoin(".", filepath.Base(f.Name), ext)
+
+	switch g.opts.ExportLayout {
+	case ExportLayoutFlat:
+		return path.Join(dir, name)
+	case ExportLayoutByFormat:
+		return path.Join(dir, ext, name)
+	}
+	return path.Join(dir, sepJoin("_", f.Name, "exports"), name)
+}
+
+// export exports f, whose local path is destAbsPath, to each of the formats in
+// exports that it can be exported to, concurrently. manifest is the paths of
+// the exports made.
+func (g *Commands) export(f *File, destAbsPath string, exports []string) (manifest []string, err error) {
+	if len(exports) < 1 || f == nil {
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, ext := range exports {
+		url, mimeType, ok := exportLink(f, ext)
+		if !ok {
+			g.log.LogErrf("export: %s cannot be exported to %s\n", f.Name, ext)
+			continue
+		}
+
+		exportPath := g.exportPath(f, destAbsPath, ext)
+		if mkErr := os.MkdirAll(filepath.Dir(exportPath), os.ModeDir|0755); mkErr != nil {
+			mu.Lock()
+			err = reComposeError(err, mkErr.Error())
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(urlMExt *urlMimeTypeExt, exportPath string) {
+			defer wg.Done()
+
+			dlArg := downloadArg{
+				ackByteProgress: false,
+				path:            exportPath,
+				id:              f.Id,
+				exportURL:       urlMExt.url,
+			}
+
+			dlErr := g.singleDownload(&dlArg)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if dlErr != nil {
+				err = reComposeError(err, fmt.Sprintf("%s: %v", exportPath, dlErr))
+				return
+			}
+			manifest = append(manifest, exportPath)
+		}(&urlMimeTypeExt{mimeType: mimeType, url: url, ext: ext}, exportPath)
+	}
+
+	wg.Wait()
+	return
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// extensionMimeTypes are the mime types that content named with each
+// extension can be expected to have.
+var extensionMimeTypes = map[string][]string{
+	"pdf":  {"application/pdf"},
+	"png":  {"image/png"},
+	"jpg":  {"image/jpeg"},
+	"jpeg": {"image/jpeg"},
+	"gif":  {"image/gif"},
+	"bmp":  {"image/bmp", "image/x-ms-bmp"},
+	"tif":  {"image/tiff"},
+	"tiff": {"image/tiff"},
+	"webp": {"image/webp"},
+	"svg":  {"image/svg+xml"},
+	"mp3":  {"audio/mpeg", "audio/mp3"},
+	"wav":  {"audio/wav", "audio/x-wav"},
+	"mp4":  {"video/mp4"},
+	"mov":  {"video/quicktime"},
+	"zip":  {"application/zip", "application/x-zip-compressed"},
+	"gz":   {"application/gzip", "application/x-gzip"},
+	"txt":  {"text/plain"},
+	"csv":  {"text/csv", "text/plain"},
+	"html": {"text/html"},
+	"htm":  {"text/html"},
+	"json": {"application/json", "text/plain"},
+	"xml":  {"text/xml", "application/xml"},
+	"doc":  {"application/msword"},
+	"xls":  {"application/vnd.ms-excel"},
+	"ppt":  {"application/vnd.ms-powerpoint"},
+	"docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	"xlsx": {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"pptx": {"application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+}
+
+// extensionMismatch returns the mime types that f's name extension calls for if
+// its mime type is none of them. Unknown extensions, Google Docs files and
+// content that Drive couldn't tell the type of are never mismatched.
+func extensionMismatch(f *File) []string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(f.Name), "."))
+	expected, known := extensionMimeTypes[ext]
+	if !known || f.MimeType == "" || f.MimeType == "application/octet-stream" || hasExportLinks(f) {
+		return nil
+	}
+
+	for _, mimeType := range expected {
+		if f.MimeType == mimeType {
+			return nil
+		}
+	}
+	return expected
+}
+
+// checkExtension reports src, to be copied to destPath, if its name's extension
+// doesn't match its content's type. Under opts.StrictExtensions it isn't copied.
+func (g *Commands) checkExtension(src *File, destPath string) error {
+	expected := extensionMismatch(src)
+	if expected == nil {
+		return nil
+	}
+
+	g.report.warn("Names that don't match content", "%s is %s, not %s", destPath, src.MimeType, strings.Join(expected, " or "))
+	if g.opts.StrictExtensions {
+		return fmt.Errorf("%s is named as %s but is %s", src.Name, strings.Join(expected, " or "), src.MimeType)
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	expirable "github.com/odeke-em/cache"
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// fakeDrive serves the few Drive API requests that mounts and scans
+// make from memory, standing in for the transport of the client.
+type fakeDrive struct {
+	sync.Mutex
+	files   map[string]*drive.File
+	content map[string][]byte
+	lastId  int
+	// fixed are the ids of files whose parents can't be changed
+	fixed map[string]bool
+	// targets are the ids of the items that shortcuts point to
+	targets map[string]string
+}
+
+// fakeShortcut is a shortcut as served, which the v2 File can't express.
+type fakeShortcut struct {
+	*drive.File
+	ShortcutDetails struct {
+		TargetId string `json:"targetId"`
+	} `json:"shortcutDetails"`
+}
+
+var (
+	fakeParentRegexp = regexp.MustCompile(`"([^"]+)" in parents`)
+	fakeTitleRegexp  = regexp.MustCompile(`title = "([^"]*)"`)
+)
+
+func newFakeDrive() *fakeDrive {
+	fd := &fakeDrive{
+		files:   make(map[string]*drive.File),
+		content: make(map[string][]byte),
+		fixed:   make(map[string]bool),
+		targets: make(map[string]string),
+	}
+	fd.files["root"] = &drive.File{Id: "root", Title: "My Drive", MimeType: DriveFolderMimeType}
+	return fd
+}
+
+func (fd *fakeDrive) add(parentId, title string, content []byte, isDir bool) *drive.File {
+	fd.lastId += 1
+	f := &drive.File{
+		Id:           fmt.Sprintf("id%d", fd.lastId),
+		Title:        title,
+		Parents:      []*drive.ParentReference{{Id: parentId}},
+		ModifiedDate: time.Now().UTC().Format(time.RFC3339),
+		Labels:       &drive.FileLabels{},
+		Copyable:     true,
+	}
+	if isDir {
+		f.MimeType = DriveFolderMimeType
+	} else {
+		fd.setContent(f, content)
+	}
+	fd.files[f.Id] = f
+	return f
+}
+
+func (fd *fakeDrive) setContent(f *drive.File, content []byte) {
+	fd.content[f.Id] = content
+	f.FileSize = int64(len(content))
+}
+
+// shortcut adds a shortcut called title to the folder with parentId
+// that points to the item with targetId.
+func (fd *fakeDrive) shortcut(parentId, title, targetId string) *drive.File {
+	f := fd.add(parentId, title, nil, false)
+	f.MimeType = DriveShortcutMimeType
+	fd.targets[f.Id] = targetId
+	return f
+}
+
+// link adds f to the folder with parentId, as well as those it is in.
+func (fd *fakeDrive) link(f *drive.File, parentId string) {
+	f.Parents = append(f.Parents, &drive.ParentReference{Id: parentId})
+}
+
+func fakeHasParent(f *drive.File, parentId string) bool {
+	for _, parent := range f.Parents {
+		if parent.Id == parentId {
+			return true
+		}
+	}
+	return false
+}
+
+// child returns the item called title in the folder with parentId.
+func (fd *fakeDrive) child(parentId, title string) *drive.File {
+	fd.Lock()
+	defer fd.Unlock()
+	for _, f := range fd.files {
+		if f.Title == title && !f.Labels.Trashed && fakeHasParent(f, parentId) {
+			return f
+		}
+	}
+	return nil
+}
+
+// commandsOn returns Commands that make their requests to fd.
+func commandsOn(fd *fakeDrive, opts *Options) *Commands {
+	r := newRemote(&http.Client{Transport: fd})
+	return &Commands{
+		rem:    r,
+		mut:    r,
+		log:    log.New(os.Stdin, ioutil.Discard, ioutil.Discard),
+		report: newReport(),
+		opts:   opts,
+
+		mkdirAllCache: expirable.New(),
+	}
+}
+
+// inTempContext gives g a context in a temporary folder, for what it
+// keeps locally such as the index, which is removed by calling done.
+func inTempContext(t *testing.T, g *Commands) (done func()) {
+	dir, err := ioutil.TempDir("", "drivecontext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, config.GDDirSuffix), 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	g.context = &config.Context{AbsPath: dir}
+	return func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func respond(status int, v interface{}) *http.Response {
+	var body []byte
+	if b, ok := v.([]byte); ok {
+		body = b
+	} else if v != nil {
+		body, _ = json.Marshal(v)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+// upsert applies the metadata and the content, if any, of req to f.
+func (fd *fakeDrive) upsert(req *http.Request, f *drive.File) error {
+	var meta drive.File
+	var content []byte
+	hasContent := false
+
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(req.Body, params["boundary"])
+		part, err := mr.NextPart()
+		if err != nil {
+			return err
+		}
+		if err := json.NewDecoder(part).Decode(&meta); err != nil {
+			return err
+		}
+		if part, err = mr.NextPart(); err != nil {
+			return err
+		}
+		if content, err = ioutil.ReadAll(part); err != nil {
+			return err
+		}
+		hasContent = true
+	} else if req.Body != nil {
+		if err := json.NewDecoder(req.Body).Decode(&meta); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	if meta.Title != "" {
+		f.Title = meta.Title
+	}
+	if meta.MimeType != "" {
+		f.MimeType = meta.MimeType
+	}
+	if len(meta.Parents) > 0 {
+		f.Parents = meta.Parents
+	}
+	if hasContent {
+		fd.setContent(f, content)
+	}
+	return nil
+}
+
+func (fd *fakeDrive) RoundTrip(req *http.Request) (*http.Response, error) {
+	fd.Lock()
+	defer fd.Unlock()
+
+	if req.URL.String() == DriveBatchURL {
+		return fd.batch(req)
+	}
+	return fd.serve(req)
+}
+
+// batch serves each of the calls in the batch request req in turn.
+func (fd *fakeDrive) batch(req *http.Request) (*http.Response, error) {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mr := multipart.NewReader(req.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Calls are made up of "<method> <path>", headers and a body
+		tp := textproto.NewReader(bufio.NewReader(part))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tp.ReadMIMEHeader(); err != nil {
+			return nil, err
+		}
+		methodPath := strings.SplitN(line, " ", 2)
+		callReq, err := http.NewRequest(methodPath[0], "https://www.googleapis.com"+methodPath[1], tp.R)
+		if err != nil {
+			return nil, err
+		}
+		callResp, err := fd.serve(callReq)
+		if err != nil {
+			return nil, err
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-ID", "<response-"+strings.Trim(part.Header.Get("Content-ID"), "<>")+">")
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := callResp.Write(w); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	resp := respond(200, body.Bytes())
+	resp.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	return resp, nil
+}
+
+func (fd *fakeDrive) serve(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "googledrive.com" {
+		content, ok := fd.content[strings.TrimPrefix(req.URL.Path, "/host/")]
+		if !ok {
+			return respond(404, nil), nil
+		}
+		return respond(200, content), nil
+	}
+
+	p := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, "/upload"), "/drive/v2/files")
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	id := parts[0]
+	f, found := fd.files[id]
+	if id != "" && !found {
+		return respond(404, nil), nil
+	}
+
+	switch {
+	case id == "" && req.Method == "GET":
+		q := req.URL.Query().Get("q")
+		list := &drive.FileList{}
+		for _, candidate := range fd.files {
+			if m := fakeParentRegexp.FindStringSubmatch(q); m != nil && !fakeHasParent(candidate, m[1]) {
+				continue
+			}
+			if m := fakeTitleRegexp.FindStringSubmatch(q); m != nil && candidate.Title != m[1] {
+				continue
+			}
+			if strings.Contains(q, "trashed=false") == candidate.Labels.Trashed {
+				continue
+			}
+			list.Items = append(list.Items, candidate)
+		}
+		return respond(200, list), nil
+
+	case id == "" && req.Method == "POST":
+		created := fd.add("", "", nil, false)
+		if err := fd.upsert(req, created); err != nil {
+			return nil, err
+		}
+		return respond(200, created), nil
+
+	case len(parts) == 1 && req.Method == "GET":
+		if targetId, ok := fd.targets[id]; ok {
+			shortcut := &fakeShortcut{File: f}
+			shortcut.ShortcutDetails.TargetId = targetId
+			return respond(200, shortcut), nil
+		}
+		return respond(200, f), nil
+
+	case len(parts) == 1 && (req.Method == "PUT" || req.Method == "PATCH"):
+		if err := fd.upsert(req, f); err != nil {
+			return nil, err
+		}
+		return respond(200, f), nil
+
+	case len(parts) == 2 && parts[1] == "copy" && req.Method == "POST":
+		copied := fd.add("", f.Title, fd.content[id], false)
+		copied.MimeType = f.MimeType
+		if err := fd.upsert(req, copied); err != nil {
+			return nil, err
+		}
+		return respond(200, copied), nil
+
+	case len(parts) == 2 && parts[1] == "trash":
+		f.Labels.Trashed = true
+		return respond(200, f), nil
+
+	case len(parts) >= 2 && parts[1] == "parents" && fd.fixed[id]:
+		return respond(403, nil), nil
+
+	case len(parts) == 2 && parts[1] == "parents" && req.Method == "POST":
+		var parent drive.ParentReference
+		json.NewDecoder(req.Body).Decode(&parent)
+		f.Parents = append(f.Parents, &parent)
+		return respond(200, &parent), nil
+
+	case len(parts) == 3 && parts[1] == "parents" && req.Method == "DELETE":
+		var kept []*drive.ParentReference
+		for _, parent := range f.Parents {
+			if parent.Id != parts[2] {
+				kept = append(kept, parent)
+			}
+		}
+		f.Parents = kept
+		return respond(204, nil), nil
+	}
+	return nil, fmt.Errorf("fake drive: unexpected %s %s", req.Method, req.URL)
+}
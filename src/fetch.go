@@ -91,6 +91,7 @@ func (g *Commands) fetch(fetchOp int) (err error) {
 		changes:   cl,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: g.opts.NoClobber,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, opMap := printFetchChangeList(&clArg)
@@ -110,7 +111,7 @@ func loneCountRegister(wg *sync.WaitGroup, progress chan int) {
 func (g *Commands) playFetchChanges(cl []*Change, opMap *map[Operation]sizeCounter) (err error) {
 	changeCount := len(cl)
 
-	g.taskStart(int64(changeCount))
+	g.taskStartCount(int64(changeCount))
 
 	progressDone := make(chan bool, 1)
 
@@ -0,0 +1,95 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// expandGlob expands the remote path p, whose segments may be shell
+// patterns e.g "/reports/*/2015-??.pdf", into the paths of the items
+// that match it. A path without patterns is returned as is.
+func (g *Commands) expandGlob(p string) ([]string, error) {
+	if !hasGlobMeta(p) {
+		return []string{p}, nil
+	}
+
+	matches := []string{"/"}
+	for _, segment := range strings.Split(strings.Trim(path.Clean(p), "/"), "/") {
+		if !hasGlobMeta(segment) {
+			for i, match := range matches {
+				matches[i] = path.Join(match, segment)
+			}
+			continue
+		}
+
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
+
+		var expanded []string
+		for _, match := range matches {
+			parent, err := g.rem.FindByPath(match)
+			if err != nil || parent == nil || !parent.IsDir {
+				continue
+			}
+			for child := range g.rem.findChildren(parent.Id, false) {
+				if ok, _ := path.Match(segment, child.Name); ok {
+					expanded = append(expanded, path.Join(match, child.Name))
+				}
+			}
+		}
+		matches = expanded
+	}
+
+	if len(matches) < 1 {
+		return nil, fmt.Errorf("%s: no matches", p)
+	}
+	return matches, nil
+}
+
+// expandSources expands the remote paths in sources that are shell patterns,
+// keeping the order of sources, so that the commands that act on opts.Sources
+// can take wildcards e.g `drive move 'Photos/2023-*' Archive`. A path with
+// pattern characters that names an existing item e.g "Report [final].pdf" is
+// taken literally.
+func (g *Commands) expandSources(sources []string) ([]string, error) {
+	var expanded []string
+	var composedError error = nil
+
+	for _, p := range sources {
+		if hasGlobMeta(p) {
+			if f, err := g.rem.FindByPath(p); err == nil && f != nil {
+				expanded = append(expanded, p)
+				continue
+			}
+		}
+
+		matches, err := g.expandGlob(p)
+		if err != nil {
+			composedError = reComposeError(composedError, err.Error())
+			continue
+		}
+		expanded = append(expanded, matches...)
+	}
+
+	return expanded, composedError
+}
@@ -21,9 +21,16 @@ import (
 const (
 	AboutKey      = "about"
 	AllKey        = "all"
+	ChownKey      = "chown"
+	ClashesKey    = "clashes"
+	CollectKey    = "collect"
+	CommentsKey   = "comments"
 	CopyKey       = "copy"
+	DedupeKey     = "dedupe"
 	DeleteKey     = "delete"
 	DiffKey       = "diff"
+	DrivesKey     = "drives"
+	DuKey         = "du"
 	EmptyTrashKey = "emptytrash"
 	FeaturesKey   = "features"
 	HelpKey       = "help"
@@ -31,18 +38,24 @@ const (
 	DeInitKey     = "deinit"
 	LinkKey       = "Link"
 	ListKey       = "list"
+	MountKey      = "mount"
 	MoveKey       = "move"
 	OSLinuxKey    = "linux"
+	PropKey       = "prop"
 	PullKey       = "pull"
 	PushKey       = "push"
 	PubKey        = "pub"
 	RenameKey     = "rename"
 	QuotaKey      = "quota"
+	SearchKey     = "search"
 	ShareKey      = "share"
+	ShortcutKey   = "shortcut"
+	StarKey       = "star"
 	StatKey       = "stat"
 	TouchKey      = "touch"
 	TrashKey      = "trash"
 	UnshareKey    = "unshare"
+	UnstarKey     = "unstar"
 	UntrashKey    = "untrash"
 	UnpubKey      = "unpub"
 	VersionKey    = "version"
@@ -50,6 +63,11 @@ const (
 	NewKey        = "new"
 	IndexKey      = "index"
 	PruneKey      = "prune"
+	PromoteKey    = "promote"
+	ReapKey       = "reap"
+	UndoKey       = "undo"
+	SyncKey       = "sync"
+	RevisionsKey  = "revisions"
 
 	CoercedMimeKeyKey     = "coerced-mime"
 	DepthKey              = "depth"
@@ -57,6 +75,9 @@ const (
 	EmailMessageKey       = "emailMessage"
 	ForceKey              = "force"
 	QuietKey              = "quiet"
+	JSONKey               = "json"
+	ProfileKey            = "profile"
+	NoPathIndexKey        = "no-path-index"
 	QuitShortKey          = "q"
 	YesShortKey           = "Y"
 	QuitLongKey           = "quit"
@@ -71,6 +92,9 @@ const (
 	ModTimeKey            = "modt"
 	LastViewedByMeTimeKey = "lvt"
 	RoleKey               = "role"
+	OriginalPathKey       = "originalPath"
+	CopyIdKey             = "copyId"
+	SourceIdKey           = "sourceId"
 	TypeKey               = "type"
 	TrashedKey            = "trashed"
 	SkipMimeKeyKey        = "skip-mime"
@@ -89,9 +113,12 @@ const (
 const (
 	DescAbout                 = "print out information about your Google drive"
 	DescAll                   = "print out the entire help section"
+	DescCollect               = "gathers files from many folders into one"
 	DescCopy                  = "copy remote paths to a destination"
+	DescDedupe                = "lists clusters of files with identical content"
 	DescDelete                = "deletes the items permanently. This operation is irreversible"
 	DescDiff                  = "compares local files with their remote equivalent"
+	DescDrives                = "lists the shared drives that you are a member of"
 	DescEmptyTrash            = "permanently cleans out your trash"
 	DescExcludeOps            = "exclude operations"
 	DescFeatures              = "returns information about the features of your drive"
@@ -100,11 +127,16 @@ const (
 	DescInit                  = "initializes a directory and authenticates user"
 	DescDeInit                = "removes the user's credentials and initialized files"
 	DescList                  = "lists the contents of remote path"
+	DescMount                 = "mounts a remote folder as a local filesystem"
 	DescMove                  = "move files/folders"
 	DescQuota                 = "prints out information related to your quota space"
 	DescPublish               = "publishes a file and prints its publicly available url"
 	DescRename                = "renames a file/folder"
 	DescPull                  = "pulls remote changes from Google Drive"
+	DescPromote               = "moves the items in folders up levels of the hierarchy"
+	DescReap                  = "trashes the sources of copies whose grace period is over"
+	DescUndo                  = "undoes the changes made by the last command run"
+	DescSync                  = "pulls only what changed remotely since the last sync"
 	DescPruneIndices          = "remove stale indices"
 	DescPush                  = "push local changes to Google Drive"
 	DescShare                 = "share files with specific emails giving the specified users specifies roles and permissions"
@@ -122,52 +154,300 @@ const (
 	DescIgnoreChecksum        = "avoids computation of checksums as a final check." +
 		"\nUse cases may include:\n\t* when you are low on bandwidth e.g SSHFS." +
 		"\n\t* Are on a low power device"
-	DescIgnoreConflict     = "turns off the conflict resolution safety"
-	DescIgnoreNameClashes  = "ignore name clashes"
-	DescSort               = "sort items in the order\n\t* md5.\n\t* name.\n\t* size.\n\t* type.\n\t* version"
-	DescSkipMime           = "skip elements with mimeTypes derived from these extensison"
-	DescMatchMime          = "get elements with the exact mimeTypes derived from extensisons"
-	DescMatchTitle         = "elements with matching titles"
-	DescExactTitle         = "get elements with the exact titles"
-	DescMatchOwner         = "elements with matching owners"
-	DescExactOwner         = "elements with the exact owner"
-	DescNotOwner           = "ignore elements owned by these users"
-	DescNew                = "create a new file/folder"
-	DescAllIndexOperations = "perform all the index related operations"
-	DescOpen               = "open a file in the appropriate filemanager or default browser"
-	DescUrl                = "returns the url of each file"
-	DescVerbose            = "show step by step information verbosely"
+	DescIgnoreConflict         = "turns off the conflict resolution safety"
+	DescIgnoreNameClashes      = "ignore name clashes"
+	DescSort                   = "sort items in the order\n\t* md5.\n\t* name.\n\t* size.\n\t* type.\n\t* version"
+	DescSkipMime               = "skip elements with mimeTypes derived from these extensison"
+	DescMatchMime              = "get elements with the exact mimeTypes derived from extensisons"
+	DescMatchTitle             = "elements with matching titles"
+	DescExactTitle             = "get elements with the exact titles"
+	DescMatchOwner             = "elements with matching owners"
+	DescExactOwner             = "elements with the exact owner"
+	DescNotOwner               = "ignore elements owned by these users"
+	DescNew                    = "create a new file/folder"
+	DescAllIndexOperations     = "perform all the index related operations"
+	DescOpen                   = "open a file in the appropriate filemanager or default browser"
+	DescUrl                    = "returns the url of each file"
+	DescVerbose                = "show step by step information verbosely"
+	DescPreserveIndexableText  = "touch copies of images and PDFs to get their searchable text re-indexed"
+	DescMaxChildren            = "the most children a destination folder may end up with, 0 to turn off the check"
+	DescShareWith              = "comma separated emails to share each copy with"
+	DescCopyShareRole          = "role to share copies with. Possible values: reader, commenter, writer"
+	DescLayout                 = "tab separated file of <current path> <desired path> lines to reorganize files to"
+	DescDedupeIdentical        = "copy files of identical content once and link that copy into the other destinations"
+	DescOlderThan              = "only operate on items last modified before this age e.g 1y, 6mo, 2w, 30d, 36h or timestamp e.g 2015-06-30"
+	DescNewerThan              = "only operate on items last modified after this age e.g 1y, 6mo, 2w, 30d, 36h or timestamp e.g 2015-06-30"
+	DescPruneEmptyDirs         = "leave out folders that end up empty from copies, instead of preserving them"
+	DescWatch                  = "keep watching the source folder, moving items that match the rule to dest as they arrive"
+	DescWatchRule              = "regular expression that names of items have to match to be moved while watching"
+	DescPollInterval           = "how often to check for changes while watching"
+	DescNameMap                = "tab separated file of <current name> <new name> lines to rename items in a folder by"
+	DescUniqueNames            = "suffix the name of each copy with the id of its source so that no copies clash"
+	DescBreadcrumb             = "record the path each item was moved from in its private originalPath property"
+	DescStatePath              = "file to checkpoint progress to, for resuming if interrupted"
+	DescMaxFileSize            = "don't copy files larger than this size e.g 512KB, 100MB, 4GB"
+	DescOversizePolicy         = "what to do with files over the max file size. Possible values: skip, error"
+	DescAuditLog               = "file to append a line of JSON to for every copy, move, rename and trash made"
+	DescAuditLogRotate         = "set aside an existing audit log, suffixed with the current time, and start a fresh one"
+	DescDestRoot               = "path or id of a folder to resolve relative paths under, paths with a leading / bypass it"
+	DescWaitReady              = "wait for Drive to finish processing each copy e.g transcoding videos, before finishing"
+	DescReadyTimeout           = "longest to wait for each copy to be processed"
+	DescMerge                  = "merge folders into same-named folders at the destination instead of failing on the clash"
+	DescPreserveLinkSharing    = "give copies the same anyone with the link access as their sources"
+	DescThrottleOnLowQuota     = "slow copies down once free storage drops below this size e.g 5GB, or percentage of the quota e.g 5%"
+	DescIncludeShared          = "copy everything that can be read, reuploading the content of files that can't be copied"
+	DescCheckWritable          = "check that items can be added to the destination before starting"
+	DescReformatDate           = "time layout e.g 01-02-2006 of dates in names to be rewritten in the layout of -date-to"
+	DescRenameRegex            = "a sed-style substitution e.g 's/IMG_/Photo_/' to rename the items matched by the paths given"
+	DescReformatDateTo         = "with reformat-date, the time layout e.g 2006-01-02 to rewrite dates in"
+	DescTrashSourceAfter       = "after each verified copy, tag its source to be trashed by `drive reap` once this grace period is over e.g 72h"
+	DescPreserveRestrictions   = "apply the download and sharing restrictions of sources to their copies"
+	DescIntoNewest             = "if the destination is many same-named folders, go into the most recently created"
+	DescIntoOldest             = "if the destination is many same-named folders, go into the first created"
+	DescPausable               = "pause copying on SIGUSR1, copies underway finish, and resume on the next SIGUSR1"
+	DescBacklink               = "link each source and its copy to each other by recording their ids as properties on one another"
+	DescMaxPathLength          = "fail renames and moves that would make for paths longer than this many characters, 0 turns it off"
+	DescVerifyCopies           = "once done, check every copy against its source and report a reconciliation with a pass/fail verdict"
+	DescVerifyReport           = "with verify, the file to write the reconciliation to as JSON"
+	DescStarIf                 = "star copies whose sources match this rule e.g size>10MB,name~\\.pdf$,mime=image/png, whose clauses all have to hold"
+	DescCheckExtensions        = "report files whose name extensions don't match the type of their content e.g a .pdf that is a PNG"
+	DescStrictExtensions       = "like check-extensions but also don't copy such files"
+	DescDestTemplate           = "path relative to the destination to copy each file to, built from placeholders e.g {year}/{month}/{name}"
+	DescPrefetch               = "list the source trees along with their checksums in parallel before copying starts"
+	DescPrefetchWorkers        = "with prefetch, the most folders to list at a time"
+	DescRetryFailedAtEnd       = "how many times to retry the moves that failed, once all the others are done"
+	DescPreserveFolderMetadata = "give created folders the description and starred state of their source folders"
+	DescPruneAfterMove         = "trash the folders, and ancestors thereof, that were left empty by moving items out"
+	DescSnapshotPath           = "only copy the files added or changed since the last copy, as per the snapshot kept at this path"
+	DescSnapshotDeletions      = "with snapshot, trash the copies of files removed from the sources since the last copy"
+	DescEstimateCost           = "log the estimated number of API calls that the moves will make, to be confirmed before moving"
+	DescTreeDiff               = "only plan, and show the trees affected as they are and would be after, with additions and removals marked"
+	DescTreeDiffDepth          = "with tree-diff, how many levels of the trees to show"
+	DescNativeSizePolicy       = "how Google native files, which report no size, count towards sizes: exclude, nominal=SIZE or export"
+	DescDestId                 = "the destination is the id of a folder, rather than its path"
+	DescCopyWorkers            = "the most items in folders to copy at a time, rate limited copies are retried with backoff"
+	DescDryRun                 = "change nothing, instead print the operations that would be made with the paths and ids involved"
+	DescBatchMoves             = "make the moves in batches of up to 100, which takes far fewer requests for many items"
+	DescJSON                   = "emit the results, a line of JSON per item or change pushed or pulled, to stdout instead of logs e.g `drive -json move a b`"
+	DescDownloadWorkers        = "the most ranges of a large file to download at a time, 1 to download it in a single stream"
+	DescDownloadChunkSize      = "the size of the ranges that large files are downloaded in e.g 16MB"
+	DescNoPathIndex            = "resolve paths from Google Drive alone, bypassing the ids cached in .gd/paths.db"
+	DescToProfile              = "copy into the account of this profile instead e.g work, streaming the content across"
+	DescQuery                  = "act on the items matching this Drive query e.g \"mimeType = 'application/zip' and modifiedDate < '2020-01-01'\""
+	DescProfile                = "the named credentials to use, side by side with the default ones e.g work"
+	DescDeviceAuth             = "authorize with a code entered on another device, for machines without a browser"
+	DescServiceAccount         = "JSON key of a service account to authenticate as, for headless use"
+	DescServiceAccountSubject  = "with a service account, the user to impersonate through domain-wide delegation"
+	DescModConflictPolicy      = "what to do with files changed both locally and remotely since last synced. Possible values: fail, local-wins, remote-wins, keep-both"
+	DescExportLayout           = "how exports are laid out, in export-dir or beside the documents. Possible values: nested, flat, by-format"
+	DescPushWatch              = "keep watching the sources after pushing them, pushing local changes as they happen"
+	DescDebounce               = "with watch, how long the local tree has to be left alone before changes are pushed"
+	DescRevisions              = "lists, pins, unpins and deletes the revisions of files"
+	DescRevision               = "the id of the revision of the sources to pull, beside their local copies"
+	DescRevisionsPin           = "comma separated ids of revisions to keep forever"
+	DescRevisionsUnpin         = "comma separated ids of revisions to no longer keep forever"
+	DescRevisionsDelete        = "comma separated ids of revisions to delete"
+	DescRevisionsPrune         = "delete the revisions that are neither pinned nor among the newest kept"
+	DescRevisionsKeep          = "with prune, the number of newest revisions to keep"
+	DescShortcut               = "creates shortcuts to items"
+	DescFollowShortcuts        = "resolve shortcuts to the items that they point to"
+	DescComments               = "lists the comments on files, along with their replies"
+	DescCommentsOpen           = "only list comments that aren't resolved"
+	DescCommentsExport         = "also export the comments beside the local copy of each file. Possible values: json, md"
+	DescShareList              = "list who has access to the items instead of sharing them"
+	DescShareFormat            = "with list, write the report to stdout in this format. Possible values: csv, json"
+	DescWithLink               = "only grant or revoke access to those that have the link"
+	DescShareRecursive         = "also share, unshare or list the access to the descendants of folders"
+	DescChown                  = "transfers the ownership of items to another user"
+	DescStar                   = "stars items"
+	DescUnstar                 = "unstars items"
+	DescStarred                = "only the starred items, anywhere in the sources"
+	DescProp                   = "sets, gets or deletes the custom properties of items"
+	DescPropPrivate            = "act on the properties private to drive instead of the public ones"
+	DescPushProperty           = "stamp each pushed file with these key=value properties, comma separated"
+	DescDedupeAuto             = "resolve every cluster of duplicates with this strategy. Possible values: keep-newest, keep-shallowest-path, replace-with-shortcut"
+	DescDedupeResolve          = "prompt for the strategy to resolve each cluster of duplicates with"
+	DescClashes                = "lists the items that share their names with siblings, and fixes them"
+	DescClashesFix             = "fix the clashes, the most recently modified item of each keeping its name"
+	DescClashesStrategy        = "with fix, how to fix the clashes. Possible values: rename, trash-older, merge"
+	DescQueryFilter            = "only the items matching this Drive query, anywhere in the sources"
+	DescSearch                 = "searches the content and metadata of items for terms"
+	DescSearchSort             = "the order of the results. Possible values: relevance, modtime"
+	DescSearchMax              = "the most results to list, all if 0"
+	DescDu                     = "reports the storage used per folder, largest first"
+	DescDuDepth                = "how many levels of folders below the sources to list, all if negative"
+	DescDuFormat               = "write the report to stdout in this format. Possible values: csv"
+	DescQuotaLargest           = "how many of the largest files owned to list, none if 0"
+	DescUrlLink                = "comma separated links to print. Possible values: view, download, embed, all"
+	DescOpenLink               = "the link to open in the browser. Possible values: view, download, embed"
+	DescPubRole                = "the role granted to anyone. Possible values: reader, commenter, writer"
+	DescPubWithLink            = "only grant access to those that have the link, instead of publishing on the web"
+	DescPubExpires             = "an age e.g 7d, 36h or a timestamp e.g 2015-06-30 after which the links are revoked by `unpub -expired`"
+	DescPubExpired             = "revoke the links published to expire whose time is up, throughout the drive"
+	DescOCRLanguage            = "with -ocr, the ISO 639-1 code of the language to OCR in e.g en, fr"
+	DescEmptyTrashOlderThan    = "only delete the items trashed before this age e.g 30d, 2w or timestamp e.g 2015-06-30"
+	DescTrashList              = "list the trashed items with the paths they were trashed from and when"
+	DescSymlinks               = "how to treat symlinks. Possible values: follow, skip, preserve"
+	DescPreserve               = "comma separated attributes of files that push keeps and pull restores. Possible values: mtime, mode, xattr"
+	DescEncrypt                = "encrypt the names and content of files before uploading them"
+	DescDecrypt                = "decrypt the names and content of files that push encrypted"
+	DescEncryptionKeyFile      = "file the encryption key is kept in, generated by the first encrypted push if missing"
+	DescGzip                   = "compress files that aren't already compressed as they are uploaded, pull decompresses them"
+	DescVerifyTransfers        = "check the md5 checksum of every transferred file against that on Drive, transferring it again on mismatch"
+	DescLimitUploadRate        = "the most bytes per second to upload at, across all uploads e.g 512KB, 2MB/s"
+	DescLimitDownloadRate      = "the most bytes per second to download at, across all downloads e.g 512KB, 2MB/s"
+	DescConflictPolicy         = "what to do with items whose names clash at the destination. Possible values: skip, rename, keep-both"
+	DescCaseFold               = "resolve items in folders whose names only differ by case, keeping the most recently modified's name"
+	DescCaseFoldTrash          = "with case-fold, trash the items whose names clash instead of renaming them"
+	DescPlan                   = "write the operations that would be made to this file, or stdout if -, instead of making them"
+	DescPartition              = "route copied files into subfolders by:\n\t* alpha.\n\t* date.\n\t* a template of {initial}, {year}, {month}, {day}, {ext}"
 )
 
 const (
-	CLIOptionExplicitlyExport   = "explicitly-export"
-	CLIOptionIgnoreChecksum     = "ignore-checksum"
-	CLIOptionIgnoreConflict     = "ignore-conflict"
-	CLIOptionIgnoreNameClashes  = "ignore-name-clashes"
-	CLIOptionExcludeOperations  = "exclude-ops"
-	CLIOptionId                 = "id"
-	CLIOptionNoClobber          = "no-clobber"
-	CLIOptionNotify             = "notify"
-	CLIOptionSkipMime           = "skip-mime"
-	CLIOptionMatchMime          = "exact-mime"
-	CLIOptionExactTitle         = "exact-title"
-	CLIOptionMatchTitle         = "match-mime"
-	CLIOptionExactOwner         = "exact-owner"
-	CLIOptionMatchOwner         = "match-owner"
-	CLIOptionNotOwner           = "skip-owner"
-	CLIOptionPruneIndices       = "prune"
-	CLIOptionAllIndexOperations = "all-ops"
-	CLIOptionVerboseKey         = "verbose"
-	CLIOptionVerboseShortKey    = "v"
-	CLIOptionOpen               = "open"
-	CLIOptionWebBrowser         = "web-browser"
-	CLIOptionFileBrowser        = "file-browser"
+	CLIOptionExplicitlyExport       = "explicitly-export"
+	CLIOptionIgnoreChecksum         = "ignore-checksum"
+	CLIOptionIgnoreConflict         = "ignore-conflict"
+	CLIOptionIgnoreNameClashes      = "ignore-name-clashes"
+	CLIOptionExcludeOperations      = "exclude-ops"
+	CLIOptionId                     = "id"
+	CLIOptionNoClobber              = "no-clobber"
+	CLIOptionNotify                 = "notify"
+	CLIOptionSkipMime               = "skip-mime"
+	CLIOptionMatchMime              = "exact-mime"
+	CLIOptionExactTitle             = "exact-title"
+	CLIOptionMatchTitle             = "match-mime"
+	CLIOptionExactOwner             = "exact-owner"
+	CLIOptionMatchOwner             = "match-owner"
+	CLIOptionNotOwner               = "skip-owner"
+	CLIOptionPruneIndices           = "prune"
+	CLIOptionAllIndexOperations     = "all-ops"
+	CLIOptionVerboseKey             = "verbose"
+	CLIOptionVerboseShortKey        = "v"
+	CLIOptionOpen                   = "open"
+	CLIOptionWebBrowser             = "web-browser"
+	CLIOptionFileBrowser            = "file-browser"
+	CLIOptionPreserveIndexableText  = "preserve-indexable-text"
+	CLIOptionPartition              = "partition"
+	CLIOptionMaxChildren            = "max-children"
+	CLIOptionShareWith              = "share-with"
+	CLIOptionLayout                 = "layout"
+	CLIOptionDedupeIdentical        = "dedupe-identical"
+	CLIOptionOlderThan              = "older-than"
+	CLIOptionNewerThan              = "newer-than"
+	CLIOptionPruneEmptyDirs         = "prune-empty-dirs"
+	CLIOptionWatch                  = "watch"
+	CLIOptionWatchRule              = "rule"
+	CLIOptionPollInterval           = "poll-interval"
+	CLIOptionNameMap                = "name-map"
+	CLIOptionUniqueNames            = "unique-names"
+	CLIOptionBreadcrumb             = "breadcrumb"
+	CLIOptionStatePath              = "state"
+	CLIOptionMaxFileSize            = "max-file-size"
+	CLIOptionOversizePolicy         = "on-oversize"
+	CLIOptionAuditLog               = "audit-log"
+	CLIOptionAuditLogRotate         = "audit-rotate"
+	CLIOptionDestRoot               = "dest-root"
+	CLIOptionWaitReady              = "wait-ready"
+	CLIOptionReadyTimeout           = "ready-timeout"
+	CLIOptionMerge                  = "merge"
+	CLIOptionPlan                   = "plan"
+	CLIOptionCaseFold               = "case-fold"
+	CLIOptionConflictPolicy         = "on-conflict"
+	CLIOptionModConflictPolicy      = "conflict"
+	CLIOptionPreserveLinkSharing    = "preserve-link-sharing"
+	CLIOptionThrottleOnLowQuota     = "throttle-on-low-quota"
+	CLIOptionIncludeShared          = "include-shared"
+	CLIOptionCheckWritable          = "check-writable"
+	CLIOptionReformatDate           = "reformat-date"
+	CLIOptionReformatDateTo         = "date-to"
+	CLIOptionRenameRegex            = "regex"
+	CLIOptionToProfile              = "to-profile"
+	CLIOptionQuery                  = "query"
+	CLIOptionLimitUploadRate        = "limit-upload-rate"
+	CLIOptionLimitDownloadRate      = "limit-download-rate"
+	CLIOptionDebounce               = "debounce"
+	CLIOptionExportLayout           = "export-layout"
+	CLIOptionRevision               = "revision"
+	CLIOptionRevisionsPin           = "pin"
+	CLIOptionRevisionsUnpin         = "unpin"
+	CLIOptionRevisionsDelete        = "delete"
+	CLIOptionRevisionsPrune         = "prune"
+	CLIOptionRevisionsKeep          = "keep"
+	CLIOptionFollowShortcuts        = "follow-shortcuts"
+	CLIOptionCommentsOpen           = "open"
+	CLIOptionCommentsExport         = "export"
+	CLIOptionShareList              = "list"
+	CLIOptionShareFormat            = "format"
+	CLIOptionWithLink               = "with-link"
+	CLIOptionStarred                = "starred"
+	CLIOptionPrivate                = "private"
+	CLIOptionProperty               = "property"
+	CLIOptionVerifyTransfers        = "verify"
+	CLIOptionDedupeAuto             = "auto"
+	CLIOptionDedupeResolve          = "resolve"
+	CLIOptionClashesFix             = "fix"
+	CLIOptionClashesStrategy        = "strategy"
+	CLIOptionSearchMax              = "max"
+	CLIOptionDuDepth                = "depth"
+	CLIOptionDuFormat               = "format"
+	CLIOptionQuotaLargest           = "largest"
+	CLIOptionLink                   = "link"
+	CLIOptionPubExpires             = "expires"
+	CLIOptionPubExpired             = "expired"
+	CLIOptionOCRLanguage            = "ocr-language"
+	CLIOptionTrashList              = "list"
+	CLIOptionSymlinks               = "symlinks"
+	CLIOptionPreserve               = "preserve"
+	CLIOptionEncrypt                = "encrypt"
+	CLIOptionDecrypt                = "decrypt"
+	CLIOptionEncryptionKeyFile      = "key-file"
+	CLIOptionGzip                   = "gzip"
+	CLIOptionTrashSourceAfter       = "trash-source-after"
+	CLIOptionPreserveRestrictions   = "preserve-restrictions"
+	CLIOptionIntoNewest             = "into-newest"
+	CLIOptionIntoOldest             = "into-oldest"
+	CLIOptionPausable               = "pausable"
+	CLIOptionBacklink               = "backlink"
+	CLIOptionMaxPathLength          = "max-path-length"
+	CLIOptionVerifyCopies           = "verify"
+	CLIOptionVerifyReport           = "verify-report"
+	CLIOptionLevels                 = "levels"
+	CLIOptionStarIf                 = "star-if"
+	CLIOptionCheckExtensions        = "check-extensions"
+	CLIOptionStrictExtensions       = "strict-extensions"
+	CLIOptionDestTemplate           = "dest-template"
+	CLIOptionPrefetch               = "prefetch"
+	CLIOptionPrefetchWorkers        = "prefetch-workers"
+	CLIOptionRetryFailedAtEnd       = "retry-failed-at-end"
+	CLIOptionPreserveFolderMetadata = "preserve-folder-metadata"
+	CLIOptionPruneAfterMove         = "prune-after-move"
+	CLIOptionSnapshotPath           = "snapshot"
+	CLIOptionSnapshotDeletions      = "snapshot-deletions"
+	CLIOptionEstimateCost           = "estimate-cost"
+	CLIOptionTreeDiff               = "tree-diff"
+	CLIOptionTreeDiffDepth          = "tree-diff-depth"
+	CLIOptionNativeSizePolicy       = "native-size"
+	CLIOptionDestId                 = "dest-id"
+	CLIOptionCopyWorkers            = "copy-workers"
+	CLIOptionDryRun                 = "dry-run"
+	CLIOptionBatchMoves             = "batch"
+	CLIOptionDownloadWorkers        = "download-workers"
+	CLIOptionDownloadChunkSize      = "download-chunk-size"
+	CLIOptionServiceAccount         = "service-account"
+	CLIOptionDeviceAuth             = "device"
+	CLIOptionServiceAccountSubject  = "subject"
+	CLIOptionCaseFoldTrash          = "trash"
 )
 
 const (
 	GoogleApiClientIdEnvKey     = "GOOGLE_API_CLIENT_ID"
 	GoogleApiClientSecretEnvKey = "GOOGLE_API_CLIENT_SECRET"
 	DriveGoMaxProcsKey          = "DRIVE_GOMAXPROCS"
+	DriveProfileEnvKey          = "DRIVE_PROFILE"
+	DriveConflictPolicyEnvKey   = "DRIVE_CONFLICT"
+	DriveUploadRateEnvKey       = "DRIVE_LIMIT_UPLOAD_RATE"
+	DriveDownloadRateEnvKey     = "DRIVE_LIMIT_DOWNLOAD_RATE"
+	DriveEncryptionKeyEnvKey    = "DRIVE_ENCRYPTION_KEY"
 	GoMaxProcsKey               = "GOMAXPROCS"
 )
 
@@ -175,29 +455,256 @@ const (
 	DesktopExtension = "desktop"
 )
 
+const (
+	// AppPropertyVisibility is the visibility of
+	// properties that only this app can see.
+	AppPropertyVisibility = "PRIVATE"
+	// PropertyVisibilityPublic is the visibility of
+	// properties that all apps can see.
+	PropertyVisibilityPublic = "PUBLIC"
+)
+
+const (
+	OversizeSkip  = "skip"
+	OversizeError = "error"
+)
+
 const (
 	InfiniteDepth = -1
+	// DefaultMaxChildren is deliberately generous, only
+	// guarding against pathologically large folders.
+	DefaultMaxChildren = 50000
 )
 
 var skipChecksumNote = fmt.Sprintf(
 	"\nNote: You can skip checksum verification by passing in flag `-%s`", CLIOptionIgnoreChecksum)
 
+var modConflictNote = fmt.Sprintf(
+	"Files changed both locally and remotely since last synced are settled as per `-%s`,\n"+
+		"or %s if it isn't set. With %s the version being replaced is kept as e.g\n"+
+		"\"notes (conflict 2024-05-01).txt\", with %s, the default, the operation stops on them.",
+	CLIOptionModConflictPolicy, DriveConflictPolicyEnvKey, ConflictKeepBoth, ConflictFail)
+
+var rateLimitNote = fmt.Sprintf(
+	"Transfers can be kept from saturating a shared connection with `-%s` and\n"+
+		"`-%s`, or %s and %s if they aren't set e.g `-%s 1MB/s`",
+	CLIOptionLimitUploadRate, CLIOptionLimitDownloadRate,
+	DriveUploadRateEnvKey, DriveDownloadRateEnvKey, CLIOptionLimitUploadRate)
+
+var queryNote = fmt.Sprintf(
+	"With `-%s`, the items matching a Drive query are acted on instead of paths, after\n"+
+		"a confirmation that `-%s` skips. v3 field names e.g modifiedTime work as well e.g\n"+
+		"\n\t$ drive trash -%s 'mimeType = \"application/zip\" and modifiedTime < \"2020-01-01\"'",
+	CLIOptionQuery, ForceKey, CLIOptionQuery)
+
+var shareTreeNote = fmt.Sprintf(
+	"With `-r`, the descendants of folders are shared or unshared too, and with `-%s`\n"+
+		"the items matching a Drive query are instead. Permissions are changed in batches\n"+
+		"and those that fail are reported without stopping the others e.g\n"+
+		"\n\t$ drive %s -r -%s writer -%s Projects/Old",
+	CLIOptionQuery, UnshareKey, RoleKey, CLIOptionWithLink)
+
+var starredNote = fmt.Sprintf(
+	"With `-%s`, list and pull act on the starred items anywhere in their sources,\n"+
+		"a collection of their own, starred folders along with everything in them e.g\n"+
+		"\n\t$ drive pull -%s",
+	CLIOptionStarred, CLIOptionStarred)
+
+var verifyNote = fmt.Sprintf(
+	"With `-%s`, the md5 checksum of every transferred file is checked against that\n"+
+		"on Drive and files that don't match are transferred again, up to %d times, before\n"+
+		"being given up on. A summary of the verified and mismatched files follows e.g\n"+
+		"\n\t$ drive %s -%s Photos",
+	CLIOptionVerifyTransfers, verifyAttempts, PullKey, CLIOptionVerifyTransfers)
+
+var symlinksNote = fmt.Sprintf(
+	"`-%s` sets how symlinks are treated. With %s, the default, what they point to is\n"+
+		"pushed in their place, except for folders that contain them or that they were reached\n"+
+		"through, e.g by symlinks between sibling folders, which would never end.\n"+
+		"With %s they are left out, and with %s they are pushed as small files holding their\n"+
+		"targets, that pull with `-%s %s` recreates as symlinks e.g\n"+
+		"\n\t$ drive push -%s %s dotfiles",
+	CLIOptionSymlinks, SymlinksFollow, SymlinksSkip, SymlinksPreserve, CLIOptionSymlinks, SymlinksPreserve,
+	CLIOptionSymlinks, SymlinksPreserve)
+
+var preserveNote = fmt.Sprintf(
+	"Modification times always round-trip. `-%s mode,xattr` also keeps the permission bits\n"+
+		"and extended attributes of pushed files in properties private to drive, and restores\n"+
+		"them on pull. Extended attributes too long for a property are left out e.g\n"+
+		"\n\t$ drive push -%s mode,xattr backups",
+	CLIOptionPreserve, CLIOptionPreserve)
+
+var encryptionNote = fmt.Sprintf(
+	"With `-%s`, push encrypts the names and content of files before uploading them, and\n"+
+		"pull with `-%s` decrypts them, using the key in $%s or else in `-%s`, by default\n"+
+		".gd/%s, which the first encrypted push generates. Keys are %d random bytes in\n"+
+		"hex e.g from `openssl rand -hex %d`, passphrases aren't taken. Keep a copy of the\n"+
+		"key: what is pushed encrypted can't be decrypted without it e.g\n"+
+		"\n\t$ drive push -%s taxes\n\t$ drive pull -%s taxes",
+	CLIOptionEncrypt, CLIOptionDecrypt, DriveEncryptionKeyEnvKey, CLIOptionEncryptionKeyFile,
+	EncryptionKeySuffix, encryptionKeySize, encryptionKeySize, CLIOptionEncrypt, CLIOptionDecrypt)
+
+var gzipNote = fmt.Sprintf(
+	"With `-%s`, push compresses files as it uploads them, which saves quota on logs, text\n"+
+		"dumps and the like, and pull decompresses them as it downloads them, with or without\n"+
+		"the flag. Images, videos and archives, whose content is already compressed, are\n"+
+		"uploaded as they are e.g\n"+
+		"\n\t$ drive push -%s logs",
+	CLIOptionGzip, CLIOptionGzip)
+
+var queryFilterNote = fmt.Sprintf(
+	"With `-%s`, list and pull act on the items matching a Drive query anywhere in\n"+
+		"their sources, pulled to local paths that mirror their remote ones. Items shared\n"+
+		"with you that aren't in your drive are left out e.g\n"+
+		"\n\t$ drive pull -%s \"mimeType contains 'image/' and modifiedTime > '2024-01-01'\" Photos",
+	CLIOptionQuery, CLIOptionQuery)
+
+var planNote = fmt.Sprintf(
+	"\nWith `-%s plan.tsv`, nothing is changed and instead the operations that would be\n"+
+		"made are written to plan.tsv in order, one tab separated line per operation with\n"+
+		"the ids it applies to. Files that would be created get placeholder ids e.g <new-1>", CLIOptionPlan)
+
+var dryRunNote = fmt.Sprintf(
+	"With `-%s`, nothing is changed and the operations that would be made are printed\n"+
+		"along with the paths and ids involved, e.g move /docs/a.txt -> /archive/a.txt", CLIOptionDryRun)
+
+var treeDiffNote = fmt.Sprintf(
+	"With `-%s`, nothing is changed either and instead the trees affected are shown\n"+
+		"as one tree, `-%s` levels deep, in which items that would be added are marked\n"+
+		"with + and those that would be removed with -", CLIOptionTreeDiff, CLIOptionTreeDiffDepth)
+
 var docMap = map[string][]string{
 	AboutKey: []string{
 		DescAbout,
 	},
+	CollectKey: []string{
+		DescCollect, "Accepts <src> [src...] <dest>",
+		"Moves the files in each source folder into dest. Sources can be shell patterns e.g",
+		fmt.Sprintf("\n\t$ drive collect -r -%s \"\\.pdf$\" \"clients/*/invoices\" Invoices\n", CLIOptionWatchRule),
+		"Files whose content is already in dest are left in place as duplicates",
+		fmt.Sprintf("and name clashes are resolved as per `-%s`. Since collected files", CLIOptionConflictPolicy),
+		"leave their sources, running collect again picks up where it stopped.",
+		planNote,
+	},
+	ChownKey: []string{
+		DescChown,
+		"Takes the email of the new owner then the items, and their descendants with `-r` e.g",
+		fmt.Sprintf("\n\t$ drive %s -r alice@example.com Handover\n", ChownKey),
+		"Where Drive requires the new owner's consent, e.g between personal accounts, they are",
+		"made the pending owner instead and the transfer completes once they accept it. Such",
+		"transfers are reported as awaiting acceptance.",
+		fmt.Sprintf("Items are listed for confirmation first, unless `-%s` is set", ForceKey),
+	},
+	ClashesKey: []string{
+		DescClashes,
+		"Drive lets many items in a folder have the same name, yet then their path can't",
+		fmt.Sprintf("tell them apart and copy and move refuse it as their destination unless `-%s`", CLIOptionIgnoreNameClashes),
+		"is set. Clashes are looked for in the",
+		fmt.Sprintf("given folders and their descendants, and with `-%s` fixed after a confirmation", CLIOptionClashesFix),
+		fmt.Sprintf("that `-%s` skips. Of each clash, the most recently modified item is kept as is", ForceKey),
+		fmt.Sprintf("and as per `-%s` the others are:", CLIOptionClashesStrategy),
+		fmt.Sprintf("\t* %s: renamed apart e.g \"notes (1).txt\", the default", ClashFixRename),
+		fmt.Sprintf("\t* %s: trashed", ClashFixTrashOlder),
+		fmt.Sprintf("\t* %s: if all are folders, emptied into the kept folder and trashed.", ClashFixMerge),
+		"\t  Clashes of the items moved in are merged too, and files are renamed instead",
+		fmt.Sprintf("\n\t$ drive %s -%s -%s %s Projects", ClashesKey, CLIOptionClashesFix, CLIOptionClashesStrategy, ClashFixMerge),
+	},
+	CommentsKey: []string{
+		DescComments,
+		"Lists the author, quoted text, status and replies of each comment.",
+		fmt.Sprintf("With `-%s`, the comments are also exported beside the local copy of each file", CLIOptionCommentsExport),
+		"e.g to proposal.comments.md, for keeping them along with a pulled document e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s -%s %s proposal\n", CommentsKey, CLIOptionCommentsOpen, CLIOptionCommentsExport, CommentsFormatMarkdown),
+	},
 	CopyKey: []string{
 		DescCopy,
+		"Sources can be shell patterns e.g \"Photos/2023-*\"",
+		"Searchable text that Drive extracts from images and PDFs by OCR isn't",
+		"guaranteed to be carried over to copies; such copies are reported",
+		fmt.Sprintf("and with flag `-%s` are touched to get them re-indexed", CLIOptionPreserveIndexableText),
+		fmt.Sprintf("Large flat folders can be split up with `-%s` e.g", CLIOptionPartition),
+		fmt.Sprintf("\n\t$ drive copy -r -%s \"{year}/{month}\" photos archive", CLIOptionPartition),
+		fmt.Sprintf("With `-%s <profile>`, the sources are copied to the account of another profile", CLIOptionToProfile),
+		"e.g from a personal to a work account. File content is streamed across whereas Google Docs",
+		"are shared with the other account just long enough for it to copy them. Items already at",
+		"the destination are skipped, so rerunning an interrupted copy picks up where it stopped.",
+		fmt.Sprintf("Folders need `-r`, and of the other flags only `-%s`, `-%s` and `-%s` apply, the", CLIOptionDryRun, CLIOptionPlan, CLIOptionAuditLog),
+		"rest are refused. What is made on the other account is journaled in its profile's journal e.g",
+		fmt.Sprintf("\n\t$ drive copy -r -%s work Projects Imported", CLIOptionToProfile),
+		fmt.Sprintf("Each copy can be shared right away with `-%s` and `-%s`", CLIOptionShareWith, RoleKey),
+		fmt.Sprintf("With `-%s`, files with the same md5 checksum are copied once and", CLIOptionDedupeIdentical),
+		"that one copy is added to each of their destination folders, under the first copy's name",
+		fmt.Sprintf("Empty folders are copied as is unless `-%s` is set", CLIOptionPruneEmptyDirs),
+		fmt.Sprintf("Files larger than `-%s` are skipped, or with `-%s %s` fail to copy", CLIOptionMaxFileSize, CLIOptionOversizePolicy, OversizeError),
+		fmt.Sprintf("\n\t$ drive copy -r -%s 100MB Documents Archive", CLIOptionMaxFileSize),
+		fmt.Sprintf("With `-%s`, each copy is waited on until Drive is done processing it,", CLIOptionWaitReady),
+		"polling them all while copying goes on and finishing once they are ready",
+		fmt.Sprintf("Copies are private unless `-%s` is set, which gives each copy", CLIOptionPreserveLinkSharing),
+		"the same \"anyone with the link\" access as its source",
+		fmt.Sprintf("With `-%s`, copies are slowed down once free storage runs low", CLIOptionThrottleOnLowQuota),
+		"and paused whenever there isn't space for the next copy, until space is freed up",
+		fmt.Sprintf("With `-%s`, files that can't be copied e.g those shared by others", CLIOptionIncludeShared),
+		"are downloaded, exported if need be, and reuploaded. Those that can't be read are skipped",
+		fmt.Sprintf("With `-%s <duration>`, the source of each copy whose content checks out", CLIOptionTrashSourceAfter),
+		fmt.Sprintf("is tagged to be trashed by `drive %s` once the duration is over", ReapKey),
+		fmt.Sprintf("With `-%s`, each file is copied to the path that the template evaluates to", CLIOptionDestTemplate),
+		"under the destination, rather than mirroring the source's folders. The placeholders are",
+		"{name}, {base} i.e the name without its extension, {ext}, {initial}, {mime}, {owner}",
+		"and {year}, {month} and {day} of the modification time e.g",
+		fmt.Sprintf("\n\t$ drive copy -r -%s \"{year}/{month}/{name}\" Photos Archive", CLIOptionDestTemplate),
+		fmt.Sprintf("With `-%s`, every copy is checked against its source once done and a reconciliation", CLIOptionVerifyCopies),
+		"of what was copied, skipped and verified is reported along with a pass or fail verdict",
+		fmt.Sprintf("With `-%s <file>`, copying is incremental: the checksums and modification", CLIOptionSnapshotPath),
+		"times of the sources are kept in the file and the next copy only copies the files",
+		"added or changed since, trashing the earlier copies of the changed ones. Copies of files",
+		fmt.Sprintf("removed from the sources are only trashed with `-%s`", CLIOptionSnapshotDeletions),
+		fmt.Sprintf("\n\t$ drive copy -r -%s archive.snapshot Documents Archive", CLIOptionSnapshotPath),
+		fmt.Sprintf("Google native files e.g Docs report no size, so by default `-%s` and", CLIOptionMaxFileSize),
+		fmt.Sprintf("the size clauses of `-%s` leave them out. With `-%s nominal=SIZE` they", CLIOptionStarIf, CLIOptionNativeSizePolicy),
+		fmt.Sprintf("are sized at SIZE and with `-%s %s` at the size of their export", CLIOptionNativeSizePolicy, NativeSizeExport),
+		treeDiffNote,
+		fmt.Sprintf("Created folders only get their source folder's description and star with `-%s`", CLIOptionPreserveFolderMetadata),
+		planNote,
+		dryRunNote,
+	},
+	DedupeKey: []string{
+		DescDedupe, "Scans the files under a remote path, by default the current directory,",
+		"and lists the clusters of those that have the same md5 checksum",
+		"The scan is checkpointed a page at a time so an interrupted scan",
+		fmt.Sprintf("resumes where it stopped when dedupe is run again. See `-%s`", CLIOptionStatePath),
+		"Files are clustered by md5 checksum and size, and the clusters are listed a page at a time.",
+		fmt.Sprintf("With `-%s`, every cluster is resolved with a strategy after a confirmation", CLIOptionDedupeAuto),
+		fmt.Sprintf("that `-%s` skips, and with `-%s` the strategy is prompted for cluster by cluster:", ForceKey, CLIOptionDedupeResolve),
+		fmt.Sprintf("\t* %s: keeps the most recently modified file and trashes the others", DedupeKeepNewest),
+		fmt.Sprintf("\t* %s: keeps the file nearest the root and trashes the others", DedupeKeepShallowest),
+		fmt.Sprintf("\t* %s: keeps the file nearest the root and replaces the others", DedupeReplaceWithShortcut),
+		"\t  with shortcuts to it, so that they can still be found where they were",
+		fmt.Sprintf("\n\t$ drive %s -%s %s Photos", DedupeKey, CLIOptionDedupeAuto, DedupeKeepShallowest),
 	},
 	DeleteKey: []string{
 		DescDelete,
+		"Paths can be shell patterns e.g \"Scans/*.tmp\"",
+		queryNote,
 	},
 	DiffKey: []string{
 		DescDiff, "Accepts multiple remote paths for line by line comparison",
 		skipChecksumNote,
 	},
+	DrivesKey: []string{
+		DescDrives,
+		"Lists the id and name of each shared drive, formerly Team Drive.",
+		fmt.Sprintf("Paths in a shared drive start with `%s` and its name, for any command e.g", SharedDrivePrefix),
+		fmt.Sprintf("\n\t$ drive ls %sMarketing/Assets", SharedDrivePrefix),
+		fmt.Sprintf("\t$ drive pull %sMarketing/Assets", SharedDrivePrefix),
+		fmt.Sprintf("\t$ drive mv %sMarketing/draft.doc %sMarketing/Archive\n", SharedDrivePrefix, SharedDrivePrefix),
+		"Items in shared drives have just one parent, so moves within and into them",
+		"are made in a single request rather than linking then unlinking.",
+	},
 	EmptyTrashKey: []string{
 		DescEmptyTrash,
+		fmt.Sprintf("With `-%s`, only the items trashed before then are deleted, along with", CLIOptionOlderThan),
+		"the contents of trashed folders, after they are listed for confirmation e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s 30d\n", EmptyTrashKey, CLIOptionOlderThan),
 	},
 	FeaturesKey: []string{
 		DescFeatures,
@@ -206,40 +713,261 @@ var docMap = map[string][]string{
 		DescInit, "Requests for access to your Google Drive",
 		"Creating a folder that contains your credentials",
 		"Note: `init` in an already initialized drive will erase the old credentials",
+		fmt.Sprintf("With `-%s work`, the credentials are kept side by side with the others as", ProfileKey),
+		fmt.Sprintf("the profile named work, which commands use given `drive -%s work <command>`", ProfileKey),
+		fmt.Sprintf("or with %s=work set in the environment", DriveProfileEnvKey),
+		fmt.Sprintf("With `-%s`, prints a code to enter at a URL on any device with a browser", CLIOptionDeviceAuth),
+		"instead of needing the authorization code pasted back.",
+		fmt.Sprintf("With `-%s key.json`, authenticates as the service account instead,", CLIOptionServiceAccount),
+		"without a browser, for headless servers. The key is kept with the credentials.",
+		fmt.Sprintf("`-%s user@example.com` impersonates the user, which needs domain-wide delegation", CLIOptionServiceAccountSubject),
 	},
 	PullKey: []string{
 		DescPull, "Downloads content from the remote drive or modifies",
 		" local content to match that on your Google Drive",
+		fmt.Sprintf("Files of %s or more are downloaded in ranges, `-%s` at a time,",
+			prettyBytes(MultiRangeDownloadThreshold), CLIOptionDownloadWorkers),
+		fmt.Sprintf("each of `-%s` and retried on its own if it fails", CLIOptionDownloadChunkSize),
+		"Google Docs, Sheets, Slides and Drawings can be exported to many formats at once e.g",
+		fmt.Sprintf("\n\t$ drive pull -export pdf,docx,odt -%s %s reports\n", CLIOptionExportLayout, ExportLayoutByFormat),
+		fmt.Sprintf("With `-%s`, the default, each document's exports go in a folder of their own,", ExportLayoutNested),
+		fmt.Sprintf("with `-%s` beside it and with `-%s` in a folder per format.", ExportLayoutFlat, ExportLayoutByFormat),
+		fmt.Sprintf("Documents count towards the totals and time left only if sized with `-%s`", CLIOptionNativeSizePolicy),
+		fmt.Sprintf("An earlier revision, as listed by `drive %s`, is pulled with `-%s`", RevisionsKey, CLIOptionRevision),
+		"beside the file's local copy e.g \"report (revision 1042).pdf\", or to stdout if piped.",
+		starredNote,
+		modConflictNote,
+		rateLimitNote,
 		skipChecksumNote,
+		verifyNote,
+		symlinksNote,
+		preserveNote,
+		encryptionNote,
+		gzipNote,
+		queryFilterNote,
 	},
 	PushKey: []string{
 		DescPush, "Uploads content to your Google Drive from your local path",
 		"Push comes in a couple of flavors",
 		"\t* Ordinary push: `drive push path1 path2 path3`",
 		"\t* Mounted push: `drive push -m path1 [path2 path3] drive_context_path`",
+		"Large files are uploaded in chunks; if such an upload is interrupted",
+		"pushing the file again resumes it from where it stopped.",
+		"With `-convert`, office files are converted to Google Docs, Sheets and Slides by extension,",
+		"as set up in the [convert] section of the .driverc, and named without their extensions.",
+		fmt.Sprintf("With `-%s`, the sources keep being watched after they are pushed and the files", CLIOptionWatch),
+		"created, modified, renamed or deleted in them are pushed as they change, once",
+		fmt.Sprintf("left alone for `-%s`, until interrupted. Changes are found by scanning the", CLIOptionDebounce),
+		fmt.Sprintf("sources every `-%s`, %v by default, rather than through filesystem notifications e.g",
+			CLIOptionPollInterval, DefaultPushWatchInterval),
+		fmt.Sprintf("\n\t$ drive push -%s -%s 5s documents\n", CLIOptionWatch, CLIOptionDebounce),
+		modConflictNote,
+		rateLimitNote,
 		skipChecksumNote,
+		verifyNote,
+		symlinksNote,
+		preserveNote,
+		encryptionNote,
+		gzipNote,
+		"With `-ocr`, Drive converts pushed images and PDFs to searchable Docs by OCR, in the",
+		fmt.Sprintf("language set by `-%s`, others are uploaded as they are e.g", CLIOptionOCRLanguage),
+		fmt.Sprintf("\n\t$ drive push -ocr -%s fr scans\n", CLIOptionOCRLanguage),
+		fmt.Sprintf("With `-%s`, each pushed file is stamped with public properties that stat, dedupe", CLIOptionProperty),
+		"and -json listings show e.g",
+		fmt.Sprintf("\n\t$ drive push -%s source-host=$(hostname),checksum=md5 backups\n", CLIOptionProperty),
 	},
 	ListKey: []string{
 		DescList,
 		"List the information of a remote path not necessarily present locally",
 		"Allows printing of long options and by default does minimal printing",
+		starredNote,
+		queryFilterNote,
+	},
+	MountKey: []string{
+		DescMount,
+		"Mounts the remote folder, by default the one for the current directory, on",
+		"the local mountpoint with FUSE, Linux only, until interrupted or unmounted e.g",
+		"\n\t$ drive mount ~/mnt/drive",
+		"\t$ drive mount ~/mnt/marketing td:Marketing\n",
+		fmt.Sprintf("Folders are listed as they are first looked into, then every %v.", MountListingTTL),
+		"Files are downloaded as they are opened and changes to them are uploaded",
+		"when they are closed. Removed items are moved to the trash. Google Docs",
+		"show up empty and read only. Mounting needs root or fusermount.",
 	},
 	MoveKey: []string{
 		DescMove,
 		"Moves files/folders between folders",
+		"Sources can be shell patterns, quoted so that the shell leaves them be e.g",
+		"\n\t$ drive move 'Photos/2023-*' Archive\n",
+		fmt.Sprintf("With `-%s layout.tsv`, moves and renames existing files to match", CLIOptionLayout),
+		"a desired layout. Each line of the layout is a tab separated pair of",
+		"paths relative to the root of your drive:",
+		"\n\t<current path>\t<desired path>\n",
+		"Only the reparents and renames needed are planned and shown before being applied",
+		fmt.Sprintf("Sources can be filtered by their modification time with `-%s` and `-%s` e.g", CLIOptionOlderThan, CLIOptionNewerThan),
+		fmt.Sprintf("\n\t$ drive move -%s 1y reports/2013 reports/2014 Archive", CLIOptionOlderThan),
+		fmt.Sprintf("With `-%s`, the single source folder is watched as an inbox and items in it", CLIOptionWatch),
+		fmt.Sprintf("matching `-%s` are moved to dest as they arrive, until interrupted e.g", CLIOptionWatchRule),
+		fmt.Sprintf("\n\t$ drive move -%s -%s \"\\.pdf$\" Inbox Invoices", CLIOptionWatch, CLIOptionWatchRule),
+		fmt.Sprintf("With `-%s`, a folder moved into a destination that already has a folder", CLIOptionMerge),
+		"of the same name has its children moved into that folder and is then trashed.",
+		fmt.Sprintf("Clashing children are left behind and reported, unless `-%s` is set", ForceKey),
+		fmt.Sprintf("With `-%s N`, moves that failed are retried up to N times once the rest are done", CLIOptionRetryFailedAtEnd),
+		fmt.Sprintf("With `-%s`, folders left empty by the moves are trashed, as are their", CLIOptionPruneAfterMove),
+		"ancestors up to the first that isn't empty. The root and destinations are always kept",
+		fmt.Sprintf("The destination can be given by id, either prefixed with `%s` or with `-%s` e.g", DestIdPrefix, CLIOptionDestId),
+		fmt.Sprintf("\n\t$ drive move reports/q1.pdf %s0B7...\n", DestIdPrefix),
+		"which suits folders shared with you that have no path in your drive",
+		fmt.Sprintf("With `-%s`, items are moved in batches of up to %d, which for many items", CLIOptionBatchMoves, MaxBatchSize),
+		fmt.Sprintf("takes far fewer requests. Folders merged with `-%s` are trashed once the batches show them emptied", CLIOptionMerge),
+		fmt.Sprintf("With `-%s`, the moves are planned first and the API calls that they", CLIOptionEstimateCost),
+		"will make are estimated, broken down by type, to be confirmed before moving",
+		treeDiffNote,
+		planNote,
+		dryRunNote,
+	},
+	PromoteKey: []string{
+		DescPromote, "Accepts multiple folders",
+		fmt.Sprintf("Items are moved `-%s` levels up e.g promoting the items in a/b/c", CLIOptionLevels),
+		"by one level moves them into a/b, leaving a/b/c in place",
+		fmt.Sprintf("Name clashes are resolved as per `-%s`", CLIOptionConflictPolicy),
+		planNote,
 	},
 	PubKey: []string{
 		DescPublish, "Accepts multiple paths",
+		fmt.Sprintf("Anyone is granted `-%s`, reader by default, on the web or only with the link if", RoleKey),
+		fmt.Sprintf("`-%s` is set. Drive only lets the access of users and groups expire, so links", CLIOptionWithLink),
+		fmt.Sprintf("published with `-%s` are tagged and revoked by `%s -%s` once their time is up e.g", CLIOptionPubExpires, UnpubKey, CLIOptionPubExpired),
+		fmt.Sprintf("\n\t$ drive %s -%s -%s commenter -%s 7d drafts/plan.doc\n", PubKey, CLIOptionWithLink, RoleKey, CLIOptionPubExpires),
 	},
 	RenameKey: []string{
 		DescRename, "Accepts <src> <newName>",
+		fmt.Sprintf("With `-%s names.tsv [folder]`, renames the items in folder listed in", CLIOptionNameMap),
+		"names.tsv, one tab separated <current name> <new name> pair per line.",
+		fmt.Sprintf("With `-%s` the first column is the id of each item instead.", CLIOptionId),
+		"Nothing is renamed unless every line matches exactly one item",
+		fmt.Sprintf("With `-%s [folder...]`, items whose names only differ by case e.g File.txt and", CLIOptionCaseFold),
+		"file.txt, which collide on case-insensitive filesystems, are resolved. The most recently",
+		"modified keeps its name and the others are numbered apart e.g \"file (1).txt\"",
+		fmt.Sprintf("or trashed with `-%s`. Add `-r` to go through all descendant folders", CLIOptionCaseFoldTrash),
+		fmt.Sprintf("With `-%s <layout> -%s <layout> [folder...]`, dates in names are rewritten", CLIOptionReformatDate, CLIOptionReformatDateTo),
+		"from one Go time layout to the other, names without such dates are left as they are e.g",
+		fmt.Sprintf("\n\t$ drive rename -%s 01-02-2006 -%s 2006-01-02 -r Scans", CLIOptionReformatDate, CLIOptionReformatDateTo),
+		fmt.Sprintf("With `-%s s/regexp/replacement/[flags] <path...>`, the names of the items at the", CLIOptionRenameRegex),
+		"paths, which may be shell patterns, are rewritten with the substitution. The flags are",
+		"g to replace every match and i to ignore case, groups are referred to as \\1 e.g",
+		fmt.Sprintf("\n\t$ drive rename -%s 's/IMG_([0-9]+)/Photo_\\1/' 'Photos/*'", CLIOptionRenameRegex),
+		planNote,
+		dryRunNote,
+	},
+	QuotaKey: []string{
+		DescQuota,
+		"Reports the bytes used, free and in the trash, and how much of the space used",
+		"is taken by each of Drive, Gmail and Photos, which share the quota. Then the",
+		fmt.Sprintf("largest files owned are listed, %d of them unless set by `-%s` e.g", DefaultQuotaLargest, CLIOptionQuotaLargest),
+		fmt.Sprintf("\n\t$ drive %s -%s 25\n", QuotaKey, CLIOptionQuotaLargest),
+		fmt.Sprintf("Google-native documents are left out unless sized with `-%s`", CLIOptionNativeSizePolicy),
+	},
+	ReapKey: []string{
+		DescReap,
+		fmt.Sprintf("Sources of copies made with `-%s` are trashed once their grace period is over,", CLIOptionTrashSourceAfter),
+		"those still within it are listed along with when they are due.",
+		fmt.Sprintf("Items are listed for confirmation first, unless `-%s` is set", ForceKey),
+	},
+	UndoKey: []string{
+		DescUndo,
+		"Every change made to your drive is journaled along with how to undo it.",
+		"Undo reverts the changes of the last command run that is yet to be undone,",
+		"in reverse order, so running it again goes further back. Changes that can't",
+		"be undone e.g permanent deletions are listed and left be.",
+		fmt.Sprintf("Changes are listed for confirmation first, unless `-%s` is set", ForceKey),
+	},
+	RevisionsKey: []string{
+		DescRevisions,
+		"Lists the revisions of each file from the oldest to the newest, marking",
+		"with a * the pinned ones, which are kept forever. Drive otherwise purges",
+		"revisions of binary files after 30 days or past 100 of them e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s 0B4f,0B4g report.pdf\n", RevisionsKey, CLIOptionRevisionsPin),
+		fmt.Sprintf("With `-%s`, revisions that are neither pinned nor among the newest `-%s`", CLIOptionRevisionsPrune, CLIOptionRevisionsKeep),
+		fmt.Sprintf("are deleted, only those last modified before `-%s` if set e.g", CLIOptionOlderThan),
+		fmt.Sprintf("\n\t$ drive %s -%s -%s 3 -%s 6mo report.pdf\n", RevisionsKey, CLIOptionRevisionsPrune, CLIOptionRevisionsKeep, CLIOptionOlderThan),
+		"The newest revision is the file's content and is never deleted.",
+		fmt.Sprintf("Revisions are listed for confirmation before deletion, unless `-%s` is set", ForceKey),
+	},
+	SyncKey: []string{
+		DescSync,
+		"The first sync of a folder pulls it in full and records how far the",
+		"changes of your drive go. Later syncs fetch only the changes made since:",
+		"items renamed or moved remotely are renamed locally, trashed or deleted",
+		"ones are removed and new or updated content is downloaded.",
+		fmt.Sprintf("Sync state is kept in .gd/%s, or .gd/<profile>.%s with `-%s`", SyncStateSuffix, SyncStateSuffix, ProfileKey),
 	},
-	QuotaKey: []string{DescQuota},
 	ShareKey: []string{
 		DescShare, "Accepts multiple paths",
 		"Specify the emails to share with as well as the message to send them on notification",
 		"Accepted values for:\n+ accountType: ",
 		DescAccountTypes, "\n+ roles:", DescRoles,
+		fmt.Sprintf("With `-%s`, who has access to the items is reported instead, for auditing", CLIOptionShareList),
+		"oversharing: the role, account type and email or domain of each permission and",
+		"whether it is inherited from the folder the item is in or granted on it directly e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s -r -%s %s Projects > access.csv\n", ShareKey, CLIOptionShareList, CLIOptionShareFormat, PermissionsFormatCSV),
+		shareTreeNote,
+	},
+	SearchKey: []string{
+		DescSearch,
+		"Lists the paths of the items whose content or metadata contain the terms, as found",
+		"by Drive's full text search, most relevant first, or most recently modified first",
+		fmt.Sprintf("with `-%s %s`. Drive doesn't return snippets of the matched content, so", SortKey, SearchByModTime),
+		fmt.Sprintf("snippets are only shown of descriptions that contain the terms. With `-%s`, a line", JSONKey),
+		"of JSON is written per result instead, along with its rank by relevance e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s %s quarterly budget", SearchKey, SortKey, SearchByModTime),
+	},
+	DuKey: []string{
+		DescDu,
+		"Walks the remote trees and adds up the bytes of the files under each folder,",
+		fmt.Sprintf("listing the folders down to `-%s` levels below the sources, largest first, and", CLIOptionDuDepth),
+		"then the totals. Google-native documents don't count against the quota and report",
+		"no size, so they are counted separately. Items with many parents count once.",
+		fmt.Sprintf("Documents add to the bytes only if sized with `-%s`, see `drive %s`", CLIOptionNativeSizePolicy, CopyKey),
+		fmt.Sprintf("With `-%s %s`, the report is written to stdout for capacity planning e.g", CLIOptionDuFormat, DuFormatCSV),
+		fmt.Sprintf("\n\t$ drive %s -%s 2 -%s %s Projects > usage.csv\n", DuKey, CLIOptionDuDepth, CLIOptionDuFormat, DuFormatCSV),
+	},
+	UrlKey: []string{
+		DescUrl,
+		fmt.Sprintf("Prints the links to each item, by default the %s link that opens it in its", LinkView),
+		fmt.Sprintf("Google editor or viewer. `-%s` picks the links among %s, which downloads", CLIOptionLink, LinkDownload),
+		fmt.Sprintf("the content of a file, %s, for embedding the item in a web page, and %s.", LinkEmbed, LinkAll),
+		"Folders and Google-native documents have no download link. With `-json`, a line of",
+		"JSON is written per item instead e.g",
+		fmt.Sprintf("\n\t$ drive -json %s -%s %s report.pdf slides\n", UrlKey, CLIOptionLink, LinkAll),
+	},
+	OpenKey: []string{
+		DescOpen,
+		fmt.Sprintf("Opens the items in the default browser by their %s link, or the one set by `-%s`,", LinkView, CLIOptionLink),
+		"and in the local file manager. Items can be picked by id e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s -%s %s 0Bz9Z...\n", OpenKey, CLIOptionId, CLIOptionLink, LinkDownload),
+	},
+	ShortcutKey: []string{
+		DescShortcut,
+		"Creates a shortcut to each target in dest if it is a folder, named after",
+		"the target, otherwise the only shortcut is created at dest e.g",
+		fmt.Sprintf("\n\t$ drive %s reports/2015.pdf Desktop\n", ShortcutKey),
+		fmt.Sprintf("Shortcuts are opaque files unless followed with `-%s`, which", CLIOptionFollowShortcuts),
+		"pull, list and copy accept; a shortcut back to a folder that it is in isn't",
+		"followed and the cycle is reported, so that shortcuts to ancestors don't loop forever.",
+	},
+	PropKey: []string{
+		DescProp,
+		fmt.Sprintf("\t* %s: `drive %s %s path key=value [key=value...]`", PropActionSet, PropKey, PropActionSet),
+		fmt.Sprintf("\t* %s: `drive %s %s path [key...]`, all properties if no key is given", PropActionGet, PropKey, PropActionGet),
+		fmt.Sprintf("\t* %s: `drive %s %s path key [key...]`", PropActionDel, PropKey, PropActionDel),
+		"Properties are public, that is visible to other apps, and their values are shown",
+		fmt.Sprintf("by stat and dedupe. With `-%s`, the properties private to drive are acted on", CLIOptionPrivate),
+		"instead, such as those that copy and move record.",
+	},
+	StarKey: []string{
+		DescStar, "Accepts multiple paths",
+		starredNote,
 	},
 	StatKey: []string{
 		DescStat, "provides detailed information about a remote file",
@@ -251,19 +979,34 @@ var docMap = map[string][]string{
 	},
 	TrashKey: []string{
 		DescTrash, "Sends a list of remote files to trash",
+		"Paths can be shell patterns e.g \"Scans/*.tmp\"",
+		queryNote,
+		fmt.Sprintf("With `-%s`, the trashed items are listed instead, most recently trashed first,", CLIOptionTrashList),
+		"with the paths they were trashed from and when they were trashed.",
 	},
 	UnshareKey: []string{
 		DescUnshare, "Accepts multiple paths",
 		"Accepted values for accountTypes::", DescAccountTypes,
+		fmt.Sprintf("Only the access that matches all of `-%s`, `-%s`, `-%s` and `-%s`, those set,", TypeKey, RoleKey, EmailsKey, CLIOptionWithLink),
+		"is revoked; owners keep theirs.",
+		shareTreeNote,
+	},
+	UnstarKey: []string{
+		DescUnstar, "Accepts multiple paths",
 	},
 	UntrashKey: []string{
 		DescUntrash, "takes remote files out of the trash",
 		"Note: untrash is a relative path command so any resolutions are made",
 		"relative to the current working directory i.e",
 		"\n\t$ drive trash mnt/logos",
+		queryNote,
 	},
 	UnpubKey: []string{
 		DescUnpublish, "revokes public access to a list of remote files",
+		"With `-r`, the links to the descendants of folders are revoked too, in bulk.",
+		fmt.Sprintf("With `-%s`, the links published to expire whose time is up are revoked instead,", CLIOptionPubExpired),
+		"wherever they are, which suits a cron job e.g",
+		fmt.Sprintf("\n\t$ drive %s -%s\n", UnpubKey, CLIOptionPubExpired),
 	},
 	VersionKey: []string{
 		DescVersion, fmt.Sprintf("current version is: %s", Version),
@@ -15,12 +15,22 @@
 package drive
 
 import (
+	"fmt"
 	"os"
 
 	"golang.org/x/net/context"
 )
 
 func (g *Commands) Init() error {
+	if g.opts != nil && g.opts.ServiceAccountKeyPath != "" {
+		return g.initServiceAccount(g.opts.ServiceAccountKeyPath, g.opts.ServiceAccountSubject)
+	}
+	if g.opts != nil && g.opts.ServiceAccountSubject != "" {
+		return fmt.Errorf("init: `-%s` is only for use with `-%s`", CLIOptionServiceAccountSubject, CLIOptionServiceAccount)
+	}
+
+	g.context.ServiceAccountKey = nil
+	g.context.ServiceAccountSubject = ""
 	g.context.ClientId = os.Getenv(GoogleApiClientIdEnvKey)
 	g.context.ClientSecret = os.Getenv(GoogleApiClientSecretEnvKey)
 	if g.context.ClientId == "" || g.context.ClientSecret == "" {
@@ -28,8 +38,13 @@ func (g *Commands) Init() error {
 		g.context.ClientSecret = "RHjKdah8RrHFwu6fcc0uEVCw"
 	}
 
-	ctx := context.Background()
-	refreshToken, err := RetrieveRefreshToken(ctx, g.context)
+	var refreshToken string
+	var err error
+	if g.opts != nil && g.opts.DeviceAuth {
+		refreshToken, err = RetrieveRefreshTokenByDevice(g.context)
+	} else {
+		refreshToken, err = RetrieveRefreshToken(context.Background(), g.context)
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,433 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	JournalSuffix = "journal"
+
+	// The ops, besides those of plans, that undo journaled ops
+	JournalOpUntrash          = "untrash"
+	JournalOpUnstar           = "unstar"
+	JournalOpDeleteProperty   = "delete-property"
+	JournalOpDeletePermission = "delete-permission"
+	// JournalOpUndo marks the transaction in its args as undone
+	JournalOpUndo = "undo"
+)
+
+// journalEntry is a single line of the journal, a mutation made.
+type journalEntry struct {
+	Txn  string    `json:"txn"`
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	Args []string  `json:"args,omitempty"`
+	// Inverse is the op followed by its args that undoes Op. It
+	// is empty if Op can't be undone e.g a permanent deletion.
+	Inverse []string `json:"inverse,omitempty"`
+}
+
+// journal appends an entry, as a line of JSON, for every mutation made.
+// The mutations made by a single run of a command make up a transaction.
+type journal struct {
+	sync.Mutex
+	path string
+	txn  string
+	log  *log.Logger
+	// f is only opened once the first mutation is made
+	f   *os.File
+	enc *json.Encoder
+}
+
+func newJournal(p string, logger *log.Logger) *journal {
+	return &journal{
+		path: p,
+		txn:  time.Now().UTC().Format("20060102T150405.000000000"),
+		log:  logger,
+	}
+}
+
+// journalPath is where the journal of the account of the context is kept,
+// each profile journaling apart as its ops are undone on its account e.g
+// .gd/work.journal.
+func (g *Commands) journalPath() string {
+	name := JournalSuffix
+	if g.context.Profile != "" {
+		name = fmt.Sprintf("%s.%s", g.context.Profile, JournalSuffix)
+	}
+	return path.Join(g.context.AbsPathOf(""), config.GDDirSuffix, name)
+}
+
+// record journals op made with args, along with the inverse op if any.
+// Failing to journal doesn't fail the op, which has already been made.
+func (j *journal) record(op string, args []string, inverse ...string) {
+	if j == nil {
+		return
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if j.f == nil {
+		f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, config.O_RWForAll)
+		if err != nil {
+			j.log.LogErrf("journal: %v\n", err)
+			return
+		}
+		j.f, j.enc = f, json.NewEncoder(f)
+	}
+
+	entry := journalEntry{
+		Txn:     j.txn,
+		Time:    time.Now().UTC(),
+		Op:      op,
+		Args:    args,
+		Inverse: inverse,
+	}
+	if err := j.enc.Encode(&entry); err != nil {
+		j.log.LogErrf("journal: %s %v: %v\n", op, args, err)
+	}
+}
+
+// journaledMutator makes mutations via mutator, journaling each made.
+// Where the inverse of a mutation needs to know what the file was like,
+// the file is looked up before the mutation is made.
+type journaledMutator struct {
+	mutator
+	rem *Remote
+	j   *journal
+}
+
+func (jm *journaledMutator) UpsertByComparison(args *upsertOpt) (*File, error) {
+	f, err := jm.mutator.UpsertByComparison(args)
+	if err != nil || f == nil {
+		return f, err
+	}
+	if args.dest == nil {
+		jm.j.record("create", []string{f.Id, args.parentId, f.Name}, PlanOpTrash, f.Id)
+	} else {
+		jm.j.record("update", []string{f.Id})
+	}
+	return f, err
+}
+
+func (jm *journaledMutator) copy(newName, parentId string, srcFile *File) (*File, error) {
+	copied, err := jm.mutator.copy(newName, parentId, srcFile)
+	if err == nil {
+		jm.j.record(PlanOpCopy, []string{copied.Id, srcFile.Id, parentId, newName}, PlanOpTrash, copied.Id)
+	}
+	return copied, err
+}
+
+func (jm *journaledMutator) reupload(newName, parentId string, srcFile *File, exportURL string, convert bool) (*File, error) {
+	created, err := jm.mutator.reupload(newName, parentId, srcFile, exportURL, convert)
+	if err == nil {
+		jm.j.record(PlanOpReupload, []string{created.Id, srcFile.Id, parentId, newName}, PlanOpTrash, created.Id)
+	}
+	return created, err
+}
+
+func (jm *journaledMutator) insertContent(body io.Reader, newName, parentId string, srcFile *File, convert bool) (*File, error) {
+	created, err := jm.mutator.insertContent(body, newName, parentId, srcFile, convert)
+	if err == nil {
+		jm.j.record(PlanOpReupload, []string{created.Id, srcFile.Id, parentId, newName}, PlanOpTrash, created.Id)
+	}
+	return created, err
+}
+
+func (jm *journaledMutator) insertParent(fileId, parentId string) error {
+	err := jm.mutator.insertParent(fileId, parentId)
+	if err == nil {
+		jm.j.record(PlanOpInsertParent, []string{fileId, parentId}, PlanOpRemoveParent, fileId, parentId)
+	}
+	return err
+}
+
+func (jm *journaledMutator) removeParent(fileId, parentId string) error {
+	err := jm.mutator.removeParent(fileId, parentId)
+	if err == nil {
+		jm.j.record(PlanOpRemoveParent, []string{fileId, parentId}, PlanOpInsertParent, fileId, parentId)
+	}
+	return err
+}
+
+func (jm *journaledMutator) reparent(fileId, fromParentId, toParentId string) error {
+	err := jm.mutator.reparent(fileId, fromParentId, toParentId)
+	if err == nil {
+		jm.j.record(PlanOpReparent, []string{fileId, fromParentId, toParentId}, PlanOpReparent, fileId, toParentId, fromParentId)
+	}
+	return err
+}
+
+func (jm *journaledMutator) rename(fileId, newTitle string) (*File, error) {
+	prev, prevErr := jm.rem.FindById(fileId)
+	renamed, err := jm.mutator.rename(fileId, newTitle)
+	if err != nil {
+		return renamed, err
+	}
+	if prevErr != nil {
+		jm.j.record(PlanOpRename, []string{fileId, newTitle})
+	} else {
+		jm.j.record(PlanOpRename, []string{fileId, newTitle}, PlanOpRename, fileId, prev.Name)
+	}
+	return renamed, err
+}
+
+func (jm *journaledMutator) setAppProperty(fileId, key, value string) error {
+	prevValue, prevErr := jm.rem.appProperty(fileId, key)
+	err := jm.mutator.setAppProperty(fileId, key, value)
+	if err != nil {
+		return err
+	}
+	if prevErr != nil {
+		jm.j.record(PlanOpSetProperty, []string{fileId, key, value}, JournalOpDeleteProperty, fileId, key)
+	} else {
+		jm.j.record(PlanOpSetProperty, []string{fileId, key, value}, PlanOpSetProperty, fileId, key, prevValue)
+	}
+	return err
+}
+
+func (jm *journaledMutator) insertPermissions(permInfo *permission) (*drive.Permission, error) {
+	perm, err := jm.mutator.insertPermissions(permInfo)
+	if err == nil {
+		args := []string{permInfo.fileId, permInfo.value, permInfo.role.String()}
+		jm.j.record(PlanOpShare, args, JournalOpDeletePermission, permInfo.fileId, perm.Id)
+	}
+	return perm, err
+}
+
+func (jm *journaledMutator) deletePermission(fileId, permissionId string) error {
+	err := jm.mutator.deletePermission(fileId, permissionId)
+	if err == nil {
+		jm.j.record(PlanOpUnshare, []string{fileId, permissionId})
+	}
+	return err
+}
+
+func (jm *journaledMutator) restrict(fileId string, downloadRestricted, writersCanShare bool) error {
+	prev, prevErr := jm.rem.FindById(fileId)
+	err := jm.mutator.restrict(fileId, downloadRestricted, writersCanShare)
+	if err != nil {
+		return err
+	}
+	args := []string{fileId, strconv.FormatBool(downloadRestricted), strconv.FormatBool(writersCanShare)}
+	if prevErr != nil || prev.Labels == nil {
+		jm.j.record(PlanOpRestrict, args)
+	} else {
+		jm.j.record(PlanOpRestrict, args, PlanOpRestrict, fileId,
+			strconv.FormatBool(prev.Labels.Restricted), strconv.FormatBool(prev.WritersCanShare))
+	}
+	return err
+}
+
+func (jm *journaledMutator) star(fileId string) error {
+	prev, prevErr := jm.rem.FindById(fileId)
+	err := jm.mutator.star(fileId)
+	if err != nil {
+		return err
+	}
+	if prevErr != nil || (prev.Labels != nil && prev.Labels.Starred) {
+		// Nothing to undo for what was starred already
+		jm.j.record(PlanOpStar, []string{fileId})
+	} else {
+		jm.j.record(PlanOpStar, []string{fileId}, JournalOpUnstar, fileId)
+	}
+	return err
+}
+
+func (jm *journaledMutator) describe(fileId, description string) error {
+	prev, prevErr := jm.rem.FindById(fileId)
+	err := jm.mutator.describe(fileId, description)
+	if err != nil {
+		return err
+	}
+	if prevErr != nil {
+		jm.j.record(PlanOpDescribe, []string{fileId, description})
+	} else {
+		jm.j.record(PlanOpDescribe, []string{fileId, description}, PlanOpDescribe, fileId, prev.Description)
+	}
+	return err
+}
+
+func (jm *journaledMutator) Touch(id string) (*File, error) {
+	f, err := jm.mutator.Touch(id)
+	if err == nil {
+		jm.j.record(PlanOpTouch, []string{id})
+	}
+	return f, err
+}
+
+func (jm *journaledMutator) Trash(id string) error {
+	err := jm.mutator.Trash(id)
+	if err == nil {
+		jm.j.record(PlanOpTrash, []string{id}, JournalOpUntrash, id)
+	}
+	return err
+}
+
+func readJournal(p string) ([]*journalEntry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*journalEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		entry := &journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", p, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Undo undoes the last transaction in the journal that is yet to be undone,
+// applying the inverses of its mutations in reverse order. Mutations that
+// can't be undone, e.g permanent deletions, are listed and left be.
+func (g *Commands) Undo() error {
+	journalPath := g.journalPath()
+	entries, err := readJournal(journalPath)
+	if os.IsNotExist(err) {
+		g.log.Logln("Nothing to undo")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("undo: %v", err)
+	}
+
+	undone := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Op == JournalOpUndo && len(entry.Args) >= 1 {
+			undone[entry.Args[0]] = true
+		}
+	}
+
+	var txn string
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entry := entries[i]; entry.Op != JournalOpUndo && !undone[entry.Txn] {
+			txn = entry.Txn
+			break
+		}
+	}
+	if txn == "" {
+		g.log.Logln("Nothing to undo")
+		return nil
+	}
+
+	var inverses [][]string
+	irreversible := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Txn != txn || entry.Op == JournalOpUndo {
+			continue
+		}
+		if len(entry.Inverse) < 1 {
+			g.log.Logf("cannot undo %s %s\n", entry.Op, strings.Join(entry.Args, " "))
+			irreversible += 1
+			continue
+		}
+		inverses = append(inverses, entry.Inverse)
+	}
+
+	g.log.Logf("Undoing transaction %s:\n", txn)
+	for _, inverse := range inverses {
+		g.log.Logf("  %s\n", strings.Join(inverse, " "))
+	}
+	if irreversible >= 1 {
+		g.log.Logf("%d operations cannot be undone\n", irreversible)
+	}
+
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			return fmt.Errorf("undo: noPrompt is set, use `%s` to make the %d operations above", ForceKey, len(inverses))
+		}
+		if !promptForChanges() {
+			return nil
+		}
+	}
+
+	var composedError error = nil
+	for _, inverse := range inverses {
+		if err := g.applyInverse(inverse); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("undo: %s: %v", strings.Join(inverse, " "), err))
+		}
+	}
+
+	// A partly undone transaction is left to be undone again
+	if composedError != nil {
+		return composedError
+	}
+	newJournal(journalPath, g.log).record(JournalOpUndo, []string{txn})
+	return nil
+}
+
+func (g *Commands) applyInverse(inverse []string) error {
+	op, args := inverse[0], inverse[1:]
+	switch op {
+	case PlanOpInsertParent:
+		return g.rem.insertParent(args[0], args[1])
+	case PlanOpRemoveParent:
+		return g.rem.removeParent(args[0], args[1])
+	case PlanOpReparent:
+		return g.rem.reparent(args[0], args[1], args[2])
+	case PlanOpRename:
+		_, err := g.rem.rename(args[0], args[1])
+		return err
+	case PlanOpTrash:
+		return g.rem.Trash(args[0])
+	case JournalOpUntrash:
+		return g.rem.Untrash(args[0])
+	case JournalOpUnstar:
+		return g.rem.unstar(args[0])
+	case PlanOpSetProperty:
+		return g.rem.setAppProperty(args[0], args[1], args[2])
+	case JournalOpDeleteProperty:
+		return g.rem.deleteAppProperty(args[0], args[1])
+	case JournalOpDeletePermission:
+		return g.rem.deletePermission(args[0], args[1])
+	case PlanOpDescribe:
+		return g.rem.patchFields(args[0], map[string]interface{}{"description": args[1]})
+	case PlanOpRestrict:
+		restricted, err := strconv.ParseBool(args[1])
+		if err != nil {
+			return err
+		}
+		writersCanShare, err := strconv.ParseBool(args[2])
+		if err != nil {
+			return err
+		}
+		return g.rem.restrict(args[0], restricted, writersCanShare)
+	}
+	return fmt.Errorf("unknown op %q", op)
+}
@@ -47,6 +47,7 @@ type traversalSt struct {
 	explicitNoPrompt bool
 	sorters          []string
 	matchQuery       *matchQuery
+	branch           *shortcutBranch
 }
 
 func sorters(opts *Options) (sortKeys []string) {
@@ -350,7 +351,7 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 
 	f := travSt.file
 	if !f.IsDir {
-		f.pretty(g.log, opt)
+		g.printFile(f, opt)
 		return true
 	}
 
@@ -359,6 +360,12 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		opt.parent = sepJoin("/", opt.parent, f.Name)
 	}
 
+	if cycle := travSt.branch.cycle(f); cycle != "" {
+		g.log.LogErrf("%s: not following the shortcut back around %s\n", opt.parent, cycle)
+		return true
+	}
+	travSt.branch = travSt.branch.descend(f)
+
 	// A depth of < 0 means traverse as deep as you can
 	if travSt.depth == 0 {
 		// At the end of the line, this was successful.
@@ -386,6 +393,9 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 	}
 
 	fileChan := reqDoPage(req, g.opts.Hidden, canPrompt)
+	if !travSt.inTrash {
+		fileChan = g.rem.followShortcuts(fileChan)
+	}
 
 	spin.play()
 
@@ -403,6 +413,9 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		if isHidden(file.Name, g.opts.Hidden) {
 			continue
 		}
+		if !travSt.inTrash && g.opts.Ignores.Match(sepJoin("/", opt.parent, file.Name), file.IsDir) {
+			continue
+		}
 
 		collector = append(collector, file)
 	}
@@ -424,7 +437,7 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		if onlyFiles && file.IsDir {
 			continue
 		}
-		file.pretty(g.log, opt)
+		g.printFile(file, opt)
 		iterCount += 1
 	}
 
@@ -439,6 +452,7 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 				explicitNoPrompt: travSt.explicitNoPrompt,
 				sorters:          travSt.sorters,
 				matchQuery:       travSt.matchQuery,
+				branch:           travSt.branch,
 			}
 
 			if !g.breadthFirst(childSt, spin) {
@@ -40,6 +40,9 @@ const (
 
 const (
 	MaxFailedRetryCount = uint32(20) // Arbitrary value
+	// MaxRateLimitedRetryCount is the most times that a rate limited copy is
+	// retried, backing off exponentially for up to about 2^7 seconds between
+	MaxRateLimitedRetryCount = uint32(7)
 )
 
 var DefaultMaxProcs = runtime.NumCPU()
@@ -111,6 +114,40 @@ func retryableErrorCheck(v interface{}) (ok, retryable bool) {
 	return
 }
 
+// rateLimitedErrorCheck is like retryableErrorCheck but of the 4XX errors
+// only retries rate limiting i.e 429s and 403s for exceeded rate limits,
+// as other 403s e.g for lacking permissions won't pass on retrying.
+func rateLimitedErrorCheck(v interface{}) (ok, retryable bool) {
+	pr, pOk := v.(*tuple)
+	if pr == nil || !pOk {
+		retryable = true
+		return
+	}
+
+	if pr.last == nil {
+		ok = true
+		return
+	}
+
+	err, assertOk := pr.last.(*googleapi.Error)
+	if !assertOk {
+		retryable = true
+		return
+	}
+
+	switch {
+	case err.Code >= 500 && err.Code <= 599, err.Code == 429:
+		retryable = true
+	case err.Code == 403:
+		for _, item := range err.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				retryable = true
+			}
+		}
+	}
+	return
+}
+
 func noopPlayable() *playable {
 	return &playable{
 		play:  noop,
@@ -132,6 +169,99 @@ func parseTimeAndRound(ts string) (t time.Time) {
 	return parseTime(ts, true)
 }
 
+var ageRegexp = regexp.MustCompile("^(\\d+)(y|mo|w|d)$")
+
+// parseCutoff parses spec either as an age relative to now e.g "1y", "6mo",
+// "2w", "30d", "36h" or as a timestamp e.g "2015-06-30", returning the time it refers to.
+func parseCutoff(spec string, now time.Time) (time.Time, error) {
+	spec = strings.TrimSpace(spec)
+
+	if matches := ageRegexp.FindStringSubmatch(spec); len(matches) == 3 {
+		n, _ := strconv.Atoi(matches[1])
+		switch matches[2] {
+		case "y":
+			return now.AddDate(-n, 0, 0), nil
+		case "mo":
+			return now.AddDate(0, -n, 0), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		default:
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(spec); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, spec); err == nil {
+			return t, nil
+		}
+	}
+	return now, fmt.Errorf("%q is neither an age e.g 30d, 1y nor a timestamp e.g 2015-06-30", spec)
+}
+
+// timeWindow bounds modification times. Zero bounds are open.
+type timeWindow struct {
+	olderThan time.Time
+	newerThan time.Time
+}
+
+func newTimeWindow(olderThan, newerThan string) (*timeWindow, error) {
+	if olderThan == "" && newerThan == "" {
+		return nil, nil
+	}
+
+	now := time.Now()
+	tw := &timeWindow{}
+	var err error
+	if olderThan != "" {
+		if tw.olderThan, err = parseCutoff(olderThan, now); err != nil {
+			return nil, fmt.Errorf("older-than: %v", err)
+		}
+	}
+	if newerThan != "" {
+		if tw.newerThan, err = parseCutoff(newerThan, now); err != nil {
+			return nil, fmt.Errorf("newer-than: %v", err)
+		}
+	}
+	return tw, nil
+}
+
+func (tw *timeWindow) contains(t time.Time) bool {
+	if tw == nil {
+		return true
+	}
+	if !tw.olderThan.IsZero() && !t.Before(tw.olderThan) {
+		return false
+	}
+	if !tw.newerThan.IsZero() && !t.After(tw.newerThan) {
+		return false
+	}
+	return true
+}
+
+var byteSizeRegexp = regexp.MustCompile("^(\\d+(?:\\.\\d+)?)\\s*([kmgtp]?)i?b?$")
+
+// ParseByteSize parses sizes like "500", "700KB", "1.5G" or "4GiB", with
+// the same 1024 based units that sizes are printed with, into bytes.
+func ParseByteSize(spec string) (int64, error) {
+	matches := byteSizeRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(spec)))
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("%q is not a size e.g 512KB, 100MB, 4GB", spec)
+	}
+
+	size, _ := strconv.ParseFloat(matches[1], 64)
+	if matches[2] != "" {
+		exponent := strings.Index("kmgtp", matches[2]) + 1
+		for i := 0; i < exponent; i++ {
+			size *= BytesPerKB
+		}
+	}
+	return int64(size), nil
+}
+
 func internalIgnores() (ignores []string) {
 	if runtime.GOOS == OSLinuxKey {
 		ignores = append(ignores, "\\.\\s*desktop$")
@@ -384,6 +514,34 @@ func readCommentedFile(p, comment string) (clauses []string, err error) {
 	return readFile_(p, ignorer)
 }
 
+// mapping is a from->to pair as read from a two column file.
+type mapping struct {
+	from string
+	to   string
+}
+
+// readMappingsFile reads the tab separated from->to pairs of a
+// commented file e.g a two column sheet exported from a spreadsheet.
+func readMappingsFile(p, comment string) (mappings []*mapping, err error) {
+	lines, err := readCommentedFile(p, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		columns := strings.SplitN(line, "\t", 2)
+		if len(columns) < 2 {
+			return nil, fmt.Errorf("%s: line %d: expecting two tab separated columns, got %q", p, i+1, line)
+		}
+		from, to := strings.TrimSpace(columns[0]), strings.TrimSpace(columns[1])
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("%s: line %d: empty column in %q", p, i+1, line)
+		}
+		mappings = append(mappings, &mapping{from: from, to: to})
+	}
+	return mappings, nil
+}
+
 func chunkInt64(v int64) chan int {
 	var maxInt int
 	maxInt = 1<<31 - 1
@@ -0,0 +1,568 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/odeke-em/drive/fuse"
+)
+
+// MountListingTTL is how long the listing of a mounted folder is kept
+// before it is listed again to pick up changes made elsewhere.
+const MountListingTTL = 30 * time.Second
+
+// mountFS serves the remote tree under a folder as a filesystem. Folders are
+// listed when they are first looked into and content is downloaded when a
+// file is first opened, into a local cache that writes go to. Writes are
+// uploaded when the file is flushed or closed.
+type mountFS struct {
+	g *Commands
+	// cacheDir holds the content of the open files
+	cacheDir string
+
+	mu sync.Mutex
+	// nodes are the folders and files by id, so that each
+	// item is the same node wherever it is reached from
+	nodes map[string]fuse.Node
+}
+
+type mountDir struct {
+	fs *mountFS
+	sync.Mutex
+	file *File
+	path string
+	// children are the items in the folder by name, nil until listed
+	children map[string]*File
+	listedAt time.Time
+}
+
+type mountFile struct {
+	fs *mountFS
+	sync.Mutex
+	file     *File
+	parentId string
+	path     string
+	// cache holds the content while the file is open, nil otherwise
+	cache *os.File
+	// dirty is set once the cached content is changed until it is uploaded
+	dirty bool
+	opens int
+}
+
+type mountHandle struct {
+	f *mountFile
+}
+
+// node returns the node for f, reached at p in the folder with parentId.
+// Files that are known already are updated with f unless they are open.
+func (mfs *mountFS) node(f *File, parentId, p string) fuse.Node {
+	mfs.mu.Lock()
+	n, known := mfs.nodes[f.Id]
+	if !known {
+		if f.IsDir {
+			n = &mountDir{fs: mfs, file: f, path: p}
+		} else {
+			n = &mountFile{fs: mfs, file: f, parentId: parentId, path: p}
+		}
+		mfs.nodes[f.Id] = n
+	}
+	mfs.mu.Unlock()
+
+	// Nodes are locked after the lock on them all is let go, as folders
+	// are locked while their children are looked up
+	if mf, ok := n.(*mountFile); ok && known {
+		mf.Lock()
+		if mf.cache == nil {
+			mf.file = f
+		}
+		mf.Unlock()
+	}
+	return n
+}
+
+func (mfs *mountFS) forget(id string) {
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+	delete(mfs.nodes, id)
+}
+
+// moved updates the node of the item with id, if there is one, to be
+// called name at p in the folder with parentId.
+func (mfs *mountFS) moved(id, name, parentId, p string) {
+	mfs.mu.Lock()
+	n, ok := mfs.nodes[id]
+	mfs.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	switch n := n.(type) {
+	case *mountDir:
+		n.Lock()
+		n.file = renamedFile(n.file, name)
+		n.path = p
+		n.Unlock()
+	case *mountFile:
+		n.Lock()
+		n.file = renamedFile(n.file, name)
+		n.parentId, n.path = parentId, p
+		n.Unlock()
+	}
+}
+
+func renamedFile(f *File, name string) *File {
+	renamed := DupFile(f)
+	renamed.Name = name
+	return renamed
+}
+
+// mountErr logs err, as what went wrong is otherwise lost
+// in translation to an errno, and returns EIO.
+func (mfs *mountFS) mountErr(op, p string, err error) error {
+	mfs.g.log.LogErrf("mount: %s %s: %v\n", op, p, err)
+	return syscall.EIO
+}
+
+func (d *mountDir) Attr() (fuse.Attr, error) {
+	d.Lock()
+	defer d.Unlock()
+	return fuse.Attr{Mode: os.ModeDir | 0755, Mtime: d.file.ModTime}, nil
+}
+
+// list lists the folder unless its listing is recent enough,
+// it is called with the folder locked.
+func (d *mountDir) list() map[string]*File {
+	if d.children != nil && time.Since(d.listedAt) < MountListingTTL {
+		return d.children
+	}
+	children := make(map[string]*File)
+	for f := range d.fs.g.rem.findChildren(d.file.Id, false) {
+		if f == nil {
+			continue
+		}
+		if _, clash := children[f.Name]; clash {
+			d.fs.g.report.warn("Mount name clashes", "%s: only the first is shown", path.Join(d.path, f.Name))
+			continue
+		}
+		children[f.Name] = f
+	}
+	d.children, d.listedAt = children, time.Now()
+	return children
+}
+
+func (d *mountDir) Lookup(name string) (fuse.Node, error) {
+	d.Lock()
+	defer d.Unlock()
+	f, ok := d.list()[name]
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	return d.fs.node(f, d.file.Id, path.Join(d.path, name)), nil
+}
+
+func (d *mountDir) ReadDir() ([]fuse.Dirent, error) {
+	d.Lock()
+	defer d.Unlock()
+	var dirents []fuse.Dirent
+	for name, f := range d.list() {
+		dirents = append(dirents, fuse.Dirent{Name: name, IsDir: f.IsDir})
+	}
+	return dirents, nil
+}
+
+func (d *mountDir) Mkdir(name string) (fuse.Node, error) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.list()[name]; ok {
+		return nil, syscall.EEXIST
+	}
+
+	childPath := path.Join(d.path, name)
+	created, err := d.fs.g.mut.UpsertByComparison(&upsertOpt{
+		parentId: d.file.Id,
+		src:      &File{Name: name, IsDir: true, ModTime: time.Now()},
+	})
+	if err != nil {
+		return nil, d.fs.mountErr("mkdir", childPath, err)
+	}
+	d.children[name] = created
+	return d.fs.node(created, d.file.Id, childPath), nil
+}
+
+func (d *mountDir) Create(name string) (fuse.Node, fuse.Handle, error) {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.list()[name]; ok {
+		return nil, nil, syscall.EEXIST
+	}
+
+	cache, err := ioutil.TempFile(d.fs.cacheDir, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	childPath := path.Join(d.path, name)
+	created, err := d.fs.g.mut.UpsertByComparison(&upsertOpt{
+		parentId:  d.file.Id,
+		fsAbsPath: cache.Name(),
+		src:       &File{Name: name, ModTime: time.Now()},
+	})
+	if err != nil {
+		cache.Close()
+		os.Remove(cache.Name())
+		return nil, nil, d.fs.mountErr("create", childPath, err)
+	}
+	d.children[name] = created
+
+	mf := d.fs.node(created, d.file.Id, childPath).(*mountFile)
+	mf.Lock()
+	defer mf.Unlock()
+	mf.cache = cache
+	mf.opens += 1
+	return mf, &mountHandle{f: mf}, nil
+}
+
+func (d *mountDir) Remove(name string, dir bool) error {
+	d.Lock()
+	defer d.Unlock()
+	f, ok := d.list()[name]
+	if !ok {
+		return syscall.ENOENT
+	}
+	childPath := path.Join(d.path, name)
+	if err := d.fs.removable(f, dir, childPath); err != nil {
+		return err
+	}
+
+	err := d.fs.g.mut.Trash(f.Id)
+	d.fs.g.audit(AuditTrash, f, childPath, "", err)
+	if err != nil {
+		return d.fs.mountErr("remove", childPath, err)
+	}
+	delete(d.children, name)
+	d.fs.forget(f.Id)
+	return nil
+}
+
+// removable checks that f can be removed at p as a folder if dir is set,
+// else as a file, folders only being removable once they are empty.
+func (mfs *mountFS) removable(f *File, dir bool, p string) error {
+	if !dir {
+		if f.IsDir {
+			return syscall.EISDIR
+		}
+		return nil
+	}
+	if !f.IsDir {
+		return syscall.ENOTDIR
+	}
+	hasChildren, err := mfs.g.rem.hasChildren(f.Id)
+	if err != nil {
+		return mfs.mountErr("remove", p, err)
+	}
+	if hasChildren {
+		return syscall.ENOTEMPTY
+	}
+	return nil
+}
+
+func (d *mountDir) Rename(oldName string, newDir fuse.Dir, newName string) error {
+	nd, ok := newDir.(*mountDir)
+	if !ok {
+		return syscall.EXDEV
+	}
+	d.Lock()
+	defer d.Unlock()
+	if nd != d {
+		nd.Lock()
+		defer nd.Unlock()
+	}
+
+	f, ok := d.list()[oldName]
+	if !ok {
+		return syscall.ENOENT
+	}
+	srcPath, destPath := path.Join(d.path, oldName), path.Join(nd.path, newName)
+
+	// Whatever is at the new name is replaced, as with rename(2)
+	if clash, ok := nd.list()[newName]; ok {
+		if clash.Id == f.Id {
+			return nil
+		}
+		if err := d.fs.removable(clash, f.IsDir, destPath); err != nil {
+			return err
+		}
+		err := d.fs.g.mut.Trash(clash.Id)
+		d.fs.g.audit(AuditTrash, clash, destPath, "", err)
+		if err != nil {
+			return d.fs.mountErr("rename", destPath, err)
+		}
+		delete(nd.children, newName)
+		d.fs.forget(clash.Id)
+	}
+
+	if newName != oldName {
+		_, err := d.fs.g.mut.rename(f.Id, newName)
+		d.fs.g.audit(AuditRename, f, srcPath, destPath, err)
+		if err != nil {
+			return d.fs.mountErr("rename", srcPath, err)
+		}
+	}
+
+	if nd != d {
+		var err error
+		if d.fs.g.rem.inSharedDrive(d.file.Id, nd.file.Id) {
+			err = d.fs.g.mut.reparent(f.Id, d.file.Id, nd.file.Id)
+		} else if err = d.fs.g.mut.insertParent(f.Id, nd.file.Id); err == nil {
+			err = d.fs.g.mut.removeParent(f.Id, d.file.Id)
+		}
+		d.fs.g.audit(AuditMove, f, srcPath, nd.path, err)
+		if err != nil {
+			return d.fs.mountErr("rename", srcPath, err)
+		}
+	}
+
+	delete(d.children, oldName)
+	nd.children[newName] = renamedFile(f, newName)
+	d.fs.moved(f.Id, newName, nd.file.Id, destPath)
+	return nil
+}
+
+func (f *mountFile) Attr() (fuse.Attr, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	attr := fuse.Attr{Size: uint64(f.file.Size), Mode: 0644, Mtime: f.file.ModTime}
+	if hasExportLinks(f.file) {
+		attr.Mode = 0444
+	}
+	if f.cache != nil {
+		fi, err := f.cache.Stat()
+		if err != nil {
+			return attr, err
+		}
+		attr.Size = uint64(fi.Size())
+	}
+	return attr, nil
+}
+
+// load downloads the content of the file into its cache unless it
+// is cached already, it is called with the file locked. Google Docs
+// have no content of their own so they are empty.
+func (f *mountFile) load() error {
+	if f.cache != nil {
+		return nil
+	}
+	cache, err := ioutil.TempFile(f.fs.cacheDir, "")
+	if err != nil {
+		return err
+	}
+
+	if !hasExportLinks(f.file) && f.file.Size > 0 {
+		err = f.download(cache)
+	}
+	if err != nil {
+		cache.Close()
+		os.Remove(cache.Name())
+		return f.fs.mountErr("download", f.path, err)
+	}
+	f.cache = cache
+	return nil
+}
+
+func (f *mountFile) download(w io.Writer) error {
+	body, err := f.fs.g.rem.Download(f.file.Id, "")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// upload uploads the cached content if it was changed,
+// it is called with the file locked.
+func (f *mountFile) upload() error {
+	if !f.dirty {
+		return nil
+	}
+	fi, err := f.cache.Stat()
+	if err != nil {
+		return err
+	}
+
+	updated, err := f.fs.g.mut.UpsertByComparison(&upsertOpt{
+		parentId:    f.parentId,
+		fsAbsPath:   f.cache.Name(),
+		nonStatable: true,
+		src:         &File{Id: f.file.Id, Name: f.file.Name, Size: fi.Size(), ModTime: time.Now()},
+	})
+	if err != nil {
+		return f.fs.mountErr("upload", f.path, err)
+	}
+	f.file, f.dirty = updated, false
+	return nil
+}
+
+// release uploads the cached content if it was changed and then drops it,
+// once the file isn't open anymore. It is called with the file locked.
+func (f *mountFile) release() error {
+	if f.opens > 0 || f.cache == nil {
+		return nil
+	}
+	err := f.upload()
+	f.cache.Close()
+	os.Remove(f.cache.Name())
+	f.cache, f.dirty = nil, false
+	return err
+}
+
+func (f *mountFile) Open(write bool) (fuse.Handle, error) {
+	f.Lock()
+	defer f.Unlock()
+	if write && hasExportLinks(f.file) {
+		return nil, syscall.EACCES
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	f.opens += 1
+	return &mountHandle{f: f}, nil
+}
+
+// Truncate truncates the file and uploads it, as it isn't done through
+// a handle that would be flushed, even if the file is still open.
+func (f *mountFile) Truncate(size uint64) error {
+	f.Lock()
+	defer f.Unlock()
+	if hasExportLinks(f.file) {
+		return syscall.EACCES
+	}
+
+	var err error
+	if size == 0 && f.cache == nil {
+		// The content is about to be dropped anyway
+		f.cache, err = ioutil.TempFile(f.fs.cacheDir, "")
+	} else {
+		err = f.load()
+	}
+	if err != nil {
+		return err
+	}
+	if err := f.cache.Truncate(int64(size)); err != nil {
+		return err
+	}
+	f.dirty = true
+	if err := f.upload(); err != nil {
+		return err
+	}
+	return f.release()
+}
+
+func (h *mountHandle) ReadAt(p []byte, off int64) (int, error) {
+	h.f.Lock()
+	defer h.f.Unlock()
+	return h.f.cache.ReadAt(p, off)
+}
+
+func (h *mountHandle) WriteAt(p []byte, off int64) (int, error) {
+	h.f.Lock()
+	defer h.f.Unlock()
+	h.f.dirty = true
+	return h.f.cache.WriteAt(p, off)
+}
+
+func (h *mountHandle) Truncate(size uint64) error {
+	h.f.Lock()
+	defer h.f.Unlock()
+	h.f.dirty = true
+	return h.f.cache.Truncate(int64(size))
+}
+
+func (h *mountHandle) Flush() error {
+	h.f.Lock()
+	defer h.f.Unlock()
+	return h.f.upload()
+}
+
+func (h *mountHandle) Release() error {
+	h.f.Lock()
+	defer h.f.Unlock()
+	h.f.opens -= 1
+	return h.f.release()
+}
+
+// Mount mounts the remote folder at the first source on mountPoint,
+// serving it until it is unmounted or the command is interrupted.
+func (g *Commands) Mount(mountPoint string) error {
+	if len(g.opts.Sources) < 1 {
+		return fmt.Errorf("mount: expecting the remote folder to mount")
+	}
+	if g.plan != nil {
+		return fmt.Errorf("mount: mounts can't be planned")
+	}
+	remotePath := g.opts.Sources[0]
+
+	root, err := g.rem.FindByPath(remotePath)
+	if err != nil {
+		return fmt.Errorf("mount: %s: %v", remotePath, err)
+	}
+	if root == nil || !root.IsDir {
+		return fmt.Errorf("mount: %s is not a folder", remotePath)
+	}
+
+	cacheDir, err := ioutil.TempDir("", "drive-mount")
+	if err != nil {
+		return fmt.Errorf("mount: cache: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	mfs := &mountFS{g: g, cacheDir: cacheDir, nodes: make(map[string]fuse.Node)}
+	conn, err := fuse.Mount(mountPoint, "drive", mfs.node(root, "", remotePath).(*mountDir))
+	if err != nil {
+		return fmt.Errorf("mount: %v", err)
+	}
+	defer conn.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	unmounted := make(chan error, 1)
+	go func() {
+		unmounted <- conn.Wait()
+	}()
+
+	g.log.Logf("Mounted %s on %s. Interrupt or unmount it to stop\n", remotePath, mountPoint)
+	for {
+		select {
+		case err := <-unmounted:
+			g.log.Logf("Unmounted %s\n", mountPoint)
+			return err
+		case <-interrupt:
+			if err := fuse.Unmount(mountPoint); err != nil {
+				g.log.LogErrf("mount: could not unmount %s: %v\n", mountPoint, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/fuse"
+)
+
+// openBlocking opens name without the Go runtime registering it for polling,
+// as the test serves the mount that it opens files in, see Mount.
+func openBlocking(name string, flag int, perm os.FileMode) (*os.File, error) {
+	fd, err := syscall.Open(name, flag|syscall.O_CLOEXEC, uint32(perm))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+func readFile(name string) ([]byte, error) {
+	f, err := openBlocking(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	f, err := openBlocking(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func TestMount(t *testing.T) {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("no /dev/fuse")
+	}
+
+	fd := newFakeDrive()
+	docs := fd.add("root", "docs", nil, true)
+	fd.add(docs.Id, "a.txt", []byte("hello"), false)
+
+	g := commandsOn(fd, &Options{Sources: []string{"/"}})
+
+	mountPoint, err := ioutil.TempDir("", "drivemount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(mountPoint)
+
+	mounted := make(chan error, 1)
+	go func() {
+		mounted <- g.Mount(mountPoint)
+	}()
+	for i := 0; ; i++ {
+		if _, err := os.Stat(filepath.Join(mountPoint, "docs")); err == nil {
+			break
+		}
+		select {
+		case err := <-mounted:
+			t.Skipf("can't mount here: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+		if i > 500 {
+			t.Fatal("the mount didn't come up")
+		}
+	}
+	defer func() {
+		if err := fuse.Unmount(mountPoint); err != nil {
+			t.Errorf("unmount: %v", err)
+		}
+		if err := <-mounted; err != nil {
+			t.Errorf("mount: %v", err)
+		}
+	}()
+
+	at := func(p ...string) string {
+		return filepath.Join(append([]string{mountPoint}, p...)...)
+	}
+	contentOf := func(parentId, title string) string {
+		f := fd.child(parentId, title)
+		if f == nil {
+			return "<missing>"
+		}
+		fd.Lock()
+		defer fd.Unlock()
+		return string(fd.content[f.Id])
+	}
+
+	if content, err := readFile(at("docs", "a.txt")); err != nil || string(content) != "hello" {
+		t.Errorf("read: got %q, %v want %q", content, err, "hello")
+	}
+
+	// Writes are uploaded as the file is closed
+	if err := writeFile(at("docs", "b.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got := contentOf(docs.Id, "b.txt"); got != "new" {
+		t.Errorf("create: uploaded %q want %q", got, "new")
+	}
+
+	f, err := openBlocking(at("docs", "a.txt"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(", world")
+	if got := contentOf(docs.Id, "a.txt"); got != "hello" {
+		t.Errorf("append: uploaded %q before it was closed", got)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if got := contentOf(docs.Id, "a.txt"); got != "hello, world" {
+		t.Errorf("append: uploaded %q want %q", got, "hello, world")
+	}
+	if fi, err := os.Stat(at("docs", "a.txt")); err != nil || fi.Size() != 12 {
+		t.Errorf("stat after append: got %v, %v", fi, err)
+	}
+
+	if err := os.Truncate(at("docs", "a.txt"), 5); err != nil {
+		t.Errorf("truncate: %v", err)
+	}
+	if got := contentOf(docs.Id, "a.txt"); got != "hello" {
+		t.Errorf("truncate: uploaded %q want %q", got, "hello")
+	}
+
+	// Truncating as it is opened is uploaded along with what is written
+	if err := writeFile(at("docs", "a.txt"), []byte("bye"), 0644); err != nil {
+		t.Errorf("overwrite: %v", err)
+	}
+	if got := contentOf(docs.Id, "a.txt"); got != "bye" {
+		t.Errorf("overwrite: uploaded %q want %q", got, "bye")
+	}
+
+	if err := os.Mkdir(at("work"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	work := fd.child("root", "work")
+	if work == nil || work.MimeType != DriveFolderMimeType {
+		t.Fatalf("mkdir: got %v want a folder", work)
+	}
+
+	if err := os.Rename(at("docs", "b.txt"), at("work", "c.txt")); err != nil {
+		t.Errorf("rename: %v", err)
+	}
+	if got := contentOf(work.Id, "c.txt"); got != "new" {
+		t.Errorf("rename: got %q in work/c.txt want %q", got, "new")
+	}
+	if fd.child(docs.Id, "b.txt") != nil {
+		t.Errorf("rename: docs/b.txt is still there")
+	}
+
+	if err := os.Remove(at("docs")); err == nil || !os.IsExist(err) && err.(*os.PathError).Err != syscall.ENOTEMPTY {
+		t.Errorf("rmdir of a folder with items: got %v want ENOTEMPTY", err)
+	}
+	if err := os.Remove(at("work", "c.txt")); err != nil {
+		t.Errorf("unlink: %v", err)
+	}
+	if fd.child(work.Id, "c.txt") != nil {
+		t.Errorf("unlink: work/c.txt wasn't trashed")
+	}
+
+	infos, err := ioutil.ReadDir(mountPoint)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"docs", "work"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("readdir: got %v want %v", names, want)
+	}
+}
@@ -16,13 +16,27 @@ package drive
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DestIdPrefix marks a destination given by the id of the folder rather than its path
+	DestIdPrefix = "id:"
+
+	// retryFailedPause is how long to pause, times the pass, before retrying failed moves
+	retryFailedPause = 5 * time.Second
 )
 
 type moveOpt struct {
 	src  string
 	dest string
 	byId bool
+	// file is the resolved src, if already known
+	file *File
 }
 
 func (g *Commands) Move(byId bool) error {
@@ -31,9 +45,48 @@ func (g *Commands) Move(byId bool) error {
 		return fmt.Errorf("move: expected <src> [src...] <dest>, instead got: %v", g.opts.Sources)
 	}
 
+	// Ids aren't paths to be scoped, only the dest is
+	scoped := g.opts.Sources
+	if byId {
+		scoped = scoped[argc-1:]
+	}
+	destId := strings.HasPrefix(g.opts.Sources[argc-1], DestIdPrefix)
+	if destId {
+		scoped = scoped[:len(scoped)-1]
+	}
+	if err := g.scopeToDestRoot(scoped); err != nil {
+		return err
+	}
+
+	if destId {
+		destPath, err := g.resolveDestId(strings.TrimPrefix(g.opts.Sources[argc-1], DestIdPrefix))
+		if err != nil {
+			return fmt.Errorf("move: dest: %v", err)
+		}
+		g.opts.Sources[argc-1] = destPath
+	}
+
+	if !byId {
+		sources, err := g.expandSources(g.opts.Sources[:argc-1])
+		if err != nil {
+			return fmt.Errorf("move: %v", err)
+		}
+		g.opts.Sources = append(sources, g.opts.Sources[argc-1])
+		argc = len(g.opts.Sources)
+	}
+
+	window, err := newTimeWindow(g.opts.OlderThan, g.opts.NewerThan)
+	if err != nil {
+		return err
+	}
+
 	rest, dest := g.opts.Sources[:argc-1], g.opts.Sources[argc-1]
 
+	g.report = newReport()
+	defer g.report.summarize(g.log)
+
 	var composedError error = nil
+	var opts []*moveOpt
 
 	for _, src := range rest {
 		prefix := commonPrefix(src, dest)
@@ -43,21 +96,152 @@ func (g *Commands) Move(byId bool) error {
 			return fmt.Errorf("%s cannot be nested into %s", src, dest)
 		}
 
-		opt := moveOpt{
+		opts = append(opts, &moveOpt{
 			src:  src,
 			dest: dest,
 			byId: byId,
+		})
+	}
+
+	opts, composedError = g.expandMoveSources(opts, window)
+
+	var dests []string
+	incoming := make(map[string]int)
+	for _, opt := range opts {
+		if _, seen := incoming[opt.dest]; !seen {
+			dests = append(dests, opt.dest)
 		}
+		incoming[opt.dest] += 1
+	}
 
-		if err := g.move(&opt); err != nil {
-			message := fmt.Sprintf("move: %s: %v", src, err)
-			composedError = reComposeError(composedError, message)
+	for _, dest := range dests {
+		if err := g.pickDest(dest); err != nil {
+			return fmt.Errorf("move: dest: %v", err)
+		}
+		destFile, destErr := g.findDest(dest)
+		if destErr != nil && destErr != ErrPathNotExists {
+			return fmt.Errorf("move: dest: '%s' %v", dest, destErr)
+		}
+		if err := g.checkDestWritable(dest, destFile); err != nil {
+			return fmt.Errorf("move: dest: %v", err)
 		}
+		if destFile != nil && destFile.IsDir {
+			if err := g.checkChildLimit(destFile, dest, incoming[dest]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if g.opts.EstimateCost && len(opts) >= 1 {
+		proceed, err := g.confirmMoveCost(opts)
+		if err != nil {
+			return reComposeError(composedError, err.Error())
+		}
+		if !proceed {
+			return composedError
+		}
+	}
+
+	failures := make(map[*moveOpt]error)
+	failed := g.movePass(opts, failures)
+
+	// The failures are retried once the rest are done, by when bursts
+	// of requests that made for transient failures have subsided.
+	for pass := 1; pass <= g.opts.RetryFailedAtEnd && len(failed) >= 1; pass++ {
+		g.log.Logf("Retrying %d failed moves, pass %d of %d\n", len(failed), pass, g.opts.RetryFailedAtEnd)
+		time.Sleep(time.Duration(pass) * retryFailedPause)
+
+		stillFailed := g.movePass(failed, failures)
+		for _, opt := range failed {
+			if _, still := failures[opt]; !still {
+				g.report.note("Moved on retrying", "%s", opt.src)
+			}
+		}
+		failed = stillFailed
+	}
+
+	if g.opts.PruneAfterMove {
+		if err := g.pruneAfterMove(opts, failed); err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+
+	for _, opt := range failed {
+		if g.opts.RetryFailedAtEnd >= 1 {
+			g.report.warn("Moves that failed despite retrying", "%s: %v", opt.src, failures[opt])
+		}
+		message := fmt.Sprintf("move: %s: %v", opt.src, failures[opt])
+		composedError = reComposeError(composedError, message)
 	}
 
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
 	return composedError
 }
 
+// resolveDestId resolves the destination folder with id to a path that
+// lookups then resolve via that folder. That is the folder's path in your
+// drive or if it has none e.g it was shared with you, one made of its id.
+func (g *Commands) resolveDestId(id string) (string, error) {
+	f, err := g.rem.FindById(id)
+	if err != nil {
+		return "", fmt.Errorf("%s%s: %v", DestIdPrefix, id, err)
+	}
+	if !f.IsDir {
+		return "", fmt.Errorf("%s%s: %v", DestIdPrefix, id, ErrPathNotDir)
+	}
+
+	destPath, err := g.rem.pathOf(f.Id)
+	if err != nil {
+		destPath = "/" + DestIdPrefix + f.Id
+	}
+	g.rem.pinPath(destPath, f)
+	return destPath, nil
+}
+
+// expandMoveSources resolves the sources to be moved, leaving out those
+// whose modification time is outside of window, and routes each to its
+// destination as computed by the DestResolver if set.
+func (g *Commands) expandMoveSources(opts []*moveOpt, window *timeWindow) (expanded []*moveOpt, composedError error) {
+	for _, opt := range opts {
+		srcResolver := g.rem.FindByPath
+		if opt.byId {
+			srcResolver = g.rem.FindById
+		}
+
+		file, err := srcResolver(opt.src)
+		if err != nil || file == nil {
+			message := fmt.Sprintf("move: %s: src('%s') %v", opt.src, opt.src, err)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+
+		if !window.contains(file.ModTime) {
+			continue
+		}
+
+		if g.opts.DestResolver != nil {
+			if dest := g.opts.DestResolver(file, opt.src); dest != "" {
+				if commonPrefix(opt.src, dest) == opt.src {
+					message := fmt.Sprintf("move: %s cannot be nested into %s", opt.src, dest)
+					composedError = reComposeError(composedError, message)
+					continue
+				}
+				opt.dest = dest
+			}
+		}
+
+		opt.file = file
+		expanded = append(expanded, opt)
+	}
+
+	if window != nil {
+		g.log.Logf("%d of %d sources matched the time window\n", len(expanded), len(opts))
+	}
+	return expanded, composedError
+}
+
 func (g *Commands) move(opt *moveOpt) (err error) {
 	var newParent, remSrc *File
 
@@ -66,14 +250,20 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 		srcResolver = g.rem.FindById
 	}
 
-	if remSrc, err = srcResolver(opt.src); err != nil {
-		return fmt.Errorf("src('%s') %v", opt.src, err)
+	if remSrc = opt.file; remSrc == nil {
+		if remSrc, err = srcResolver(opt.src); err != nil {
+			return fmt.Errorf("src('%s') %v", opt.src, err)
+		}
 	}
 
 	if remSrc == nil {
 		return fmt.Errorf("src: '%s' could not be found", opt.src)
 	}
 
+	defer func() {
+		g.auditMove(remSrc, opt.src, opt.dest, err)
+	}()
+
 	if newParent, err = g.rem.FindByPath(opt.dest); err != nil {
 		return fmt.Errorf("dest: '%s' %v", opt.dest, err)
 	}
@@ -82,9 +272,11 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 		return fmt.Errorf("dest: '%s' must be an existant folder", opt.dest)
 	}
 
+	var oldParent *File
 	if !opt.byId {
 		parentPath := g.parentPather(opt.src)
-		oldParent, parErr := g.rem.FindByPath(parentPath)
+		var parErr error
+		oldParent, parErr = g.rem.FindByPath(parentPath)
 		if parErr != nil && parErr != ErrPathNotExists {
 			return parErr
 		}
@@ -94,9 +286,18 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 			return fmt.Errorf("src and dest are the same srcParentId %s destParentId %s",
 				customQuote(oldParent.Id), customQuote(newParent.Id))
 		}
+		if oldParent != nil {
+			g.plan.knowPath(oldParent.Id, parentPath)
+		}
 	}
 
+	g.plan.knowPath(remSrc.Id, opt.src)
+	g.plan.knowPath(newParent.Id, opt.dest)
+
 	newFullPath := filepath.Join(opt.dest, remSrc.Name)
+	if err = g.checkPathLength(remSrc, newFullPath); err != nil {
+		return err
+	}
 
 	// Check for a duplicate
 	var dupCheck *File
@@ -109,6 +310,9 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 		if dupCheck.Id == remSrc.Id { // Trying to move to self
 			return fmt.Errorf("move: trying to move fileId:%s to self fileId:%s", customQuote(dupCheck.Id), customQuote(remSrc.Id))
 		}
+		if g.opts.Merge && remSrc.IsDir && dupCheck.IsDir && !opt.byId {
+			return g.mergeInto(remSrc, opt.src, dupCheck, newFullPath)
+		}
 		if !g.opts.Force {
 			return fmt.Errorf("%s already exists. Use `%s` flag to override this behaviour", newFullPath, ForceKey)
 		}
@@ -119,7 +323,20 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 		return fmt.Errorf("move: cannot move '%s' to itself", opt.src)
 	}
 
-	if err = g.rem.insertParent(remSrc.Id, newParent.Id); err != nil {
+	// By id, the original path isn't known and the file keeps its current parent
+	if g.opts.Breadcrumb && !opt.byId {
+		originalPath := path.Join(g.parentPather(opt.src), remSrc.Name)
+		if bcErr := g.mut.setAppProperty(remSrc.Id, OriginalPathKey, originalPath); bcErr != nil {
+			g.log.LogErrf("move: %s: could not record its original path: %v\n", opt.src, bcErr)
+		}
+	}
+
+	// Items in shared drives have just the one parent so are moved in one go
+	if oldParent != nil && g.rem.inSharedDrive(oldParent.Id, newParent.Id) {
+		return g.mut.reparent(remSrc.Id, oldParent.Id, newParent.Id)
+	}
+
+	if err = g.mut.insertParent(remSrc.Id, newParent.Id); err != nil {
 		return err
 	}
 
@@ -129,6 +346,267 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 	return g.removeParent(remSrc.Id, opt.src)
 }
 
+// mergeOutcome is what came of merging a folder, which is
+// only known once the batch is flushed if moves are batched.
+type mergeOutcome struct {
+	// emptied is set if all of the children were moved and the folder trashed
+	emptied bool
+	err     error
+}
+
+// mergeInto moves the children of the folder src into the existing folder
+// dest of the same name, merging same-named subfolders likewise. Children
+// that clash with those in dest are left in place unless opts.Force is set.
+// src is trashed once it has been emptied.
+func (g *Commands) mergeInto(src *File, srcPath string, dest *File, destPath string) error {
+	outcome := g.merge(src, srcPath, dest, destPath)
+
+	bm, batching := g.mut.(*batchingMutator)
+	if !batching {
+		return outcome.err
+	}
+	// The merge fails along with the move of src once the batch is flushed
+	bm.batch.whenAllFlushed(func(failures map[string]error) {
+		if outcome.err != nil {
+			failures[src.Id] = outcome.err
+		}
+	})
+	return nil
+}
+
+func (g *Commands) merge(src *File, srcPath string, dest *File, destPath string) *mergeOutcome {
+	var children []*File
+	for child := range g.rem.findChildren(src.Id, false) {
+		children = append(children, child)
+	}
+
+	outcome := &mergeOutcome{}
+	left := 0
+	// moved and merged are only known to have been once batches are flushed
+	var moved []*File
+	var merged []*mergeOutcome
+	for _, child := range children {
+		childSrcPath := path.Join(srcPath, child.Name)
+		childDestPath := path.Join(destPath, child.Name)
+
+		clash, err := g.rem.FindByPath(childDestPath)
+		if err != nil && err != ErrPathNotExists {
+			outcome.err = reComposeError(outcome.err, fmt.Sprintf("%s: %v", childDestPath, err))
+			left += 1
+			continue
+		}
+
+		if clash != nil {
+			if child.IsDir && clash.IsDir {
+				merged = append(merged, g.merge(child, childSrcPath, clash, childDestPath))
+				continue
+			}
+			if !g.opts.Force {
+				g.report.warn("Merge conflicts left in place", "%s clashes with %s", childSrcPath, childDestPath)
+				left += 1
+				continue
+			}
+		}
+
+		if g.opts.Breadcrumb {
+			if bcErr := g.mut.setAppProperty(child.Id, OriginalPathKey, childSrcPath); bcErr != nil {
+				g.log.LogErrf("merge: %s: could not record its original path: %v\n", childSrcPath, bcErr)
+			}
+		}
+
+		g.plan.knowPath(child.Id, childSrcPath)
+		g.plan.knowPath(dest.Id, destPath)
+		g.plan.knowPath(src.Id, srcPath)
+
+		if g.rem.inSharedDrive(src.Id, dest.Id) {
+			err = g.mut.reparent(child.Id, src.Id, dest.Id)
+		} else if err = g.mut.insertParent(child.Id, dest.Id); err == nil {
+			err = g.mut.removeParent(child.Id, src.Id)
+		}
+		g.auditMove(child, childSrcPath, destPath, err)
+		if err != nil {
+			outcome.err = reComposeError(outcome.err, fmt.Sprintf("%s: %v", childSrcPath, err))
+			left += 1
+			continue
+		}
+		moved = append(moved, child)
+	}
+
+	// src is only trashed once it is known to have been emptied
+	finish := func(failures map[string]error) {
+		for _, child := range moved {
+			if err := failures[child.Id]; err != nil {
+				outcome.err = reComposeError(outcome.err, fmt.Sprintf("%s: %v", path.Join(srcPath, child.Name), err))
+				left += 1
+			}
+		}
+		for _, sub := range merged {
+			if sub.err != nil {
+				outcome.err = reComposeError(outcome.err, sub.err.Error())
+			}
+			if !sub.emptied {
+				left += 1
+			}
+		}
+
+		if left >= 1 {
+			g.report.note("Folders merged partially", "%s into %s, %d items left behind", srcPath, destPath, left)
+			return
+		}
+
+		err := g.mut.Trash(src.Id)
+		g.audit(AuditTrash, src, srcPath, "", err)
+		if err != nil {
+			outcome.err = reComposeError(outcome.err, fmt.Sprintf("trashing merged %s: %v", srcPath, err))
+			return
+		}
+		outcome.emptied = true
+		g.report.note("Folders merged", "%s into %s", srcPath, destPath)
+	}
+
+	// Subfolders are merged, and so finished, before their parents
+	if bm, batching := g.mut.(*batchingMutator); batching {
+		bm.batch.whenAllFlushed(finish)
+	} else {
+		finish(nil)
+	}
+	return outcome
+}
+
+// auditMove records the move of f, once its parents are changed if the
+// changes are batched, as it is only then known whether the move was made.
+func (g *Commands) auditMove(f *File, srcPath, destPath string, err error) {
+	if bm, ok := g.mut.(*batchingMutator); ok && err == nil && f != nil {
+		queued := bm.batch.whenFlushed(f.Id, func(err error) {
+			g.audit(AuditMove, f, srcPath, destPath, err)
+		})
+		if queued {
+			return
+		}
+	}
+	g.audit(AuditMove, f, srcPath, destPath, err)
+}
+
+// movePass makes the moves of opts, returning those that failed, whose
+// errors are set in failures. Errors of moves that succeed are cleared.
+// With opts.BatchMoves, the changes to parents are made in batches once
+// all the moves are otherwise done.
+func (g *Commands) movePass(opts []*moveOpt, failures map[*moveOpt]error) (failed []*moveOpt) {
+	var batch *parentBatch
+	mut := g.mut
+	// Planned moves aren't made so there is nothing to batch
+	if g.opts.BatchMoves && g.plan == nil {
+		batch = &parentBatch{}
+		g.mut = &batchingMutator{mutator: mut, batch: batch}
+	}
+
+	for _, opt := range opts {
+		delete(failures, opt)
+		if err := g.move(opt); err != nil {
+			failed = append(failed, opt)
+			failures[opt] = err
+		}
+	}
+
+	if batch == nil {
+		return failed
+	}
+	g.mut = mut
+
+	batchFailures := batch.flush(g.rem, g.journal)
+	for _, opt := range opts {
+		if _, moveFailed := failures[opt]; moveFailed || opt.file == nil {
+			continue
+		}
+		if err := batchFailures[opt.file.Id]; err != nil {
+			failed = append(failed, opt)
+			failures[opt] = err
+		}
+	}
+	return failed
+}
+
+// pruneAfterMove trashes the folders that the moved sources were taken
+// out of if they were left empty, and then likewise each of their
+// ancestors, stopping at the first that isn't empty. The root, the
+// destinations and their ancestors are never trashed.
+func (g *Commands) pruneAfterMove(opts []*moveOpt, failed []*moveOpt) error {
+	stillFailed := make(map[*moveOpt]bool)
+	for _, opt := range failed {
+		stillFailed[opt] = true
+	}
+
+	kept := map[string]bool{"/": true}
+	for _, opt := range opts {
+		for p := path.Clean(opt.dest); ; p = g.parentPather(p) {
+			kept[p] = true
+			if p == "/" || p == "." || p == "" {
+				break
+			}
+		}
+	}
+
+	var folderPaths []string
+	seen := make(map[string]bool)
+	for _, opt := range opts {
+		// By id, the folders that the source was taken out of aren't known
+		if opt.byId || stillFailed[opt] {
+			continue
+		}
+		folderPath := g.parentPather(path.Clean(opt.src))
+		if !seen[folderPath] {
+			seen[folderPath] = true
+			folderPaths = append(folderPaths, folderPath)
+		}
+	}
+
+	// Deeper folders first, so that ancestors shared by several
+	// sources are only checked once their descendants are pruned.
+	sort.Sort(sort.Reverse(byPathDepth(folderPaths)))
+
+	pruned := make(map[string]bool)
+	var composedError error = nil
+	for _, folderPath := range folderPaths {
+		var chain []string
+		for p := folderPath; !kept[p] && !pruned[p] && p != "" && p != "."; p = g.parentPather(p) {
+			folder, err := g.rem.FindByPath(p)
+			if err != nil || folder == nil || !folder.IsDir {
+				break
+			}
+			nonEmpty, err := g.rem.hasChildren(folder.Id)
+			if err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("prune: %s: %v", p, err))
+				break
+			}
+			if nonEmpty {
+				break
+			}
+
+			err = g.mut.Trash(folder.Id)
+			g.audit(AuditTrash, folder, p, "", err)
+			if err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("prune: %s: %v", p, err))
+				break
+			}
+			pruned[p] = true
+			chain = append(chain, p)
+		}
+
+		if len(chain) >= 1 {
+			g.report.note("Pruned empty folders", "%s", sepJoin(" -> ", chain...))
+		}
+	}
+	return composedError
+}
+
+type byPathDepth []string
+
+func (bpd byPathDepth) Len() int      { return len(bpd) }
+func (bpd byPathDepth) Swap(i, j int) { bpd[i], bpd[j] = bpd[j], bpd[i] }
+func (bpd byPathDepth) Less(i, j int) bool {
+	return strings.Count(bpd[i], "/") < strings.Count(bpd[j], "/")
+}
+
 func (g *Commands) removeParent(fileId, relToRootPath string) error {
 	parentPath := g.parentPather(relToRootPath)
 	parent, pErr := g.rem.FindByPath(parentPath)
@@ -138,7 +616,8 @@ func (g *Commands) removeParent(fileId, relToRootPath string) error {
 	if parent == nil {
 		return fmt.Errorf("non existant parent '%s' for src", parentPath)
 	}
-	return g.rem.removeParent(fileId, parent.Id)
+	g.plan.knowPath(parent.Id, parentPath)
+	return g.mut.removeParent(fileId, parent.Id)
 }
 
 func (g *Commands) Rename(byId bool) error {
@@ -146,6 +625,12 @@ func (g *Commands) Rename(byId bool) error {
 		return fmt.Errorf("rename: expecting <src> <newname>")
 	}
 
+	if !byId {
+		if err := g.scopeToDestRoot(g.opts.Sources[:1]); err != nil {
+			return err
+		}
+	}
+
 	src := g.opts.Sources[0]
 	resolver := g.rem.FindByPath
 	if byId {
@@ -166,12 +651,25 @@ func (g *Commands) Rename(byId bool) error {
 		parentPath = g.opts.Path
 	}
 
-	newName := g.opts.Sources[1]
+	if err := g.rename(remSrc, parentPath, g.opts.Sources[1]); err != nil {
+		return err
+	}
+	return g.flushPlan()
+}
+
+// rename renames remSrc that lives in the folder at parentPath to newName.
+func (g *Commands) rename(remSrc *File, parentPath, newName string) (err error) {
+	defer func() {
+		g.audit(AuditRename, remSrc, path.Join(parentPath, remSrc.Name), newName, err)
+	}()
+
 	urlBoundName := urlToPath(newName, true)
 	newFullPath := filepath.Join(parentPath, urlBoundName)
+	if err = g.checkPathLength(remSrc, newFullPath); err != nil {
+		return err
+	}
 
-	var dupCheck *File
-	dupCheck, err = g.rem.FindByPath(newFullPath)
+	dupCheck, err := g.rem.FindByPath(newFullPath)
 
 	if err == nil && dupCheck != nil {
 		if dupCheck.Id == remSrc.Id { // Trying to rename self
@@ -182,6 +680,152 @@ func (g *Commands) Rename(byId bool) error {
 		}
 	}
 
-	_, err = g.rem.rename(remSrc.Id, newName)
+	g.plan.knowPath(remSrc.Id, path.Join(parentPath, remSrc.Name))
+	_, err = g.mut.rename(remSrc.Id, newName)
 	return err
 }
+
+// RenameByMap renames items in folder as listed in the file at mapPath whose
+// lines are tab separated pairs of <current name> <new name>. With byId, the
+// first column is the id of the item instead. Every mapping has to match exactly
+// one item, otherwise no renames are made.
+func (g *Commands) RenameByMap(mapPath, folder string, byId bool) error {
+	mappings, err := readMappingsFile(mapPath, "#")
+	if err != nil {
+		return err
+	}
+
+	folders := []string{folder}
+	if err := g.scopeToDestRoot(folders); err != nil {
+		return err
+	}
+	folder = folders[0]
+
+	parent, err := g.rem.FindByPath(folder)
+	if err != nil {
+		return fmt.Errorf("rename: %s: %v", folder, err)
+	}
+	if parent == nil || !parent.IsDir {
+		return fmt.Errorf("rename: %s: %v", folder, ErrPathNotDir)
+	}
+
+	byName := make(map[string][]*File)
+	byIdMap := make(map[string]*File)
+	for child := range g.rem.findChildren(parent.Id, false) {
+		byName[child.Name] = append(byName[child.Name], child)
+		byIdMap[child.Id] = child
+	}
+
+	var unmatched, ambiguous []string
+	matches := make([]*File, len(mappings))
+	for i, m := range mappings {
+		if byId {
+			if match, ok := byIdMap[m.from]; ok {
+				matches[i] = match
+			} else {
+				unmatched = append(unmatched, m.from)
+			}
+			continue
+		}
+
+		switch candidates := byName[m.from]; len(candidates) {
+		case 0:
+			unmatched = append(unmatched, m.from)
+		case 1:
+			matches[i] = candidates[0]
+		default:
+			ambiguous = append(ambiguous, fmt.Sprintf("%s (%d matches)", m.from, len(candidates)))
+		}
+	}
+
+	if len(unmatched) >= 1 || len(ambiguous) >= 1 {
+		for _, entry := range unmatched {
+			g.log.LogErrf("unmatched: %s\n", entry)
+		}
+		for _, entry := range ambiguous {
+			g.log.LogErrf("ambiguous: %s\n", entry)
+		}
+		return fmt.Errorf("rename: %d unmatched and %d ambiguous entries in %s, nothing was renamed",
+			len(unmatched), len(ambiguous), mapPath)
+	}
+
+	var composedError error = nil
+	for i, m := range mappings {
+		if err := g.rename(matches[i], folder, m.to); err != nil {
+			message := fmt.Sprintf("rename: %s: %v", m.from, err)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+		g.log.Logf("%s -> %s\n", m.from, m.to)
+	}
+
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+// checkChildLimit guards against bulk moves and copies that would leave
+// the folder at destPath with more than opts.MaxChildren children, since
+// Drive degrades on pathologically large folders.
+// checkDestWritable makes sure that items can be added to destPath, or to its
+// closest existing ancestor if it is yet to be created, failing early with
+// the access that is missing instead of on the first item moved or copied.
+func (g *Commands) checkDestWritable(destPath string, destFile *File) error {
+	if !g.opts.CheckWritable {
+		return nil
+	}
+
+	dirPath := destPath
+	for destFile == nil || !destFile.IsDir {
+		if dirPath == "/" || dirPath == "." || dirPath == "" {
+			return nil
+		}
+		dirPath = g.parentPather(dirPath)
+
+		var err error
+		if destFile, err = g.rem.FindByPath(dirPath); err != nil && err != ErrPathNotExists {
+			return err
+		}
+	}
+
+	if destFile.Editable {
+		return nil
+	}
+
+	role := "none"
+	if destFile.UserPermission != nil {
+		role = destFile.UserPermission.Role
+	}
+	return fmt.Errorf("%s: cannot add items to it, that needs edit access but yours is %q", dirPath, role)
+}
+
+func (g *Commands) checkChildLimit(destDir *File, destPath string, incoming int) error {
+	if g.opts.MaxChildren <= 0 || incoming < 1 {
+		return nil
+	}
+
+	existing := 0
+	if destDir != nil && destDir.Id != "" {
+		for _ = range g.rem.findChildren(destDir.Id, false) {
+			existing += 1
+		}
+	}
+
+	projected := existing + incoming
+	if projected <= g.opts.MaxChildren {
+		return nil
+	}
+
+	limitMsg := fmt.Sprintf("%s: projected child count %d (%d existing + %d incoming) exceeds the limit of %d\n",
+		destPath, projected, existing, incoming, g.opts.MaxChildren)
+	if !g.opts.canPrompt() {
+		return fmt.Errorf("child limit: noPrompt is set yet for %s", limitMsg)
+	}
+
+	g.log.LogErrf("\033[91m%s\033[00m", limitMsg)
+	if !promptForChanges() {
+		return fmt.Errorf("child limit: aborted for %s", destPath)
+	}
+	return nil
+}
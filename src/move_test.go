@@ -0,0 +1,62 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+)
+
+func TestMergeBatched(t *testing.T) {
+	for _, fixed := range []bool{false, true} {
+		fd := newFakeDrive()
+		docs := fd.add("root", "docs", nil, true)
+		a := fd.add(docs.Id, "a.txt", []byte("a"), false)
+		sub := fd.add(docs.Id, "sub", nil, true)
+		c := fd.add(sub.Id, "c.txt", []byte("c"), false)
+		b := fd.add("root", "b", nil, true)
+		destDocs := fd.add(b.Id, "docs", nil, true)
+		destSub := fd.add(destDocs.Id, "sub", nil, true)
+		fd.fixed[a.Id] = fixed
+
+		g := commandsOn(fd, &Options{
+			Sources:    []string{"/docs", "/b"},
+			Merge:      true,
+			BatchMoves: true,
+			NoPrompt:   true,
+		})
+		err := g.Move(false)
+
+		if got := fd.child(destSub.Id, "c.txt"); got == nil || got.Id != c.Id {
+			t.Errorf("fixed=%v: sub/c.txt wasn't merged into b/docs/sub", fixed)
+		}
+		if !sub.Labels.Trashed {
+			t.Errorf("fixed=%v: the emptied docs/sub wasn't trashed", fixed)
+		}
+
+		if fixed {
+			// docs still has a.txt in it
+			if err == nil || docs.Labels.Trashed {
+				t.Errorf("got %v with docs trashed %v, want an error and docs kept", err, docs.Labels.Trashed)
+			}
+			continue
+		}
+		if err != nil || !docs.Labels.Trashed {
+			t.Errorf("got %v with docs trashed %v, want docs trashed", err, docs.Labels.Trashed)
+		}
+		if got := fd.child(destDocs.Id, "a.txt"); got == nil || got.Id != a.Id {
+			t.Errorf("a.txt wasn't merged into b/docs")
+		}
+	}
+}
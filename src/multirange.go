@@ -0,0 +1,168 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDownloadWorkers is the default number of ranges of a file downloaded at a time
+	DefaultDownloadWorkers = 4
+	// DefaultDownloadChunkSize is the default size of each range of a file downloaded
+	DefaultDownloadChunkSize = "16MB"
+	// MultiRangeDownloadThreshold is the size from which files are downloaded
+	// in ranges, smaller ones download about as fast in a single stream.
+	MultiRangeDownloadThreshold = 64 * 1024 * 1024
+
+	// MaxRangeRetryCount is the most times a failed range is retried
+	MaxRangeRetryCount = 4
+)
+
+// byteRange is an inclusive range of the bytes of a file.
+type byteRange struct {
+	start, end int64
+}
+
+func (br byteRange) size() int64 {
+	return br.end - br.start + 1
+}
+
+func splitRanges(size, chunkSize int64) []byteRange {
+	var ranges []byteRange
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+	}
+	return ranges
+}
+
+// offsetWriter writes to w from off onwards.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.w.WriteAt(p, ow.off)
+	ow.off += int64(n)
+	return n, err
+}
+
+// downloadRange downloads the bytes in br of the content at url into w
+// at the same offsets.
+func (r *Remote) downloadRange(url string, br byteRange, w io.WriterAt) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", br.start, br.end))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A 200 would be the whole content, ranges must be honored
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("download: range %d-%d of \"%s\". StatusCode: %v", br.start, br.end, url, resp.StatusCode)
+	}
+
+	n, err := io.Copy(&offsetWriter{w: w, off: br.start}, r.downloadLimit.reader(io.LimitReader(resp.Body, br.size())))
+	if err != nil {
+		return err
+	}
+	if n != br.size() {
+		return fmt.Errorf("download: range %d-%d of \"%s\": got %d bytes", br.start, br.end, url, n)
+	}
+	return nil
+}
+
+// multiRangeDownload downloads the file in dlArg in ranges of opts.DownloadChunkSize,
+// opts.DownloadWorkers of them at a time, straight into their place in the file.
+// Each range is retried with backoff on failure, without starting the others over.
+func (g *Commands) multiRangeDownload(dlArg *downloadArg) error {
+	fo, err := os.Create(dlArg.path)
+	if err != nil {
+		return err
+	}
+	defer fo.Close()
+
+	if err := fo.Truncate(dlArg.size); err != nil {
+		return err
+	}
+
+	ranges := make(chan byteRange)
+	go func() {
+		defer close(ranges)
+		for _, br := range splitRanges(dlArg.size, g.opts.DownloadChunkSize) {
+			ranges <- br
+		}
+	}()
+
+	var mu sync.Mutex
+	var composedError error = nil
+
+	var wg sync.WaitGroup
+	for i := 0; i < g.opts.DownloadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for br := range ranges {
+				err := g.rem.downloadRange(dlArg.blobURL, br, fo)
+				for retry := 1; err != nil && retry <= MaxRangeRetryCount; retry++ {
+					time.Sleep(time.Duration(1<<uint(retry)) * time.Second)
+					err = g.rem.downloadRange(dlArg.blobURL, br, fo)
+				}
+
+				if err != nil {
+					mu.Lock()
+					composedError = reComposeError(composedError, err.Error())
+					mu.Unlock()
+					continue
+				}
+
+				n := 0
+				if dlArg.ackByteProgress {
+					n = int(br.size())
+					dlArg.progress.add(br.size())
+				}
+				g.rem.progressChan <- n
+			}
+		}()
+	}
+	wg.Wait()
+
+	return composedError
+}
+
+// multiRange reports whether the file in dlArg is to be downloaded in ranges.
+// Compressed or encrypted content is undone as it streams in, so it is downloaded whole.
+func (g *Commands) multiRange(dlArg *downloadArg) bool {
+	if dlArg.blobURL == "" || dlArg.exportURL != "" || dlArg.transformed != nil {
+		return false
+	}
+	return g.opts.DownloadWorkers > 1 && g.opts.DownloadChunkSize > 0 &&
+		dlArg.size >= MultiRangeDownloadThreshold
+}
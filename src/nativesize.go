@@ -0,0 +1,151 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	NativeSizeExclude = "exclude"
+	NativeSizeNominal = "nominal"
+	NativeSizeExport  = "export"
+
+	nativeSizeTitle = "Google native files sized"
+)
+
+// nativeSizer decides how Google native files, e.g Docs and Sheets, which
+// report no size, count towards sizes. As per its policy they are either
+// excluded from sizes, counted at a fixed nominal size or counted at the
+// size of their export, which takes a request for each file.
+type nativeSizer struct {
+	sync.Mutex
+	policy  string
+	nominal int64
+	// exportSizes are the sizes of the exports looked up, by file id
+	exportSizes map[string]int64
+}
+
+// parseNativeSizePolicy parses spec, one of "exclude", "nominal=SIZE"
+// e.g "nominal=1MB" or "export". An empty spec is "exclude".
+func parseNativeSizePolicy(spec string) (*nativeSizer, error) {
+	ns := &nativeSizer{policy: NativeSizeExclude, exportSizes: make(map[string]int64)}
+	switch {
+	case spec == "", spec == NativeSizeExclude:
+	case spec == NativeSizeExport:
+		ns.policy = NativeSizeExport
+	case strings.HasPrefix(spec, NativeSizeNominal+"="):
+		nominal, err := ParseByteSize(spec[len(NativeSizeNominal+"="):])
+		if err != nil {
+			return nil, fmt.Errorf("native-size: %s: %v", spec, err)
+		}
+		ns.policy, ns.nominal = NativeSizeNominal, nominal
+	default:
+		return nil, fmt.Errorf("native-size: unknown policy %q, expecting %s, %s=SIZE or %s",
+			spec, NativeSizeExclude, NativeSizeNominal, NativeSizeExport)
+	}
+	return ns, nil
+}
+
+// prepareNativeSizer sets up the sizing of Google native files as per opts.
+func (g *Commands) prepareNativeSizer() (err error) {
+	g.nativeSizer, err = parseNativeSizePolicy(g.opts.NativeSizePolicy)
+	return err
+}
+
+func (ns *nativeSizer) String() string {
+	if ns.policy == NativeSizeNominal {
+		return fmt.Sprintf("%s=%s", ns.policy, prettyBytes(ns.nominal))
+	}
+	return ns.policy
+}
+
+// sizeOf returns the size that f counts towards sizes, and whether it counts
+// at all, which a Google native file doesn't if excluded or its export size
+// can't be had. Size filters and estimates are to leave out uncounted files.
+func (g *Commands) sizeOf(f *File) (size int64, counted bool) {
+	if !hasExportLinks(f) {
+		return f.Size, true
+	}
+
+	ns := g.nativeSizer
+	if ns == nil {
+		return 0, false
+	}
+
+	switch ns.policy {
+	case NativeSizeNominal:
+		return ns.nominal, true
+	case NativeSizeExport:
+		return g.exportSize(f)
+	}
+	return 0, false
+}
+
+// exportSize looks up the size of the export of f, preferring PDF
+// exports, from the length reported for it without downloading it.
+func (g *Commands) exportSize(f *File) (int64, bool) {
+	ns := g.nativeSizer
+	ns.Lock()
+	size, known := ns.exportSizes[f.Id]
+	ns.Unlock()
+	if known {
+		return size, size >= 0
+	}
+
+	exportURL, ok := f.ExportLinks["application/pdf"]
+	if !ok {
+		var mimeTypes []string
+		for mimeType := range f.ExportLinks {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+		sort.Strings(mimeTypes)
+		exportURL = f.ExportLinks[mimeTypes[0]]
+	}
+
+	size, err := g.rem.contentLength(exportURL)
+	if err != nil {
+		size = -1
+	}
+
+	ns.Lock()
+	ns.exportSizes[f.Id] = size
+	ns.Unlock()
+	return size, size >= 0
+}
+
+// sizeWith is the size of f as per sizeOf, or as reported if sizeOf is nil.
+func sizeWith(sizeOf func(*File) (int64, bool), f *File) int64 {
+	if sizeOf == nil {
+		return f.Size
+	}
+	size, _ := sizeOf(f)
+	return size
+}
+
+// noteNativeSize reports how the Google native file f was sized.
+func (g *Commands) noteNativeSize(f *File) {
+	if !hasExportLinks(f) || g.nativeSizer == nil {
+		return
+	}
+	if _, counted := g.sizeOf(f); counted {
+		g.report.count(nativeSizeTitle, g.nativeSizer.String())
+	} else {
+		g.report.count(nativeSizeTitle, "excluded from sizes")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestNativeSizes(t *testing.T) {
+	doc := &File{Id: "doc", MimeType: GoogleAppsMimeTypePrefix + "document", ExportLinks: map[string]string{"application/pdf": "x"}}
+	blob := &File{Id: "blob", Size: 2048}
+	changes := []*Change{{Src: doc}, {Src: blob}}
+
+	tests := []struct {
+		spec    string
+		docSize int64
+		counted bool
+	}{
+		{"", 0, false},
+		{NativeSizeExclude, 0, false},
+		{"nominal=1KB", 1024, true},
+	}
+	for _, tt := range tests {
+		g := &Commands{opts: &Options{NativeSizePolicy: tt.spec}}
+		if err := g.prepareNativeSizer(); err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if size, counted := g.sizeOf(doc); size != tt.docSize || counted != tt.counted {
+			t.Errorf("%q: sizeOf(doc) = %d, %v, want %d, %v", tt.spec, size, counted, tt.docSize, tt.counted)
+		}
+		if size, counted := g.sizeOf(blob); size != blob.Size || !counted {
+			t.Errorf("%q: sizeOf(blob) = %d, %v, want %d, true", tt.spec, size, counted, blob.Size)
+		}
+		if src, _ := reduceToSize(changes, SelectSrc, g.sizeOf); src != blob.Size+tt.docSize {
+			t.Errorf("%q: reduceToSize = %d, want %d", tt.spec, src, blob.Size+tt.docSize)
+		}
+		if ops := opChangeCount([]*Change{{Src: doc}}, g.sizeOf); ops[OpAdd].src != tt.docSize {
+			t.Errorf("%q: opChangeCount = %d, want %d", tt.spec, ops[OpAdd].src, tt.docSize)
+		}
+	}
+
+	for _, spec := range []string{"nominal", "nominal=lots", "guess"} {
+		g := &Commands{opts: &Options{NativeSizePolicy: spec}}
+		if err := g.prepareNativeSizer(); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+const (
+	ocrSection = "OCR"
+	ocrSkipped = "OCR skipped"
+)
+
+// ocrMimeTypes are the types of the uploads that Drive attempts OCR on.
+var ocrMimeTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+}
+
+func ocrable(mimeType string) bool {
+	return ocrMimeTypes[mimeType]
+}
+
+// startOCR sets up the tally of the outcomes of OCR if -ocr is set.
+func (g *Commands) startOCR() {
+	if ocr(g.opts.TypeMask) && g.report == nil {
+		g.report = newReport()
+	}
+}
+
+// finishOCR summarizes the outcomes of OCR, unless
+// they are summarized along with verified transfers.
+func (g *Commands) finishOCR() {
+	if ocr(g.opts.TypeMask) && !g.opts.Verify {
+		g.report.summarize(g.log)
+	}
+}
+
+// prepareOCR only leaves OCR requested for uploads of mimeType that Drive can
+// OCR, others are uploaded as they are, and sets the language to OCR in.
+func (g *Commands) prepareOCR(relPath, mimeType string, args *upsertOpt) {
+	if !ocr(args.mask) || args.src == nil || args.src.IsDir {
+		return
+	}
+	if !ocrable(mimeType) {
+		args.mask &^= OptOCR
+		if mimeType == "" {
+			mimeType = "unknown type"
+		}
+		g.report.count(ocrSection, "skipped")
+		g.report.note(ocrSkipped, "%s: %s isn't OCR-able, uploaded as is", relPath, mimeType)
+		return
+	}
+	args.ocrLanguage = g.opts.OCRLanguage
+}
+
+// ocrDone reports whether OCR turned the upload into a searchable document.
+func (g *Commands) ocrDone(relPath string, args *upsertOpt, rem *File) {
+	if !ocr(args.mask) || rem == nil || rem.IsDir {
+		return
+	}
+	if isGoogleNative(rem) {
+		g.report.count(ocrSection, "converted")
+		return
+	}
+	g.report.count(ocrSection, "not converted")
+	g.report.warn("OCR failed", "%s: uploaded as %s", relPath, rem.MimeType)
+}
@@ -29,13 +29,15 @@ const (
 
 type opener func(string) error
 
-func (g *Commands) Open(ot OpenType) error {
+// Open opens each of opts.Sources in the local file manager and, by
+// its link of linkType, in the default browser as per ot.
+func (g *Commands) Open(ot OpenType, linkType string) error {
 	byId := (ot & IdOpen) != 0
 	kvChan := g.urler(byId)
 
 	for kv := range kvChan {
-		switch kv.value.(type) {
-		case error:
+		f, ok := kv.value.(*File)
+		if !ok {
 			g.log.LogErrf("%s: %s\n", kv.key, kv.value)
 			continue
 		}
@@ -50,8 +52,10 @@ func (g *Commands) Open(ot OpenType) error {
 		}
 
 		if canAddUrl {
-			if castKey, ok := kv.value.(string); ok {
-				openArgs = append(openArgs, castKey)
+			if link := fileLink(f, linkType); link != "" {
+				openArgs = append(openArgs, link)
+			} else {
+				g.log.LogErrf("%s: no %s link\n", kv.key, linkType)
 			}
 		}
 
@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	PartitionAlpha = "alpha"
+	PartitionDate  = "date"
+)
+
+// partitioner derives the name of the subfolder, relative to the copy
+// destination, that a file should be routed into.
+type partitioner func(f *File) string
+
+var partitionPlaceholderRegexp = regexp.MustCompile("{[^{}]*}")
+
+var partitionPlaceholders = map[string]func(f *File) string{
+	"{initial}": alphaPartition,
+	"{year}":    func(f *File) string { return f.ModTime.Format("2006") },
+	"{month}":   func(f *File) string { return f.ModTime.Format("01") },
+	"{day}":     func(f *File) string { return f.ModTime.Format("02") },
+	"{ext}": func(f *File) string {
+		ext := strings.TrimPrefix(filepath.Ext(f.Name), ".")
+		if ext == "" {
+			return "_"
+		}
+		return strings.ToLower(ext)
+	},
+}
+
+func alphaPartition(f *File) string {
+	r, _ := utf8.DecodeRuneInString(f.Name)
+	switch {
+	case unicode.IsLetter(r):
+		return string(unicode.ToUpper(r))
+	case unicode.IsDigit(r):
+		return "0-9"
+	}
+	return "_"
+}
+
+func datePartition(f *File) string {
+	return f.ModTime.Format("2006-01")
+}
+
+// parsePartitioner resolves the built-in partitioners by name, otherwise
+// treats spec as a template of placeholders e.g "{year}/{month}".
+func parsePartitioner(spec string) (partitioner, error) {
+	switch spec {
+	case "":
+		return nil, nil
+	case PartitionAlpha:
+		return alphaPartition, nil
+	case PartitionDate:
+		return datePartition, nil
+	}
+
+	placeholders := partitionPlaceholderRegexp.FindAllString(spec, -1)
+	if len(placeholders) < 1 {
+		return nil, fmt.Errorf("partition: %q is neither a known partitioner nor a template", spec)
+	}
+	for _, placeholder := range placeholders {
+		if _, ok := partitionPlaceholders[placeholder]; !ok {
+			return nil, fmt.Errorf("partition: unknown placeholder %s in %q", placeholder, spec)
+		}
+	}
+
+	return func(f *File) string {
+		return partitionPlaceholderRegexp.ReplaceAllStringFunc(spec, func(placeholder string) string {
+			return partitionPlaceholders[placeholder](f)
+		})
+	}, nil
+}
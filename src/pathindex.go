@@ -0,0 +1,328 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	PathIndexSuffix = "paths.db"
+
+	pathIndexPathsBucket = "paths"
+	pathIndexIdsBucket   = "ids"
+	pathIndexMetaBucket  = "meta"
+
+	pathIndexChangeIdKey = "largestChangeId"
+
+	// pathIndexOpenTimeout is how long to wait for another drive
+	// process to let go of the index before going without it.
+	pathIndexOpenTimeout = time.Second
+)
+
+// pathIndexEntry is what is known of the item at a path.
+type pathIndexEntry struct {
+	Id          string    `json:"id"`
+	ParentId    string    `json:"parentId"`
+	Md5Checksum string    `json:"md5Checksum,omitempty"`
+	ModTime     time.Time `json:"modTime"`
+}
+
+// pathIndex caches the ids of the items at remote paths so that resolving
+// a path takes a single lookup of the item instead of a listing per segment.
+// Entries are only trusted after checking that the item is still named and
+// placed as recorded. Entries of items that changed since the last run are
+// dropped as per the Changes feed the first time the index is used, and
+// those of items renamed, moved or removed during the run as they are.
+// A nil *pathIndex indexes nothing.
+type pathIndex struct {
+	db        *bolt.DB
+	refresher sync.Once
+}
+
+// pathIndexPath is where the index of the account of context is kept,
+// each profile being that of an account of its own e.g .gd/work.paths.db.
+func pathIndexPath(context *config.Context) string {
+	name := PathIndexSuffix
+	if context.Profile != "" {
+		name = fmt.Sprintf("%s.%s", context.Profile, PathIndexSuffix)
+	}
+	return path.Join(context.AbsPathOf(""), config.GDDirSuffix, name)
+}
+
+func openPathIndex(p string) (*pathIndex, error) {
+	db, err := bolt.Open(p, config.O_RWForAll, &bolt.Options{Timeout: pathIndexOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{pathIndexPathsBucket, pathIndexIdsBucket, pathIndexMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(byteify(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &pathIndex{db: db}, nil
+}
+
+// refresh drops the entries of the items that changed since the index was
+// last used, or all of them if the changes since then can't be listed.
+func (pi *pathIndex) refresh(r *Remote) {
+	if pi == nil {
+		return
+	}
+
+	pi.refresher.Do(func() {
+		var lastChangeId int64 = -1
+		pi.db.View(func(tx *bolt.Tx) error {
+			data := tx.Bucket(byteify(pathIndexMetaBucket)).Get(byteify(pathIndexChangeIdKey))
+			if id, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+				lastChangeId = id
+			}
+			return nil
+		})
+
+		var changedIds []string
+		var largestChangeId int64
+		reset := lastChangeId < 0
+		if !reset {
+			changes, largest, err := r.changes(lastChangeId + 1)
+			if err != nil {
+				reset = true
+			}
+			for _, ch := range changes {
+				changedIds = append(changedIds, ch.FileId)
+			}
+			largestChangeId = largest
+		}
+		if reset {
+			about, err := r.About()
+			if err != nil {
+				return
+			}
+			largestChangeId = about.LargestChangeId
+		}
+
+		pi.db.Update(func(tx *bolt.Tx) error {
+			if reset {
+				for _, name := range []string{pathIndexPathsBucket, pathIndexIdsBucket} {
+					if err := tx.DeleteBucket(byteify(name)); err != nil && err != bolt.ErrBucketNotFound {
+						return err
+					}
+					if _, err := tx.CreateBucket(byteify(name)); err != nil {
+						return err
+					}
+				}
+			}
+			for _, id := range changedIds {
+				if err := forgetPathIndexId(tx, id); err != nil {
+					return err
+				}
+			}
+			changeId := strconv.FormatInt(largestChangeId, 10)
+			return tx.Bucket(byteify(pathIndexMetaBucket)).Put(byteify(pathIndexChangeIdKey), byteify(changeId))
+		})
+	})
+}
+
+func (pi *pathIndex) get(p string) *pathIndexEntry {
+	if pi == nil {
+		return nil
+	}
+
+	var entry *pathIndexEntry
+	pi.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(byteify(pathIndexPathsBucket)).Get(byteify(p))
+		if data == nil {
+			return nil
+		}
+		entry = &pathIndexEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			entry = nil
+		}
+		return nil
+	})
+	return entry
+}
+
+func (pi *pathIndex) put(p, parentId string, f *File) {
+	if pi == nil || f == nil {
+		return
+	}
+
+	entry := &pathIndexEntry{
+		Id:          f.Id,
+		ParentId:    parentId,
+		Md5Checksum: f.Md5Checksum,
+		ModTime:     f.ModTime,
+	}
+	pi.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(byteify(pathIndexPathsBucket)).Put(byteify(p), data); err != nil {
+			return err
+		}
+
+		// An item in many folders is at many paths
+		ids := tx.Bucket(byteify(pathIndexIdsBucket))
+		var paths []string
+		if data := ids.Get(byteify(f.Id)); data != nil {
+			json.Unmarshal(data, &paths)
+		}
+		for _, known := range paths {
+			if known == p {
+				return nil
+			}
+		}
+		if data, err = json.Marshal(append(paths, p)); err != nil {
+			return err
+		}
+		return ids.Put(byteify(f.Id), data)
+	})
+}
+
+// forget drops the entries of the item with id, and of its descendants.
+func (pi *pathIndex) forget(id string) {
+	if pi == nil {
+		return
+	}
+	pi.db.Update(func(tx *bolt.Tx) error {
+		return forgetPathIndexId(tx, id)
+	})
+}
+
+// forgetPath drops the entry at p, and those of its descendants.
+func (pi *pathIndex) forgetPath(p string) {
+	if pi == nil {
+		return
+	}
+	pi.db.Update(func(tx *bolt.Tx) error {
+		return forgetPathIndexPrefix(tx, p)
+	})
+}
+
+func forgetPathIndexId(tx *bolt.Tx, id string) error {
+	ids := tx.Bucket(byteify(pathIndexIdsBucket))
+	data := ids.Get(byteify(id))
+	if data == nil {
+		return nil
+	}
+
+	var paths []string
+	json.Unmarshal(data, &paths)
+	for _, p := range paths {
+		if err := forgetPathIndexPrefix(tx, p); err != nil {
+			return err
+		}
+	}
+	return ids.Delete(byteify(id))
+}
+
+func forgetPathIndexPrefix(tx *bolt.Tx, p string) error {
+	paths := tx.Bucket(byteify(pathIndexPathsBucket))
+	if err := paths.Delete(byteify(p)); err != nil {
+		return err
+	}
+
+	// Keys are sorted so the descendants of p are the run of keys prefixed
+	// with it. Their ids' entries are left be as they only point back here.
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	var descendants [][]byte
+	c := paths.Cursor()
+	for k, _ := c.Seek(byteify(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Next() {
+		descendants = append(descendants, append([]byte{}, k...))
+	}
+	for _, k := range descendants {
+		if err := paths.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveIndexed returns the item at p as per the index, if it is still there.
+func (r *Remote) resolveIndexed(p string) *File {
+	entry := r.index.get(p)
+	if entry == nil {
+		return nil
+	}
+
+	f, err := r.service.Files.Get(entry.Id).Fields(remoteFileFields).Do()
+	stillThere := err == nil && f.Title == urlToPath(lastPathSegment(p), false) &&
+		(f.Labels == nil || !f.Labels.Trashed)
+	if stillThere {
+		stillThere = false
+		for _, parent := range f.Parents {
+			if parent.Id == entry.ParentId || (parent.IsRoot && entry.ParentId == "root") {
+				stillThere = true
+				break
+			}
+		}
+	}
+	if !stillThere {
+		r.index.forgetPath(p)
+		return nil
+	}
+	return NewRemoteFile(f)
+}
+
+func lastPathSegment(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+// findByPathIndexed resolves p starting off from the deepest of its ancestors
+// in the index, indexing each segment resolved along the way.
+func (r *Remote) findByPathIndexed(p string) (*File, error) {
+	r.index.refresh(r)
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	resolved := len(parts)
+	parentId := "root"
+
+	var f *File
+	for ; resolved > 0; resolved-- {
+		if f = r.resolveIndexed("/" + strings.Join(parts[:resolved], "/")); f != nil {
+			parentId = f.Id
+			break
+		}
+	}
+
+	for ; resolved < len(parts); resolved++ {
+		var err error
+		if f, err = r.findByPathRecv(parentId, parts[resolved:resolved+1]); err != nil {
+			return nil, err
+		}
+		r.index.put("/"+strings.Join(parts[:resolved+1], "/"), parentId, f)
+		parentId = f.Id
+	}
+	return f, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"unicode/utf8"
+)
+
+// longestRelPath returns the longest path, relative to the folder
+// with id folderId, of any of its descendants.
+func (g *Commands) longestRelPath(folderId string) string {
+	longest := ""
+	for child := range g.rem.findChildren(folderId, false) {
+		relPath := child.Name
+		if child.IsDir {
+			if rest := g.longestRelPath(child.Id); rest != "" {
+				relPath = path.Join(child.Name, rest)
+			}
+		}
+		if utf8.RuneCountInString(relPath) > utf8.RuneCountInString(longest) {
+			longest = relPath
+		}
+	}
+	return longest
+}
+
+// checkPathLength fails if f at newPath, along with its descendants if it is
+// a folder, makes for any path longer than opts.MaxPathLength characters, in
+// which case it suggests how short f's name has to be to fit.
+func (g *Commands) checkPathLength(f *File, newPath string) error {
+	maxLen := g.opts.MaxPathLength
+	if maxLen <= 0 {
+		return nil
+	}
+
+	longest := newPath
+	if f.IsDir {
+		if relPath := g.longestRelPath(f.Id); relPath != "" {
+			longest = path.Join(newPath, relPath)
+		}
+	}
+
+	n := utf8.RuneCountInString(longest)
+	if n <= maxLen {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s would be %d characters long, over the max path length of %d", longest, n, maxLen)
+	if fits := utf8.RuneCountInString(path.Base(newPath)) - (n - maxLen); fits >= 1 {
+		return fmt.Errorf("%s. Try a name of at most %d characters", message, fits)
+	}
+	return fmt.Errorf("%s, however short its name", message)
+}
@@ -0,0 +1,94 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// pauseGate holds back new copies while paused. Copies already
+// underway carry on to completion.
+type pauseGate struct {
+	sync.Mutex
+	// resumed is closed on resuming, it is nil while not paused
+	resumed chan bool
+}
+
+// toggle pauses the gate if it is open otherwise resumes it,
+// returning whether it is now paused.
+func (pg *pauseGate) toggle() bool {
+	pg.Lock()
+	defer pg.Unlock()
+
+	if pg.resumed == nil {
+		pg.resumed = make(chan bool)
+		return true
+	}
+	close(pg.resumed)
+	pg.resumed = nil
+	return false
+}
+
+// wait blocks for as long as the gate is paused.
+func (pg *pauseGate) wait() {
+	if pg == nil {
+		return
+	}
+	pg.Lock()
+	resumed := pg.resumed
+	pg.Unlock()
+
+	if resumed != nil {
+		<-resumed
+	}
+}
+
+// watchPauseSignals pauses and resumes copying each time the process gets
+// one of the pauseSignals. The returned func stops the watching.
+func (g *Commands) watchPauseSignals() (func(), error) {
+	if len(pauseSignals) < 1 {
+		return nil, fmt.Errorf("pausing isn't supported on this platform")
+	}
+
+	g.pauseGate = &pauseGate{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, pauseSignals...)
+
+	g.log.Logf("Send %v to process %d e.g `kill -%s %d` to pause and again to resume\n",
+		pauseSignals[0], os.Getpid(), pauseSignalName, os.Getpid())
+
+	go func() {
+		for _ = range signals {
+			at := time.Now().Format(time.Kitchen)
+			if g.pauseGate.toggle() {
+				g.log.LogErrf("%s: paused, copies underway will finish\n", at)
+				g.report.count("Pauses", "paused")
+			} else {
+				g.log.LogErrf("%s: resumed\n", at)
+				g.report.count("Pauses", "resumed")
+			}
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+	return stop, nil
+}
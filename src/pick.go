@@ -0,0 +1,99 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	DestPickNewest = "newest"
+	DestPickOldest = "oldest"
+)
+
+type byCreatedTime []*File
+
+func (fl byCreatedTime) Len() int      { return len(fl) }
+func (fl byCreatedTime) Swap(i, j int) { fl[i], fl[j] = fl[j], fl[i] }
+func (fl byCreatedTime) Less(i, j int) bool {
+	if fl[i].CreatedTime.Equal(fl[j].CreatedTime) {
+		return fl[i].ModTime.Before(fl[j].ModTime)
+	}
+	return fl[i].CreatedTime.Before(fl[j].CreatedTime)
+}
+
+// pickDest settles which folder destPath resolves to when there are many
+// same-named folders at it, picking the newest or oldest by creation time as
+// per opts.DestPick. Everything copied or moved to, or under, destPath then
+// goes into that one folder.
+func (g *Commands) pickDest(destPath string) error {
+	if g.opts.DestPick == "" {
+		return nil
+	}
+	// A destination given by id is already the one folder
+	if pin, rest := g.rem.pinned(destPath); pin != nil && len(rest) < 1 {
+		return nil
+	}
+
+	matches, err := g.rem.findAllByPath(destPath)
+	if err == ErrPathNotExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", destPath, err)
+	}
+
+	var folders []*File
+	for _, match := range matches {
+		if match.IsDir {
+			folders = append(folders, match)
+		}
+	}
+	if len(folders) < 2 {
+		return nil
+	}
+
+	sort.Sort(byCreatedTime(folders))
+	picked := folders[0]
+	if g.opts.DestPick == DestPickNewest {
+		picked = folders[len(folders)-1]
+	}
+
+	g.rem.pinPath(destPath, picked)
+	g.log.Logf("%s is %d folders, using the %s (%s) created %s\n", destPath, len(folders),
+		g.opts.DestPick, picked.Id, picked.CreatedTime.Local().Format(time.RFC822))
+	return nil
+}
+
+// findDest looks up the destination of a move or copy, failing if many items
+// go by its path, which one of them the items would end up in is unknown,
+// unless one of them was picked or name clashes are ignored.
+func (g *Commands) findDest(destPath string) (*File, error) {
+	destFile, err := g.rem.FindByPath(destPath)
+	if err != nil || g.opts.IgnoreNameClashes {
+		return destFile, err
+	}
+	if pin, _ := g.rem.pinned(destPath); pin != nil {
+		return destFile, nil
+	}
+
+	matches, err := g.rem.findAllByPath(destPath)
+	if err == nil && len(matches) > 1 {
+		return nil, ErrPathAmbiguous
+	}
+	return destFile, nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	PlanOpMkdir        = "mkdir"
+	PlanOpCopy         = "copy"
+	PlanOpInsertParent = "insert-parent"
+	PlanOpRemoveParent = "remove-parent"
+	PlanOpReparent     = "reparent"
+	PlanOpRename       = "rename"
+	PlanOpSetProperty  = "set-property"
+	PlanOpTrash        = "trash"
+	PlanOpTouch        = "touch"
+	PlanOpShare        = "share"
+	PlanOpUnshare      = "unshare"
+	PlanOpReupload     = "reupload"
+	PlanOpRestrict     = "restrict"
+	PlanOpStar         = "star"
+	PlanOpDescribe     = "describe"
+)
+
+// mutator is the set of remote mutations that move, copy and rename are
+// made of. *Remote applies them, a *plan only records them.
+type mutator interface {
+	UpsertByComparison(args *upsertOpt) (*File, error)
+	copy(newName, parentId string, srcFile *File) (*File, error)
+	reupload(newName, parentId string, srcFile *File, exportURL string, convert bool) (*File, error)
+	insertContent(body io.Reader, newName, parentId string, srcFile *File, convert bool) (*File, error)
+	insertParent(fileId, parentId string) error
+	removeParent(fileId, parentId string) error
+	reparent(fileId, fromParentId, toParentId string) error
+	rename(fileId, newTitle string) (*File, error)
+	setAppProperty(fileId, key, value string) error
+	insertPermissions(permInfo *permission) (*drive.Permission, error)
+	deletePermission(fileId, permissionId string) error
+	restrict(fileId string, downloadRestricted, writersCanShare bool) error
+	star(fileId string) error
+	describe(fileId, description string) error
+	Touch(id string) (*File, error)
+	Trash(id string) error
+}
+
+type planOp struct {
+	op string
+	// args are the ids and values that the op is applied with
+	args []string
+}
+
+// plan records the mutations of an operation in the order that they
+// would have been made, instead of making them. Files that would have
+// been created are given placeholder ids, e.g "<new-1>", that later
+// operations on them refer to.
+type plan struct {
+	sync.Mutex
+	ops     []*planOp
+	created int
+	// paths are the known paths of the ids referred to, for annotating the ops.
+	paths map[string]string
+}
+
+func newPlan() *plan {
+	return &plan{
+		paths: make(map[string]string),
+	}
+}
+
+func (p *plan) record(op string, args ...string) {
+	p.Lock()
+	defer p.Unlock()
+	p.ops = append(p.ops, &planOp{op: op, args: args})
+}
+
+func (p *plan) placeholder(name string, isDir bool, mimeType string) *File {
+	p.Lock()
+	defer p.Unlock()
+
+	p.created += 1
+	return &File{
+		Id:       fmt.Sprintf("<new-%d>", p.created),
+		Name:     name,
+		IsDir:    isDir,
+		MimeType: mimeType,
+		ModTime:  time.Now(),
+		Copyable: true,
+	}
+}
+
+// isPlaceholder reports whether id is that of a file that only exists in the plan.
+func isPlaceholder(id string) bool {
+	return strings.HasPrefix(id, "<new-")
+}
+
+// knowPath annotates id with its path, for the benefit of reviewers of the plan.
+func (p *plan) knowPath(id, relToRoot string) {
+	if p == nil || id == "" {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.paths[id] = relToRoot
+}
+
+func (p *plan) UpsertByComparison(args *upsertOpt) (*File, error) {
+	if !args.src.IsDir {
+		return nil, fmt.Errorf("plan: uploading %s is not supported", args.src.Name)
+	}
+	created := p.placeholder(args.src.Name, true, DriveFolderMimeType)
+	p.record(PlanOpMkdir, created.Id, args.parentId, args.src.Name)
+	return created, nil
+}
+
+func (p *plan) copy(newName, parentId string, srcFile *File) (*File, error) {
+	created := p.placeholder(newName, false, srcFile.MimeType)
+	p.record(PlanOpCopy, created.Id, srcFile.Id, parentId, newName)
+	return created, nil
+}
+
+func (p *plan) reupload(newName, parentId string, srcFile *File, exportURL string, convert bool) (*File, error) {
+	created := p.placeholder(newName, false, srcFile.MimeType)
+	p.record(PlanOpReupload, created.Id, srcFile.Id, parentId, newName)
+	return created, nil
+}
+
+func (p *plan) insertContent(body io.Reader, newName, parentId string, srcFile *File, convert bool) (*File, error) {
+	created := p.placeholder(newName, false, srcFile.MimeType)
+	p.record(PlanOpReupload, created.Id, srcFile.Id, parentId, newName)
+	return created, nil
+}
+
+func (p *plan) insertParent(fileId, parentId string) error {
+	p.record(PlanOpInsertParent, fileId, parentId)
+	return nil
+}
+
+func (p *plan) removeParent(fileId, parentId string) error {
+	p.record(PlanOpRemoveParent, fileId, parentId)
+	return nil
+}
+
+// reparent is recorded as the insert and removal it amounts to, so that
+// it reads, costs and simulates like any other move.
+func (p *plan) reparent(fileId, fromParentId, toParentId string) error {
+	p.record(PlanOpInsertParent, fileId, toParentId)
+	p.record(PlanOpRemoveParent, fileId, fromParentId)
+	return nil
+}
+
+func (p *plan) rename(fileId, newTitle string) (*File, error) {
+	p.record(PlanOpRename, fileId, newTitle)
+	return &File{Id: fileId, Name: newTitle}, nil
+}
+
+func (p *plan) setAppProperty(fileId, key, value string) error {
+	p.record(PlanOpSetProperty, fileId, key, value)
+	return nil
+}
+
+func (p *plan) insertPermissions(permInfo *permission) (*drive.Permission, error) {
+	p.record(PlanOpShare, permInfo.fileId, permInfo.value, permInfo.role.String())
+	return &drive.Permission{Value: permInfo.value, Role: permInfo.role.String()}, nil
+}
+
+func (p *plan) deletePermission(fileId, permissionId string) error {
+	p.record(PlanOpUnshare, fileId, permissionId)
+	return nil
+}
+
+func (p *plan) restrict(fileId string, downloadRestricted, writersCanShare bool) error {
+	p.record(PlanOpRestrict, fileId, fmt.Sprintf("restricted=%v", downloadRestricted), fmt.Sprintf("writersCanShare=%v", writersCanShare))
+	return nil
+}
+
+func (p *plan) star(fileId string) error {
+	p.record(PlanOpStar, fileId)
+	return nil
+}
+
+func (p *plan) describe(fileId, description string) error {
+	p.record(PlanOpDescribe, fileId, description)
+	return nil
+}
+
+func (p *plan) Touch(id string) (*File, error) {
+	p.record(PlanOpTouch, id)
+	return &File{Id: id}, nil
+}
+
+func (p *plan) Trash(id string) error {
+	p.record(PlanOpTrash, id)
+	return nil
+}
+
+// writeTo writes the plan out, one tab separated op per line annotated
+// with the known paths of the ids that it refers to, e.g
+//
+//	insert-parent	0B7...	0B3...	# /docs/a.txt /archive
+func (p *plan) writeTo(w io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# %d operations\n", len(p.ops)); err != nil {
+		return err
+	}
+
+	for _, op := range p.ops {
+		var known []string
+		for _, arg := range op.args {
+			if relToRoot, ok := p.paths[arg]; ok {
+				known = append(known, relToRoot)
+			}
+		}
+
+		line := strings.Join(append([]string{op.op}, op.args...), "\t")
+		if len(known) >= 1 {
+			line = fmt.Sprintf("%s\t# %s", line, strings.Join(known, " "))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeTo writes the plan out for reading, one line per operation with
+// the paths involved and the ids it applies to. Adding an item to a folder
+// followed by removing it from another is shown as a single move, e.g
+//
+//	move    /docs/a.txt -> /archive/a.txt  (0B7... from 0B1... into 0B3...)
+func (p *plan) describeTo(w io.Writer) error {
+	p.Lock()
+	defer p.Unlock()
+
+	known := make(map[string]string)
+	for id, relToRoot := range p.paths {
+		known[id] = relToRoot
+	}
+	pathOf := func(id string) string {
+		if relToRoot, ok := known[id]; ok {
+			return relToRoot
+		}
+		return id
+	}
+	created := func(id, parentId, name string) string {
+		createdPath := path.Join(pathOf(parentId), name)
+		known[id] = createdPath
+		return createdPath
+	}
+
+	for i := 0; i < len(p.ops); i++ {
+		op, args := p.ops[i], p.ops[i].args
+
+		var line string
+		switch op.op {
+		case PlanOpMkdir:
+			line = fmt.Sprintf("mkdir   %s  (%s)", created(args[0], args[1], args[2]), args[0])
+		case PlanOpCopy, PlanOpReupload:
+			line = fmt.Sprintf("%-7s %s -> %s  (%s as %s)", op.op, pathOf(args[1]), created(args[0], args[2], args[3]), args[1], args[0])
+		case PlanOpInsertParent:
+			var next *planOp
+			if i+1 < len(p.ops) {
+				next = p.ops[i+1]
+			}
+			if next == nil || next.op != PlanOpRemoveParent || next.args[0] != args[0] {
+				line = fmt.Sprintf("link    %s into %s  (%s into %s)", pathOf(args[0]), pathOf(args[1]), args[0], args[1])
+				break
+			}
+			i += 1
+			oldPath := pathOf(args[0])
+			newPath := path.Join(pathOf(args[1]), path.Base(oldPath))
+			line = fmt.Sprintf("move    %s -> %s  (%s from %s into %s)", oldPath, newPath, args[0], next.args[1], args[1])
+			known[args[0]] = newPath
+		case PlanOpRemoveParent:
+			line = fmt.Sprintf("unlink  %s from %s  (%s from %s)", pathOf(args[0]), pathOf(args[1]), args[0], args[1])
+		case PlanOpRename:
+			oldPath := pathOf(args[0])
+			newPath := path.Join(path.Dir(oldPath), args[1])
+			line = fmt.Sprintf("rename  %s -> %s  (%s)", oldPath, newPath, args[0])
+			known[args[0]] = newPath
+		default:
+			line = fmt.Sprintf("%-7s %s  (%s)", op.op, pathOf(args[0]), strings.Join(args, " "))
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushPlan writes out the plan recorded, if any, to opts.PlanPath
+// or to stdout if that path is "-", after describing the plan if
+// opts.DryRun is set and logging its tree diff if opts.TreeDiff is set.
+func (g *Commands) flushPlan() error {
+	if g.plan == nil {
+		return nil
+	}
+
+	if g.opts.DryRun {
+		if err := g.plan.describeTo(os.Stdout); err != nil {
+			return fmt.Errorf("dry-run: %v", err)
+		}
+	}
+	if g.opts.TreeDiff {
+		g.logTreeDiff()
+	}
+	if g.opts.PlanPath == "" {
+		g.log.Logf("%d operations planned, nothing was changed\n", len(g.plan.ops))
+		return nil
+	}
+
+	if g.opts.PlanPath == "-" {
+		return g.plan.writeTo(os.Stdout)
+	}
+
+	f, err := os.Create(g.opts.PlanPath)
+	if err != nil {
+		return fmt.Errorf("plan: %v", err)
+	}
+	defer f.Close()
+
+	if err := g.plan.writeTo(f); err != nil {
+		return fmt.Errorf("plan: %v", err)
+	}
+	g.log.Logf("Plan of %d operations written to %s, nothing was changed\n", len(g.plan.ops), g.opts.PlanPath)
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultPrefetchWorkers = 8
+
+// treeIndex holds the children of the folders of source trees, listed
+// up front so that copying doesn't have to wait on a listing per folder.
+type treeIndex struct {
+	sync.Mutex
+	children map[string][]*File
+}
+
+func (ti *treeIndex) get(folderId string) ([]*File, bool) {
+	if ti == nil {
+		return nil, false
+	}
+	ti.Lock()
+	defer ti.Unlock()
+	children, ok := ti.children[folderId]
+	return children, ok
+}
+
+// lister is a channel of the children of the folder with the given id.
+type lister func(folderId string) chan *File
+
+// prefetchTrees lists the trees under roots, at most workers folders at a time,
+// into an index of the children of each folder. Children come listed along
+// with their checksums, which hence are all at hand once copying starts.
+func (g *Commands) prefetchTrees(roots []*File, workers int) *treeIndex {
+	start := time.Now()
+	ti, fileCount := indexTrees(roots, workers, func(folderId string) chan *File {
+		return g.rem.findChildren(folderId, false)
+	})
+	g.log.Logf("Indexed %d folders and %d files in %v\n", len(ti.children), fileCount, time.Since(start))
+	return ti
+}
+
+// indexTrees walks the trees under roots with list, returning the index
+// of the children of their folders and the number of files in them.
+func indexTrees(roots []*File, workers int, list lister) (*treeIndex, int) {
+	if workers < 1 {
+		workers = DefaultPrefetchWorkers
+	}
+
+	ti := &treeIndex{children: make(map[string][]*File)}
+	seen := make(map[string]bool)
+	slots := make(chan bool, workers)
+	var wg sync.WaitGroup
+	var fileCount int
+
+	var walk func(folder *File)
+	walk = func(folder *File) {
+		defer wg.Done()
+
+		slots <- true
+		var children []*File
+		for child := range list(folder.Id) {
+			children = append(children, child)
+		}
+		<-slots
+
+		ti.Lock()
+		defer ti.Unlock()
+
+		ti.children[folder.Id] = children
+		for _, child := range children {
+			if !child.IsDir {
+				fileCount += 1
+				continue
+			}
+			// Folders with many parents are only walked once
+			if seen[child.Id] {
+				continue
+			}
+			seen[child.Id] = true
+			wg.Add(1)
+			go walk(child)
+		}
+	}
+
+	ti.Lock()
+	for _, root := range roots {
+		if root == nil || !root.IsDir || seen[root.Id] {
+			continue
+		}
+		seen[root.Id] = true
+		wg.Add(1)
+		go walk(root)
+	}
+	ti.Unlock()
+	wg.Wait()
+	return ti, fileCount
+}
+
+// copyChildren is a channel of the children of the folder with id
+// folderId, from the prefetched index if it has them.
+func (g *Commands) copyChildren(folderId string) chan *File {
+	children, ok := g.treeIndex.get(folderId)
+	if !ok {
+		return g.rem.findChildren(folderId, false)
+	}
+
+	childrenChan := make(chan *File, len(children))
+	for _, child := range children {
+		childrenChan <- child
+	}
+	close(childrenChan)
+	return childrenChan
+}
@@ -0,0 +1,123 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeTree is a tree of the given depth whose folders each have fanout
+// folders and files, listed with latency as a stand-in for a round trip.
+type fakeTree struct {
+	children map[string][]*File
+	latency  time.Duration
+	folders  int
+	files    int
+}
+
+func newFakeTree(depth, fanout int, latency time.Duration) *fakeTree {
+	ft := &fakeTree{children: make(map[string][]*File), latency: latency}
+	var grow func(id string, depth int)
+	grow = func(id string, depth int) {
+		ft.folders += 1
+		if depth == 0 {
+			return
+		}
+		for i := 0; i < fanout; i++ {
+			dir := &File{Id: fmt.Sprintf("%s/d%d", id, i), Name: fmt.Sprintf("d%d", i), IsDir: true}
+			file := &File{Id: fmt.Sprintf("%s/f%d", id, i), Name: fmt.Sprintf("f%d", i)}
+			ft.children[id] = append(ft.children[id], dir, file)
+			ft.files += 1
+			grow(dir.Id, depth-1)
+		}
+	}
+	grow("root", depth)
+	return ft
+}
+
+func (ft *fakeTree) root() *File {
+	return &File{Id: "root", Name: "root", IsDir: true}
+}
+
+func (ft *fakeTree) list(folderId string) chan *File {
+	time.Sleep(ft.latency)
+	children := ft.children[folderId]
+	childrenChan := make(chan *File, len(children))
+	for _, child := range children {
+		childrenChan <- child
+	}
+	close(childrenChan)
+	return childrenChan
+}
+
+// walkSequentially is how folders are listed without prefetch, one at a time.
+func walkSequentially(folder *File, list lister) (folders, files int) {
+	folders = 1
+	for child := range list(folder.Id) {
+		if !child.IsDir {
+			files += 1
+			continue
+		}
+		childFolders, childFiles := walkSequentially(child, list)
+		folders += childFolders
+		files += childFiles
+	}
+	return folders, files
+}
+
+func TestIndexTrees(t *testing.T) {
+	ft := newFakeTree(3, 4, 0)
+	root := ft.root()
+	// Roots listed twice or that aren't folders are skipped
+	ti, files := indexTrees([]*File{root, root, nil, {Id: "file"}}, 3, ft.list)
+	if len(ti.children) != ft.folders || files != ft.files {
+		t.Fatalf("indexed %d folders and %d files, want %d and %d", len(ti.children), files, ft.folders, ft.files)
+	}
+	for id, want := range ft.children {
+		got, ok := ti.get(id)
+		if !ok || len(got) != len(want) {
+			t.Errorf("%s: got %d children, want %d", id, len(got), len(want))
+		}
+	}
+	if _, ok := ti.get("root/f0"); ok {
+		t.Errorf("files shouldn't be indexed")
+	}
+}
+
+// With a 2ms round trip for each of the 85 folders of the tree, on a
+// single core, the listings being what takes the time:
+//
+//	BenchmarkListTrees/sequential    183ms
+//	BenchmarkListTrees/prefetch-1    183ms
+//	BenchmarkListTrees/prefetch-8     26ms
+//	BenchmarkListTrees/prefetch-32    11ms
+func BenchmarkListTrees(b *testing.B) {
+	ft := newFakeTree(3, 4, 2*time.Millisecond)
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkSequentially(ft.root(), ft.list)
+		}
+	})
+	for _, workers := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("prefetch-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				indexTrees([]*File{ft.root()}, workers, ft.list)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PreserveMtime is always preserved, pushed files take the local
+	// modification times and pulled files those on Drive.
+	PreserveMtime = 1 << iota
+	// PreserveMode keeps the permission bits of files.
+	PreserveMode
+	// PreserveXattr keeps the extended attributes of files.
+	PreserveXattr
+)
+
+const (
+	// FileModeKey is the app property that keeps the permission bits of
+	// a pushed file, in octal.
+	FileModeKey = "fileMode"
+	// XattrKeyPrefix prefixes the app properties that keep the extended
+	// attributes of a pushed file, base64 encoded.
+	XattrKeyPrefix = "xattr."
+
+	// maxPropertyBytes is how long the key and value of a property can be together
+	maxPropertyBytes = 124
+)
+
+var preserveNames = map[string]int{
+	"mtime": PreserveMtime,
+	"mode":  PreserveMode,
+	"xattr": PreserveXattr,
+}
+
+// propertyKeyRegexp matches the keys that Drive accepts for properties.
+var propertyKeyRegexp = regexp.MustCompile("^[A-Za-z0-9._-]+$")
+
+// ParsePreserve parses a comma separated list of the attributes to
+// preserve e.g "mtime,mode" into a mask. mtime is always preserved.
+func ParsePreserve(spec string) (int, error) {
+	mask := PreserveMtime
+	for _, name := range NonEmptyTrimmedStrings(strings.Split(spec, ",")...) {
+		bit, ok := preserveNames[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown attribute %q to preserve, expecting mtime, mode or xattr", name)
+		}
+		mask |= bit
+	}
+	return mask, nil
+}
+
+// localMetadata are the app properties that keep the attributes of the
+// file at fsPath that are to be preserved. Extended attributes too long
+// for a property are left out with a warning.
+func (g *Commands) localMetadata(relPath, fsPath string) map[string]string {
+	mask := g.opts.PreserveMask
+	if mask&(PreserveMode|PreserveXattr) == 0 {
+		return nil
+	}
+
+	props := make(map[string]string)
+	if mask&PreserveMode != 0 {
+		if info, err := os.Stat(fsPath); err == nil {
+			props[FileModeKey] = fmt.Sprintf("%#o", info.Mode().Perm())
+		}
+	}
+
+	if mask&PreserveXattr != 0 {
+		attrs, err := listXattrs(fsPath)
+		if err != nil && err != errXattrUnsupported {
+			g.log.LogErrf("%s: extended attributes: %v\n", relPath, err)
+		}
+		for name, value := range attrs {
+			key := XattrKeyPrefix + name
+			encoded := base64.StdEncoding.EncodeToString(value)
+			if !propertyKeyRegexp.MatchString(key) || len(key)+len(encoded) > maxPropertyBytes {
+				g.log.LogErrf("%s: extended attribute %s is too long to keep, leaving it out\n", relPath, name)
+				continue
+			}
+			props[key] = encoded
+		}
+	}
+	return props
+}
+
+// restoreMetadata sets the attributes of the file at fsPath that are to be
+// preserved to those kept in the app properties of remote, if it has them.
+func (g *Commands) restoreMetadata(relPath, fsPath string, remote *File) {
+	mask := g.opts.PreserveMask
+	if remote == nil || mask&(PreserveMode|PreserveXattr) == 0 {
+		return
+	}
+
+	if mode, ok := remote.Properties[FileModeKey]; ok && mask&PreserveMode != 0 {
+		perm, err := strconv.ParseUint(mode, 0, 32)
+		if err == nil {
+			err = os.Chmod(fsPath, os.FileMode(perm).Perm())
+		}
+		if err != nil {
+			g.log.LogErrf("%s: restoring mode %s: %v\n", relPath, mode, err)
+		}
+	}
+
+	if mask&PreserveXattr == 0 {
+		return
+	}
+	for key, encoded := range remote.Properties {
+		if !strings.HasPrefix(key, XattrKeyPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, XattrKeyPrefix)
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil {
+			err = setXattr(fsPath, name, value)
+		}
+		if err != nil {
+			g.log.LogErrf("%s: restoring extended attribute %s: %v\n", relPath, name, err)
+			if err == errXattrUnsupported {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/log"
+)
+
+const (
+	// progressRedrawEvery is how often the bars are redrawn on a terminal
+	progressRedrawEvery = 200 * time.Millisecond
+	// progressLogEvery is how often the totals are logged when not on a terminal
+	progressLogEvery = 5 * time.Second
+	// progressMaxBars is the most transfers given a bar at a time
+	progressMaxBars = 8
+
+	progressBarWidth     = 30
+	progressFileBarWidth = 20
+	progressNameWidth    = 28
+)
+
+// transferProgress renders the progress of transfers that run concurrently:
+// a bar for each file in flight and one for the totals, with the current and
+// average throughput and the time left. When stdout is not a terminal it
+// degrades to logging each finished file and, periodically, the totals.
+type transferProgress struct {
+	sync.Mutex
+	logf log.Loggerf
+	tty  bool
+	// counting is set if the units are items rather than bytes
+	counting bool
+
+	total     int64
+	done      int64
+	finished  int
+	startedAt time.Time
+	inFlight  []*fileProgress
+
+	// sampledAt and sampledDone are the time and the total at the last
+	// sample, from which the current throughput, rate, is derived.
+	sampledAt   time.Time
+	sampledDone int64
+	rate        float64
+
+	drawn   int
+	stopped chan bool
+	stopper sync.Once
+	ended   sync.WaitGroup
+}
+
+// fileProgress is the progress of a single transfer.
+type fileProgress struct {
+	p         *transferProgress
+	name      string
+	size      int64
+	done      int64
+	startedAt time.Time
+}
+
+// newTransferProgress starts off rendering the progress of transfers totalling
+// total units. A non-positive total means that it isn't known ahead of time.
+func newTransferProgress(total int64, counting, tty bool, logf log.Loggerf) *transferProgress {
+	now := time.Now()
+	p := &transferProgress{
+		logf:      logf,
+		tty:       tty,
+		counting:  counting,
+		total:     total,
+		startedAt: now,
+		sampledAt: now,
+		stopped:   make(chan bool),
+	}
+
+	every := progressLogEvery
+	if tty {
+		every = progressRedrawEvery
+	}
+
+	p.ended.Add(1)
+	go func() {
+		defer p.ended.Done()
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-p.stopped:
+				return
+			case <-tick.C:
+				p.Lock()
+				p.sample()
+				p.render()
+				p.Unlock()
+			}
+		}
+	}()
+
+	return p
+}
+
+// add counts n units as done towards the total.
+func (p *transferProgress) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	p.done += n
+	p.Unlock()
+}
+
+// start registers the transfer of the file called name, of size bytes.
+func (p *transferProgress) start(name string, size int64) *fileProgress {
+	if p == nil {
+		return nil
+	}
+	fp := &fileProgress{p: p, name: name, size: size, startedAt: time.Now()}
+
+	p.Lock()
+	p.inFlight = append(p.inFlight, fp)
+	p.Unlock()
+
+	return fp
+}
+
+// startChange registers the transfer of the content of c, if it has any.
+func (g *Commands) startChange(c *Change) *fileProgress {
+	if c.Src == nil || c.Src.IsDir {
+		return nil
+	}
+	switch c.Op() {
+	case OpAdd, OpMod, OpModConflict:
+		return g.progress.start(c.Path, c.Src.Size)
+	}
+	return nil
+}
+
+// file retrieves the transfer in flight called name, if any.
+func (p *transferProgress) file(name string) *fileProgress {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	for _, fp := range p.inFlight {
+		if fp.name == name {
+			return fp
+		}
+	}
+	return nil
+}
+
+// add counts n bytes of the file as transferred. The totals are counted
+// separately, by transferProgress.add, since not all transfers are tracked.
+func (fp *fileProgress) add(n int64) {
+	if fp == nil {
+		return
+	}
+	fp.p.Lock()
+	fp.done += n
+	fp.p.Unlock()
+}
+
+// finish retires the transfer from the ones in flight.
+func (fp *fileProgress) finish(err error) {
+	if fp == nil {
+		return
+	}
+	p := fp.p
+	p.Lock()
+	defer p.Unlock()
+
+	for i, other := range p.inFlight {
+		if other == fp {
+			p.inFlight = append(p.inFlight[:i], p.inFlight[i+1:]...)
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+	p.finished += 1
+
+	if !p.tty {
+		elapsed := time.Since(fp.startedAt)
+		p.logf("Done %s %s in %s (%s/s)\n", fp.name, prettyBytes(fp.size),
+			roundDuration(elapsed), prettyBytes(int64(perSecond(fp.size, elapsed))))
+	}
+}
+
+// stop renders the final totals and stops rendering.
+func (p *transferProgress) stop() {
+	if p == nil {
+		return
+	}
+	p.stopper.Do(p.finalize)
+}
+
+func (p *transferProgress) finalize() {
+	close(p.stopped)
+	p.ended.Wait()
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.inFlight = nil
+	p.sample()
+	p.render()
+
+	if !p.tty {
+		return
+	}
+	p.logf("\n")
+}
+
+// sample updates the current throughput as a moving average, so that the
+// time left doesn't swing wildly with every burst.
+func (p *transferProgress) sample() {
+	now := time.Now()
+	elapsed := now.Sub(p.sampledAt)
+	if elapsed <= 0 {
+		return
+	}
+
+	current := perSecond(p.done-p.sampledDone, elapsed)
+	if p.sampledDone == 0 && p.rate == 0 {
+		p.rate = current
+	} else {
+		p.rate = 0.3*current + 0.7*p.rate
+	}
+	p.sampledAt, p.sampledDone = now, p.done
+}
+
+func (p *transferProgress) render() {
+	if !p.tty {
+		p.logf("%s\n", p.totals())
+		return
+	}
+
+	var lines []string
+	for i, fp := range p.inFlight {
+		if i >= progressMaxBars {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(p.inFlight)-i))
+			break
+		}
+		lines = append(lines, fp.line())
+	}
+	lines = append(lines, p.totals())
+
+	// Move back up to redraw over the lines drawn last time
+	if p.drawn > 1 {
+		p.logf("\033[%dA", p.drawn-1)
+	}
+	for i, line := range lines {
+		if i > 0 {
+			p.logf("\n")
+		}
+		p.logf("\r\033[K%s", line)
+	}
+	// Clear any lines left over from a taller drawing
+	for i := len(lines); i < p.drawn; i++ {
+		p.logf("\n\r\033[K")
+	}
+	if extra := p.drawn - len(lines); extra > 0 {
+		p.logf("\033[%dA", extra)
+	}
+	p.drawn = len(lines)
+}
+
+func (p *transferProgress) amount(n int64) string {
+	if p.counting {
+		return fmt.Sprintf("%d", n)
+	}
+	return prettyBytes(n)
+}
+
+func (p *transferProgress) totals() string {
+	elapsed := time.Since(p.startedAt)
+	average := perSecond(p.done, elapsed)
+
+	var parts []string
+	if p.total > 0 {
+		if p.tty {
+			parts = append(parts, progressBar(p.done, p.total, progressBarWidth))
+		}
+		parts = append(parts, fmt.Sprintf("%3d%%", percentOf(p.done, p.total)),
+			fmt.Sprintf("%s/%s", p.amount(p.done), p.amount(p.total)))
+	} else {
+		parts = append(parts, p.amount(p.done))
+	}
+	if p.finished > 0 {
+		parts = append(parts, fmt.Sprintf("%d files", p.finished))
+	}
+	if !p.counting {
+		parts = append(parts, fmt.Sprintf("%s/s (avg %s/s)",
+			prettyBytes(int64(p.rate)), prettyBytes(int64(average))))
+	}
+
+	if left := p.total - p.done; p.total > 0 && left > 0 {
+		rate := p.rate
+		if rate <= 0 {
+			rate = average
+		}
+		if rate > 0 {
+			eta := time.Duration(float64(left) / rate * float64(time.Second))
+			parts = append(parts, fmt.Sprintf("ETA %s", roundDuration(eta)))
+		}
+	} else {
+		parts = append(parts, roundDuration(elapsed).String())
+	}
+
+	return sepJoin(" ", parts...)
+}
+
+func (fp *fileProgress) line() string {
+	name := fp.name
+	if len(name) > progressNameWidth {
+		name = "..." + name[len(name)-progressNameWidth+3:]
+	}
+
+	rate := perSecond(fp.done, time.Since(fp.startedAt))
+	return fmt.Sprintf("  %-*s %s %3d%% %s/%s %s/s", progressNameWidth, name,
+		progressBar(fp.done, fp.size, progressFileBarWidth), percentOf(fp.done, fp.size),
+		prettyBytes(fp.done), prettyBytes(fp.size), prettyBytes(int64(rate)))
+}
+
+func progressBar(done, total int64, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = int(int64(width) * done / total)
+	}
+	if filled > width {
+		filled = width
+	}
+
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	return "[" + bar + "]"
+}
+
+func percentOf(done, total int64) int64 {
+	if total <= 0 {
+		return 0
+	}
+	if done >= total {
+		return 100
+	}
+	return 100 * done / total
+}
+
+func perSecond(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds()
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d - d%time.Millisecond
+	}
+	return d - d%time.Second
+}
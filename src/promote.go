@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Promote moves the items in each of the folders in opts.Sources up levels
+// folders in the hierarchy e.g promoting the items in /a/b/c by one level moves
+// them into /a/b. Names that clash in the target folder are dealt with as per
+// opts.ConflictPolicy. The folders promoted out of are left in place.
+func (g *Commands) Promote(levels int) error {
+	if levels < 1 {
+		return fmt.Errorf("promote: levels has to be at least 1, instead got %d", levels)
+	}
+	switch g.opts.ConflictPolicy {
+	case "", ConflictSkip, ConflictRename, ConflictKeepBoth:
+	default:
+		return fmt.Errorf("promote: unknown conflict policy %q, expecting %s, %s or %s",
+			g.opts.ConflictPolicy, ConflictSkip, ConflictRename, ConflictKeepBoth)
+	}
+
+	g.report = newReport()
+	var composedError error = nil
+
+	for _, folderPath := range g.opts.Sources {
+		if err := g.promote(folderPath, levels); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("promote: %s: %v", folderPath, err))
+		}
+	}
+
+	g.report.summarize(g.log)
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (g *Commands) promote(folderPath string, levels int) error {
+	folder, err := g.rem.FindByPath(folderPath)
+	if err != nil {
+		return err
+	}
+	if folder == nil || !folder.IsDir {
+		return ErrPathNotDir
+	}
+
+	targetPath := folderPath
+	for i := 0; i < levels; i++ {
+		if rootLike(targetPath) {
+			return fmt.Errorf("is only %d levels deep, cannot be promoted out of by %d", i, levels)
+		}
+		targetPath = g.parentPather(targetPath)
+	}
+
+	target, err := g.rem.FindByPath(targetPath)
+	if err != nil {
+		return fmt.Errorf("%s: %v", targetPath, err)
+	}
+
+	taken := make(map[string]bool)
+	for child := range g.rem.findChildren(target.Id, false) {
+		taken[strings.ToLower(child.Name)] = true
+	}
+
+	var children []*File
+	for child := range g.rem.findChildren(folder.Id, false) {
+		children = append(children, child)
+	}
+
+	g.plan.knowPath(folder.Id, folderPath)
+	g.plan.knowPath(target.Id, targetPath)
+
+	var composedError error = nil
+	for _, child := range children {
+		childPath := path.Join(folderPath, child.Name)
+		if err := g.promoteItem(child, childPath, folder, target, targetPath, taken); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", childPath, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) promoteItem(f *File, srcPath string, parent, target *File, targetPath string, taken map[string]bool) (err error) {
+	name := f.Name
+	if taken[strings.ToLower(name)] {
+		switch g.opts.ConflictPolicy {
+		case ConflictRename:
+			name = caseFoldFreeName(name, taken)
+		case ConflictKeepBoth:
+		default:
+			g.report.warn("Name clashes left in place", "%s", srcPath)
+			return nil
+		}
+	}
+	taken[strings.ToLower(name)] = true
+
+	defer func() {
+		g.audit(AuditMove, f, srcPath, targetPath, err)
+	}()
+
+	g.plan.knowPath(f.Id, srcPath)
+	if err = g.mut.insertParent(f.Id, target.Id); err != nil {
+		return err
+	}
+	if err = g.mut.removeParent(f.Id, parent.Id); err != nil {
+		return err
+	}
+	if name != f.Name {
+		if _, err = g.mut.rename(f.Id, name); err != nil {
+			return err
+		}
+		g.report.note("Renamed apart", "%s as %s", srcPath, name)
+	}
+
+	g.report.count("Promoted", fmt.Sprintf("into %s", targetPath))
+	return nil
+}
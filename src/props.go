@@ -0,0 +1,211 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	PropActionSet = "set"
+	PropActionGet = "get"
+	PropActionDel = "del"
+)
+
+// propertyEntry is a property as emitted with -json.
+type propertyEntry struct {
+	Path       string `json:"path"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Visibility string `json:"visibility"`
+}
+
+type byPropertyKey []*drive.Property
+
+func (p byPropertyKey) Len() int           { return len(p) }
+func (p byPropertyKey) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPropertyKey) Less(i, j int) bool { return p[i].Key < p[j].Key }
+
+// properties returns the properties of the file that this app can see,
+// that is the public ones and those private to this app.
+func (r *Remote) properties(fileId string) ([]*drive.Property, error) {
+	props, err := r.service.Properties.List(fileId).Do()
+	if err != nil {
+		return nil, err
+	}
+	return props.Items, nil
+}
+
+// setProperty sets a property with the given visibility on the
+// file, replacing any previous value of the property.
+func (r *Remote) setProperty(fileId, key, value, visibility string) error {
+	prop := &drive.Property{
+		Key:        key,
+		Value:      value,
+		Visibility: visibility,
+	}
+	_, err := r.service.Properties.Insert(fileId, prop).Do()
+	return err
+}
+
+func (r *Remote) deleteProperty(fileId, key, visibility string) error {
+	return r.service.Properties.Delete(fileId, key).Visibility(visibility).Do()
+}
+
+// propertyMap converts the properties of a file into a map
+// of key to value, leaving out those without a key.
+func propertyMap(props []*drive.Property) map[string]string {
+	if len(props) < 1 {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, prop := range props {
+		if prop != nil && prop.Key != "" {
+			m[prop.Key] = prop.Value
+		}
+	}
+	return m
+}
+
+// ParseProperties parses "key=value" pairs into a map of key to value.
+func ParseProperties(pairs ...string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("expecting key=value, got %q", pair)
+		}
+		m[key] = strings.TrimSpace(kv[1])
+	}
+	return m, nil
+}
+
+// driveProperties converts a map of key to value into properties with
+// the given visibility, ordered by key.
+func driveProperties(m map[string]string, visibility string) []*drive.Property {
+	var props []*drive.Property
+	for key, value := range m {
+		props = append(props, &drive.Property{Key: key, Value: value, Visibility: visibility})
+	}
+	sort.Sort(byPropertyKey(props))
+	return props
+}
+
+// Prop sets, gets or deletes the properties named in args on each of the
+// sources. set expects "key=value" args, get lists all properties if no keys
+// are given and del expects keys. Properties are public, that is visible to
+// other apps, unless private is set.
+func (g *Commands) Prop(action string, args []string, private, byId bool) error {
+	visibility := PropertyVisibilityPublic
+	if private {
+		visibility = AppPropertyVisibility
+	}
+
+	var pairs map[string]string
+	switch action {
+	case PropActionSet:
+		if len(args) < 1 {
+			return fmt.Errorf("%s: expecting at least one key=value", action)
+		}
+		var err error
+		if pairs, err = ParseProperties(args...); err != nil {
+			return err
+		}
+	case PropActionDel:
+		if len(args) < 1 {
+			return fmt.Errorf("%s: expecting at least one key", action)
+		}
+	case PropActionGet:
+	default:
+		return fmt.Errorf("unknown action %q, expecting one of %s, %s or %s",
+			action, PropActionSet, PropActionGet, PropActionDel)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var composedError error = nil
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err == nil {
+			switch action {
+			case PropActionSet:
+				err = g.setProperties(f, pairs, visibility)
+			case PropActionGet:
+				err = g.getProperties(p, f, args)
+			case PropActionDel:
+				err = g.deleteProperties(f, args, visibility)
+			}
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("prop %s: %s: %v", action, p, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) setProperties(f *File, pairs map[string]string, visibility string) error {
+	for _, prop := range driveProperties(pairs, visibility) {
+		if err := g.rem.setProperty(f.Id, prop.Key, prop.Value, visibility); err != nil {
+			return fmt.Errorf("%s: %v", prop.Key, err)
+		}
+	}
+	return nil
+}
+
+func (g *Commands) deleteProperties(f *File, keys []string, visibility string) error {
+	var composedError error = nil
+	for _, key := range keys {
+		if err := g.rem.deleteProperty(f.Id, key, visibility); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) getProperties(p string, f *File, keys []string) error {
+	props, err := g.rem.properties(f.Id)
+	if err != nil {
+		return err
+	}
+	sort.Sort(byPropertyKey(props))
+
+	wanted := make(map[string]bool)
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	for _, prop := range props {
+		if len(wanted) > 0 && !wanted[prop.Key] {
+			continue
+		}
+		if g.emitter != nil {
+			g.emitter.emit(&propertyEntry{Path: p, Key: prop.Key, Value: prop.Value, Visibility: prop.Visibility})
+			continue
+		}
+		g.log.Logf("%s\t%s=%s\t%s\n", p, prop.Key, prop.Value, strings.ToLower(prop.Visibility))
+	}
+	return nil
+}
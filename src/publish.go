@@ -1,4 +1,4 @@
-// Copyright 2013 Google Inc. All Rights Reserved.
+// Copyright 2015 Google Inc. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,11 +16,80 @@ package drive
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
-func (c *Commands) Publish(byId bool) (err error) {
+const (
+	// PubExpiringKey tags published items whose links expire, since
+	// only files with a property of a given value can be searched for.
+	PubExpiringKey = "pubExpiring"
+	// PubExpiresKey is the time after which the links to a tagged item are revoked.
+	PubExpiresKey = "pubExpires"
+)
+
+// PublishArgs are the options of Publish.
+type PublishArgs struct {
+	ById bool
+	// Role is what anyone is granted, reader, commenter or writer
+	Role string
+	// WithLink when set only grants those with the link access,
+	// otherwise the items are public on the web
+	WithLink bool
+	// Expires if set is an age e.g 7d or a timestamp after which
+	// `unpub -expired` revokes the links to the items
+	Expires string
+}
+
+// parseExpiry parses spec either as a period from now e.g "7d", "2w",
+// "36h" or as a timestamp e.g "2015-06-30", which mustn't be past.
+func parseExpiry(spec string, now time.Time) (time.Time, error) {
+	spec = strings.TrimSpace(spec)
+	cutoff, err := parseCutoff(spec, now)
+	if err != nil {
+		return now, err
+	}
+
+	// Ages are counted back from now but periods of validity forward
+	_, durErr := time.ParseDuration(spec)
+	if ageRegexp.MatchString(spec) || durErr == nil {
+		return now.Add(now.Sub(cutoff)), nil
+	}
+	if !cutoff.After(now) {
+		return now, fmt.Errorf("expiry %q is already past", spec)
+	}
+	return cutoff, nil
+}
+
+func parsePublishRole(s string) (Role, error) {
+	if s == "" {
+		return Reader, nil
+	}
+	role := stringToRole()(s)
+	switch role {
+	case Reader, Commenter, Writer:
+		if role.String() == strings.ToLower(s) {
+			return role, nil
+		}
+	}
+	return UnknownRole, fmt.Errorf("unknown role %q, expecting reader, commenter or writer", s)
+}
+
+func (c *Commands) Publish(args *PublishArgs) (err error) {
+	role, err := parsePublishRole(args.Role)
+	if err != nil {
+		return err
+	}
+
+	var expires time.Time
+	if args.Expires != "" {
+		if expires, err = parseExpiry(args.Expires, time.Now()); err != nil {
+			return err
+		}
+	}
+
 	for _, relToRoot := range c.opts.Sources {
-		if pubErr := c.pub(relToRoot, byId); pubErr != nil {
+		if pubErr := c.pub(relToRoot, args, role, expires); pubErr != nil {
 			c.log.LogErrf("\033[91mPub\033[00m %s:  %v\n", relToRoot, pubErr)
 		}
 	}
@@ -36,42 +105,168 @@ func (c *Commands) remFileResolve(relToRoot string, byId bool) (*File, error) {
 	return resolver(relToRoot)
 }
 
-func (c *Commands) pub(relToRoot string, byId bool) (err error) {
-	file, err := c.remFileResolve(relToRoot, byId)
+func (c *Commands) pub(relToRoot string, args *PublishArgs, role Role, expires time.Time) (err error) {
+	file, err := c.remFileResolve(relToRoot, args.ById)
 	if err != nil || file == nil {
 		return err
 	}
 
 	var link string
-	link, err = c.rem.Publish(file.Id)
+	link, err = c.rem.Publish(file.Id, role, args.WithLink)
 	if err != nil {
 		return
 	}
 
 	link = file.Url()
 
-	if byId {
+	if args.ById {
 		relToRoot = fmt.Sprintf("%s aka %s", relToRoot, file.Name)
 	}
 
-	c.log.Logf("%s published on %s\n", relToRoot, link)
+	if expires.IsZero() {
+		c.log.Logf("%s published on %s\n", relToRoot, link)
+		return
+	}
+
+	// Drive only lets permissions of users and groups expire,
+	// links are tagged to be revoked by `unpub -expired` instead.
+	if err = c.mut.setAppProperty(file.Id, PubExpiresKey, expires.UTC().Format(time.RFC3339)); err != nil {
+		return
+	}
+	if err = c.mut.setAppProperty(file.Id, PubExpiringKey, "true"); err != nil {
+		return
+	}
+	c.log.Logf("%s published on %s until %s\n", relToRoot, link, expires.Local().Format(time.RFC822))
 	return
 }
 
-func (c *Commands) Unpublish(byId bool) error {
+// UnpublishArgs are the options of Unpublish.
+type UnpublishArgs struct {
+	ById bool
+	// Recursive also revokes the links to the descendants of folders
+	Recursive bool
+	// Expired revokes the links that were published to expire and
+	// whose time is up, wherever they are in the drive
+	Expired bool
+}
+
+func (c *Commands) Unpublish(args *UnpublishArgs) error {
+	c.report = newReport()
+	defer c.report.summarize(c.log)
+
+	if args.Expired {
+		return c.unpubExpired()
+	}
+
+	var composedError error = nil
 	for _, relToRoot := range c.opts.Sources {
-		if unpubErr := c.unpub(relToRoot, byId); unpubErr != nil {
+		if unpubErr := c.unpub(relToRoot, args); unpubErr != nil {
 			c.log.LogErrf("\033[91mUnpub\033[00m %s:  %v\n", relToRoot, unpubErr)
+			composedError = reComposeError(composedError, fmt.Sprintf("unpub: %s: %v", relToRoot, unpubErr))
 		}
 	}
-	return nil
+	return composedError
 }
 
-func (c *Commands) unpub(relToRoot string, byId bool) error {
-	file, err := c.remFileResolve(relToRoot, byId)
+func (c *Commands) unpub(relToRoot string, args *UnpublishArgs) error {
+	file, err := c.remFileResolve(relToRoot, args.ById)
 	if err != nil {
 		return err
 	}
+	if file == nil {
+		return ErrPathNotExists
+	}
+
+	if !args.Recursive || !file.IsDir {
+		return c.revokeLinks(file, relToRoot, true)
+	}
+
+	var composedError error = nil
+	if err := c.revokeLinks(file, relToRoot, false); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+
+	seen := map[string]bool{file.Id: true}
+	var walk func(folder *File, folderPath string)
+	walk = func(folder *File, folderPath string) {
+		for child := range c.rem.findChildren(folder.Id, false) {
+			if child == nil || seen[child.Id] {
+				continue
+			}
+			seen[child.Id] = true
+			childPath := sepJoin("/", folderPath, child.Name)
+			if err := c.revokeLinks(child, childPath, false); err != nil {
+				composedError = reComposeError(composedError, err.Error())
+			}
+			if child.IsDir {
+				walk(child, childPath)
+			}
+		}
+	}
+	walk(file, relToRoot)
+	return composedError
+}
+
+// revokeLinks deletes the permissions granting anyone access to f, and
+// the tags of links set to expire. If its permissions aren't visible, as
+// only those who can share an item see them, the public permission is
+// deleted when force is set, otherwise f is left as is.
+func (c *Commands) revokeLinks(f *File, p string, force bool) error {
+	var ids []string
+	for _, perm := range f.Permissions {
+		if perm != nil && perm.Type == "anyone" {
+			ids = append(ids, perm.Id)
+		}
+	}
+	if len(ids) < 1 && force {
+		ids = append(ids, "anyone")
+	}
+	if len(ids) < 1 {
+		return nil
+	}
 
-	return c.rem.Unpublish(file.Id)
+	for _, id := range ids {
+		if err := c.rem.deletePermission(f.Id, id); err != nil {
+			return fmt.Errorf("%s: %v", p, err)
+		}
+	}
+	c.report.count("Links revoked", "items")
+
+	if f.Properties[PubExpiringKey] != "" {
+		if err := c.rem.deleteAppProperty(f.Id, PubExpiringKey); err != nil {
+			c.report.warn("Revoked but still tagged to expire", "%s: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// unpubExpired revokes the links to the items published to expire
+// whose time is up. Those still valid are reported with their expiry.
+func (c *Commands) unpubExpired() error {
+	now := time.Now()
+	var composedError error = nil
+	for f := range c.rem.findByAppProperty(PubExpiringKey, "true") {
+		if f == nil {
+			continue
+		}
+		p, err := c.rem.pathOf(f.Id)
+		if err != nil {
+			p = fmt.Sprintf("%s (%s)", f.Name, f.Id)
+		}
+
+		expires, err := time.Parse(time.RFC3339, f.Properties[PubExpiresKey])
+		if err != nil {
+			c.report.warn("Expiring links skipped", "%s: %s: %v", p, PubExpiresKey, err)
+			continue
+		}
+		if now.Before(expires) {
+			c.report.note("Not yet expired", "%s at %s", p, expires.Local().Format(time.RFC822))
+			continue
+		}
+
+		if err := c.revokeLinks(f, p, true); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("unpub: %v", err))
+		}
+	}
+	return composedError
 }
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
 	"sort"
@@ -42,12 +41,33 @@ type downloadArg struct {
 	path            string
 	exportURL       string
 	ackByteProgress bool
+	// blobURL and size are of the content, for downloading it in ranges
+	blobURL string
+	size    int64
+	// progress is the transfer's progress, if it is tracked
+	progress *fileProgress
+	// transformed is the file being downloaded if push compressed
+	// or encrypted its content, which is undone as it downloads
+	transformed *File
 }
 
 // Pull from remote if remote path exists and in a god context. If path is a
 // directory, it recursively pulls from the remote if there are remote changes.
 // It doesn't check if there are remote changes if isForce is set.
 func (g *Commands) Pull(byId bool) error {
+	if err := g.checkModConflictPolicy(); err != nil {
+		return err
+	}
+	if err := checkExportLayout(g.opts.ExportLayout); err != nil {
+		return err
+	}
+	if err := g.prepareEncryption(); err != nil {
+		return err
+	}
+	if err := g.prepareNativeSizer(); err != nil {
+		return err
+	}
+
 	cl, clashes, err := pullLikeResolve(g, byId)
 
 	if len(clashes) >= 1 {
@@ -72,6 +92,7 @@ func (g *Commands) Pull(byId bool) error {
 		changes:   nonConflicts,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: g.opts.NoClobber,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, opMap := printChangeList(&clArg)
@@ -79,6 +100,9 @@ func (g *Commands) Pull(byId bool) error {
 		return nil
 	}
 
+	if err := g.setAsideConflicts(); err != nil {
+		return err
+	}
 	return g.playPullChanges(nonConflicts, g.opts.Exports, opMap)
 }
 
@@ -158,6 +182,16 @@ func pullLikeMatchesResolver(g *Commands) (cl, clashes []*Change, err error) {
 }
 
 func (g *Commands) PullMatches() (err error) {
+	if err := g.checkModConflictPolicy(); err != nil {
+		return err
+	}
+	if err := checkExportLayout(g.opts.ExportLayout); err != nil {
+		return err
+	}
+	if err := g.prepareNativeSizer(); err != nil {
+		return err
+	}
+
 	cl, clashes, err := pullLikeMatchesResolver(g)
 
 	if len(clashes) >= 1 {
@@ -186,6 +220,7 @@ func (g *Commands) PullMatches() (err error) {
 		changes:   nonConflicts,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: g.opts.NoClobber,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, opMap := printChangeList(&clArg)
@@ -193,6 +228,9 @@ func (g *Commands) PullMatches() (err error) {
 		return nil
 	}
 
+	if err := g.setAsideConflicts(); err != nil {
+		return err
+	}
 	return g.playPullChanges(nonConflicts, g.opts.Exports, opMap)
 }
 
@@ -290,6 +328,9 @@ func (g *Commands) pullAndDownload(relToRootPath string, fh io.Writer, rem *File
 	if blobHandle == nil {
 		return nil
 	}
+	if blobHandle, err = g.transferredContent(rem, blobHandle); err != nil {
+		return err
+	}
 
 	_, err = io.Copy(fh, blobHandle)
 	blobHandle.Close()
@@ -298,7 +339,7 @@ func (g *Commands) pullAndDownload(relToRootPath string, fh io.Writer, rem *File
 
 func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Operation]sizeCounter) (err error) {
 	if opMap == nil {
-		result := opChangeCount(cl)
+		result := opChangeCount(cl, g.sizeOf)
 		opMap = &result
 	}
 
@@ -310,6 +351,7 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 	}
 
 	g.taskStart(totalSize)
+	g.startVerifying()
 
 	defer close(g.rem.progressChan)
 
@@ -381,9 +423,13 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 					g.log.Logln("\033[01mPull::Started", c.Path, "\033[00m")
 				}
 
-				if err := f(c, exports); err != nil {
+				fp := g.startChange(c)
+				err := f(c, exports)
+				fp.finish(err)
+				if err != nil {
 					g.log.LogErrf("pull: %s err: %v\n", c.Path, err)
 				}
+				g.emitChange(PullKey, c, err)
 
 				if canPrintSteps {
 					g.log.Logln("\033[04mPull::Done", c.Path, "\033[00m")
@@ -400,6 +446,9 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 	}
 
 	g.taskFinish()
+	if vErr := g.finishVerifying(); vErr != nil {
+		err = vErr
+	}
 	return err
 }
 
@@ -421,7 +470,7 @@ func (g *Commands) localMod(change *Change, exports []string) (err error) {
 	defer func() {
 		if err == nil {
 			src := change.Src
-			indexErr := g.createIndex(src)
+			indexErr := g.createBlockIndex(src, g.context.AbsPathOf(change.Path))
 			// TODO: Should indexing errors be reported?
 			if indexErr != nil {
 				g.log.LogErrf("localMod:createIndex %s: %v\n", src.Name, indexErr)
@@ -436,14 +485,32 @@ func (g *Commands) localMod(change *Change, exports []string) (err error) {
 	// Simple heuristic to avoid downloading all the
 	// content yet it could just be a modTime difference
 	mask := fileDifferences(change.Src, change.Dest, change.IgnoreChecksum)
-	if checksumDiffers(mask) {
+	download := checksumDiffers(mask)
+
+	// Block maps tell same sized edits on Drive apart from touches, and spare
+	// downloading files whose content is as last synced on both sides
+	if remoteChanged, known := g.remoteChanged(change.Src); known {
+		download = remoteChanged
+		if !remoteChanged {
+			if localChanged, localKnown := g.localChanged(change.Path, destAbsPath, change.Src); localKnown {
+				download = localChanged
+			} else {
+				download = checksumDiffers(mask)
+			}
+		}
+	}
+
+	if download {
 		// download and replace
 		if err = g.download(change, exports); err != nil {
 			return
 		}
 		downloadPerformed = true
 	}
-	err = os.Chtimes(destAbsPath, change.Src.ModTime, change.Src.ModTime)
+	if _, ok := g.preservedSymlink(change.Src); !ok {
+		g.restoreMetadata(change.Path, destAbsPath, change.Src)
+		err = os.Chtimes(destAbsPath, change.Src.ModTime, change.Src.ModTime)
+	}
 
 	// Update progress for the case in which you are only Chtime-ing
 	// since progress for downloaded files is already handled separately
@@ -462,7 +529,7 @@ func (g *Commands) localAdd(change *Change, exports []string) (err error) {
 		if err == nil && change.Src != nil {
 			fileToSerialize := change.Src
 
-			indexErr := g.createIndex(fileToSerialize)
+			indexErr := g.createBlockIndex(fileToSerialize, g.context.AbsPathOf(change.Path))
 			// TODO: Should indexing errors be reported?
 			if indexErr != nil {
 				g.log.LogErrf("localAdd:createIndex %s: %v\n", fileToSerialize.Name, indexErr)
@@ -487,6 +554,9 @@ func (g *Commands) localAdd(change *Change, exports []string) (err error) {
 		if os.IsExist(err) {
 			err = nil
 		}
+		if err == nil {
+			g.restoreMetadata(change.Path, destAbsPath, change.Src)
+		}
 		return err
 	}
 
@@ -495,6 +565,11 @@ func (g *Commands) localAdd(change *Change, exports []string) (err error) {
 		return
 	}
 
+	// Changing the times of a symlink would change those of its target
+	if _, ok := g.preservedSymlink(change.Src); ok {
+		return
+	}
+	g.restoreMetadata(change.Path, destAbsPath, change.Src)
 	err = os.Chtimes(destAbsPath, change.Src.ModTime, change.Src.ModTime)
 	return
 }
@@ -536,82 +611,6 @@ func touchFile(path string) (err error) {
 	return
 }
 
-func (g *Commands) export(f *File, destAbsPath string, exports []string) (manifest []string, err error) {
-	if len(exports) < 1 || f == nil {
-		return
-	}
-
-	dirPath := sepJoin("_", destAbsPath, "exports")
-	if err = os.MkdirAll(dirPath, os.ModeDir|0755); err != nil {
-		return
-	}
-
-	var ok bool
-	var mimeType, exportURL string
-
-	waitables := []*urlMimeTypeExt{}
-
-	for _, ext := range exports {
-		mimeType = mimeTypeFromExt(ext)
-		exportURL, ok = f.ExportLinks[mimeType]
-		if !ok {
-			continue
-		}
-
-		waitables = append(waitables, &urlMimeTypeExt{
-			mimeType: mimeType,
-			url:      exportURL,
-			ext:      ext,
-		})
-	}
-
-	n := len(waitables)
-	doneAck := make(chan bool, n)
-
-	basePath := filepath.Base(f.Name)
-	baseDir := path.Join(dirPath, basePath)
-
-	for _, exportee := range waitables {
-		go func(baseDirPath, id string, urlMExt *urlMimeTypeExt) error {
-			defer func() {
-				doneAck <- true
-			}()
-
-			exportPath := sepJoin(".", baseDirPath, urlMExt.ext)
-
-			// TODO: Decide if users should get to make *.desktop users even for exports
-			if runtime.GOOS == OSLinuxKey && false {
-				desktopEntryPath := sepJoin(".", exportPath, DesktopExtension)
-
-				_, dentErr := f.serializeAsDesktopEntry(desktopEntryPath, urlMExt)
-				if dentErr != nil {
-					g.log.LogErrf("desktopEntry: %s %v\n", desktopEntryPath, dentErr)
-				}
-			}
-
-			dlArg := downloadArg{
-				ackByteProgress: false,
-				path:            exportPath,
-				id:              id,
-				exportURL:       urlMExt.url,
-			}
-
-			err := g.singleDownload(&dlArg)
-			if err == nil {
-				manifest = append(manifest, exportPath)
-			}
-
-			return err
-		}(baseDir, f.Id, exportee)
-	}
-
-	for i := 0; i < n; i++ {
-		<-doneAck
-	}
-
-	return
-}
-
 func isLocalFile(f *File) bool {
 	// TODO: Better check
 	return f != nil && f.Etag == ""
@@ -623,14 +622,23 @@ func (g *Commands) download(change *Change, exports []string) (err error) {
 	}
 
 	destAbsPath := g.context.AbsPathOf(change.Path)
+	if target, ok := g.preservedSymlink(change.Src); ok {
+		return relink(destAbsPath, target)
+	}
 	if change.Src.BlobAt != "" {
 		dlArg := downloadArg{
 			path:            destAbsPath,
 			id:              change.Src.Id,
 			ackByteProgress: true,
+			blobURL:         change.Src.BlobAt,
+			size:            change.Src.Size,
+			progress:        g.progress.file(change.Path),
+		}
+		if g.transformed(change.Src) {
+			dlArg.transformed = change.Src
 		}
 
-		return g.singleDownload(&dlArg)
+		return g.downloadVerified(change.Path, &dlArg, change.Src)
 	}
 
 	// We need to touch the empty file to
@@ -662,12 +670,7 @@ func (g *Commands) download(change *Change, exports []string) (err error) {
 		return nil
 	}
 
-	exportDirPath := destAbsPath
-	if g.opts.ExportsDir != "" {
-		exportDirPath = path.Join(g.opts.ExportsDir, change.Src.Name)
-	}
-
-	manifest, exportErr := g.export(change.Src, exportDirPath, exports)
+	manifest, exportErr := g.export(change.Src, destAbsPath, exports)
 
 	if exportErr == nil {
 		for _, exportPath := range manifest {
@@ -679,6 +682,10 @@ func (g *Commands) download(change *Change, exports []string) (err error) {
 }
 
 func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
+	if g.multiRange(dlArg) {
+		return g.multiRangeDownload(dlArg)
+	}
+
 	var fo *os.File
 	fo, err = os.Create(dlArg.path)
 	if err != nil {
@@ -706,6 +713,11 @@ func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
 	if err != nil {
 		return err
 	}
+	if dlArg.transformed != nil {
+		if blob, err = g.transferredContent(dlArg.transformed, blob); err != nil {
+			return err
+		}
+	}
 
 	ws := statos.NewWriter(fo)
 
@@ -713,6 +725,7 @@ func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
 		commChan := ws.ProgressChan()
 		if dlArg.ackByteProgress {
 			for n := range commChan {
+				dlArg.progress.add(int64(n))
 				g.rem.progressChan <- n
 			}
 		} else { // Just drain the progress channel
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
 )
 
 var mkdirAllMu = sync.Mutex{}
@@ -38,6 +39,13 @@ var mkdirAllMu = sync.Mutex{}
 func (g *Commands) Push() (err error) {
 	defer g.clearMountPoints()
 
+	if err = g.checkModConflictPolicy(); err != nil {
+		return err
+	}
+	if err = g.prepareEncryption(); err != nil {
+		return err
+	}
+
 	root := g.context.AbsPathOf("")
 	var cl []*Change
 
@@ -101,7 +109,7 @@ func (g *Commands) Push() (err error) {
 
 	nonConflicts := *nonConflictsPtr
 
-	pushSize, modSize := reduceToSize(cl, SelectDest|SelectSrc)
+	pushSize, modSize := reduceToSize(cl, SelectDest|SelectSrc, g.sizeOf)
 
 	// Compensate for deletions and modifications
 	pushSize -= modSize
@@ -138,6 +146,7 @@ func (g *Commands) Push() (err error) {
 		changes:   nonConflicts,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: g.opts.NoClobber,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, opMap := printChangeList(&clArg)
@@ -145,6 +154,9 @@ func (g *Commands) Push() (err error) {
 		return
 	}
 
+	if err = g.setAsideConflicts(); err != nil {
+		return err
+	}
 	return g.playPushChanges(nonConflicts, opMap)
 }
 
@@ -156,7 +168,11 @@ func (g *Commands) resolveConflicts(cl []*Change, push bool) (*[]*Change, *[]*Ch
 	nonConflicts, conflicts := sift(cl)
 	resolved, unresolved := resolveConflicts(conflicts, push, g.deserializeIndex)
 	if conflictsPersist(unresolved) {
-		return &resolved, &unresolved
+		settled, ok := g.applyModConflictPolicy(unresolved, push)
+		if !ok {
+			return &resolved, &unresolved
+		}
+		unresolved = settled
 	}
 
 	for _, ch := range unresolved {
@@ -206,6 +222,8 @@ func (g *Commands) PushPiped() (err error) {
 		fauxSrc := DupFile(rem)
 		if fauxSrc != nil {
 			fauxSrc.ModTime = time.Now()
+			// The piped content replaces what rem was, a symlink included
+			fauxSrc.SymlinkTarget = ""
 		}
 
 		args := upsertOpt{
@@ -216,6 +234,7 @@ func (g *Commands) PushPiped() (err error) {
 			mask:           g.opts.TypeMask,
 			nonStatable:    true,
 			ignoreChecksum: g.opts.IgnoreChecksum,
+			properties:     g.opts.Properties,
 		}
 
 		rem, _, rErr := g.rem.upsertByComparison(os.Stdin, &args)
@@ -266,7 +285,7 @@ func translateOpToChanger(g *Commands, c *Change) func(*Change) error {
 
 func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounter) (err error) {
 	if opMap == nil {
-		result := opChangeCount(cl)
+		result := opChangeCount(cl, g.sizeOf)
 		opMap = &result
 	}
 
@@ -277,6 +296,8 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 	}
 
 	g.taskStart(totalSize)
+	g.startVerifying()
+	g.startOCR()
 
 	defer close(g.rem.progressChan)
 
@@ -339,9 +360,13 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 					g.log.Logln("\033[01mPush::Started", c.Path, "\033[00m")
 				}
 
-				if err := fn(c); err != nil {
+				fp := g.startChange(c)
+				err := fn(c)
+				fp.finish(err)
+				if err != nil {
 					g.log.LogErrf("push: %s err: %v\n", c.Path, err)
 				}
+				g.emitChange(PushKey, c, err)
 
 				if canPrintSteps {
 					g.log.Logln("\033[04mPush::Done", c.Path, "\033[00m")
@@ -360,6 +385,10 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 	}
 
 	g.taskFinish()
+	g.finishOCR()
+	if vErr := g.finishVerifying(); vErr != nil {
+		err = vErr
+	}
 	return err
 }
 
@@ -444,6 +473,17 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 		dest:           change.Dest,
 		mask:           g.opts.TypeMask,
 		ignoreChecksum: g.opts.IgnoreChecksum,
+		progress:       g.progress.file(change.Path),
+		properties:     g.opts.Properties,
+	}
+
+	// Only files of the extensions set up for it are converted, losing their extensions
+	if args.src != nil && !args.src.IsDir {
+		if g.convertTarget(args.src.Name) != "" {
+			args.title = convertedName(args.src.Name)
+		} else {
+			args.mask &^= OptConvert
+		}
 	}
 
 	coercedMimeKey, ok := g.coercedMimeKey()
@@ -452,8 +492,40 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 	} else if args.src != nil && !args.src.IsDir { // Infer it from the extension
 		args.mimeKey = filepath.Ext(args.src.Name)
 	}
+	g.prepareOCR(change.Path, guessMimeType(args.mimeKey), &args)
 
-	rem, err := g.rem.UpsertByComparison(&args)
+	args.compress = g.opts.Gzip && args.src != nil && !args.src.IsDir &&
+		args.src.SymlinkTarget == "" && compressible(args.src.Name)
+	// Content is uploaded again if it is to be compressed or not unlike before
+	if args.dest != nil && !args.dest.IsDir && compressed(args.dest) != args.compress {
+		args.nonStatable = true
+	}
+
+	// Drive can't convert or OCR what it can't read
+	if g.opts.Encrypt || args.compress {
+		args.mask &^= OptConvert | OptOCR
+		args.title = ""
+	}
+
+	if args.src != nil && args.src.SymlinkTarget != "" {
+		args.mask &^= OptConvert | OptOCR
+		args.title = ""
+		args.properties = map[string]string{SymlinkTargetKey: args.src.SymlinkTarget}
+		for key, value := range g.opts.Properties {
+			args.properties[key] = value
+		}
+	} else if args.src != nil {
+		args.appProperties = g.localMetadata(change.Path, absPath)
+	}
+
+	// Block maps tell same sized edits apart from touches, whose content isn't uploaded
+	if change.Dest != nil && args.src != nil && !args.src.IsDir {
+		if changed, known := g.localChanged(change.Path, absPath, change.Dest); known && changed {
+			args.nonStatable = true
+		}
+	}
+
+	rem, err := g.upsertVerified(change.Path, &args)
 	if err != nil {
 		g.log.LogErrf("%s: %v\n", change.Path, err)
 		return
@@ -461,8 +533,12 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 	if rem == nil {
 		return
 	}
-	index := rem.ToIndex()
-	wErr := g.context.SerializeIndex(index)
+	// Added files only get ids once uploaded, which results are emitted with
+	if change.Src != nil && change.Src.Id == "" {
+		change.Src.Id = rem.Id
+	}
+	g.ocrDone(change.Path, &args, rem)
+	wErr := g.createBlockIndex(rem, absPath)
 
 	// TODO: Should indexing errors be reported?
 	if wErr != nil {
@@ -482,6 +558,7 @@ func (g *Commands) remoteUntrash(change *Change) (err error) {
 	}()
 
 	err = g.rem.Untrash(target.Id)
+	g.audit(AuditUntrash, target, change.Path, "", err)
 	if err != nil {
 		return
 	}
@@ -496,12 +573,13 @@ func (g *Commands) remoteUntrash(change *Change) (err error) {
 	return
 }
 
-func remoteRemover(g *Commands, change *Change, fn func(string) error) (err error) {
+func remoteRemover(g *Commands, change *Change, op string, fn func(string) error) (err error) {
 	defer func() {
 		g.taskAdd(change.Dest.Size)
 	}()
 
 	err = fn(change.Dest.Id)
+	g.audit(op, change.Dest, change.Path, "", err)
 	if err != nil {
 		return
 	}
@@ -524,11 +602,18 @@ func remoteRemover(g *Commands, change *Change, fn func(string) error) (err erro
 }
 
 func (g *Commands) remoteTrash(change *Change) error {
-	return remoteRemover(g, change, g.rem.Trash)
+	return remoteRemover(g, change, AuditTrash, g.mut.Trash)
 }
 
 func (g *Commands) remoteDelete(change *Change) error {
-	return remoteRemover(g, change, g.rem.Delete)
+	return remoteRemover(g, change, AuditDelete, func(id string) error {
+		err := g.rem.Delete(id)
+		if err == nil {
+			// Deletions are permanent so there is no undoing them
+			g.journal.record(AuditDelete, []string{id})
+		}
+		return err
+	})
 }
 
 func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
@@ -580,6 +665,19 @@ func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
 
 	g.mkdirAllCache.Put(parDirPath, newExpirableCacheValue(parent))
 
+	// The lock was let go of to make the parent, in which time
+	// another worker that also found d missing could have made it
+	if cachedValue, ok := g.mkdirAllCache.Get(d); ok && cachedValue != nil {
+		if castF, castOk := cachedValue.Value().(*File); castOk && castF != nil {
+			return castF, nil
+		}
+	}
+	if g.plan == nil {
+		if retrFile, retryErr = g.rem.FindByPath(d); retrFile != nil || (retryErr != nil && retryErr != ErrPathNotExists) {
+			return retrFile, retryErr
+		}
+	}
+
 	remoteFile := &File{
 		IsDir:   true,
 		Name:    last,
@@ -591,7 +689,7 @@ func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
 		src:      remoteFile,
 	}
 
-	cur, curErr := g.rem.UpsertByComparison(&args)
+	cur, curErr := g.mut.UpsertByComparison(&args)
 	if curErr != nil {
 		return cur, curErr
 	}
@@ -600,12 +698,19 @@ func (g *Commands) remoteMkdirAll(d string) (file *File, err error) {
 		return cur, ErrPathNotExists
 	}
 
-	index := cur.ToIndex()
-	wErr := g.context.SerializeIndex(index)
+	g.mkdirAllCache.Put(d, newExpirableCacheValue(cur))
+	g.plan.knowPath(cur.Id, d)
+	g.plan.knowPath(parent.Id, parDirPath)
 
-	// TODO: Should indexing errors be reported?
-	if wErr != nil {
-		g.log.LogErrf("serializeIndex %s: %v\n", cur.Name, wErr)
+	// Folders that are only planned have nothing to index
+	if g.plan == nil {
+		index := cur.ToIndex()
+		wErr := g.context.SerializeIndex(index)
+
+		// TODO: Should indexing errors be reported?
+		if wErr != nil {
+			g.log.LogErrf("serializeIndex %s: %v\n", cur.Name, wErr)
+		}
 	}
 
 	g.mkdirAllCache.Put(d, newExpirableCacheValue(cur))
@@ -620,7 +725,9 @@ func symlink(mode os.FileMode) bool {
 	return (mode & os.ModeSymlink) != 0
 }
 
-func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp) (fileChan chan *File, err error) {
+// list lists the local folder at p, walk being that of p as
+// symlinks are followed, see localSymlink.
+func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp, symlinks string, walk *symlinkWalk, logy *log.Logger) (fileChan chan *File, err error) {
 	absPath := context.AbsPathOf(p)
 	var f []os.FileInfo
 	f, err = ioutil.ReadDir(absPath)
@@ -653,27 +760,15 @@ func list(context *config.Context, p string, hidden bool, ignore *regexp.Regexp)
 
 			if !symlink(file.Mode()) {
 				fileChan <- NewLocalFile(resPath, file)
-			} else {
-				var symResolvPath string
-				symResolvPath, err = filepath.EvalSymlinks(resPath)
-				if err != nil {
-					continue
-				}
-
-				if anyMatch(ignore, symResolvPath) {
-					continue
-				}
+				continue
+			}
 
-				var symInfo os.FileInfo
-				symInfo, err = os.Stat(symResolvPath)
-				if err != nil {
+			if symlinks == SymlinksFollow || symlinks == "" {
+				if symResolvPath, evalErr := filepath.EvalSymlinks(resPath); evalErr != nil || anyMatch(ignore, symResolvPath) {
 					continue
 				}
-
-				lf := NewLocalFile(symResolvPath, symInfo)
-				// Retain the original name as appeared in
-				// the manifest instead of the resolved one
-				lf.Name = fileName
+			}
+			if lf := localSymlink(resPath, file, symlinks, walk, logy); lf != nil {
 				fileChan <- lf
 			}
 		}
@@ -0,0 +1,356 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// DefaultPushWatchInterval is how often push -watch scans for local changes.
+	// The tree is scanned rather than watched with fsnotify, which isn't vendored
+	DefaultPushWatchInterval = 2 * time.Second
+	// DefaultDebounce is how long the tree has to be left alone before changes are pushed
+	DefaultDebounce = 3 * time.Second
+	// debounceMaxWaits is how many debounce windows a change waits for at most,
+	// so that a file that is written to non stop still gets pushed
+	debounceMaxWaits = 10
+)
+
+// localScan is a snapshot of the local tree, keyed by path relative to the root.
+type localScan map[string]os.FileInfo
+
+// pendingPush is a path that changed and is yet to be pushed.
+type pendingPush struct {
+	// from is the path it was renamed from, if it was renamed
+	from string
+	// existed is set if the path was there before it first changed
+	existed bool
+}
+
+// pushQueue coalesces the changes made between pushes, so that
+// a file edited many times in a row is only pushed once.
+type pushQueue struct {
+	pending map[string]*pendingPush
+	firstAt time.Time
+	lastAt  time.Time
+}
+
+func newPushQueue() *pushQueue {
+	return &pushQueue{pending: make(map[string]*pendingPush)}
+}
+
+func (q *pushQueue) touch(p string, existed bool, now time.Time) {
+	if len(q.pending) < 1 {
+		q.firstAt = now
+	}
+	q.lastAt = now
+	if _, queued := q.pending[p]; !queued {
+		q.pending[p] = &pendingPush{existed: existed}
+	}
+}
+
+func (q *pushQueue) rename(from, to string, now time.Time) {
+	entry := &pendingPush{from: from, existed: true}
+	if prev, queued := q.pending[from]; queued {
+		delete(q.pending, from)
+		// A path renamed again is still renamed from where it first was,
+		// whereas one that was never pushed is still to be created
+		if prev.from != "" || !prev.existed {
+			entry = prev
+		}
+	}
+	q.touch(to, true, now)
+	q.pending[to] = entry
+}
+
+// settled reports whether the queued changes are due to be pushed.
+func (q *pushQueue) settled(debounce time.Duration, now time.Time) bool {
+	if len(q.pending) < 1 {
+		return false
+	}
+	return now.Sub(q.lastAt) >= debounce || now.Sub(q.firstAt) >= debounceMaxWaits*debounce
+}
+
+// PushWatch pushes the sources and then keeps watching them, pushing local
+// creations, modifications, renames and deletions as they happen, until
+// interrupted. Changes are held back until the tree has been left alone for
+// opts.Debounce so that bursts of edits are pushed together.
+func (g *Commands) PushWatch() error {
+	if err := g.checkModConflictPolicy(); err != nil {
+		return err
+	}
+
+	interval := g.opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPushWatchInterval
+	}
+	debounce := g.opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	scan := g.scanLocal()
+	if err := g.pushPaths(g.opts.Sources); err != nil {
+		g.log.LogErrf("push: %v\n", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	g.log.Logf("Watching %s for changes to push, every %v. Interrupt to stop\n",
+		sepJoin(", ", g.opts.Sources...), interval)
+
+	queue := newPushQueue()
+	for {
+		select {
+		case <-interrupt:
+			if len(queue.pending) >= 1 {
+				g.log.LogErrf("\n%d changes were not pushed yet\n", len(queue.pending))
+			}
+			g.log.Logln("\nStopped watching")
+			return nil
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		current := g.scanLocal()
+		queue.diff(scan, current, now)
+		scan = current
+
+		if !queue.settled(debounce, now) {
+			continue
+		}
+		if err := g.pushQueued(queue); err != nil {
+			g.log.LogErrf("push: %v\n", err)
+		}
+	}
+}
+
+// scanLocal snapshots the local trees of the sources, leaving out what pushes leave out.
+func (g *Commands) scanLocal() localScan {
+	scan := make(localScan)
+	for _, relToRoot := range g.opts.Sources {
+		fsRoot := g.context.AbsPathOf(relToRoot)
+		filepath.Walk(fsRoot, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel := path.Join("/", relToRoot, filepath.ToSlash(strings.TrimPrefix(p, fsRoot)))
+			name := info.Name()
+			skip := name == config.GDDirSuffix || isHidden(name, g.opts.Hidden) ||
+				anyMatch(g.opts.IgnoreRegexp, name) || g.opts.Ignores.Match(rel, info.IsDir())
+			if skip && p != fsRoot {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			scan[rel] = info
+			return nil
+		})
+	}
+	return scan
+}
+
+// diff queues the changes between the prev and cur scans of the tree.
+func (q *pushQueue) diff(prev, cur localScan, now time.Time) {
+	var added, removed []string
+	for p, info := range cur {
+		before, seen := prev[p]
+		switch {
+		case !seen:
+			added = append(added, p)
+		// The times of folders change with their children, which are queued themselves
+		case info.IsDir():
+		case info.Size() != before.Size() || !info.ModTime().Equal(before.ModTime()):
+			q.touch(p, true, now)
+		}
+	}
+	for p := range prev {
+		if _, seen := cur[p]; !seen {
+			removed = append(removed, p)
+		}
+	}
+
+	// A rename is told apart from a deletion and a creation by being the same file
+	renamedFrom := make(map[string]string)
+	renamed := make(map[string]bool)
+	for _, to := range added {
+		for _, from := range removed {
+			if !renamed[from] && os.SameFile(prev[from], cur[to]) {
+				renamedFrom[to] = from
+				renamed[from] = true
+				break
+			}
+		}
+	}
+
+	sort.Strings(added)
+	for _, to := range added {
+		from, ok := renamedFrom[to]
+		if !ok {
+			q.touch(to, false, now)
+			continue
+		}
+		// The descendants of a renamed folder go along with it
+		if parentFrom, parentRenamed := renamedFrom[path.Dir(to)]; parentRenamed && parentFrom == path.Dir(from) {
+			continue
+		}
+		q.rename(from, to, now)
+	}
+	for _, p := range removed {
+		if !renamed[p] {
+			q.touch(p, true, now)
+		}
+	}
+}
+
+// pushQueued makes the renames in the queue remotely and then pushes the rest
+// of the changes, emptying the queue.
+func (g *Commands) pushQueued(q *pushQueue) error {
+	var settled []string
+	for p := range q.pending {
+		settled = append(settled, p)
+	}
+	sort.Strings(settled)
+
+	var paths []string
+	var composedError error = nil
+
+	for _, p := range settled {
+		entry := q.pending[p]
+		if entry.from == "" {
+			continue
+		}
+		// Its content may have changed too, which the push below takes care of
+		paths = append(paths, p)
+		if err := g.pushRename(entry.from, p); err != nil {
+			g.log.LogErrf("push: rename %s -> %s: %v, pushing them instead\n", entry.from, p, err)
+			paths = append(paths, entry.from)
+		}
+	}
+
+	for _, p := range settled {
+		entry := q.pending[p]
+		if entry.from != "" {
+			continue
+		}
+		// Created and removed again before ever being pushed
+		if _, err := os.Lstat(g.context.AbsPathOf(p)); !entry.existed && os.IsNotExist(err) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+
+	q.pending = make(map[string]*pendingPush)
+
+	if err := g.pushPaths(outermostPaths(paths)); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+// pushRename renames or moves the remote item at from to to.
+func (g *Commands) pushRename(from, to string) error {
+	ops, err := g.planLayout([]*mapping{{from: from, to: to}})
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if err := g.applyLayoutOp(op); err != nil {
+			return err
+		}
+	}
+	g.log.Logf("%s %s -> %s\n", time.Now().Format(time.Kitchen), from, to)
+	return nil
+}
+
+// pushPaths pushes the changes at paths without prompting.
+func (g *Commands) pushPaths(paths []string) error {
+	var cl []*Change
+	var composedError error = nil
+
+	for _, p := range paths {
+		ccl, _, err := g.changeListResolve(p, g.context.AbsPathOf(p), true)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		for _, c := range ccl {
+			if c.Op() != OpNone {
+				cl = append(cl, c)
+			}
+		}
+	}
+	if len(cl) < 1 {
+		return composedError
+	}
+
+	nonConflictsPtr, conflictsPtr := g.resolveConflicts(cl, true)
+	if conflictsPtr != nil {
+		warnConflictsPersist(g.log, *conflictsPtr)
+		return reComposeError(composedError, "conflicts have prevented a push operation")
+	}
+	// Pushing over the conflicts that couldn't be set aside would lose them
+	if err := g.setAsideConflicts(); err != nil {
+		return reComposeError(composedError, err.Error())
+	}
+
+	nonConflicts := *nonConflictsPtr
+	for _, c := range nonConflicts {
+		g.log.Logf("%s %s %s\n", time.Now().Format(time.Kitchen), c.Symbol(), c.Path)
+	}
+
+	// Every push is done with the progress channel once it is over
+	g.rem.progressChan = make(chan int)
+	if err := g.playPushChanges(nonConflicts, nil); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+// outermostPaths leaves out the paths that are inside others of paths,
+// since pushing a folder pushes everything in it.
+func outermostPaths(paths []string) (outermost []string) {
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		inside := false
+		for _, outer := range outermost {
+			if p == outer || strings.HasPrefix(p, strings.TrimSuffix(outer, "/")+"/") {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			outermost = append(outermost, p)
+		}
+	}
+	return outermost
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// queryPaths returns the paths of the items matching the Drive query q that
+// are in any of the folders at sources, leaving out those in matching folders.
+// Items that aren't in your drive, such as those shared with you, have no
+// paths and are left out too.
+func (g *Commands) queryPaths(q string, sources []string) []string {
+	var paths []string
+	for match := range g.rem.findByQuery(q, false, g.opts.Hidden) {
+		if match == nil {
+			continue
+		}
+		p, err := g.rem.pathOf(match.Id)
+		if err != nil {
+			continue
+		}
+		for _, source := range sources {
+			if rootLike(source) || p == source || strings.HasPrefix(p, strings.TrimSuffix(source, "/")+"/") {
+				paths = append(paths, p)
+				break
+			}
+		}
+	}
+	return outermostPaths(paths)
+}
+
+// listPaths lists the items at paths as a collection of their own.
+func (g *Commands) listPaths(paths []string) {
+	opt := attribute{
+		minimal: isMinimal(g.opts.TypeMask),
+		mask:    g.opts.TypeMask,
+	}
+	for _, p := range paths {
+		f, err := g.rem.FindByPath(p)
+		if err != nil || f == nil {
+			continue
+		}
+		opt.parent = path.Dir(p)
+		if rootLike(opt.parent) {
+			opt.parent = ""
+		}
+		g.printFile(f, opt)
+	}
+}
+
+func checkQuery(q string) error {
+	if strings.TrimSpace(q) == "" {
+		return fmt.Errorf("query: expecting a Drive query e.g \"mimeType = 'application/zip'\"")
+	}
+	return nil
+}
+
+// ListByQuery lists the items matching the Drive query q anywhere in the
+// folders in opts.Sources, as a collection of their own.
+func (g *Commands) ListByQuery(q string) error {
+	if err := checkQuery(q); err != nil {
+		return err
+	}
+	g.listPaths(g.queryPaths(q, g.opts.Sources))
+	return nil
+}
+
+// PullByQuery pulls the items matching the Drive query q anywhere in the
+// folders in opts.Sources, along with everything in the matching folders,
+// to local paths that mirror their remote ones.
+func (g *Commands) PullByQuery(q string) error {
+	if err := checkQuery(q); err != nil {
+		return err
+	}
+
+	paths := g.queryPaths(q, g.opts.Sources)
+	if len(paths) < 1 {
+		g.log.Logln("No items match the query")
+		return nil
+	}
+
+	g.opts.Sources = paths
+	return g.Pull(false)
+}
@@ -0,0 +1,151 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+// v3QueryFields are the names that Drive API v3 queries use for
+// the fields that v2 queries, which this client makes, call otherwise.
+var v3QueryFields = map[string]string{
+	"name":           "title",
+	"modifiedTime":   "modifiedDate",
+	"createdTime":    "createdDate",
+	"viewedByMeTime": "lastViewedByMeDate",
+}
+
+// toV2Query rewrites a query written for either version of the Drive API e.g
+// `mimeType = "application/zip" and modifiedTime < "2020-01-01"` into a v2 one,
+// renaming v3 fields outside of strings and single quoting strings.
+func toV2Query(q string) string {
+	var rewritten, word []byte
+	flushWord := func() {
+		if v2, ok := v3QueryFields[string(word)]; ok {
+			rewritten = append(rewritten, v2...)
+		} else {
+			rewritten = append(rewritten, word...)
+		}
+		word = nil
+	}
+
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '"' || c == '\'':
+			flushWord()
+			rewritten = append(rewritten, '\'')
+			for i += 1; i < len(q) && q[i] != c; i++ {
+				switch {
+				case q[i] == '\\' && i+1 < len(q):
+					rewritten = append(rewritten, q[i], q[i+1])
+					i += 1
+				case q[i] == '\'':
+					rewritten = append(rewritten, '\\', '\'')
+				default:
+					rewritten = append(rewritten, q[i])
+				}
+			}
+			rewritten = append(rewritten, '\'')
+		case c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9'):
+			word = append(word, c)
+		default:
+			flushWord()
+			rewritten = append(rewritten, c)
+		}
+	}
+	flushWord()
+	return string(rewritten)
+}
+
+// findByQuery lists the items that match the Drive query q,
+// among those in the trash if trashed is set, else the others.
+func (r *Remote) findByQuery(q string, trashed, hidden bool) chan *File {
+	req := r.service.Files.List()
+	req.Q(fmt.Sprintf("(%s) and trashed=%v", toV2Query(q), trashed))
+	return reqDoPage(req, hidden, false)
+}
+
+func (g *Commands) trashByQuery(q string, inTrash, permanent bool) error {
+	if err := checkQuery(q); err != nil {
+		return err
+	}
+
+	var cl []*Change
+	for match := range g.rem.findByQuery(q, inTrash, g.opts.Hidden) {
+		if match == nil {
+			continue
+		}
+		p, err := g.rem.pathOf(match.Id)
+		if err != nil {
+			p = fmt.Sprintf("%s (%s)", match.Name, match.Id)
+		}
+
+		ch := &Change{Path: p, g: g}
+		if inTrash {
+			ch.Src = match
+		} else {
+			ch.Dest = match
+		}
+		cl = append(cl, ch)
+	}
+
+	if len(cl) < 1 {
+		g.log.Logf("Nothing matches %s\n", q)
+		return nil
+	}
+	g.log.Logf("%d items match %s\n", len(cl), q)
+
+	clArg := changeListArg{
+		logy:      g.log,
+		changes:   cl,
+		noPrompt:  g.opts.Force || !g.opts.canPrompt(),
+		noClobber: false,
+		sizeOf:    g.sizeOf,
+	}
+
+	ok, _ := printChangeList(&clArg)
+	if !ok {
+		return nil
+	}
+
+	if permanent && !g.opts.Force && g.opts.canPrompt() {
+		if !promptForChanges("This operation is irreversible. Continue [Y/N] ") {
+			return nil
+		}
+	}
+
+	opt := trashOpt{
+		toTrash:   !inTrash,
+		permanent: permanent,
+	}
+	return g.playTrashChangeList(cl, &opt)
+}
+
+// TrashByQuery trashes the items that match the Drive query q e.g
+// "mimeType = 'application/zip' and modifiedDate < '2020-01-01'".
+func (g *Commands) TrashByQuery(q string) error {
+	return g.trashByQuery(q, false, false)
+}
+
+// UntrashByQuery restores the items in the trash that match the Drive query q.
+func (g *Commands) UntrashByQuery(q string) error {
+	return g.trashByQuery(q, true, false)
+}
+
+// DeleteByQuery permanently deletes the items that match the Drive query q.
+func (g *Commands) DeleteByQuery(q string) error {
+	return g.trashByQuery(q, false, true)
+}
@@ -0,0 +1,45 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestToV2Query(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{`title = 'a'`, `title = 'a'`},
+		{`name = "a"`, `title = 'a'`},
+		{`name contains 'report' and modifiedTime < "2020-01-01"`, `title contains 'report' and modifiedDate < '2020-01-01'`},
+		{`createdTime > '2019-01-01' or viewedByMeTime > '2019-06-01'`, `createdDate > '2019-01-01' or lastViewedByMeDate > '2019-06-01'`},
+		// Fields are only renamed as whole words outside of strings
+		{`fullText contains "name"`, `fullText contains 'name'`},
+		{`filename = 'x'`, `filename = 'x'`},
+		{`(name='a')`, `(title='a')`},
+		// Single quotes within double quoted strings are escaped
+		{`name = "it's"`, `title = 'it\'s'`},
+		{`name = 'it\'s'`, `title = 'it\'s'`},
+		{`mimeType = "application/vnd.google-apps.folder"`, `mimeType = 'application/vnd.google-apps.folder'`},
+		// An unterminated string is closed
+		{`name = "a`, `title = 'a'`},
+		{``, ``},
+	}
+	for _, tt := range tests {
+		if got := toV2Query(tt.q); got != tt.want {
+			t.Errorf("toV2Query(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
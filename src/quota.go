@@ -0,0 +1,86 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+)
+
+const DefaultQuotaLargest = 10
+
+// sizedFile is a file along with its size as per sizeOf.
+type sizedFile struct {
+	*File
+	size int64
+}
+
+type bySizeDesc []*sizedFile
+
+func (b bySizeDesc) Len() int      { return len(b) }
+func (b bySizeDesc) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySizeDesc) Less(i, j int) bool {
+	return b[i].size > b[j].size
+}
+
+// Quota reports the bytes used, free and in the trash, the usage by each of
+// the Google services sharing the quota and, if largest is positive, that
+// many of the largest files owned by the account, biggest first. Google
+// native files are among them only if opts.NativeSizePolicy sizes them.
+func (g *Commands) Quota(largest int) error {
+	if err := g.About(AboutQuota); err != nil {
+		return err
+	}
+	if largest < 1 {
+		return nil
+	}
+	if err := g.prepareNativeSizer(); err != nil {
+		return err
+	}
+
+	// Drive v2 can't order listings by size, so all the files
+	// owned are listed and the largest of them picked here.
+	q := fmt.Sprintf("'me' in owners and mimeType != '%s'", DriveFolderMimeType)
+	var files []*sizedFile
+	for f := range g.rem.findByQuery(q, false, true) {
+		if f == nil {
+			continue
+		}
+		size, counted := g.sizeOf(f)
+		if !counted || size < 1 {
+			continue
+		}
+		files = append(files, &sizedFile{File: f, size: size})
+	}
+
+	sort.Stable(bySizeDesc(files))
+	if len(files) > largest {
+		files = files[:largest]
+	}
+
+	if len(files) < 1 {
+		return nil
+	}
+
+	g.log.Logf("* Largest %d files *\n", len(files))
+	for _, f := range files {
+		p, err := g.rem.pathOf(f.Id)
+		if err != nil {
+			p = fmt.Sprintf("%s (%s)", f.Name, f.Id)
+		}
+		g.log.Logf("%-12s %s\n", prettyBytes(f.size), p)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket, filled at rate bytes per second up to a
+// burst of a second's worth, that the transfers sharing it draw from. It
+// keeps them, together, at the rate.
+type rateLimiter struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	filled time.Time
+}
+
+// newRateLimiter returns a limiter for bytesPerSecond,
+// or nil, which doesn't limit, if it isn't positive.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	rate := float64(bytesPerSecond)
+	return &rateLimiter{rate: rate, burst: rate, tokens: rate, filled: time.Now()}
+}
+
+// ParseRate parses a rate in bytes per second e.g "512KB", "2MB/s".
+// An empty rate is 0 which is unlimited.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	if s == "" {
+		return 0, nil
+	}
+	return ParseByteSize(s)
+}
+
+// chunk is the most bytes that can be drawn at once.
+func (rl *rateLimiter) chunk() int {
+	return int(rl.burst)
+}
+
+// take blocks until n bytes' worth of tokens have been drawn.
+func (rl *rateLimiter) take(n int) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	rl.tokens += now.Sub(rl.filled).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.filled = now
+
+	rl.tokens -= float64(n)
+	if rl.tokens >= 0 {
+		return
+	}
+
+	// Holding the lock while in debt makes the others wait their turn
+	wait := time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	time.Sleep(wait)
+	rl.tokens = 0
+	rl.filled = time.Now()
+}
+
+type limitedReader struct {
+	r  io.Reader
+	rl *rateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > lr.rl.chunk() {
+		p = p[:lr.rl.chunk()]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		lr.rl.take(n)
+	}
+	return n, err
+}
+
+// reader limits the rate that r is read at.
+func (rl *rateLimiter) reader(r io.Reader) io.Reader {
+	if rl == nil || r == nil {
+		return r
+	}
+	return &limitedReader{r: r, rl: rl}
+}
+
+// readCloser limits the rate that rc is read at.
+func (rl *rateLimiter) readCloser(rc io.ReadCloser) io.ReadCloser {
+	if rl == nil || rc == nil {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{rl.reader(rc), rc}
+}
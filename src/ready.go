@@ -0,0 +1,162 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	DefaultReadyTimeout = 10 * time.Minute
+
+	readyPollInterval = 5 * time.Second
+)
+
+// processingDone reports whether Drive is done processing f. Drive doesn't
+// expose the status of its processing, so it is inferred from the metadata
+// that only shows up once processing is done: the duration of videos and
+// the thumbnails of images.
+func processingDone(f *drive.File) bool {
+	switch {
+	case strings.HasPrefix(f.MimeType, "video/"):
+		return f.VideoMediaMetadata != nil && f.VideoMediaMetadata.DurationMillis > 0
+	case strings.HasPrefix(f.MimeType, "image/"):
+		return f.ThumbnailLink != ""
+	}
+	return true
+}
+
+// readyWatcher polls the copies that Drive is yet to be done processing,
+// all from a goroutine of its own so that copying goes on meanwhile
+// instead of each copy holding up a worker until it is ready.
+type readyWatcher struct {
+	sync.Mutex
+	pending  []*unreadyCopy
+	finished bool
+	// wake cuts short the wait between polls once finished
+	wake chan bool
+	done chan bool
+}
+
+type unreadyCopy struct {
+	file     *File
+	destPath string
+	timeout  time.Duration
+	deadline time.Time
+}
+
+// startReadyWatcher starts polling the copies given to waitReady, if
+// opts.WaitReady is set. Planned copies aren't made so there is nothing
+// to wait on.
+func (g *Commands) startReadyWatcher() {
+	if !g.opts.WaitReady || g.plan != nil {
+		return
+	}
+
+	rw := &readyWatcher{wake: make(chan bool, 1), done: make(chan bool)}
+	g.readyWatcher = rw
+
+	go func() {
+		defer close(rw.done)
+		for {
+			rw.Lock()
+			pending, finished := rw.pending, rw.finished
+			rw.pending = nil
+			rw.Unlock()
+
+			if finished && len(pending) < 1 {
+				return
+			}
+
+			var unready []*unreadyCopy
+			for _, uc := range pending {
+				if !g.pollReady(uc) {
+					unready = append(unready, uc)
+				}
+			}
+
+			rw.Lock()
+			rw.pending = append(unready, rw.pending...)
+			rw.Unlock()
+
+			select {
+			case <-time.After(readyPollInterval):
+			case <-rw.wake:
+			}
+		}
+	}()
+}
+
+// waitReady has the copy made at destPath polled until Drive is done
+// processing it or opts.ReadyTimeout elapses, whichever is first.
+func (g *Commands) waitReady(copied *File, destPath string) {
+	rw := g.readyWatcher
+	if rw == nil || copied.Processed {
+		return
+	}
+
+	timeout := g.opts.ReadyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+
+	rw.Lock()
+	rw.pending = append(rw.pending, &unreadyCopy{
+		file:     copied,
+		destPath: destPath,
+		timeout:  timeout,
+		deadline: time.Now().Add(timeout),
+	})
+	rw.Unlock()
+}
+
+// pollReady reports whether the copy is done with, being either
+// ready or given up on, which is then reported.
+func (g *Commands) pollReady(uc *unreadyCopy) bool {
+	if time.Now().After(uc.deadline) {
+		g.report.warn("Copies not ready in time", "%s after %v", uc.destPath, uc.timeout)
+		return true
+	}
+
+	latest, err := g.rem.FindById(uc.file.Id)
+	if err != nil {
+		g.report.warn("Copies not ready in time", "%s: %v", uc.destPath, err)
+		return true
+	}
+	return latest.Processed
+}
+
+// finishReadyWatcher waits for the copies being polled to be done with.
+func (g *Commands) finishReadyWatcher() {
+	rw := g.readyWatcher
+	if rw == nil {
+		return
+	}
+
+	rw.Lock()
+	rw.finished = true
+	rw.Unlock()
+
+	select {
+	case rw.wake <- true:
+	default:
+	}
+	<-rw.done
+	g.readyWatcher = nil
+}
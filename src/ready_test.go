@@ -0,0 +1,59 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadyWatcher(t *testing.T) {
+	g := &Commands{opts: &Options{WaitReady: true}, report: newReport()}
+	g.startReadyWatcher()
+
+	// Copies already processed aren't polled
+	g.waitReady(&File{Id: "ready", Processed: true}, "/ready.mp4")
+	// Nor are those past their deadline, which are reported
+	g.readyWatcher.Lock()
+	g.readyWatcher.pending = append(g.readyWatcher.pending, &unreadyCopy{
+		file:     &File{Id: "late"},
+		destPath: "/late.mp4",
+		timeout:  time.Second,
+		deadline: time.Now().Add(-time.Second),
+	})
+	g.readyWatcher.Unlock()
+
+	finished := make(chan bool)
+	go func() {
+		g.finishReadyWatcher()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(2 * readyPollInterval):
+		t.Fatalf("finishing didn't return once nothing was pending")
+	}
+
+	section := g.report.sections["Copies not ready in time"]
+	if section == nil || len(section.items) != 1 || section.items[0] != "/late.mp4 after 1s" {
+		t.Errorf("got %v, want the late copy reported", section)
+	}
+
+	// Without -wait-ready there is nothing to watch
+	g = &Commands{opts: &Options{}}
+	g.startReadyWatcher()
+	g.waitReady(&File{Id: "unready"}, "/unready.mp4")
+	g.finishReadyWatcher()
+}
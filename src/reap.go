@@ -0,0 +1,115 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ReapPendingKey tags sources that are due to be reaped, since
+	// only files with a property of a given value can be searched for.
+	ReapPendingKey = "reapPending"
+	// ReapAfterKey is the time after which a tagged source is reaped.
+	ReapAfterKey = "reapAfter"
+)
+
+// tagForReaping tags src, once its copy is verified to have the same content,
+// to be trashed by Reap after opts.TrashSourceAfter i.e the grace period in
+// which mistakes can still be caught.
+func (g *Commands) tagForReaping(src, copied *File, destPath string) {
+	// Planned copies are yet to be made, there is no content to compare
+	if !isPlaceholder(copied.Id) && src.Md5Checksum != copied.Md5Checksum {
+		g.report.warn("Sources not tagged for reaping, copies unverified", "source of %s: checksums differ", destPath)
+		return
+	}
+
+	reapAfter := time.Now().Add(g.opts.TrashSourceAfter).UTC().Format(time.RFC3339)
+	if err := g.mut.setAppProperty(src.Id, ReapAfterKey, reapAfter); err != nil {
+		g.report.warn("Sources not tagged for reaping", "source of %s: %v", destPath, err)
+		return
+	}
+	if err := g.mut.setAppProperty(src.Id, ReapPendingKey, "true"); err != nil {
+		g.report.warn("Sources not tagged for reaping", "source of %s: %v", destPath, err)
+		return
+	}
+	g.report.count("Sources tagged for reaping", fmt.Sprintf("after %s", reapAfter))
+}
+
+// Reap trashes the sources of copies that were tagged for reaping and whose
+// grace period has elapsed, listing them for confirmation first. Sources still
+// within their grace period are reported along with when they are due.
+func (g *Commands) Reap() error {
+	g.report = newReport()
+	defer g.report.summarize(g.log)
+
+	now := time.Now()
+	var due []*File
+	for f := range g.rem.findByAppProperty(ReapPendingKey, "true") {
+		if f == nil {
+			continue
+		}
+		value, err := g.rem.appProperty(f.Id, ReapAfterKey)
+		if err != nil {
+			g.report.warn("Tagged sources skipped", "%s (%s): %v", f.Name, f.Id, err)
+			continue
+		}
+		reapAfter, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			g.report.warn("Tagged sources skipped", "%s (%s): %s: %v", f.Name, f.Id, ReapAfterKey, err)
+			continue
+		}
+		if now.Before(reapAfter) {
+			g.report.note("Not yet due", "%s (%s) at %s", f.Name, f.Id, reapAfter.Local().Format(time.RFC822))
+			continue
+		}
+		due = append(due, f)
+	}
+
+	if len(due) < 1 {
+		g.log.Logln("Nothing is due to be reaped")
+		return nil
+	}
+
+	for _, f := range due {
+		g.log.Logf("reap %s (%s)\n", f.Name, f.Id)
+	}
+
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			return fmt.Errorf("reap: noPrompt is set, use `%s` to trash the %d items above", ForceKey, len(due))
+		}
+		if !promptForChanges() {
+			return nil
+		}
+	}
+
+	var composedError error = nil
+	for _, f := range due {
+		err := g.mut.Trash(f.Id)
+		g.audit(AuditTrash, f, f.Name, "", err)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("reap: %s (%s): %v", f.Name, f.Id, err))
+			continue
+		}
+		// An untrashed source mustn't get reaped again
+		if err := g.rem.deleteAppProperty(f.Id, ReapPendingKey); err != nil {
+			g.report.warn("Reaped but still tagged", "%s (%s): %v", f.Name, f.Id, err)
+		}
+		g.report.count("Reaped", "trashed")
+	}
+	return composedError
+}
@@ -15,6 +15,8 @@
 package drive
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -33,6 +35,7 @@ import (
 	"github.com/odeke-em/statos"
 
 	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
 	expb "github.com/odeke-em/exponential-backoff"
 )
 
@@ -63,10 +66,23 @@ const (
 	OptNewRevision
 )
 
+const (
+	// remoteFileFields are the fields of a file that NewRemoteFile reads, requests
+	// ask for only these to keep the responses small so keep them in sync. These
+	// are v2 field names, the client hasn't been ported to the v3 API.
+	remoteFileFields googleapi.Field = "alternateLink,copyable,createdDate,description,downloadUrl," +
+		"editable,embedLink,etag,exportLinks,fileSize,id,labels,lastModifyingUserName,lastViewedByMeDate," +
+		"md5Checksum,mimeType,modifiedDate,originalFilename,ownerNames,parents,permissions,properties,shared," +
+		"thumbnailLink,title,userPermission,version,videoMediaMetadata,webContentLink,writersCanShare"
+
+	remoteFileListFields = "nextPageToken,items(" + remoteFileFields + ")"
+)
+
 var (
 	ErrPathNotExists                  = errors.New("remote path doesn't exist")
 	ErrNetLookup                      = errors.New("net lookup failed")
 	ErrClashesDetected                = fmt.Errorf("clashes detected. use `%s` to override this behavior", CLIOptionIgnoreNameClashes)
+	ErrPathAmbiguous                  = fmt.Errorf("many items have this name, list and fix them with `drive %s`", ClashesKey)
 	ErrGoogleApiInvalidQueryHardCoded = errors.New("googleapi: Error 400: Invalid query, invalid")
 )
 
@@ -83,16 +99,41 @@ type Remote struct {
 	client       *http.Client
 	service      *drive.Service
 	progressChan chan int
+	// pins are paths resolved to specific folders, e.g the one picked of many
+	// same-named folders. They are only set up before lookups start.
+	pins map[string]*File
+	// uploads are the resumable uploads underway, nil if not saved
+	uploads *uploadSessions
+	// index caches the ids of the items at paths, nil if not indexing
+	index *pathIndex
+	// uploadLimit and downloadLimit limit the rate of
+	// transfers, they are nil if transfers aren't limited
+	uploadLimit   *rateLimiter
+	downloadLimit *rateLimiter
+	// shortcuts dereferences shortcuts, nil if they aren't followed
+	shortcuts *shortcutFollower
+	// crypt encrypts names and content, nil if they aren't encrypted
+	crypt *cipherSuite
+	// drives are the shared drives and the folders known to be in them
+	drives *sharedDriveIndex
 }
 
 func NewRemoteContext(context *config.Context) *Remote {
-	client := newOAuthClient(context)
-	service, _ := drive.New(client)
+	return newRemote(newOAuthClient(context))
+}
+
+func newRemote(client *http.Client) *Remote {
+	drives := &sharedDriveIndex{}
+	scoped := *client
+	scoped.Transport = &sharedDrivesTransport{base: client.Transport, drives: drives}
+
+	service, _ := drive.New(&scoped)
 	progressChan := make(chan int)
 	return &Remote{
 		progressChan: progressChan,
 		service:      service,
-		client:       client,
+		client:       &scoped,
+		drives:       drives,
 	}
 }
 
@@ -103,35 +144,30 @@ func hasExportLinks(f *File) bool {
 	return len(f.ExportLinks) >= 1
 }
 
-func (r *Remote) changes(startChangeId int64) (chan *drive.Change, error) {
-	req := r.service.Changes.List()
+// changes lists the changes from startChangeId onwards, oldest first, along
+// with the largest change id as of the listing to continue from next time.
+func (r *Remote) changes(startChangeId int64) (changes []*drive.Change, largestChangeId int64, err error) {
+	req := r.service.Changes.List().IncludeSubscribed(false)
 	if startChangeId >= 0 {
 		req = req.StartChangeId(startChangeId)
 	}
 
-	changeChan := make(chan *drive.Change)
-	go func() {
-		pageToken := ""
-		for {
-			if pageToken != "" {
-				req = req.PageToken(pageToken)
-			}
-			res, err := req.Do()
-			if err != nil {
-				break
-			}
-			for _, chItem := range res.Items {
-				changeChan <- chItem
-			}
-			pageToken = res.NextPageToken
-			if pageToken == "" {
-				break
-			}
+	pageToken := ""
+	for {
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
 		}
-		close(changeChan)
-	}()
-
-	return changeChan, nil
+		res, err := req.Do()
+		if err != nil {
+			return nil, 0, err
+		}
+		changes = append(changes, res.Items...)
+		largestChangeId = res.LargestChangeId
+		pageToken = res.NextPageToken
+		if pageToken == "" {
+			return changes, largestChangeId, nil
+		}
+	}
 }
 
 func buildExpression(parentId string, typeMask int, inTrash bool) string {
@@ -171,12 +207,12 @@ func RetrieveRefreshToken(ctx context.Context, context *config.Context) (string,
 }
 
 func (r *Remote) FindById(id string) (file *File, err error) {
-	req := r.service.Files.Get(id)
+	req := r.service.Files.Get(id).Fields(remoteFileFields)
 	var f *drive.File
 	if f, err = req.Do(); err != nil {
 		return
 	}
-	return NewRemoteFile(f), nil
+	return r.crypt.decryptFile(NewRemoteFile(f)), nil
 }
 
 func retryableChangeOp(fn func() (interface{}, error)) *expb.ExponentialBacker {
@@ -188,10 +224,48 @@ func retryableChangeOp(fn func() (interface{}, error)) *expb.ExponentialBacker {
 	}
 }
 
+// pinPath makes lookups of p, and of the paths under it, resolve via f.
+func (r *Remote) pinPath(p string, f *File) {
+	if r.pins == nil {
+		r.pins = make(map[string]*File)
+	}
+	r.pins[p] = f
+}
+
+// pinned returns the folder that the longest pinned prefix of p is pinned
+// to, along with the segments of p that are left to resolve under it.
+func (r *Remote) pinned(p string) (*File, []string) {
+	var longest string
+	var pin *File
+	for prefix, f := range r.pins {
+		if (p == prefix || strings.HasPrefix(p, prefix+"/")) && len(prefix) >= len(longest) {
+			longest, pin = prefix, f
+		}
+	}
+	if pin == nil || p == longest {
+		return pin, nil
+	}
+	return pin, strings.Split(strings.TrimPrefix(p, longest+"/"), "/")
+}
+
 func (r *Remote) findByPath(p string, trashed bool) (*File, error) {
+	if !trashed && len(r.pins) >= 1 {
+		if pin, rest := r.pinned(p); pin != nil {
+			if len(rest) < 1 {
+				return pin, nil
+			}
+			return r.findByPathRecv(pin.Id, rest)
+		}
+	}
 	if rootLike(p) {
 		return r.FindById("root")
 	}
+	if file, ok, err := r.findBySharedDrivePath(p, trashed); ok {
+		return file, err
+	}
+	if !trashed && r.index != nil {
+		return r.findByPathIndexed(p)
+	}
 	parts := strings.Split(p, "/")
 	finder := r.findByPathRecv
 	if trashed {
@@ -200,18 +274,72 @@ func (r *Remote) findByPath(p string, trashed bool) (*File, error) {
 	return finder("root", parts[1:])
 }
 
+// findAllByPath returns all the items at p, of which there can be
+// many since items in a folder can have the same names.
+func (r *Remote) findAllByPath(p string) ([]*File, error) {
+	if rootLike(p) {
+		root, err := r.FindById("root")
+		if err != nil {
+			return nil, err
+		}
+		return []*File{root}, nil
+	}
+
+	parentIds := []string{"root"}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if name, rest, ok := splitSharedDrivePath(p); ok {
+		root, err := r.sharedDriveRoot(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) < 1 {
+			return []*File{root}, nil
+		}
+		parentIds, parts = []string{root.Id}, rest
+	}
+
+	var matches []*File
+	for _, part := range parts {
+		matches = nil
+		for _, parentId := range parentIds {
+			req := r.service.Files.List()
+			req.Q(fmt.Sprintf("%s in parents and title = %s and trashed=false",
+				customQuote(parentId), customQuote(urlToPath(part, false))))
+			for f := range reqDoPage(req, true, false) {
+				if driveId := r.drives.drive(parentId); driveId != "" && f.IsDir {
+					r.drives.mark(f.Id, driveId)
+				}
+				matches = append(matches, f)
+			}
+		}
+
+		parentIds = nil
+		for _, match := range matches {
+			parentIds = append(parentIds, match.Id)
+		}
+	}
+
+	if len(matches) < 1 {
+		return nil, ErrPathNotExists
+	}
+	return matches, nil
+}
+
 func (r *Remote) FindByPath(p string) (file *File, err error) {
-	return r.findByPath(p, false)
+	file, err = r.findByPath(r.crypt.encryptPath(p), false)
+	return r.crypt.decryptFile(file), err
 }
 
 func (r *Remote) FindByPathTrashed(p string) (file *File, err error) {
-	return r.findByPath(p, true)
+	file, err = r.findByPath(r.crypt.encryptPath(p), true)
+	return r.crypt.decryptFile(file), err
 }
 
 func reqDoPage(req *drive.FilesListCall, hidden bool, promptOnPagination bool) chan *File {
 	fileChan := make(chan *File)
 
 	throttle := time.Tick(1e7)
+	req = req.Fields(remoteFileListFields)
 
 	go func() {
 		pageToken := ""
@@ -221,7 +349,7 @@ func reqDoPage(req *drive.FilesListCall, hidden bool, promptOnPagination bool) c
 			}
 			results, err := req.Do()
 			if err != nil {
-				fmt.Println(err)
+				fmt.Fprintln(os.Stderr, err)
 				break
 			}
 
@@ -257,7 +385,18 @@ func reqDoPage(req *drive.FilesListCall, hidden bool, promptOnPagination bool) c
 func (r *Remote) findByParentIdRaw(parentId string, trashed, hidden bool) (fileChan chan *File) {
 	req := r.service.Files.List()
 	req.Q(fmt.Sprintf("%s in parents and trashed=%v", customQuote(parentId), trashed))
-	return reqDoPage(req, hidden, false)
+	if r.crypt != nil {
+		// Hidden items are only known by their decrypted names
+		children := reqDoPage(req, true, false)
+		if !trashed {
+			children = r.followShortcuts(children)
+		}
+		return r.crypt.decryptFiles(children, hidden)
+	}
+	if trashed {
+		return reqDoPage(req, hidden, false)
+	}
+	return r.followShortcuts(reqDoPage(req, hidden, false))
 }
 
 func (r *Remote) FindByParentId(parentId string, hidden bool) chan *File {
@@ -273,16 +412,18 @@ func (r *Remote) EmptyTrash() error {
 }
 
 func (r *Remote) Trash(id string) error {
-	_, err := r.service.Files.Trash(id).Do()
+	r.index.forget(id)
+	_, err := r.service.Files.Trash(id).Fields("id").Do()
 	return err
 }
 
 func (r *Remote) Untrash(id string) error {
-	_, err := r.service.Files.Untrash(id).Do()
+	_, err := r.service.Files.Untrash(id).Fields("id").Do()
 	return err
 }
 
 func (r *Remote) Delete(id string) error {
+	r.index.forget(id)
 	return r.service.Files.Delete(id).Do()
 }
 
@@ -303,37 +444,55 @@ func (r *Remote) listPermissions(id string) ([]*drive.Permission, error) {
 }
 
 func (r *Remote) insertPermissions(permInfo *permission) (*drive.Permission, error) {
+	req := r.service.Permissions.Insert(permInfo.fileId, newDrivePermission(permInfo))
+
+	if permInfo.message != "" {
+		req = req.EmailMessage(permInfo.message)
+	}
+	req = req.SendNotificationEmails(permInfo.notify)
+	return req.Do()
+}
+
+func newDrivePermission(permInfo *permission) *drive.Permission {
 	perm := &drive.Permission{
 		Role: permInfo.role.String(),
 		Type: permInfo.accountType.String(),
 	}
 
-	if permInfo.value != "" {
-		perm.Value = permInfo.value
+	// Commenting is granted as an additional role on top of reading
+	if permInfo.role == Commenter {
+		perm.Role = "reader"
+		perm.AdditionalRoles = []string{"commenter"}
 	}
-	req := r.service.Permissions.Insert(permInfo.fileId, perm)
 
-	if permInfo.message != "" {
-		req = req.EmailMessage(permInfo.message)
+	if permInfo.value != "" {
+		perm.Value = permInfo.value
 	}
-	req = req.SendNotificationEmails(permInfo.notify)
-	return req.Do()
+	perm.WithLink = permInfo.withLink
+	return perm
 }
 
 func (r *Remote) deletePermissions(id string, accountType AccountType) error {
 	return r.service.Permissions.Delete(id, accountType.String()).Do()
 }
 
+func (r *Remote) deletePermission(fileId, permissionId string) error {
+	return r.service.Permissions.Delete(fileId, permissionId).Do()
+}
+
 func (r *Remote) Unpublish(id string) error {
 	return r.deletePermissions(id, Anyone)
 }
 
-func (r *Remote) Publish(id string) (string, error) {
+// Publish grants anyone role on the file with id, either only those with
+// its link or anyone at all, who can then also find it by searching.
+func (r *Remote) Publish(id string, role Role, withLink bool) (string, error) {
 	_, err := r.insertPermissions(&permission{
 		fileId:      id,
 		value:       "",
-		role:        Reader,
+		role:        role,
 		accountType: Anyone,
+		withLink:    withLink,
 	})
 	if err != nil {
 		return "", err
@@ -363,7 +522,7 @@ func (r *Remote) Download(id string, exportURL string) (io.ReadCloser, error) {
 		if resp == nil {
 			err = fmt.Errorf("bug on: download for url \"%s\". resp and err are both nil", url)
 		} else if httpOk(resp.StatusCode) { // TODO: Handle other statusCodes e.g redirects?
-			body = resp.Body
+			body = r.downloadLimit.readCloser(resp.Body)
 		} else {
 			err = fmt.Errorf("download: failed for url \"%s\". StatusCode: %v", url, resp.StatusCode)
 		}
@@ -372,8 +531,25 @@ func (r *Remote) Download(id string, exportURL string) (io.ReadCloser, error) {
 	return body, err
 }
 
+// contentLength returns the length of the content at url, as reported
+// for it without downloading it, failing if it isn't reported.
+func (r *Remote) contentLength(url string) (int64, error) {
+	resp, err := r.client.Head(url)
+	if err != nil {
+		return -1, err
+	}
+	resp.Body.Close()
+	if !httpOk(resp.StatusCode) {
+		return -1, fmt.Errorf("head: failed for url \"%s\". StatusCode: %v", url, resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return -1, fmt.Errorf("head: no content length for url \"%s\"", url)
+	}
+	return resp.ContentLength, nil
+}
+
 func (r *Remote) Touch(id string) (*File, error) {
-	f, err := r.service.Files.Touch(id).Do()
+	f, err := r.service.Files.Touch(id).Fields(remoteFileFields).Do()
 	if err != nil {
 		return nil, err
 	}
@@ -417,12 +593,28 @@ type upsertOpt struct {
 	ignoreChecksum bool
 	mimeKey        string
 	nonStatable    bool
+	// progress is the transfer's progress, if it is tracked
+	progress *fileProgress
+	// title is the name the file goes by remotely, if not that of src
+	title string
+	// properties are the public properties to stamp the file with
+	properties map[string]string
+	// ocrLanguage is the ISO 639-1 code of the language to OCR in
+	ocrLanguage string
+	// appProperties are the properties private to this app to stamp
+	// the file with e.g its local attributes that are preserved
+	appProperties map[string]string
+	// compress is set if the content is to be gzipped as it is uploaded
+	compress bool
 }
 
-func togglePropertiesInsertCall(req *drive.FilesInsertCall, mask int) *drive.FilesInsertCall {
+func togglePropertiesInsertCall(req *drive.FilesInsertCall, mask int, ocrLanguage string) *drive.FilesInsertCall {
 	// TODO: if ocr toggled respect the quota limits if ocr is enabled.
 	if ocr(mask) {
 		req = req.Ocr(true)
+		if ocrLanguage != "" {
+			req = req.OcrLanguage(ocrLanguage)
+		}
 	}
 	if convert(mask) {
 		req = req.Convert(true)
@@ -436,10 +628,13 @@ func togglePropertiesInsertCall(req *drive.FilesInsertCall, mask int) *drive.Fil
 	return req
 }
 
-func togglePropertiesUpdateCall(req *drive.FilesUpdateCall, mask int) *drive.FilesUpdateCall {
+func togglePropertiesUpdateCall(req *drive.FilesUpdateCall, mask int, ocrLanguage string) *drive.FilesUpdateCall {
 	// TODO: if ocr toggled respect the quota limits if ocr is enabled.
 	if ocr(mask) {
 		req = req.Ocr(true)
+		if ocrLanguage != "" {
+			req = req.OcrLanguage(ocrLanguage)
+		}
 	}
 	if convert(mask) {
 		req = req.Convert(true)
@@ -460,6 +655,14 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 		Parents: []*drive.ParentReference{&drive.ParentReference{Id: args.parentId}},
 	}
 
+	if args.title != "" {
+		uploaded.Title = urlToPath(args.title, false)
+	}
+
+	if r.crypt != nil {
+		uploaded.Title = r.crypt.encryptName(args.src.Name)
+	}
+
 	if args.src.IsDir {
 		uploaded.MimeType = DriveFolderMimeType
 	}
@@ -472,19 +675,34 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 		uploaded.MimeType = guessMimeType(args.mimeKey)
 	}
 
+	if args.compress {
+		uploaded.MimeType = GzipMimeType
+	}
+
 	// Ensure that the ModifiedDate is retrieved from local
 	uploaded.ModifiedDate = toUTCString(args.src.ModTime)
 
+	if len(args.properties) > 0 {
+		uploaded.Properties = driveProperties(args.properties, PropertyVisibilityPublic)
+	}
+	if len(args.appProperties) > 0 {
+		uploaded.Properties = append(uploaded.Properties, driveProperties(args.appProperties, AppPropertyVisibility)...)
+	}
+
 	if args.src.Id == "" {
-		req := r.service.Files.Insert(uploaded)
+		req := r.service.Files.Insert(uploaded).Fields(remoteFileFields)
 
 		if !args.src.IsDir && body != nil {
+			if size, ok := resumableSize(args); ok && r.crypt == nil {
+				f, err = r.resumableUpsert(uploaded, args, size)
+				return f, true, err
+			}
 			req = req.Media(body)
 			mediaInserted = true
 		}
 
 		// Toggle the respective properties
-		req = togglePropertiesInsertCall(req, args.mask)
+		req = togglePropertiesInsertCall(req, args.mask, args.ocrLanguage)
 
 		if uploaded, err = req.Do(); err != nil {
 			return
@@ -495,23 +713,29 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 	}
 
 	// update the existing
-	req := r.service.Files.Update(args.src.Id, uploaded)
+	req := r.service.Files.Update(args.src.Id, uploaded).Fields(remoteFileFields)
 
 	// We always want it to match up with the local time
 	req.SetModifiedDate(true)
 
 	if !args.src.IsDir {
 		if args.dest == nil || args.nonStatable {
-			req = req.Media(body)
 			mediaInserted = true
 		} else if mask := fileDifferences(args.src, args.dest, args.ignoreChecksum); checksumDiffers(mask) {
 			mediaInserted = true
-			req = req.Media(body)
 		}
 	}
 
+	if mediaInserted {
+		if size, ok := resumableSize(args); ok && r.crypt == nil {
+			f, err = r.resumableUpsert(uploaded, args, size)
+			return
+		}
+		req = req.Media(body)
+	}
+
 	// Next toggle the appropriate properties
-	req = togglePropertiesUpdateCall(req, args.mask)
+	req = togglePropertiesUpdateCall(req, args.mask, args.ocrLanguage)
 
 	if uploaded, err = req.Do(); err != nil {
 		return
@@ -525,7 +749,8 @@ func (r *Remote) rename(fileId, newTitle string) (*File, error) {
 		Title: newTitle,
 	}
 
-	req := r.service.Files.Update(fileId, f)
+	r.index.forget(fileId)
+	req := r.service.Files.Update(fileId, f).Fields(remoteFileFields)
 	uploaded, err := req.Do()
 	if err != nil {
 		return nil, err
@@ -535,12 +760,107 @@ func (r *Remote) rename(fileId, newTitle string) (*File, error) {
 }
 
 func (r *Remote) removeParent(fileId, parentId string) error {
+	r.index.forget(fileId)
 	return r.service.Parents.Delete(fileId, parentId).Do()
 }
 
+// setAppProperty sets a property on the file that is private to this app,
+// replacing any previous value of the property.
+func (r *Remote) setAppProperty(fileId, key, value string) error {
+	return r.setProperty(fileId, key, value, AppPropertyVisibility)
+}
+
+// restrict sets whether viewers are restricted from downloading, printing and
+// copying the file and whether its writers can share it. The request is made
+// by hand since the client library leaves out fields that are false.
+func (r *Remote) restrict(fileId string, downloadRestricted, writersCanShare bool) error {
+	return r.patchFields(fileId, map[string]interface{}{
+		"labels":          map[string]bool{"restricted": downloadRestricted},
+		"writersCanShare": writersCanShare,
+	})
+}
+
+// patchFields patches the fields of the file with fileId to the values set
+// in fields, as is, since false values can't be sent via Files.Patch.
+func (r *Remote) patchFields(fileId string, fields map[string]interface{}) error {
+	return r.patch("files/"+url.QueryEscape(fileId), fields)
+}
+
+// patch sends fields, as is, as a patch of resource e.g "files/<id>".
+func (r *Remote) patch(resource string, fields map[string]interface{}) error {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PATCH", r.service.BasePath+resource, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !httpOk(resp.StatusCode) {
+		return fmt.Errorf("patch: failed for %s. StatusCode: %v", resource, resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *Remote) star(fileId string) error {
+	_, err := r.service.Files.Patch(fileId, &drive.File{Labels: &drive.FileLabels{Starred: true}}).Fields("id").Do()
+	return err
+}
+
+func (r *Remote) unstar(fileId string) error {
+	return r.patchFields(fileId, map[string]interface{}{
+		"labels": map[string]bool{"starred": false},
+	})
+}
+
+func (r *Remote) describe(fileId, description string) error {
+	_, err := r.service.Files.Patch(fileId, &drive.File{Description: description}).Fields("id").Do()
+	return err
+}
+
+// appProperty returns the value of the property private to this app named key.
+func (r *Remote) appProperty(fileId, key string) (string, error) {
+	prop, err := r.service.Properties.Get(fileId, key).Visibility(AppPropertyVisibility).Do()
+	if err != nil {
+		return "", err
+	}
+	return prop.Value, nil
+}
+
+func (r *Remote) deleteAppProperty(fileId, key string) error {
+	return r.deleteProperty(fileId, key, AppPropertyVisibility)
+}
+
+// findByAppProperty finds the untrashed files whose property private to
+// this app named key has value.
+func (r *Remote) findByAppProperty(key, value string) chan *File {
+	req := r.service.Files.List()
+	req.Q(fmt.Sprintf("properties has { key=%s and value=%s and visibility=%s } and trashed=false",
+		customQuote(key), customQuote(value), customQuote(AppPropertyVisibility)))
+	return reqDoPage(req, true, false)
+}
+
 func (r *Remote) insertParent(fileId, parentId string) error {
 	parent := &drive.ParentReference{Id: parentId}
-	_, err := r.service.Parents.Insert(fileId, parent).Do()
+	_, err := r.service.Parents.Insert(fileId, parent).Fields("id").Do()
+	return err
+}
+
+// reparent moves the file from one parent to another in a single request,
+// as items in shared drives can't have a second parent even for a moment.
+func (r *Remote) reparent(fileId, fromParentId, toParentId string) error {
+	r.index.forget(fileId)
+	_, err := r.service.Files.Patch(fileId, &drive.File{}).
+		AddParents(toParentId).RemoveParents(fromParentId).Fields("id").Do()
 	return err
 }
 
@@ -552,13 +872,63 @@ func (r *Remote) copy(newName, parentId string, srcFile *File) (*File, error) {
 	if parentId != "" {
 		f.Parents = []*drive.ParentReference{&drive.ParentReference{Id: parentId}}
 	}
-	copied, err := r.service.Files.Copy(srcFile.Id, f).Do()
+
+	emitter := func() (interface{}, error) {
+		copied, err := r.service.Files.Copy(srcFile.Id, f).Fields(remoteFileFields).Do()
+		return &tuple{first: copied, last: err}, err
+	}
+	retrier := &expb.ExponentialBacker{
+		Do:          emitter,
+		StatusCheck: rateLimitedErrorCheck,
+		RetryCount:  MaxRateLimitedRetryCount,
+	}
+
+	res, err := expb.ExponentialBackOffSync(retrier)
 	if err != nil {
 		return nil, err
 	}
+	copied, _ := res.(*tuple).first.(*drive.File)
 	return NewRemoteFile(copied), nil
 }
 
+// reupload makes a new file named newName in parentId out of the content of
+// srcFile, as exported from exportURL if set. This gets a copy of files that
+// can be read but not copied. With convert set, the exported content is
+// converted back into the Google Docs format that it was exported from.
+func (r *Remote) reupload(newName, parentId string, srcFile *File, exportURL string, convert bool) (*File, error) {
+	body, err := r.Download(srcFile.Id, exportURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return r.insertContent(body, newName, parentId, srcFile, convert)
+}
+
+// insertContent creates a file named newName in the folder parentId with the
+// content read from body, which is that of srcFile e.g downloaded from it.
+func (r *Remote) insertContent(body io.Reader, newName, parentId string, srcFile *File, convert bool) (*File, error) {
+	f := &drive.File{
+		Title:        urlToPath(newName, false),
+		ModifiedDate: toUTCString(srcFile.ModTime),
+		Parents:      []*drive.ParentReference{&drive.ParentReference{Id: parentId}},
+	}
+	// Exported content's type is left to be detected
+	if !hasExportLinks(srcFile) {
+		f.MimeType = srcFile.MimeType
+	}
+
+	req := r.service.Files.Insert(f).Fields(remoteFileFields).Media(r.uploadLimit.reader(body))
+	if convert {
+		req = req.Convert(true)
+	}
+	uploaded, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+	return NewRemoteFile(uploaded), nil
+}
+
 func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	/*
 	   // TODO: (@odeke-em) decide:
@@ -571,11 +941,35 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	}
 
 	var body io.Reader
-	if !args.src.IsDir {
+	if args.src.SymlinkTarget != "" {
+		body = strings.NewReader(args.src.SymlinkTarget)
+	} else if !args.src.IsDir {
 		body, err = os.Open(args.fsAbsPath)
 		if err != nil {
 			return
 		}
+		body = r.uploadLimit.reader(body)
+	}
+
+	if args.compress && body != nil {
+		body = &gzipReader{src: body}
+		if r.crypt == nil {
+			args.appProperties = plainProperties(args.src, args.appProperties)
+		} else if args.appProperties == nil {
+			args.appProperties = make(map[string]string)
+		}
+		args.appProperties[CompressionKey] = CompressionGzip
+	}
+
+	if r.crypt != nil {
+		if body != nil {
+			if body, err = r.crypt.encryptReader(body); err != nil {
+				return
+			}
+		}
+		if args.appProperties, err = r.crypt.encryptionProperties(args.src, args.appProperties); err != nil {
+			return
+		}
 	}
 
 	bd := statos.NewReader(body)
@@ -583,6 +977,7 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	go func() {
 		commChan := bd.ProgressChan()
 		for n := range commChan {
+			args.progress.add(int64(n))
 			r.progressChan <- n
 		}
 	}()
@@ -629,7 +1024,20 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 		}
 	}
 
-	return f, err
+	// Content uploaded as it is over compressed content is no longer compressed
+	if err == nil && f != nil && !args.compress && compressed(args.dest) {
+		err = r.deleteAppProperty(f.Id, CompressionKey)
+	}
+	// The checksum and size in the clear of content once encrypted go with it
+	if err == nil && f != nil && r.crypt != nil && args.dest != nil {
+		for _, key := range []string{PlainMd5Key, PlainSizeKey} {
+			if _, ok := args.dest.Properties[key]; ok && err == nil {
+				err = r.deleteAppProperty(f.Id, key)
+			}
+		}
+	}
+
+	return r.crypt.decryptFile(f), err
 }
 
 func (r *Remote) findShared(p []string) (chan *File, error) {
@@ -680,7 +1088,72 @@ func (r *Remote) FindMatches(mq *matchQuery) (chan *File, error) {
 func (r *Remote) findChildren(parentId string, trashed bool) chan *File {
 	req := r.service.Files.List()
 	req.Q(fmt.Sprintf("%s in parents and trashed=%v", customQuote(parentId), trashed))
-	return reqDoPage(req, true, false)
+	children := reqDoPage(req, true, false)
+	if driveId := r.drives.drive(parentId); driveId != "" {
+		children = r.markSharedFolders(driveId, children)
+	}
+	if trashed {
+		return children
+	}
+	return r.followShortcuts(children)
+}
+
+// listChildrenPage lists one page of the children of parentId starting at
+// pageToken, returning the token of the next page if there is one.
+func (r *Remote) listChildrenPage(parentId, pageToken string, pageSize int64) ([]*File, string, error) {
+	req := r.service.Files.List()
+	req.Q(fmt.Sprintf("%s in parents and trashed=false", customQuote(parentId)))
+	if pageSize > 0 {
+		req.MaxResults(pageSize)
+	}
+	if pageToken != "" {
+		req.PageToken(pageToken)
+	}
+
+	results, err := req.Fields(remoteFileListFields).Do()
+	if err != nil {
+		return nil, "", err
+	}
+
+	files := make([]*File, 0, len(results.Items))
+	for _, f := range results.Items {
+		files = append(files, NewRemoteFile(f))
+	}
+	return files, results.NextPageToken, nil
+}
+
+func (r *Remote) hasChildren(parentId string) (bool, error) {
+	req := r.service.Files.List()
+	req.Q(fmt.Sprintf("%s in parents and trashed=false", customQuote(parentId)))
+	results, err := req.MaxResults(1).Fields("items(id)").Do()
+	if err != nil {
+		return false, err
+	}
+	return len(results.Items) >= 1, nil
+}
+
+// pathOf works out the path of the file with id, relative to the root
+// of the drive, by walking up its first parents.
+func (r *Remote) pathOf(id string) (string, error) {
+	var names []string
+	for {
+		f, err := r.service.Files.Get(id).Fields("id,title,parents").Do()
+		if err != nil {
+			return "", err
+		}
+		if len(f.Parents) < 1 {
+			if root, rootErr := r.FindById("root"); rootErr == nil && root.Id == f.Id {
+				return "/" + strings.Join(names, "/"), nil
+			}
+			return "", fmt.Errorf("%s is not in your drive", f.Title)
+		}
+
+		names = append([]string{urlToPath(f.Title, true)}, names...)
+		if f.Parents[0].IsRoot {
+			return "/" + strings.Join(names, "/"), nil
+		}
+		id = f.Parents[0].Id
+	}
 }
 
 func (r *Remote) About() (about *drive.About, err error) {
@@ -689,8 +1162,7 @@ func (r *Remote) About() (about *drive.About, err error) {
 
 func (r *Remote) findByPathRecvRaw(parentId string, p []string, trashed bool) (file *File, err error) {
 	// find the file or directory under parentId and titled with p[0]
-	req := r.service.Files.List()
-	// TODO: use field selectors
+	req := r.service.Files.List().Fields(remoteFileListFields)
 	var expr string
 	head := urlToPath(p[0], false)
 	if trashed {
@@ -717,9 +1189,15 @@ func (r *Remote) findByPathRecvRaw(parentId string, p []string, trashed bool) (f
 		return nil, ErrPathNotExists
 	}
 
-	first := files.Items[0]
+	first, err := r.dereference(NewRemoteFile(files.Items[0]))
+	if err != nil {
+		return nil, err
+	}
+	if driveId := r.drives.drive(parentId); driveId != "" && first.IsDir {
+		r.drives.mark(first.Id, driveId)
+	}
 	if len(p) == 1 {
-		return NewRemoteFile(first), nil
+		return first, nil
 	}
 	return r.findByPathRecvRaw(first.Id, p[1:], trashed)
 }
@@ -743,6 +1221,14 @@ func newAuthConfig(context *config.Context) *oauth2.Config {
 }
 
 func newOAuthClient(configContext *config.Context) *http.Client {
+	if len(configContext.ServiceAccountKey) > 0 {
+		client, err := serviceAccountClient(configContext)
+		if err != nil {
+			return &http.Client{Transport: failingTransport{err: err}}
+		}
+		return client
+	}
+
 	config := newAuthConfig(configContext)
 
 	token := oauth2.Token{
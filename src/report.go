@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/odeke-em/log"
+)
+
+type reportSection struct {
+	title   string
+	warning bool
+	items   []string
+	keys    []string
+	counts  map[string]int
+}
+
+// report collects per-item outcomes from concurrently running
+// operations so that they can be summarized once all work is done.
+type report struct {
+	sync.Mutex
+	order    []string
+	sections map[string]*reportSection
+}
+
+func newReport() *report {
+	return &report{
+		sections: make(map[string]*reportSection),
+	}
+}
+
+func (r *report) section(title string, warning bool) *reportSection {
+	section, ok := r.sections[title]
+	if !ok {
+		section = &reportSection{title: title, warning: warning, counts: make(map[string]int)}
+		r.sections[title] = section
+		r.order = append(r.order, title)
+	}
+	return section
+}
+
+func (r *report) add(title string, warning bool, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	section := r.section(title, warning)
+	section.items = append(section.items, fmt.Sprintf(format, args...))
+}
+
+// count increments the tally of key under title, for distributions
+// where only the number of occurrences of each key is of interest.
+func (r *report) count(title, key string) {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	section := r.section(title, false)
+	if _, seen := section.counts[key]; !seen {
+		section.keys = append(section.keys, key)
+	}
+	section.counts[key] += 1
+}
+
+// tally returns the tally of key under title.
+func (r *report) tally(title, key string) int {
+	if r == nil {
+		return 0
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if section, ok := r.sections[title]; ok {
+		return section.counts[key]
+	}
+	return 0
+}
+
+// note records an informational item under title.
+func (r *report) note(title, format string, args ...interface{}) {
+	r.add(title, false, format, args...)
+}
+
+// warn records an item under title that will be summarized to stderr.
+func (r *report) warn(title, format string, args ...interface{}) {
+	r.add(title, true, format, args...)
+}
+
+func (r *report) summarize(logy *log.Logger) {
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, title := range r.order {
+		section := r.sections[title]
+		logf := logy.Logf
+		if section.warning {
+			logf = logy.LogErrf
+		}
+
+		logf("\n%s (%d):\n", section.title, len(section.items)+len(section.keys))
+		for _, item := range section.items {
+			logf("  %s\n", item)
+		}
+		for _, key := range section.keys {
+			logf("  %s: %d\n", key, section.counts[key])
+		}
+	}
+}
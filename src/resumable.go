@@ -0,0 +1,372 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	UploadSessionsSuffix = "uploads"
+
+	DriveUploadURL = "https://www.googleapis.com/upload/drive/v2/files"
+
+	// ResumableUploadThreshold is the size from which files are uploaded
+	// in resumable sessions, smaller ones are cheap enough to start over.
+	ResumableUploadThreshold = 32 * 1024 * 1024
+	// ResumableChunkSize must be a multiple of 256KiB as per Drive.
+	ResumableChunkSize = 8 * 1024 * 1024
+
+	statusResumeIncomplete = 308
+)
+
+// uploadSession is a resumable upload underway. It only resumes the
+// upload of the same content, that is of the same size and mod time.
+type uploadSession struct {
+	URI     string    `json:"uri"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Offset is how far into the content the upload had got
+	Offset int64 `json:"offset"`
+}
+
+// uploadSessions are the resumable uploads underway keyed by the absolute
+// paths of the files being uploaded. They are saved on every change so that
+// an upload interrupted by a crash or network drop is resumed by the next push.
+type uploadSessions struct {
+	sync.Mutex
+	path     string
+	Sessions map[string]*uploadSession `json:"sessions"`
+}
+
+// uploadSessionsPath is where the upload sessions of the account of the
+// context are kept, each profile apart as sessions are its account's e.g
+// .gd/work.uploads.
+func uploadSessionsPath(context *config.Context) string {
+	name := UploadSessionsSuffix
+	if context.Profile != "" {
+		name = fmt.Sprintf("%s.%s", context.Profile, UploadSessionsSuffix)
+	}
+	return path.Join(context.AbsPathOf(""), config.GDDirSuffix, name)
+}
+
+func loadUploadSessions(p string) (*uploadSessions, error) {
+	us := &uploadSessions{
+		path:     p,
+		Sessions: make(map[string]*uploadSession),
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return us, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, us); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if us.Sessions == nil {
+		us.Sessions = make(map[string]*uploadSession)
+	}
+	return us, nil
+}
+
+// get returns the session uploading the content of fsPath, if any. Sessions
+// aren't kept if there's nowhere to save them so get on nil returns nil.
+func (us *uploadSessions) get(fsPath string, size int64, modTime time.Time) *uploadSession {
+	if us == nil {
+		return nil
+	}
+
+	us.Lock()
+	defer us.Unlock()
+
+	sess := us.Sessions[fsPath]
+	if sess == nil || sess.Size != size || !sess.ModTime.Equal(modTime) {
+		return nil
+	}
+	dup := *sess
+	return &dup
+}
+
+func (us *uploadSessions) put(fsPath string, sess *uploadSession) error {
+	if us == nil {
+		return nil
+	}
+
+	us.Lock()
+	defer us.Unlock()
+
+	dup := *sess
+	us.Sessions[fsPath] = &dup
+	return us.save()
+}
+
+func (us *uploadSessions) remove(fsPath string) error {
+	if us == nil {
+		return nil
+	}
+
+	us.Lock()
+	defer us.Unlock()
+
+	if _, ok := us.Sessions[fsPath]; !ok {
+		return nil
+	}
+	delete(us.Sessions, fsPath)
+	return us.save()
+}
+
+// save writes out the sessions, to a temporary file first so that a
+// failed save doesn't clobber them. It expects the lock to be held.
+func (us *uploadSessions) save() error {
+	data, err := json.Marshal(us)
+	if err != nil {
+		return err
+	}
+	tmpPath := us.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, config.O_RWForAll); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, us.path)
+}
+
+// resumableSize returns the size of the file being upserted if it is
+// big enough to be uploaded in a resumable session.
+func resumableSize(args *upsertOpt) (int64, bool) {
+	if args.src != nil && args.src.SymlinkTarget != "" || args.compress {
+		return 0, false
+	}
+	fi, err := os.Stat(args.fsAbsPath)
+	if err != nil || !fi.Mode().IsRegular() {
+		return 0, false
+	}
+	return fi.Size(), fi.Size() >= ResumableUploadThreshold
+}
+
+func upsertParams(mask int, ocrLanguage string) url.Values {
+	params := url.Values{}
+	params.Set("uploadType", "resumable")
+	if ocr(mask) {
+		params.Set("ocr", "true")
+		if ocrLanguage != "" {
+			params.Set("ocrLanguage", ocrLanguage)
+		}
+	}
+	if convert(mask) {
+		params.Set("convert", "true")
+	}
+	if pin(mask) {
+		params.Set("pinned", "true")
+	}
+	if indexContent(mask) {
+		params.Set("useContentAsIndexableText", "true")
+	}
+	return params
+}
+
+// resumableUpsert uploads the content of the file being upserted with its
+// metadata in ResumableChunkSize chunks, resuming the session of an earlier
+// interrupted upload of the same content if there is one.
+func (r *Remote) resumableUpsert(meta *drive.File, args *upsertOpt, size int64) (*File, error) {
+	fsPath := args.fsAbsPath
+	sess := r.uploads.get(fsPath, size, args.src.ModTime)
+
+	if sess != nil {
+		offset, uploaded, err := r.uploadStatus(sess)
+		switch {
+		case err != nil:
+			// Most likely the session expired, it lasts about a week
+			sess = nil
+		case uploaded != nil:
+			r.uploads.remove(fsPath)
+			return NewRemoteFile(uploaded), nil
+		default:
+			sess.Offset = offset
+			args.progress.add(offset)
+		}
+	}
+
+	if sess == nil {
+		uri, err := r.startUploadSession(meta, args, size)
+		if err != nil {
+			return nil, err
+		}
+		sess = &uploadSession{URI: uri, Size: size, ModTime: args.src.ModTime}
+		if err := r.uploads.put(fsPath, sess); err != nil {
+			return nil, fmt.Errorf("saving upload session: %v", err)
+		}
+	}
+
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	for {
+		if _, err := f.Seek(sess.Offset, os.SEEK_SET); err != nil {
+			return nil, err
+		}
+
+		end := sess.Offset + ResumableChunkSize
+		if end > size {
+			end = size
+		}
+
+		req, err := http.NewRequest("PUT", sess.URI, r.uploadLimit.reader(io.LimitReader(f, end-sess.Offset)))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = end - sess.Offset
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", sess.Offset, end-1, size))
+
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		offset, uploaded, err := uploadProgress(resp)
+		if err != nil {
+			if isGone(err) {
+				r.uploads.remove(fsPath)
+			}
+			return nil, err
+		}
+
+		if uploaded != nil {
+			args.progress.add(size - sess.Offset)
+			r.progressChan <- int(size - sess.Offset)
+			r.uploads.remove(fsPath)
+			return NewRemoteFile(uploaded), nil
+		}
+
+		args.progress.add(offset - sess.Offset)
+		r.progressChan <- int(offset - sess.Offset)
+		sess.Offset = offset
+		if err := r.uploads.put(fsPath, sess); err != nil {
+			return nil, fmt.Errorf("saving upload session: %v", err)
+		}
+	}
+}
+
+// startUploadSession sends the metadata of the file being upserted and
+// returns the URI of the session that its content is then uploaded to.
+func (r *Remote) startUploadSession(meta *drive.File, args *upsertOpt, size int64) (string, error) {
+	params := upsertParams(args.mask, args.ocrLanguage)
+	method, uri := "POST", DriveUploadURL
+	if args.src.Id != "" {
+		// We always want it to match up with the local time
+		params.Set("setModifiedDate", "true")
+		method, uri = "PUT", DriveUploadURL+"/"+url.QueryEscape(args.src.Id)
+	}
+
+	body, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(method, uri+"?"+params.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	if meta.MimeType != "" {
+		req.Header.Set("X-Upload-Content-Type", meta.MimeType)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return "", err
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("upload session: no session URI in the response")
+	}
+	return location, nil
+}
+
+// uploadStatus asks Drive how much of the content of sess it has received.
+func (r *Remote) uploadStatus(sess *uploadSession) (int64, *drive.File, error) {
+	req, err := http.NewRequest("PUT", sess.URI, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", sess.Size))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	return uploadProgress(resp)
+}
+
+// uploadProgress reads the response to a chunk or status request of an upload
+// session, returning the offset to continue from or the file once uploaded.
+func uploadProgress(resp *http.Response) (int64, *drive.File, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode == statusResumeIncomplete {
+		// The range received so far e.g "bytes=0-524287", none if unset
+		received := resp.Header.Get("Range")
+		if received == "" {
+			return 0, nil, nil
+		}
+		last, err := strconv.ParseInt(received[strings.LastIndex(received, "-")+1:], 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("upload session: bad range %q: %v", received, err)
+		}
+		return last + 1, nil, nil
+	}
+
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return 0, nil, err
+	}
+
+	uploaded := &drive.File{}
+	if err := json.NewDecoder(resp.Body).Decode(uploaded); err != nil {
+		return 0, nil, err
+	}
+	return 0, uploaded, nil
+}
+
+// isGone reports whether err is that of an expired or unknown upload session.
+func isGone(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone)
+}
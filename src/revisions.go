@@ -0,0 +1,361 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// revisionEntry is a revision as listed and emitted.
+type revisionEntry struct {
+	Id       string    `json:"id"`
+	Path     string    `json:"path"`
+	ModTime  time.Time `json:"modTime"`
+	Size     int64     `json:"size"`
+	User     string    `json:"user,omitempty"`
+	Md5      string    `json:"md5Checksum,omitempty"`
+	Pinned   bool      `json:"pinned"`
+	revision *drive.Revision
+}
+
+type byRevisionTime []*revisionEntry
+
+func (rt byRevisionTime) Len() int           { return len(rt) }
+func (rt byRevisionTime) Swap(i, j int)      { rt[i], rt[j] = rt[j], rt[i] }
+func (rt byRevisionTime) Less(i, j int) bool { return rt[i].ModTime.Before(rt[j].ModTime) }
+
+func (r *Remote) revisions(fileId string) ([]*drive.Revision, error) {
+	list, err := r.service.Revisions.List(fileId).Do()
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (r *Remote) revision(fileId, revId string) (*drive.Revision, error) {
+	return r.service.Revisions.Get(fileId, revId).Do()
+}
+
+// pinRevision sets whether the revision is kept forever. Unpinned
+// revisions of binary files are purged by Drive after 30 days or once
+// there are more than 100 of them.
+func (r *Remote) pinRevision(fileId, revId string, pinned bool) error {
+	resource := "files/" + url.QueryEscape(fileId) + "/revisions/" + url.QueryEscape(revId)
+	return r.patch(resource, map[string]interface{}{"pinned": pinned})
+}
+
+func (r *Remote) deleteRevision(fileId, revId string) error {
+	return r.service.Revisions.Delete(fileId, revId).Do()
+}
+
+// revisionsOf resolves the source at p, by id if byId is set, to a file and its
+// revisions, sorted from the oldest to the newest.
+func (g *Commands) revisionsOf(p string, byId bool) (*File, string, []*revisionEntry, error) {
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	f, err := resolver(p)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if f == nil {
+		return nil, "", nil, ErrPathNotExists
+	}
+	if f.IsDir {
+		return nil, "", nil, fmt.Errorf("folders have no revisions")
+	}
+	relToRootPath := p
+	if byId {
+		relToRootPath = filepath.Join(g.opts.Path, f.Name)
+	}
+
+	revisions, err := g.rem.revisions(f.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var entries []*revisionEntry
+	for _, rev := range revisions {
+		entries = append(entries, &revisionEntry{
+			Id:       rev.Id,
+			Path:     relToRootPath,
+			ModTime:  parseTimeAndRound(rev.ModifiedDate),
+			Size:     rev.FileSize,
+			User:     rev.LastModifyingUserName,
+			Md5:      rev.Md5Checksum,
+			Pinned:   rev.Pinned,
+			revision: rev,
+		})
+	}
+	sort.Stable(byRevisionTime(entries))
+	return f, relToRootPath, entries, nil
+}
+
+// Revisions lists the revision history of each of the files in opts.Sources,
+// from the oldest to the newest. Pinned revisions, those kept forever, are
+// marked with a *.
+func (g *Commands) Revisions(byId bool) error {
+	var composedError error = nil
+
+	for _, p := range g.opts.Sources {
+		_, relToRootPath, entries, err := g.revisionsOf(p, byId)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("revisions: %s: %v", p, err))
+			continue
+		}
+
+		if g.emitter == nil {
+			g.log.Logf("%s\n", relToRootPath)
+		}
+		for _, entry := range entries {
+			if g.emitter != nil {
+				g.emitter.emit(entry)
+				continue
+			}
+			pin := " "
+			if entry.Pinned {
+				pin = "*"
+			}
+			g.log.Logf("%s %-30s %-10s %-20s %s\n", pin, entry.Id, prettyBytes(entry.Size),
+				entry.ModTime.Local().Format("2006-01-02 15:04:05"), entry.User)
+		}
+	}
+	return composedError
+}
+
+// PinRevisions pins, or unpins if pinned isn't set, the revisions revIds
+// of the file that is the only source.
+func (g *Commands) PinRevisions(revIds []string, pinned bool, byId bool) error {
+	if len(g.opts.Sources) != 1 {
+		return fmt.Errorf("revisions: expected exactly one file, instead got: %v", g.opts.Sources)
+	}
+	p := g.opts.Sources[0]
+
+	f, _, entries, err := g.revisionsOf(p, byId)
+	if err != nil {
+		return fmt.Errorf("revisions: %s: %v", p, err)
+	}
+
+	known := make(map[string]bool)
+	for _, entry := range entries {
+		known[entry.Id] = true
+	}
+
+	var composedError error = nil
+	for _, revId := range revIds {
+		if !known[revId] {
+			composedError = reComposeError(composedError, fmt.Sprintf("revisions: %s: no revision %q", p, revId))
+			continue
+		}
+		if err := g.rem.pinRevision(f.Id, revId, pinned); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("revisions: %s: %s: %v", p, revId, err))
+		}
+	}
+	return composedError
+}
+
+// PruneRevisions deletes the revisions of each of the files in opts.Sources
+// that are neither pinned, among the newest keep of them nor, if olderThan is
+// set, newer than it. The newest revision, the file's content, is always kept.
+// The revisions to delete can instead be named with revIds.
+func (g *Commands) PruneRevisions(revIds []string, keep int, olderThan string, byId bool) error {
+	if keep < 1 {
+		keep = 1
+	}
+	var cutoff time.Time
+	if olderThan != "" {
+		var err error
+		if cutoff, err = parseCutoff(olderThan, time.Now()); err != nil {
+			return fmt.Errorf("revisions: %v", err)
+		}
+	}
+
+	named := make(map[string]bool)
+	for _, revId := range revIds {
+		named[revId] = true
+	}
+
+	type doomed struct {
+		f     *File
+		entry *revisionEntry
+	}
+	var prunable []*doomed
+	var composedError error = nil
+
+	for _, p := range g.opts.Sources {
+		f, _, entries, err := g.revisionsOf(p, byId)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("revisions: %s: %v", p, err))
+			continue
+		}
+
+		for i, entry := range entries {
+			newest := i == len(entries)-1
+			if len(named) >= 1 {
+				if !named[entry.Id] {
+					continue
+				}
+				if newest {
+					message := fmt.Sprintf("revisions: %s: %s is the newest revision and cannot be deleted", p, entry.Id)
+					composedError = reComposeError(composedError, message)
+					continue
+				}
+			} else if entry.Pinned || i >= len(entries)-keep || (!cutoff.IsZero() && !entry.ModTime.Before(cutoff)) {
+				continue
+			}
+			prunable = append(prunable, &doomed{f: f, entry: entry})
+		}
+	}
+
+	if len(prunable) < 1 {
+		g.log.Logln("No revisions to delete")
+		return composedError
+	}
+
+	for _, d := range prunable {
+		g.log.Logf("%s %-30s %-10s %s\n", d.entry.Path, d.entry.Id, prettyBytes(d.entry.Size),
+			d.entry.ModTime.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	if !g.opts.Force {
+		if !g.opts.canPrompt() {
+			message := fmt.Sprintf("revisions: noPrompt is set, use `%s` to delete the revisions above", ForceKey)
+			return reComposeError(composedError, message)
+		}
+		if !promptForChanges(fmt.Sprintf("Delete these %d revisions? This operation is irreversible. Proceed? Y/n ", len(prunable))) {
+			return composedError
+		}
+	}
+
+	for _, d := range prunable {
+		if err := g.rem.deleteRevision(d.f.Id, d.entry.Id); err != nil {
+			message := fmt.Sprintf("revisions: %s: %s: %v", d.entry.Path, d.entry.Id, err)
+			composedError = reComposeError(composedError, message)
+		}
+	}
+	return composedError
+}
+
+// revisionName is the name that the revision revId of the file name is
+// pulled as, so that it lands beside the current content e.g
+// "report (revision 1042).pdf".
+func revisionName(name, revId, ext string) string {
+	if ext == "" {
+		ext = filepath.Ext(name)
+		if ext == name {
+			ext = ""
+		}
+		name = strings.TrimSuffix(name, ext)
+	} else {
+		ext = "." + ext
+	}
+	return fmt.Sprintf("%s (revision %s)%s", name, revId, ext)
+}
+
+// PullRevision fetches the revision opts.Revision of each of the files in
+// opts.Sources, beside the file's local copy or to stdout if piping. Revisions
+// of Google Docs are exported to the first of opts.Exports they can be.
+func (g *Commands) PullRevision(byId bool) error {
+	var composedError error = nil
+	for _, p := range g.opts.Sources {
+		if err := g.pullRevision(p, byId); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("pull: %s: revision %s: %v", p, g.opts.Revision, err))
+		}
+	}
+	return composedError
+}
+
+func (g *Commands) pullRevision(p string, byId bool) error {
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	f, err := resolver(p)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return ErrPathNotExists
+	}
+	relToRootPath := p
+	if byId {
+		relToRootPath = filepath.Join(g.opts.Path, f.Name)
+	}
+
+	rev, err := g.rem.revision(f.Id, g.opts.Revision)
+	if err != nil {
+		return err
+	}
+
+	downloadURL, ext := rev.DownloadUrl, ""
+	if len(rev.ExportLinks) >= 1 {
+		downloadURL = ""
+		exportable := &File{MimeType: f.MimeType, ExportLinks: rev.ExportLinks}
+		for _, candidate := range g.opts.Exports {
+			if link, _, ok := exportLink(exportable, candidate); ok {
+				downloadURL, ext = link, candidate
+				break
+			}
+		}
+		if downloadURL == "" {
+			return fmt.Errorf("is a Google document, use `-export` with one of the formats it can be exported to")
+		}
+	}
+	if downloadURL == "" {
+		return fmt.Errorf("has no downloadable content")
+	}
+
+	blobHandle, err := g.rem.Download(f.Id, downloadURL)
+	if err != nil {
+		return err
+	}
+	defer blobHandle.Close()
+
+	if g.opts.Piped {
+		_, err = io.Copy(os.Stdout, blobHandle)
+		return err
+	}
+
+	destAbsPath := g.context.AbsPathOf(filepath.Join(filepath.Dir(relToRootPath), revisionName(f.Name, rev.Id, ext)))
+	if err := os.MkdirAll(filepath.Dir(destAbsPath), os.ModeDir|0755); err != nil {
+		return err
+	}
+	fh, err := os.Create(destAbsPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(fh, blobHandle); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+
+	g.log.Logf("Pulled revision %s of %s as %s\n", rev.Id, relToRootPath, destAbsPath)
+	return nil
+}
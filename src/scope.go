@@ -0,0 +1,77 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// resolveDestRoot resolves opts.DestRoot, either the path of a
+// folder or its id, to the path of that folder.
+func (g *Commands) resolveDestRoot() (string, error) {
+	root := g.opts.DestRoot
+	if strings.HasPrefix(root, "/") {
+		return g.destRootByPath(root)
+	}
+
+	// Otherwise the root is taken to be an id, falling back to a path
+	f, err := g.rem.FindById(root)
+	if err != nil {
+		return g.destRootByPath("/" + root)
+	}
+	if !f.IsDir {
+		return "", fmt.Errorf("dest-root: %s: %v", root, ErrPathNotDir)
+	}
+
+	rootPath, err := g.rem.pathOf(f.Id)
+	if err != nil {
+		return "", fmt.Errorf("dest-root: %s: %v", root, err)
+	}
+	return rootPath, nil
+}
+
+func (g *Commands) destRootByPath(p string) (string, error) {
+	f, err := g.rem.FindByPath(p)
+	if err != nil {
+		return "", fmt.Errorf("dest-root: %s: %v", p, err)
+	}
+	if f == nil || !f.IsDir {
+		return "", fmt.Errorf("dest-root: %s: %v", p, ErrPathNotDir)
+	}
+	return path.Clean(p), nil
+}
+
+// scopeToDestRoot rewrites, in place, the relative paths in paths to be
+// under the dest root. Paths with a leading "/" are left as they are.
+// It is a no-op if no dest root is set.
+func (g *Commands) scopeToDestRoot(paths []string) error {
+	if g.opts.DestRoot == "" {
+		return nil
+	}
+
+	rootPath, err := g.resolveDestRoot()
+	if err != nil {
+		return err
+	}
+
+	for i, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			paths[i] = path.Join(rootPath, p)
+		}
+	}
+	return nil
+}
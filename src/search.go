@@ -0,0 +1,137 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SearchByRelevance = "relevance"
+	SearchByModTime   = "modtime"
+)
+
+// searchSnippetRadius is the number of characters shown on either side of
+// the first term found in a snippet.
+const searchSnippetRadius = 40
+
+// searchEntry is a search result as emitted with -json. Rank is its position
+// by relevance as ranked by Drive, starting at 1.
+type searchEntry struct {
+	Rank     int       `json:"rank"`
+	Path     string    `json:"path"`
+	Id       string    `json:"id"`
+	MimeType string    `json:"mimeType,omitempty"`
+	ModTime  time.Time `json:"modTime"`
+	Snippet  string    `json:"snippet,omitempty"`
+}
+
+type bySearchModTime []*searchEntry
+
+func (s bySearchModTime) Len() int           { return len(s) }
+func (s bySearchModTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySearchModTime) Less(i, j int) bool { return s[i].ModTime.After(s[j].ModTime) }
+
+// searchSnippet returns the text around the first of terms found in text,
+// case insensitively, or "" if none of them is in it.
+func searchSnippet(text string, terms []string) string {
+	folded := strings.ToLower(text)
+	for _, term := range terms {
+		i := strings.Index(folded, strings.ToLower(term))
+		if term == "" || i < 0 {
+			continue
+		}
+
+		start, end := i-searchSnippetRadius, i+len(term)+searchSnippetRadius
+		prefix, suffix := "...", "..."
+		if start <= 0 {
+			start, prefix = 0, ""
+		}
+		if end >= len(text) {
+			end, suffix = len(text), ""
+		}
+		snippet := strings.Join(strings.Fields(text[start:end]), " ")
+		return prefix + snippet + suffix
+	}
+	return ""
+}
+
+// Search lists, up to max, the items whose content or metadata contain terms,
+// as found by Drive's full text search, most relevant first or most recently
+// modified first as per sortBy. Drive doesn't return snippets of the content
+// that matched, so snippets are only shown of descriptions that contain terms.
+func (g *Commands) Search(terms []string, sortBy string, max int) error {
+	text := strings.TrimSpace(strings.Join(terms, " "))
+	if text == "" {
+		return fmt.Errorf("search: expecting terms to search for")
+	}
+	if sortBy != SearchByRelevance && sortBy != SearchByModTime {
+		return fmt.Errorf("search: unknown sort %q, expecting %s or %s", sortBy, SearchByRelevance, SearchByModTime)
+	}
+
+	// Drive orders full text search results by relevance and
+	// doesn't allow ordering them otherwise.
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text)
+	q := fmt.Sprintf("fullText contains '%s'", escaped)
+
+	var entries []*searchEntry
+	for match := range g.rem.findByQuery(q, false, g.opts.Hidden) {
+		if match == nil {
+			continue
+		}
+		if max > 0 && len(entries) >= max {
+			break
+		}
+
+		p, err := g.rem.pathOf(match.Id)
+		if err != nil {
+			// Items shared with you aren't in your drive
+			p = fmt.Sprintf("%s (%s)", match.Name, match.Id)
+		}
+
+		entries = append(entries, &searchEntry{
+			Rank:     len(entries) + 1,
+			Path:     p,
+			Id:       match.Id,
+			MimeType: match.MimeType,
+			ModTime:  match.ModTime,
+			Snippet:  searchSnippet(match.Description, strings.Fields(text)),
+		})
+	}
+
+	if sortBy == SearchByModTime {
+		sort.Stable(bySearchModTime(entries))
+	}
+
+	if len(entries) < 1 && g.emitter == nil {
+		g.log.Logf("No items match %q\n", text)
+		return nil
+	}
+
+	for _, entry := range entries {
+		if g.emitter != nil {
+			g.emitter.emit(entry)
+			continue
+		}
+		g.log.Logf("%-4d %s  %s\n", entry.Rank, entry.ModTime.Local().Format(time.RFC822), entry.Path)
+		if entry.Snippet != "" {
+			g.log.Logf("     %s\n", entry.Snippet)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// substitution is a sed-style substitution e.g "s/IMG_(\d+)/Photo_\1/g".
+type substitution struct {
+	re     *regexp.Regexp
+	repl   string
+	global bool
+}
+
+// parseSubstitution parses expr as s<delim>regexp<delim>replacement<delim>flags
+// where delim is any character e.g "/" or "|", and flags are any of g to
+// replace every match instead of the first only and i to ignore case.
+// Replacements refer to groups as \1 and to the whole match as &.
+func parseSubstitution(expr string) (*substitution, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return nil, fmt.Errorf("regex: %q is not of the form s/regexp/replacement/[flags]", expr)
+	}
+
+	delim := expr[1:2]
+	parts := splitUnescaped(expr[2:], delim[0])
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("regex: %q is not of the form s%sregexp%sreplacement%s[flags]", expr, delim, delim, delim)
+	}
+
+	// An escaped delimiter stands for itself, in the regexp too
+	pattern := strings.Replace(parts[0], `\`+delim, regexp.QuoteMeta(delim), -1)
+	repl, flags := parts[1], parts[2]
+	sub := &substitution{repl: sedToGoReplacement(repl)}
+	for _, flag := range flags {
+		switch flag {
+		case 'g':
+			sub.global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("regex: unknown flag %q in %q, expecting g or i", flag, expr)
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex: %v", err)
+	}
+	sub.re = re
+	return sub, nil
+}
+
+// splitUnescaped splits s at the occurrences of delim that
+// aren't escaped with a backslash, leaving escapes be.
+func splitUnescaped(s string, delim byte) []string {
+	var parts []string
+	var cur []byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			cur = append(cur, s[i], s[i+1])
+			i += 1
+		case s[i] == delim:
+			parts = append(parts, string(cur))
+			cur = nil
+		default:
+			cur = append(cur, s[i])
+		}
+	}
+	return append(parts, string(cur))
+}
+
+// sedToGoReplacement rewrites the references to groups in a sed replacement
+// e.g "\1" and "&" into those that regexp.Expand takes e.g "${1}" and "${0}".
+func sedToGoReplacement(repl string) string {
+	var expanded []byte
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '\\' && i+1 < len(repl) && repl[i+1] >= '0' && repl[i+1] <= '9':
+			expanded = append(expanded, "${"+repl[i+1:i+2]+"}"...)
+			i += 1
+		case c == '\\' && i+1 < len(repl):
+			expanded = append(expanded, repl[i+1])
+			i += 1
+		case c == '&':
+			expanded = append(expanded, "${0}"...)
+		case c == '$':
+			expanded = append(expanded, "$$"...)
+		default:
+			expanded = append(expanded, c)
+		}
+	}
+	return string(expanded)
+}
+
+func (sub *substitution) apply(name string) string {
+	if sub.global {
+		return sub.re.ReplaceAllString(name, sub.repl)
+	}
+
+	match := sub.re.FindStringSubmatchIndex(name)
+	if match == nil {
+		return name
+	}
+	replaced := sub.re.ExpandString(nil, sub.repl, name, match)
+	return name[:match[0]] + string(replaced) + name[match[1]:]
+}
+
+// RenameByRegex renames the items in opts.Sources, which may be shell patterns
+// e.g "Photos/*", by applying the sed-style substitution expr to their names.
+// Items whose names don't match are left as they are, as are those that would
+// end up with the same name as another renamed into the same folder.
+func (g *Commands) RenameByRegex(expr string) error {
+	sub, err := parseSubstitution(expr)
+	if err != nil {
+		return fmt.Errorf("rename: %v", err)
+	}
+
+	if err := g.scopeToDestRoot(g.opts.Sources); err != nil {
+		return err
+	}
+
+	sources, composedError := g.expandSources(g.opts.Sources)
+	if composedError != nil {
+		composedError = fmt.Errorf("rename: %v", composedError)
+	}
+
+	// taken are the new paths, case folded, of the renames so far
+	taken := make(map[string]string)
+	renamed := 0
+	for _, srcPath := range sources {
+		src, err := g.rem.FindByPath(srcPath)
+		if err != nil || src == nil {
+			if err == nil {
+				err = ErrPathNotExists
+			}
+			composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: %v", srcPath, err))
+			continue
+		}
+
+		newName := sub.apply(src.Name)
+		if newName == src.Name {
+			continue
+		}
+		if newName == "" {
+			composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: would have no name", srcPath))
+			continue
+		}
+
+		parentPath := g.parentPather(srcPath)
+		newPath := path.Join(parentPath, newName)
+		if prev, clash := taken[strings.ToLower(newPath)]; clash {
+			message := fmt.Sprintf("rename: %s: would be %s just like %s", srcPath, newName, prev)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+		taken[strings.ToLower(newPath)] = srcPath
+
+		if err := g.rename(src, parentPath, newName); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("rename: %s: %v", srcPath, err))
+			continue
+		}
+		renamed += 1
+		g.log.Logf("%s -> %s\n", srcPath, newName)
+	}
+
+	g.log.Logf("%d of %d names were rewritten\n", renamed, len(sources))
+
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
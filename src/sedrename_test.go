@@ -0,0 +1,69 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestParseSubstitution(t *testing.T) {
+	tests := []struct {
+		expr string
+		name string
+		want string
+	}{
+		{`s/IMG_(\d+)/Photo_\1/`, "IMG_0042.jpg", "Photo_0042.jpg"},
+		{`s/a/b/`, "banana", "bbnana"},
+		{`s/a/b/g`, "banana", "bbnbnb"},
+		{`s/A/b/gi`, "banana", "bbnbnb"},
+		{`s/A/b/`, "banana", "banana"},
+		{`s/x/y/`, "banana", "banana"},
+		{`s/^/draft-/`, "report.pdf", "draft-report.pdf"},
+		{`s/.pdf$/ (&)/`, "report.pdf", "report (.pdf)"},
+		// The replacement's & is the whole match unless escaped
+		{`s/report/\&/`, "report.pdf", "&.pdf"},
+		// Other delimiters, and escaped delimiters standing for themselves
+		{`s|/|-|g`, "a/b/c", "a-b-c"},
+		{`s/a\/b/c/`, "xa/by", "xcy"},
+		{`s,\,,;,g`, "a,b,c", "a;b;c"},
+		// $ is literal in sed replacements
+		{`s/cost/$5/`, "cost.txt", "$5.txt"},
+		{`s/(\w+)-(\w+)/\2-\1/`, "left-right", "right-left"},
+	}
+	for _, tt := range tests {
+		sub, err := parseSubstitution(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := sub.apply(tt.name); got != tt.want {
+			t.Errorf("%s on %q = %q, want %q", tt.expr, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubstitutionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"s",
+		"x/a/b/",
+		"s/a/b",
+		"s/a/b/c/",
+		"s/a/b/x",
+		"s/(/b/",
+	} {
+		if _, err := parseSubstitution(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// DriveRcSyncSection is the section of the .driverc that limits what pull
+	// and push mirror to some folders, e.g to keep part of a large drive on a
+	// small disk. Its keys are pull.include, pull.exclude, push.include and
+	// push.exclude, each a comma separated list of paths from the drive root.
+	DriveRcSyncSection = "sync"
+)
+
+type syncScope int
+
+const (
+	// syncIn paths are pulled or pushed
+	syncIn syncScope = iota
+	// syncOut paths are left alone
+	syncOut
+	// syncThrough paths are the ancestors of included folders, they are
+	// only descended into on the way to what is included.
+	syncThrough
+)
+
+// syncFilter is what is included and excluded in a direction. Excludes
+// win over includes, and with no includes everything is included.
+type syncFilter struct {
+	include []string
+	exclude []string
+}
+
+// syncRules are the sync section of the .driverc, nil if there is none.
+type syncRules struct {
+	pull syncFilter
+	push syncFilter
+}
+
+// under reports whether p is dir or inside of it.
+func under(p, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+func (sf *syncFilter) scope(p string) syncScope {
+	for _, dir := range sf.exclude {
+		if under(p, dir) {
+			return syncOut
+		}
+	}
+	if len(sf.include) < 1 {
+		return syncIn
+	}
+	for _, dir := range sf.include {
+		if under(p, dir) {
+			return syncIn
+		}
+	}
+	for _, dir := range sf.include {
+		if under(dir, p) {
+			return syncThrough
+		}
+	}
+	return syncOut
+}
+
+func syncPaths(value string) []string {
+	var paths []string
+	for _, p := range NonEmptyTrimmedStrings(strings.Split(value, ",")...) {
+		paths = append(paths, path.Clean(path.Join("/", p)))
+	}
+	return paths
+}
+
+// loadSyncRules sets up what pull and push mirror from the sync section of the
+// .driverc. Without the section, everything is mirrored.
+func (g *Commands) loadSyncRules(rc driveRc) error {
+	section := rc.section(DriveRcSyncSection)
+	if len(section) < 1 {
+		return nil
+	}
+
+	rules := &syncRules{}
+	for key, value := range section {
+		switch strings.ToLower(key) {
+		case "pull.include":
+			rules.pull.include = syncPaths(value)
+		case "pull.exclude":
+			rules.pull.exclude = syncPaths(value)
+		case "push.include":
+			rules.push.include = syncPaths(value)
+		case "push.exclude":
+			rules.push.exclude = syncPaths(value)
+		default:
+			return fmt.Errorf("%s: [%s] %s: expecting pull.include, pull.exclude, push.include or push.exclude",
+				DriveRcSuffix, DriveRcSyncSection, key)
+		}
+	}
+
+	g.syncRules = rules
+	return nil
+}
+
+// syncScope is whether the item at p, from the drive root, is pushed or pulled.
+func (g *Commands) syncScope(p string, push bool) syncScope {
+	if g.syncRules == nil {
+		return syncIn
+	}
+	p = path.Clean(path.Join("/", p))
+	if push {
+		return g.syncRules.push.scope(p)
+	}
+	return g.syncRules.pull.scope(p)
+}
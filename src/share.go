@@ -16,10 +16,13 @@ package drive
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
 	"strings"
-	"sync"
 
 	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
 )
 
 type AccountType int
@@ -53,8 +56,17 @@ type shareChange struct {
 	role         Role
 	accountType  AccountType
 	files        []*File
-	revoke       bool
-	notify       bool
+	// paths are the paths of files, at the same indices
+	paths  []string
+	revoke bool
+	notify bool
+	// withLink when set grants access only to those that have the link,
+	// or only revokes such access
+	withLink bool
+	// roleSet and accountTypeSet are whether role and accountType were
+	// given rather than defaulted, revoking only matches on those given
+	roleSet        bool
+	accountTypeSet bool
 }
 
 type permission struct {
@@ -64,6 +76,8 @@ type permission struct {
 	role        Role
 	accountType AccountType
 	notify      bool
+	// withLink when set grants access only to those that have the link
+	withLink bool
 }
 
 func (r *Role) String() string {
@@ -127,46 +141,6 @@ func stringToAccountType() func(string) AccountType {
 var reverseRoleResolve = stringToRole()
 var reverseAccountTypeResolve = stringToAccountType()
 
-func (g *Commands) resolveRemotePaths(relToRootPaths []string, byId bool) (files []*File) {
-	var wg sync.WaitGroup
-
-	resolver := g.rem.FindByPath
-	if byId {
-		resolver = g.rem.FindById
-	}
-
-	wg.Add(len(relToRootPaths))
-	for _, relToRoot := range relToRootPaths {
-		go func(p string, wgg *sync.WaitGroup) {
-			defer wgg.Done()
-			file, err := resolver(p)
-			if err != nil || file == nil {
-				return
-			}
-			files = append(files, file)
-		}(relToRoot, &wg)
-	}
-	wg.Wait()
-	return files
-}
-
-func emailsToIds(g *Commands, emails []string) map[string]string {
-	emailToIds := make(map[string]string)
-	var wg sync.WaitGroup
-	wg.Add(len(emails))
-	for _, email := range emails {
-		go func(email string, wgg *sync.WaitGroup) {
-			defer wgg.Done()
-			emailId, err := g.rem.idForEmail(email)
-			if err == nil {
-				emailToIds[email] = emailId
-			}
-		}(email, &wg)
-	}
-	wg.Wait()
-	return emailToIds
-}
-
 func (c *Commands) Unshare(byId bool) (err error) {
 	return c.share(true, byId)
 }
@@ -175,123 +149,303 @@ func (c *Commands) Share(byId bool) (err error) {
 	return c.share(false, byId)
 }
 
+// ShareByQuery shares the items that match the Drive query q.
+func (c *Commands) ShareByQuery(q string) error {
+	return c.shareByQuery(false, q)
+}
+
+// UnshareByQuery revokes access to the items that match the Drive query q.
+func (c *Commands) UnshareByQuery(q string) error {
+	return c.shareByQuery(true, q)
+}
+
 func showPromptShareChanges(logy *log.Logger, change *shareChange) bool {
 	if len(change.files) < 1 {
 		return false
 	}
 	if change.revoke {
-		logy.Logf("Revoke access for accountType: \033[92m%s\033[00m for file(s):\n",
-			change.accountType.String())
-		for _, file := range change.files {
-			logy.Logln("+ ", file.Name)
+		logy.Logf("Revoke access for %s for file(s):\n", change.revokeFilter())
+		for _, p := range change.paths {
+			logy.Logln("+ ", p)
 		}
 		logy.Logln()
 		return promptForChanges()
 	}
 
-	if len(change.emails) < 1 {
-		return false
-	}
-
 	if change.notify {
 		logy.Logln("Message:\n\t", change.emailMessage)
 	}
 
 	logy.Logln("Receipients:")
-	for _, email := range change.emails {
+	for _, email := range change.grantees() {
+		if email == "" {
+			email = change.accountType.String()
+		}
 		logy.Logf("\t\033[92m+\033[00m %s\n", email)
 	}
 
 	logy.Logln("\nFile(s) to share:")
-	for _, file := range change.files {
-		if file == nil {
+	for _, p := range change.paths {
+		logy.Logf("\t\033[92m+\033[00m %s\n", p)
+	}
+	return promptForChanges()
+}
+
+func (change *shareChange) add(f *File, p string) {
+	change.files = append(change.files, f)
+	change.paths = append(change.paths, p)
+}
+
+// grantees are the values of the permissions to grant, a single empty
+// one for access by anyone which isn't granted to anyone in particular.
+func (change *shareChange) grantees() []string {
+	if len(change.emails) < 1 && change.accountType == Anyone {
+		return []string{""}
+	}
+	return change.emails
+}
+
+func (change *shareChange) revokeFilter() string {
+	var filters []string
+	if change.accountTypeSet {
+		filters = append(filters, "accountType: "+change.accountType.String())
+	}
+	if change.roleSet {
+		filters = append(filters, "role: "+change.role.String())
+	}
+	if len(change.emails) >= 1 {
+		filters = append(filters, "emails: "+strings.Join(change.emails, ","))
+	}
+	if change.withLink {
+		filters = append(filters, "with link")
+	}
+	return strings.Join(filters, ", ")
+}
+
+// revokes tells if perm is amongst the permissions that change revokes,
+// those that match all of its filters. Owners' access is never revoked.
+func (change *shareChange) revokes(perm *drive.Permission) bool {
+	if perm.Role == "owner" {
+		return false
+	}
+	if change.accountTypeSet && perm.Type != change.accountType.String() {
+		return false
+	}
+	if change.roleSet && permissionRole(perm) != change.role.String() {
+		return false
+	}
+	if change.withLink && !perm.WithLink {
+		return false
+	}
+	if len(change.emails) < 1 {
+		return true
+	}
+	holder := permissionHolder(perm)
+	for _, email := range change.emails {
+		if strings.EqualFold(email, holder) {
+			return true
+		}
+	}
+	return false
+}
+
+// addDescendants adds the descendants of the folders in change, for sharing
+// or unsharing whole trees.
+func (c *Commands) addDescendants(change *shareChange) {
+	for i := 0; i < len(change.files); i++ {
+		f, p := change.files[i], change.paths[i]
+		if !f.IsDir {
 			continue
 		}
-		logy.Logf("\t\033[92m+\033[00m %s\n", file.Name)
+		for child := range c.rem.findChildren(f.Id, false) {
+			change.add(child, path.Join(p, child.Name))
+		}
 	}
-	return promptForChanges()
 }
 
+// playShareChanges grants or revokes the permissions of change, in batches.
+// A permission that fails to be granted or revoked is reported without
+// holding up the others.
 func (c *Commands) playShareChanges(change *shareChange) error {
+	if c.opts.Recursive {
+		c.addDescendants(change)
+	}
+
 	if c.opts.canPrompt() && !showPromptShareChanges(c.log, change) {
 		return nil
 	}
 
-	for _, file := range change.files {
+	var composedError error = nil
+	var calls []*batchCall
+	var callPaths []string
+
+	for i, file := range change.files {
+		fileId := url.QueryEscape(file.Id)
 		if change.revoke {
-			if err := c.rem.deletePermissions(file.Id, change.accountType); err != nil {
-				return fmt.Errorf("%s: %v", file.Name, err)
+			perms, err := c.rem.listPermissions(file.Id)
+			if err != nil {
+				composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", change.paths[i], err))
+				continue
+			}
+			for _, perm := range perms {
+				if !change.revokes(perm) {
+					continue
+				}
+				calls = append(calls, &batchCall{
+					method: "DELETE",
+					path:   "files/" + fileId + "/permissions/" + url.QueryEscape(perm.Id),
+				})
+				callPaths = append(callPaths, change.paths[i])
 			}
 			continue
 		}
 
-		for _, email := range change.emails {
+		params := url.Values{}
+		params.Set("sendNotificationEmails", fmt.Sprintf("%v", change.notify))
+		if change.notify && change.emailMessage != "" {
+			params.Set("emailMessage", change.emailMessage)
+		}
+		for _, email := range change.grantees() {
 			perm := permission{
 				fileId:      file.Id,
 				value:       email,
-				message:     change.emailMessage,
-				notify:      change.notify,
 				role:        change.role,
 				accountType: change.accountType,
+				withLink:    change.withLink,
 			}
-			_, err := c.rem.insertPermissions(&perm)
-			if err != nil {
-				return err
-			}
+			calls = append(calls, &batchCall{
+				method: "POST",
+				path:   "files/" + fileId + "/permissions?" + params.Encode(),
+				body:   newDrivePermission(&perm),
+			})
+			callPaths = append(callPaths, change.paths[i])
 		}
 	}
-	return nil
-}
 
-func (c *Commands) share(revoke, byId bool) (err error) {
-	files := c.resolveRemotePaths(c.opts.Sources, byId)
-
-	var role Role
-	var accountType AccountType
-	var emails []string
-	var emailMessage string
-
-	// Setup the defaults
-	role = Reader
-	accountType = User
-	meta := *c.opts.Meta
-
-	if meta != nil {
-		emailList, eOk := meta["emails"]
-		if eOk {
-			emails = emailList
-			if false {
-				emailIdMap := emailsToIds(c, emailList)
-				c.log.Logln(emailIdMap)
-			}
+	failed := 0
+	for i, err := range c.rem.batch(calls) {
+		if err != nil {
+			failed += 1
+			c.log.LogErrf("%s: %v\n", callPaths[i], err)
 		}
+	}
+
+	action := "Granted"
+	if change.revoke {
+		action = "Revoked"
+	}
+	c.log.Logf("%s %d permissions on %d items\n", action, len(calls)-failed, len(change.files))
+	if failed >= 1 {
+		composedError = reComposeError(composedError, fmt.Sprintf("%d permissions failed to be changed", failed))
+	}
+	return composedError
+}
+
+// newShareChange sets up a change from the options, without any files yet.
+func (c *Commands) newShareChange(revoke bool) (*shareChange, error) {
+	change := &shareChange{
+		// Setup the defaults
+		role:        Reader,
+		accountType: User,
+		revoke:      revoke,
+		notify:      (c.opts.TypeMask & Notify) != 0,
+		withLink:    c.opts.WithLink,
+	}
+
+	if c.opts.Meta != nil {
+		meta := *c.opts.Meta
+		change.emails = meta["emails"]
 
 		roleList, rOk := meta["role"]
 		if rOk && len(roleList) >= 1 {
-			role = reverseRoleResolve(roleList[0])
+			change.role = reverseRoleResolve(roleList[0])
+			change.roleSet = true
 		}
 		accountTypeList, aOk := meta["accountType"]
 		if aOk && len(accountTypeList) >= 1 {
-			accountType = reverseAccountTypeResolve(accountTypeList[0])
+			change.accountType = reverseAccountTypeResolve(accountTypeList[0])
+			change.accountTypeSet = true
 		}
 
 		emailMessageList, emOk := meta["emailMessage"]
 		if emOk && len(emailMessageList) >= 1 {
-			emailMessage = strings.Join(emailMessageList, "\n")
+			change.emailMessage = strings.Join(emailMessageList, "\n")
 		}
 	}
 
-	notify := (c.opts.TypeMask & Notify) != 0
+	// Access with the link is access by anyone who has it unless otherwise set
+	if change.withLink && !change.accountTypeSet {
+		change.accountType = Anyone
+		change.accountTypeSet = true
+	}
 
-	change := shareChange{
-		accountType:  accountType,
-		emailMessage: emailMessage,
-		emails:       emails,
-		files:        files,
-		revoke:       revoke,
-		role:         role,
-		notify:       notify,
+	if revoke {
+		if !change.accountTypeSet && !change.roleSet && !change.withLink && len(change.emails) < 1 {
+			return nil, fmt.Errorf("unshare: expecting at least one of -%s, -%s, -%s or -%s to pick the access to revoke",
+				TypeKey, RoleKey, EmailsKey, CLIOptionWithLink)
+		}
+	} else if len(change.grantees()) < 1 {
+		return nil, fmt.Errorf("share: expecting -%s to share with", EmailsKey)
 	}
+	return change, nil
+}
 
-	return c.playShareChanges(&change)
+func (c *Commands) share(revoke, byId bool) (err error) {
+	change, err := c.newShareChange(revoke)
+	if err != nil {
+		return err
+	}
+
+	resolver := c.rem.FindByPath
+	if byId {
+		resolver = c.rem.FindById
+	}
+
+	var composedError error = nil
+	for _, p := range c.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		relToRootPath := p
+		if byId {
+			relToRootPath = filepath.Join(c.opts.Path, f.Name)
+		}
+		change.add(f, relToRootPath)
+	}
+
+	if err := c.playShareChanges(change); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (c *Commands) shareByQuery(revoke bool, q string) error {
+	if strings.TrimSpace(q) == "" {
+		return fmt.Errorf("query: expecting a Drive query e.g \"mimeType = 'application/zip'\"")
+	}
+
+	change, err := c.newShareChange(revoke)
+	if err != nil {
+		return err
+	}
+
+	for match := range c.rem.findByQuery(q, false, c.opts.Hidden) {
+		p, err := c.rem.pathOf(match.Id)
+		if err != nil {
+			p = fmt.Sprintf("%s (%s)", match.Name, match.Id)
+		}
+		change.add(match, p)
+	}
+
+	if len(change.files) < 1 {
+		c.log.Logf("Nothing matches %s\n", q)
+		return nil
+	}
+	c.log.Logf("%d items match %s\n", len(change.files), q)
+	return c.playShareChanges(change)
 }
@@ -0,0 +1,203 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	PermissionsFormatCSV  = "csv"
+	PermissionsFormatJSON = "json"
+)
+
+// permissionEntry is who has access to an item, and how, as reported.
+type permissionEntry struct {
+	Path     string `json:"path"`
+	Id       string `json:"id"`
+	Role     string `json:"role"`
+	Type     string `json:"type"`
+	Who      string `json:"who,omitempty"`
+	WithLink bool   `json:"withLink"`
+	// Inherited is set if the access comes with the folder the item is in,
+	// as opposed to having been granted on the item directly.
+	Inherited bool `json:"inherited"`
+}
+
+var permissionsCSVHeader = []string{"path", "id", "role", "type", "who", "withLink", "inherited"}
+
+func (entry *permissionEntry) record() []string {
+	return []string{
+		entry.Path, entry.Id, entry.Role, entry.Type, entry.Who,
+		strconv.FormatBool(entry.WithLink), strconv.FormatBool(entry.Inherited),
+	}
+}
+
+// permissionRole is perm's role, commenter for readers who can comment.
+func permissionRole(perm *drive.Permission) string {
+	for _, additional := range perm.AdditionalRoles {
+		if additional == "commenter" && perm.Role == "reader" {
+			return additional
+		}
+	}
+	return perm.Role
+}
+
+// permissionHolder is who perm grants access to e.g an email address or domain.
+func permissionHolder(perm *drive.Permission) string {
+	switch {
+	case perm.EmailAddress != "":
+		return perm.EmailAddress
+	case perm.Domain != "":
+		return perm.Domain
+	case perm.Type == "anyone":
+		return "anyone"
+	}
+	return perm.Name
+}
+
+// permissionsSt is the state of a permissions report.
+type permissionsSt struct {
+	emit func(*permissionEntry) error
+	// items and entries are counted for the summary
+	items   int
+	entries int
+}
+
+// ListPermissions reports who has access to each of the items in opts.Sources,
+// and to their descendants if opts.Recursive is set: the role, account type and
+// email address or domain of each permission and whether it is inherited from
+// the folder the item is in or was granted on the item directly. The report is
+// written to stdout as format, "csv" or "json", if set, otherwise it is logged.
+func (g *Commands) ListPermissions(byId bool, format string) error {
+	st := &permissionsSt{}
+	switch format {
+	case "":
+		st.emit = func(entry *permissionEntry) error {
+			if g.emitter != nil {
+				g.emitter.emit(entry)
+				return nil
+			}
+			how := "direct"
+			if entry.Inherited {
+				how = "inherited"
+			}
+			if entry.WithLink {
+				how += ", with link"
+			}
+			g.log.Logf("%-10s %-7s %-30s %-18s %s\n", entry.Role, entry.Type, entry.Who, how, entry.Path)
+			return nil
+		}
+	case PermissionsFormatJSON:
+		em := newEmitter(os.Stdout)
+		st.emit = func(entry *permissionEntry) error {
+			em.emit(entry)
+			return nil
+		}
+	case PermissionsFormatCSV:
+		w := csv.NewWriter(os.Stdout)
+		defer w.Flush()
+		if err := w.Write(permissionsCSVHeader); err != nil {
+			return err
+		}
+		st.emit = func(entry *permissionEntry) error {
+			return w.Write(entry.record())
+		}
+	default:
+		return fmt.Errorf("share: unknown format %q, expecting %s or %s",
+			format, PermissionsFormatCSV, PermissionsFormatJSON)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var composedError error = nil
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("share: %s: %v", p, err))
+			continue
+		}
+		relToRootPath := p
+		if byId {
+			relToRootPath = filepath.Join(g.opts.Path, f.Name)
+		}
+
+		if err := g.listPermissions(st, f, relToRootPath, nil); err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+
+	if format == "" {
+		g.log.Logf("%d permissions on %d items\n", st.entries, st.items)
+	}
+	return composedError
+}
+
+// listPermissions reports the permissions of f, and of its descendants if
+// recursing. inherited are the permissions of the folder that f was reached
+// through, keyed by id, nil for the items that the report starts from.
+func (g *Commands) listPermissions(st *permissionsSt, f *File, p string, inherited map[string]string) error {
+	perms, err := g.rem.listPermissions(f.Id)
+	if err != nil {
+		return fmt.Errorf("share: %s: %v", p, err)
+	}
+
+	st.items += 1
+	own := make(map[string]string)
+	for _, perm := range perms {
+		role := permissionRole(perm)
+		own[perm.Id] = role
+
+		parentRole, ok := inherited[perm.Id]
+		entry := &permissionEntry{
+			Path:      p,
+			Id:        f.Id,
+			Role:      role,
+			Type:      perm.Type,
+			Who:       permissionHolder(perm),
+			WithLink:  perm.WithLink,
+			Inherited: ok && parentRole == role,
+		}
+		st.entries += 1
+		if err := st.emit(entry); err != nil {
+			return err
+		}
+	}
+
+	if !f.IsDir || !g.opts.Recursive {
+		return nil
+	}
+
+	var composedError error = nil
+	for child := range g.rem.findChildren(f.Id, false) {
+		if err := g.listPermissions(st, child, path.Join(p, child.Name), own); err != nil {
+			composedError = reComposeError(composedError, err.Error())
+		}
+	}
+	return composedError
+}
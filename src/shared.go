@@ -0,0 +1,73 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+const (
+	SharedStrategyCopied     = "copied"
+	SharedStrategyReuploaded = "reuploaded"
+	SharedStrategySkipped    = "skipped"
+
+	sharedStrategiesTitle = "Copy strategies"
+)
+
+// reuploadExports are the formats that Google Docs files are exported to for
+// reuploading, chosen since Drive converts them back to the original format.
+var reuploadExports = map[string]string{
+	"application/vnd.google-apps.document":     "docx",
+	"application/vnd.google-apps.spreadsheet":  "xlsx",
+	"application/vnd.google-apps.presentation": "pptx",
+	"application/vnd.google-apps.drawing":      "svg",
+}
+
+// reuploadSource returns the URL that src's content is read from for reuploading
+// and whether that content has to be converted back to a Google Docs format.
+func reuploadSource(src *File) (exportURL string, convert bool, err error) {
+	if ext, ok := reuploadExports[src.MimeType]; ok {
+		exportURL, _, _ = exportLink(src, ext)
+		if exportURL == "" {
+			return "", false, fmt.Errorf("cannot be exported to %s", ext)
+		}
+		// Drawings can't be converted back, they stay as svg
+		return exportURL, src.MimeType != "application/vnd.google-apps.drawing", nil
+	}
+	if hasExportLinks(src) {
+		return "", false, fmt.Errorf("%s has no format to be exported to", src.MimeType)
+	}
+	return src.BlobAt, false, nil
+}
+
+// copyShared is the fallback for files that couldn't be copied with opts.IncludeShared
+// set e.g files shared by others that their owners restricted copying of. Their
+// content is read, exported if need be, and reuploaded as a new file. Files
+// that can't be read either are skipped, reporting why.
+func (g *Commands) copyShared(src *File, destBase, parentId, destPath string, copyErr error) (*File, error) {
+	exportURL, convert, err := reuploadSource(src)
+	if err == nil {
+		var reuploaded *File
+		if reuploaded, err = g.mut.reupload(destBase, parentId, src, exportURL, convert); err == nil {
+			g.report.count(sharedStrategiesTitle, SharedStrategyReuploaded)
+			g.report.note("Reuploaded instead of copied", "%s: %v", destPath, copyErr)
+			return reuploaded, nil
+		}
+	}
+
+	g.report.count(sharedStrategiesTitle, SharedStrategySkipped)
+	g.report.warn("Could neither copy nor reupload", "%s: copy: %v, reupload: %v", destPath, copyErr, err)
+	return nil, fmt.Errorf("copy: %v, reupload: %v", copyErr, err)
+}
@@ -0,0 +1,246 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	DriveShortcutMimeType = "application/vnd.google-apps.shortcut"
+)
+
+// shortcutFollower dereferences shortcuts to the items that they point to.
+// Recursions stop at shortcuts back into their branch, see shortcutBranch.
+type shortcutFollower struct {
+	// log is told of the shortcuts that can't be followed
+	log *log.Logger
+}
+
+func newShortcutFollower(logger *log.Logger) *shortcutFollower {
+	return &shortcutFollower{log: logger}
+}
+
+// shortcutBranch is a folder and the branch of folders that a recursion
+// descended through to get to it, shortcuts followed included. Following a
+// shortcut back to a folder in the branch would recurse forever, so each
+// recursion threads its branch through to stop there. Branches aren't
+// changed once made, so concurrent recursions share their common ancestors.
+type shortcutBranch struct {
+	parent *shortcutBranch
+	id     string
+	name   string
+}
+
+// descend returns the branch through b to the folder f.
+func (b *shortcutBranch) descend(f *File) *shortcutBranch {
+	return &shortcutBranch{parent: b, id: f.Id, name: f.Name}
+}
+
+// cycle returns the members of the cycle that f makes if it is in b, from f
+// down the branch and back to f e.g "a -> to-b -> to-a", otherwise "".
+func (b *shortcutBranch) cycle(f *File) string {
+	var members []string
+	for cur := b; cur != nil; cur = cur.parent {
+		members = append([]string{cur.name}, members...)
+		if cur.id == f.Id {
+			return sepJoin(" -> ", append(members, f.Name)...)
+		}
+	}
+	return ""
+}
+
+// shortcutTarget returns the id of the item that the shortcut with fileId
+// points to. The Drive client in use predates shortcuts so their details
+// are requested directly.
+func (r *Remote) shortcutTarget(fileId string) (string, error) {
+	params := url.Values{}
+	params.Set("fields", "shortcutDetails")
+	resp, err := r.client.Get(r.service.BasePath + "files/" + url.QueryEscape(fileId) + "?" + params.Encode())
+	if err != nil {
+		return "", err
+	}
+
+	var shortcut struct {
+		ShortcutDetails struct {
+			TargetId string `json:"targetId"`
+		} `json:"shortcutDetails"`
+	}
+	if err := decodeResponse(resp, &shortcut); err != nil {
+		return "", err
+	}
+	if shortcut.ShortcutDetails.TargetId == "" {
+		return "", fmt.Errorf("shortcut %s has no target", fileId)
+	}
+	return shortcut.ShortcutDetails.TargetId, nil
+}
+
+// createShortcut creates a shortcut named name in the folder with parentId
+// that points to the item with targetId.
+func (r *Remote) createShortcut(name, parentId, targetId string) (*File, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":           name,
+		"mimeType":        DriveShortcutMimeType,
+		"parents":         []map[string]string{{"id": parentId}},
+		"shortcutDetails": map[string]string{"targetId": targetId},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Post(r.service.BasePath+"files", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	created := &drive.File{}
+	if err := decodeResponse(resp, created); err != nil {
+		return nil, err
+	}
+	return NewRemoteFile(created), nil
+}
+
+// dereference returns the item that f points to, named as f, if following
+// shortcuts and f is one, otherwise f itself. Shortcuts to shortcuts are
+// followed in turn, unless they lead back to one already followed.
+func (r *Remote) dereference(f *File) (*File, error) {
+	if r.shortcuts == nil || f == nil || f.MimeType != DriveShortcutMimeType {
+		return f, nil
+	}
+
+	target := f
+	followed := []*File{f}
+	for target.MimeType == DriveShortcutMimeType {
+		targetId, err := r.shortcutTarget(target.Id)
+		if err != nil {
+			return nil, err
+		}
+		for i, shortcut := range followed {
+			if shortcut.Id != targetId {
+				continue
+			}
+			var members []string
+			for _, member := range append(followed[i:], shortcut) {
+				members = append(members, member.Name)
+			}
+			return nil, fmt.Errorf("shortcut cycle %s", sepJoin(" -> ", members...))
+		}
+		if target, err = r.FindById(targetId); err != nil {
+			return nil, fmt.Errorf("shortcut %s: %v", f.Name, err)
+		}
+		followed = append(followed, target)
+	}
+	target.Name = f.Name
+	return target, nil
+}
+
+// followShortcuts dereferences the shortcuts amongst children, if following
+// shortcuts. Those that can't be followed are left out and logged.
+func (r *Remote) followShortcuts(children chan *File) chan *File {
+	follower := r.shortcuts
+	if follower == nil {
+		return children
+	}
+
+	followed := make(chan *File)
+	go func() {
+		defer close(followed)
+		for child := range children {
+			target, err := r.dereference(child)
+			if err != nil {
+				follower.log.LogErrf("%s: %v\n", child.Name, err)
+				continue
+			}
+			followed <- target
+		}
+	}()
+	return followed
+}
+
+// Shortcut creates shortcuts to the sources, the first of opts.Sources but the
+// last, in the destination that is the last of them. If the destination is an
+// existing folder the shortcuts are created in it, named as their targets,
+// otherwise the only shortcut is created at the destination path.
+func (g *Commands) Shortcut(byId bool) error {
+	argc := len(g.opts.Sources)
+	if argc < 2 {
+		return fmt.Errorf("shortcut: expected <target> [target...] <dest>, instead got: %v", g.opts.Sources)
+	}
+
+	targets, destPath := g.opts.Sources[:argc-1], g.opts.Sources[argc-1]
+	if !byId {
+		expanded, err := g.expandSources(targets)
+		if err != nil {
+			return fmt.Errorf("shortcut: %v", err)
+		}
+		targets = expanded
+	}
+
+	dest, err := g.rem.FindByPath(destPath)
+	if err != nil && err != ErrPathNotExists {
+		return fmt.Errorf("shortcut: %s: %v", destPath, err)
+	}
+
+	destDir, destName := destPath, ""
+	if dest == nil || !dest.IsDir {
+		if len(targets) > 1 {
+			return fmt.Errorf("shortcut: %s: %v", destPath, ErrPathNotDir)
+		}
+		if dest != nil {
+			return fmt.Errorf("shortcut: %s already exists", destPath)
+		}
+		destDir, destName = g.pathSplitter(destPath)
+	}
+
+	parent, err := g.remoteMkdirAll(destDir)
+	if err != nil {
+		return fmt.Errorf("shortcut: %s: %v", destDir, err)
+	}
+
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	var composedError error = nil
+	for _, targetPath := range targets {
+		target, err := resolver(targetPath)
+		if err == nil && target == nil {
+			err = ErrPathNotExists
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("shortcut: %s: %v", targetPath, err))
+			continue
+		}
+
+		name := destName
+		if name == "" {
+			name = target.Name
+		}
+		if _, err := g.rem.createShortcut(name, parent.Id, target.Id); err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("shortcut: %s: %v", targetPath, err))
+			continue
+		}
+		g.log.Logf("%s -> %s\n", path.Join(destDir, name), targetPath)
+	}
+	return composedError
+}
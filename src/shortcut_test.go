@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDereferenceShortcutChains(t *testing.T) {
+	fd := newFakeDrive()
+	folder := fd.add("root", "folder", nil, true)
+	s1 := fd.add("root", "s1", nil, false)
+	s2 := fd.shortcut("root", "s2", s1.Id)
+	fd.files[s1.Id].MimeType = DriveShortcutMimeType
+	fd.targets[s1.Id] = s2.Id
+	s3 := fd.shortcut("root", "s3", s2.Id)
+	s4 := fd.shortcut("root", "s4", folder.Id)
+	s5 := fd.shortcut("root", "s5", s4.Id)
+
+	g := commandsOn(fd, &Options{})
+	g.rem.shortcuts = newShortcutFollower(g.log)
+
+	deref := func(id string) (*File, error) {
+		f, err := g.rem.FindById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g.rem.dereference(f)
+	}
+
+	if got, err := deref(s5.Id); err != nil || got.Id != folder.Id || got.Name != "s5" {
+		t.Errorf("s5 -> s4 -> folder: got %v, %v want the folder named s5", got, err)
+	}
+	for id, want := range map[string]string{
+		s1.Id: "s1 -> s2 -> s1",
+		s3.Id: "s2 -> s1 -> s2",
+	} {
+		got, err := deref(id)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, %v want the cycle %q", got, err, want)
+		}
+	}
+}
+
+func TestCopyStopsAtShortcutCycles(t *testing.T) {
+	fd := newFakeDrive()
+	a := fd.add("root", "a", nil, true)
+	b := fd.add("root", "b", nil, true)
+	fd.shortcut(a.Id, "to-b", b.Id)
+	fd.shortcut(b.Id, "to-a", a.Id)
+	fd.add(b.Id, "x.txt", []byte("x"), false)
+
+	g := commandsOn(fd, &Options{
+		Sources:         []string{"/a", "/dest"},
+		Recursive:       true,
+		FollowShortcuts: true,
+		NoPrompt:        true,
+	})
+	g.rem.shortcuts = newShortcutFollower(g.log)
+	defer inTempContext(t, g)()
+
+	copied := make(chan error, 1)
+	go func() {
+		copied <- g.Copy(false)
+	}()
+	select {
+	case <-copied:
+	case <-time.After(10 * time.Second):
+		t.Fatal("copying went round the cycle")
+	}
+
+	dest := fd.child("root", "dest")
+	if dest == nil {
+		t.Fatal("dest wasn't made")
+	}
+	toB := fd.child(dest.Id, "to-b")
+	if toB == nil || fd.child(toB.Id, "x.txt") == nil {
+		t.Fatalf("the folder that to-b points to wasn't copied into dest/to-b")
+	}
+	if toA := fd.child(toB.Id, "to-a"); toA != nil {
+		t.Errorf("dest/to-b/to-a was copied, going back around the cycle")
+	}
+	var cycles []string
+	if section, ok := g.report.sections["Folder cycles skipped"]; ok {
+		cycles = section.items
+	}
+	if len(cycles) != 1 || !strings.Contains(cycles[0], "a -> to-b -> to-a") {
+		t.Errorf("got cycles %v reported, want a -> to-b -> to-a", cycles)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	SnapshotAdded     = "added"
+	SnapshotChanged   = "changed"
+	SnapshotUnchanged = "unchanged"
+	SnapshotRemoved   = "removed"
+
+	snapshotTitle = "Snapshot"
+)
+
+// snapshotEntry is what a source file was like when it was last copied.
+type snapshotEntry struct {
+	Md5Checksum string    `json:"md5Checksum,omitempty"`
+	ModTime     time.Time `json:"modTime"`
+	CopyId      string    `json:"copyId"`
+	Dest        string    `json:"dest"`
+}
+
+// copySnapshot is the index of the source files as of the last copy, which
+// lets a copy be made incremental by only copying the files that were added
+// or changed since then. It is persisted as JSON.
+type copySnapshot struct {
+	sync.Mutex
+	path string
+	// Entries are keyed by the ids of the source files
+	Entries map[string]*snapshotEntry `json:"entries"`
+	// seen are the ids of the source files come across by this copy
+	seen map[string]bool
+}
+
+// loadCopySnapshot loads the snapshot at p, which
+// is empty if p doesn't exist yet e.g on the first copy.
+func loadCopySnapshot(p string) (*copySnapshot, error) {
+	cs := &copySnapshot{
+		path:    p,
+		Entries: make(map[string]*snapshotEntry),
+		seen:    make(map[string]bool),
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cs, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cs); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if cs.Entries == nil {
+		cs.Entries = make(map[string]*snapshotEntry)
+	}
+	return cs, nil
+}
+
+// lookup returns the entry of src as of the last copy, if any, and
+// whether src is unchanged since i.e its md5 checksum and modification
+// time are the same. Either differing makes for a change since Google Docs
+// files have no checksums and content can be restored to an earlier time.
+func (cs *copySnapshot) lookup(src *File) (prev *snapshotEntry, unchanged bool) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	cs.seen[src.Id] = true
+	prev = cs.Entries[src.Id]
+	if prev == nil {
+		return nil, false
+	}
+	return prev, prev.Md5Checksum == src.Md5Checksum && prev.ModTime.Equal(src.ModTime)
+}
+
+func (cs *copySnapshot) update(src, copied *File, destPath string) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	cs.Entries[src.Id] = &snapshotEntry{
+		Md5Checksum: src.Md5Checksum,
+		ModTime:     src.ModTime,
+		CopyId:      copied.Id,
+		Dest:        destPath,
+	}
+}
+
+// save writes out the snapshot, to a temporary file first
+// so that a failed save doesn't clobber the last snapshot.
+func (cs *copySnapshot) save() error {
+	cs.Lock()
+	defer cs.Unlock()
+
+	data, err := json.Marshal(cs)
+	if err != nil {
+		return err
+	}
+	tmpPath := cs.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, config.O_RWForAll); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cs.path)
+}
+
+// snapshotSkip reports whether src can be skipped for being unchanged since
+// the last copy. Otherwise the entry of its last copy, if any, is returned.
+func (g *Commands) snapshotSkip(src *File) (prev *snapshotEntry, skip bool) {
+	if g.copySnapshot == nil {
+		return nil, false
+	}
+	prev, unchanged := g.copySnapshot.lookup(src)
+	if unchanged {
+		g.report.count(snapshotTitle, SnapshotUnchanged)
+	}
+	return prev, unchanged
+}
+
+// snapshotCopied records the copy of src in the snapshot. A changed file's
+// earlier copy is trashed since the new copy replaces it.
+func (g *Commands) snapshotCopied(src, copied *File, destPath string, prev *snapshotEntry) {
+	if g.copySnapshot == nil {
+		return
+	}
+
+	if prev == nil {
+		g.report.count(snapshotTitle, SnapshotAdded)
+	} else {
+		g.report.count(snapshotTitle, SnapshotChanged)
+		if prev.CopyId != copied.Id {
+			g.plan.knowPath(prev.CopyId, prev.Dest)
+			err := g.mut.Trash(prev.CopyId)
+			g.audit(AuditTrash, &File{Id: prev.CopyId}, prev.Dest, "", err)
+			if err != nil {
+				g.report.warn("Earlier copies not trashed", "%s: %v", prev.Dest, err)
+			}
+		}
+	}
+
+	g.copySnapshot.update(src, copied, destPath)
+}
+
+// settleSnapshot deals with the files in the snapshot that this copy didn't
+// come across i.e that were removed from the sources since the last copy.
+// Their copies are trashed if opts.SnapshotDeletions is set, otherwise they
+// are left be and kept in the snapshot. The snapshot is then saved.
+func (g *Commands) settleSnapshot() error {
+	cs := g.copySnapshot
+
+	var removed []string
+	for id := range cs.Entries {
+		if !cs.seen[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	for _, id := range removed {
+		entry := cs.Entries[id]
+		g.report.count(snapshotTitle, SnapshotRemoved)
+		if !g.opts.SnapshotDeletions {
+			continue
+		}
+
+		g.plan.knowPath(entry.CopyId, entry.Dest)
+		err := g.mut.Trash(entry.CopyId)
+		g.audit(AuditTrash, &File{Id: entry.CopyId}, entry.Dest, "", err)
+		if err != nil {
+			g.report.warn("Copies of removed files not trashed", "%s: %v", entry.Dest, err)
+			continue
+		}
+		g.report.note("Copies of removed files trashed", "%s", entry.Dest)
+		delete(cs.Entries, id)
+	}
+
+	// Planned copies have placeholder ids that mustn't be persisted
+	if g.plan != nil {
+		return nil
+	}
+	if err := cs.save(); err != nil {
+		return fmt.Errorf("snapshot: %s: %v", cs.path, err)
+	}
+	return nil
+}
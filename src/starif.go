@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// starRule decides whether a copy is to be starred, going by its source.
+type starRule func(f *File) bool
+
+// parseStarRule parses spec, comma separated clauses that all have to hold, e.g
+// "size>10MB,name~\.pdf$". Sizes are as per sizeOf, files that it doesn't
+// count the size of never hold for size clauses. The clauses are:
+//
+//	size>SIZE, size<SIZE  the size is over or under SIZE e.g 512KB, 4GB
+//	name~REGEXP           the name matches REGEXP
+//	mime=TYPE             the mime type is TYPE
+func parseStarRule(spec string, sizeOf func(*File) (int64, bool)) (starRule, error) {
+	var clauses []starRule
+	for _, clause := range NonEmptyTrimmedStrings(strings.Split(spec, ",")...) {
+		var rule starRule
+		switch {
+		case strings.HasPrefix(clause, "size>"), strings.HasPrefix(clause, "size<"):
+			size, err := ParseByteSize(clause[len("size>"):])
+			if err != nil {
+				return nil, fmt.Errorf("star-if: %s: %v", clause, err)
+			}
+			over := clause[len("size")] == '>'
+			rule = func(f *File) bool {
+				fSize, counted := sizeOf(f)
+				if !counted {
+					return false
+				}
+				if over {
+					return fSize > size
+				}
+				return fSize < size
+			}
+		case strings.HasPrefix(clause, "name~"):
+			nameRegexp, err := regexp.Compile(clause[len("name~"):])
+			if err != nil {
+				return nil, fmt.Errorf("star-if: %s: %v", clause, err)
+			}
+			rule = func(f *File) bool { return nameRegexp.MatchString(f.Name) }
+		case strings.HasPrefix(clause, "mime="):
+			mimeType := clause[len("mime="):]
+			rule = func(f *File) bool { return f.MimeType == mimeType }
+		default:
+			return nil, fmt.Errorf("star-if: unknown clause %q, expecting size>, size<, name~ or mime=", clause)
+		}
+		clauses = append(clauses, rule)
+	}
+
+	if len(clauses) < 1 {
+		return nil, fmt.Errorf("star-if: %q has no clauses", spec)
+	}
+
+	return func(f *File) bool {
+		for _, rule := range clauses {
+			if !rule(f) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// starIf stars copied if its source src matches the star rule.
+func (g *Commands) starIf(src, copied *File, destPath string) {
+	if !g.starRule(src) {
+		return
+	}
+	if err := g.mut.star(copied.Id); err != nil {
+		g.report.warn("Copies not starred", "%s: %v", destPath, err)
+		return
+	}
+	g.report.count("Starred", "copies")
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import "testing"
+
+func TestParseStarRule(t *testing.T) {
+	sizeOf := func(f *File) (int64, bool) {
+		if f.MimeType == GoogleAppsMimeTypePrefix+"document" {
+			return 0, false
+		}
+		return f.Size, true
+	}
+
+	big := &File{Name: "talk.pdf", Size: 20 * 1024 * 1024, MimeType: "application/pdf"}
+	small := &File{Name: "notes.txt", Size: 100, MimeType: "text/plain"}
+	doc := &File{Name: "plan", MimeType: GoogleAppsMimeTypePrefix + "document"}
+
+	tests := []struct {
+		spec string
+		want map[*File]bool
+	}{
+		{"size>10MB", map[*File]bool{big: true, small: false, doc: false}},
+		{"size<1KB", map[*File]bool{big: false, small: true, doc: false}},
+		{`name~\.pdf$`, map[*File]bool{big: true, small: false, doc: false}},
+		{"mime=text/plain", map[*File]bool{big: false, small: true, doc: false}},
+		{`size>10MB, name~\.txt$`, map[*File]bool{big: false, small: false, doc: false}},
+		{`size>10MB,name~^talk`, map[*File]bool{big: true, small: false, doc: false}},
+		{"name~plan,", map[*File]bool{big: false, small: false, doc: true}},
+	}
+	for _, tt := range tests {
+		rule, err := parseStarRule(tt.spec, sizeOf)
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+			continue
+		}
+		for f, want := range tt.want {
+			if got := rule(f); got != want {
+				t.Errorf("%q on %s = %v, want %v", tt.spec, f.Name, got, want)
+			}
+		}
+	}
+}
+
+func TestParseStarRuleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" , ",
+		"size>lots",
+		"size=10MB",
+		"name~(",
+		"owner=me",
+	} {
+		if _, err := parseStarRule(spec, nil); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+// Star stars the items in opts.Sources.
+func (g *Commands) Star(byId bool) error {
+	return g.setStarred(true, byId)
+}
+
+// Unstar unstars the items in opts.Sources.
+func (g *Commands) Unstar(byId bool) error {
+	return g.setStarred(false, byId)
+}
+
+func (g *Commands) setStarred(starred, byId bool) error {
+	resolver := g.rem.FindByPath
+	if byId {
+		resolver = g.rem.FindById
+	}
+
+	op, action := g.rem.unstar, "unstar"
+	if starred {
+		op, action = g.rem.star, "star"
+	}
+
+	var composedError error = nil
+	for _, p := range g.opts.Sources {
+		f, err := resolver(p)
+		if err == nil && f == nil {
+			err = ErrPathNotExists
+		}
+		if err == nil {
+			err = op(f.Id)
+		}
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %s: %v", action, p, err))
+		}
+	}
+	return composedError
+}
+
+// starredPaths returns the paths of the starred items that are in any of
+// the folders at sources, leaving out those in starred folders.
+func (g *Commands) starredPaths(sources []string) []string {
+	return g.queryPaths("starred=true", sources)
+}
+
+// ListStarred lists the starred items anywhere in the folders in
+// opts.Sources, as a collection of their own.
+func (g *Commands) ListStarred() error {
+	g.listPaths(g.starredPaths(g.opts.Sources))
+	return nil
+}
+
+// PullStarred pulls the starred items anywhere in the folders in opts.Sources,
+// along with everything in the starred folders, to their local paths.
+func (g *Commands) PullStarred() error {
+	paths := g.starredPaths(g.opts.Sources)
+	if len(paths) < 1 {
+		g.log.Logln("No starred items to pull")
+		return nil
+	}
+
+	g.opts.Sources = paths
+	return g.Pull(false)
+}
@@ -19,6 +19,7 @@ import (
 	drive "google.golang.org/api/drive/v2"
 	"github.com/odeke-em/log"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -121,6 +122,15 @@ func prettyFileStat(logf log.Loggerf, relToRootPath string, file *File) {
 		)
 	}
 
+	var keys []string
+	for key := range file.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kvList = append(kvList, &keyValue{"Property:" + key, file.Properties[key]})
+	}
+
 	for _, kv := range kvList {
 		logf("%-25s %-30v\n", kv.key, kv.value.(string))
 	}
@@ -128,7 +138,9 @@ func prettyFileStat(logf log.Loggerf, relToRootPath string, file *File) {
 
 func (g *Commands) stat(relToRootPath string, file *File, depth int) error {
 
-	if g.opts.Md5sum {
+	if g.emitter != nil {
+		g.emitter.emit(newFileEntry(relToRootPath, file))
+	} else if g.opts.Md5sum {
 		if file.Md5Checksum != "" {
 			g.log.Logf("%32s  %s\n", file.Md5Checksum, strings.TrimPrefix(relToRootPath, "/"))
 		}
@@ -0,0 +1,140 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/odeke-em/log"
+)
+
+const (
+	// SymlinksFollow pushes what symlinks point to as if it were where
+	// the symlinks are, except for folders that contain the symlinks or
+	// that they were reached through.
+	SymlinksFollow = "follow"
+	// SymlinksSkip leaves symlinks out of pushes and pulls.
+	SymlinksSkip = "skip"
+	// SymlinksPreserve pushes symlinks as small files holding their
+	// targets, tagged with SymlinkTargetKey, and pull recreates them.
+	SymlinksPreserve = "preserve"
+
+	// SymlinkTargetKey is the public property that
+	// preserved symlinks keep their targets in.
+	SymlinkTargetKey = "symlinkTarget"
+)
+
+func CheckSymlinksPolicy(policy string) error {
+	switch policy {
+	case "", SymlinksFollow, SymlinksSkip, SymlinksPreserve:
+		return nil
+	}
+	return fmt.Errorf("unknown symlinks policy %q, expecting %s, %s or %s",
+		policy, SymlinksFollow, SymlinksSkip, SymlinksPreserve)
+}
+
+// symlinkWalk is the real paths of the folders that a local walk descended
+// through, links back to any of which aren't followed as they'd never end.
+type symlinkWalk struct {
+	parent   *symlinkWalk
+	realPath string
+}
+
+func (w *symlinkWalk) descend(realPath string) *symlinkWalk {
+	return &symlinkWalk{parent: w, realPath: realPath}
+}
+
+func (w *symlinkWalk) visited(realPath string) bool {
+	for ; w != nil; w = w.parent {
+		if w.realPath == realPath {
+			return true
+		}
+	}
+	return false
+}
+
+// localSymlink is the local file for the symlink at resPath, listed as per
+// policy, or nil if it is to be left out e.g because it dangles. walk is
+// that of the folder the symlink is in.
+func localSymlink(resPath string, info os.FileInfo, policy string, walk *symlinkWalk, logy *log.Logger) *File {
+	switch policy {
+	case SymlinksSkip:
+		return nil
+	case SymlinksPreserve:
+		target, err := os.Readlink(resPath)
+		if err != nil {
+			return nil
+		}
+		lf := NewLocalFile(resPath, info)
+		lf.IsDir = false
+		lf.Size = int64(len(target))
+		lf.Md5Checksum = fmt.Sprintf("%x", md5.Sum([]byte(target)))
+		lf.SymlinkTarget = target
+		return lf
+	}
+
+	symResolvPath, err := filepath.EvalSymlinks(resPath)
+	if err != nil {
+		return nil
+	}
+	symInfo, err := os.Stat(symResolvPath)
+	if err != nil {
+		return nil
+	}
+
+	// Following a symlink to a folder that contains it, or that it was
+	// reached through e.g by a symlink to a sibling, would never end
+	if symInfo.IsDir() {
+		dir, err := filepath.EvalSymlinks(filepath.Dir(resPath))
+		if err != nil || under(filepath.ToSlash(dir), filepath.ToSlash(symResolvPath)) {
+			logy.LogErrf("%s: links to a folder that contains it, not following it\n", resPath)
+			return nil
+		}
+		if walk.visited(symResolvPath) {
+			logy.LogErrf("%s: links back to %s, which it was reached through, not following it\n", resPath, symResolvPath)
+			return nil
+		}
+	}
+
+	lf := NewLocalFile(symResolvPath, symInfo)
+	// Retain the original name as appeared in
+	// the manifest instead of the resolved one
+	lf.Name = info.Name()
+	return lf
+}
+
+// preservedSymlink is the target of the symlink that f was pushed
+// as, if symlinks are preserved and f is one.
+func (g *Commands) preservedSymlink(f *File) (string, bool) {
+	if f == nil || f.IsDir || g.opts.Symlinks != SymlinksPreserve {
+		return "", false
+	}
+	target, ok := f.Properties[SymlinkTargetKey]
+	return target, ok && target != ""
+}
+
+// relink recreates the symlink to target at p, replacing whatever is there.
+func relink(p, target string) error {
+	if current, err := os.Readlink(p); err == nil && current == target {
+		return nil
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(target, p)
+}
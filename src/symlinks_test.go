@@ -0,0 +1,88 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFollowSymlinksBetweenSiblings(t *testing.T) {
+	g := commandsOn(newFakeDrive(), &Options{Recursive: true, Symlinks: SymlinksFollow})
+	defer inTempContext(t, g)()
+
+	root := g.context.AbsPathOf("")
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeTestFile(filepath.Join(root, dir, dir+".txt")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../b", filepath.Join(root, "a", "l1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../a", filepath.Join(root, "b", "l2")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved := make(chan []*Change, 1)
+	go func() {
+		cl, _, _ := g.resolveChangeListRecv(&changeListResolve{push: true, base: "/", local: NewLocalFile(root, info)})
+		resolved <- cl
+	}()
+
+	var cl []*Change
+	select {
+	case cl = <-resolved:
+	case <-time.After(10 * time.Second):
+		t.Fatal("walking the symlinks didn't end")
+	}
+
+	var got []string
+	for _, change := range cl {
+		got = append(got, change.Path)
+	}
+	sort.Strings(got)
+	want := []string{
+		"/", "/a", "/a/a.txt", "/a/l1", "/a/l1/b.txt",
+		"/b", "/b/b.txt", "/b/l2", "/b/l2/a.txt",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got changes %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got changes %v, want %v", got, want)
+		}
+	}
+}
+
+func writeTestFile(p string) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	f.WriteString(p)
+	return f.Close()
+}
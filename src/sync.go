@@ -0,0 +1,401 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+	drive "google.golang.org/api/drive/v2"
+)
+
+const SyncStateSuffix = "sync"
+
+// syncRoot is what is known of a synced folder as of its last sync: the
+// largest change id seen and the paths of the items under it by their ids.
+type syncRoot struct {
+	LargestChangeId int64             `json:"largestChangeId"`
+	Paths           map[string]string `json:"paths"`
+}
+
+// syncState is the change-token store, of the synced folders by path.
+type syncState struct {
+	path  string
+	Roots map[string]*syncRoot `json:"roots"`
+}
+
+// syncRename is a local rename that follows an item renamed or moved remotely.
+type syncRename struct {
+	id       string
+	from, to string
+}
+
+// syncStatePath is where the sync state of the account of the context is
+// kept, each profile apart as the changes it follows are its account's e.g
+// .gd/work.sync.
+func (g *Commands) syncStatePath() string {
+	name := SyncStateSuffix
+	if g.context.Profile != "" {
+		name = fmt.Sprintf("%s.%s", g.context.Profile, SyncStateSuffix)
+	}
+	return path.Join(g.context.AbsPathOf(""), config.GDDirSuffix, name)
+}
+
+func loadSyncState(p string) (*syncState, error) {
+	ss := &syncState{path: p, Roots: make(map[string]*syncRoot)}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ss, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, ss); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if ss.Roots == nil {
+		ss.Roots = make(map[string]*syncRoot)
+	}
+	return ss, nil
+}
+
+// save writes out the state, to a temporary file first
+// so that a failed save doesn't clobber the last state.
+func (ss *syncState) save() error {
+	data, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	tmpPath := ss.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, config.O_RWForAll); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, ss.path)
+}
+
+// setSyncPath records p as the path of id, along with the paths of its
+// descendants if it was elsewhere before i.e it is a folder that moved.
+func setSyncPath(paths map[string]string, id, p string) {
+	prev, known := paths[id]
+	paths[id] = p
+	if !known || prev == p {
+		return
+	}
+	for childId, childPath := range paths {
+		if strings.HasPrefix(childPath, prev+"/") {
+			paths[childId] = p + strings.TrimPrefix(childPath, prev)
+		}
+	}
+}
+
+// removeSyncPath forgets id and its descendants.
+func removeSyncPath(paths map[string]string, id string) {
+	prev, known := paths[id]
+	if !known {
+		return
+	}
+	delete(paths, id)
+	for childId, childPath := range paths {
+		if strings.HasPrefix(childPath, prev+"/") {
+			delete(paths, childId)
+		}
+	}
+}
+
+func copySyncPaths(paths map[string]string) map[string]string {
+	dup := make(map[string]string, len(paths))
+	for id, p := range paths {
+		dup[id] = p
+	}
+	return dup
+}
+
+// Sync brings the local copy of the folder at opts.Sources[0], or of the whole
+// drive, up to date. The first sync pulls it in full and records where the Drive
+// Changes feed stands. Later syncs only fetch the changes made since and apply
+// them locally: renames and moves are followed with local renames, trashed and
+// deleted items are removed and new or updated content is downloaded.
+func (g *Commands) Sync() error {
+	rootPath := "/"
+	if len(g.opts.Sources) >= 1 {
+		rootPath = g.opts.Sources[0]
+	}
+
+	ss, err := loadSyncState(g.syncStatePath())
+	if err != nil {
+		return fmt.Errorf("sync: state: %v", err)
+	}
+
+	root := ss.Roots[rootPath]
+	if root == nil {
+		err = g.syncBaseline(ss, rootPath)
+	} else {
+		err = g.syncChanges(ss, rootPath, root)
+	}
+	if err != nil {
+		return fmt.Errorf("sync: %v", err)
+	}
+	return nil
+}
+
+func (g *Commands) syncBaseline(ss *syncState, rootPath string) error {
+	g.log.Logf("First sync of %s, pulling it in full\n", rootPath)
+
+	// Changes made while pulling are picked up by the next sync
+	about, err := g.rem.About()
+	if err != nil {
+		return err
+	}
+
+	folder, err := g.rem.FindByPath(rootPath)
+	if err != nil {
+		return fmt.Errorf("%s: %v", rootPath, err)
+	}
+	if folder == nil || !folder.IsDir {
+		return fmt.Errorf("%s: %v", rootPath, ErrPathNotDir)
+	}
+
+	paths := map[string]string{folder.Id: rootPath}
+	g.walkSyncPaths(folder.Id, rootPath, paths)
+
+	g.opts.Sources = []string{rootPath}
+	if err := g.Pull(false); err != nil {
+		return err
+	}
+
+	ss.Roots[rootPath] = &syncRoot{LargestChangeId: about.LargestChangeId, Paths: paths}
+	return ss.save()
+}
+
+func (g *Commands) walkSyncPaths(folderId, folderPath string, paths map[string]string) {
+	for child := range g.rem.findChildren(folderId, false) {
+		childPath := path.Join(folderPath, child.Name)
+		paths[child.Id] = childPath
+		if child.IsDir {
+			g.walkSyncPaths(child.Id, childPath, paths)
+		}
+	}
+}
+
+// latestSyncChanges keeps the last change of each item, in the order of the changes.
+func latestSyncChanges(changes []*drive.Change) []*drive.Change {
+	last := make(map[string]int)
+	for i, ch := range changes {
+		last[ch.FileId] = i
+	}
+
+	var latest []*drive.Change
+	for i, ch := range changes {
+		if last[ch.FileId] == i {
+			latest = append(latest, ch)
+		}
+	}
+	return latest
+}
+
+func syncGone(ch *drive.Change) bool {
+	return ch.Deleted || ch.File == nil || (ch.File.Labels != nil && ch.File.Labels.Trashed)
+}
+
+func (g *Commands) syncChanges(ss *syncState, rootPath string, root *syncRoot) error {
+	changes, largestChangeId, err := g.rem.changes(root.LargestChangeId + 1)
+	if err != nil {
+		return err
+	}
+
+	// Where the items are to be, worked out change by change. A change is only
+	// placed once its parent is, which may be by a later change e.g of a new
+	// folder, so placing goes round until no more changes can be placed.
+	newPaths := copySyncPaths(root.Paths)
+	var goneIds []string
+	var pending []*drive.Change
+	for _, ch := range latestSyncChanges(changes) {
+		// The root's own path is what the user asks to sync, not where it is
+		if root.Paths[ch.FileId] == rootPath {
+			continue
+		}
+		if syncGone(ch) {
+			goneIds = append(goneIds, ch.FileId)
+		} else {
+			pending = append(pending, ch)
+		}
+	}
+
+	placed := make(map[string]*File)
+	for progressed := true; progressed; {
+		progressed = false
+		var unplaced []*drive.Change
+		for _, ch := range pending {
+			parentPath := ""
+			for _, parent := range ch.File.Parents {
+				if p, ok := newPaths[parent.Id]; ok {
+					parentPath = p
+					break
+				}
+			}
+			if parentPath == "" {
+				unplaced = append(unplaced, ch)
+				continue
+			}
+
+			f := NewRemoteFile(ch.File)
+			setSyncPath(newPaths, f.Id, path.Join(parentPath, f.Name))
+			placed[f.Id] = f
+			progressed = true
+		}
+		pending = unplaced
+	}
+	// Items that can't be placed were moved out from under the root
+	for _, ch := range pending {
+		goneIds = append(goneIds, ch.FileId)
+	}
+
+	// The local renames, shallowest first, so that renaming a folder
+	// takes its contents along before any of them are renamed.
+	var renames []*syncRename
+	for id := range placed {
+		if prev, known := root.Paths[id]; known && prev != newPaths[id] {
+			renames = append(renames, &syncRename{id: id, to: newPaths[id]})
+		}
+	}
+	sort.Sort(byRenameDepth(renames))
+
+	local := copySyncPaths(root.Paths)
+	for _, rename := range renames {
+		rename.from = local[rename.id]
+		setSyncPath(local, rename.id, rename.to)
+	}
+
+	var cl []*Change
+	for _, id := range goneIds {
+		prev, known := root.Paths[id]
+		if !known {
+			continue
+		}
+		// Gone with a folder that is gone too
+		if _, stillKnown := newPaths[id]; !stillKnown {
+			continue
+		}
+		fi, err := os.Stat(g.context.AbsPathOf(prev))
+		removeSyncPath(newPaths, id)
+		if err != nil {
+			continue
+		}
+		p := local[id]
+		cl = append(cl, &Change{
+			Path:   p,
+			Parent: path.Dir(p),
+			Dest:   NewLocalFile(g.context.AbsPathOf(p), fi),
+			g:      g,
+		})
+	}
+
+	for id, f := range placed {
+		p := newPaths[id]
+		if anyMatch(g.opts.IgnoreRegexp, f.Name) || g.opts.Ignores.Match(p, f.IsDir) {
+			continue
+		}
+		if _, stillKnown := newPaths[id]; !stillKnown {
+			continue
+		}
+
+		var dest *File
+		localPath := p
+		if prev, known := root.Paths[id]; known {
+			localPath = prev
+		}
+		if fi, err := os.Stat(g.context.AbsPathOf(localPath)); err == nil {
+			dest = NewLocalFile(g.context.AbsPathOf(p), fi)
+		}
+
+		change := &Change{
+			Path:           p,
+			Parent:         path.Dir(p),
+			Src:            f,
+			Dest:           dest,
+			Force:          g.opts.Force,
+			NoClobber:      g.opts.NoClobber,
+			IgnoreChecksum: g.opts.IgnoreChecksum,
+			g:              g,
+		}
+		if change.Op() != OpNone {
+			cl = append(cl, change)
+		}
+	}
+
+	for _, rename := range renames {
+		if rename.from != rename.to {
+			g.log.Logf("Rename %s -> %s\n", rename.from, rename.to)
+		}
+	}
+
+	if len(cl) < 1 {
+		if len(renames) >= 1 && g.opts.canPrompt() && !promptForChanges() {
+			return nil
+		}
+		if len(renames) < 1 {
+			g.log.Logln("Everything is up-to-date.")
+		}
+	} else {
+		clArg := changeListArg{
+			logy:      g.log,
+			changes:   cl,
+			noPrompt:  !g.opts.canPrompt(),
+			noClobber: g.opts.NoClobber,
+			sizeOf:    g.sizeOf,
+		}
+		if ok, _ := printChangeList(&clArg); !ok {
+			return nil
+		}
+	}
+
+	for _, rename := range renames {
+		if rename.from == rename.to {
+			continue
+		}
+		fromAbsPath, toAbsPath := g.context.AbsPathOf(rename.from), g.context.AbsPathOf(rename.to)
+		if err := os.MkdirAll(path.Dir(toAbsPath), os.ModeDir|0755); err != nil {
+			return err
+		}
+		if err := os.Rename(fromAbsPath, toAbsPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if len(cl) >= 1 {
+		if err := g.playPullChanges(cl, g.opts.Exports, nil); err != nil {
+			return err
+		}
+	}
+
+	root.LargestChangeId = largestChangeId
+	root.Paths = newPaths
+	return ss.save()
+}
+
+type byRenameDepth []*syncRename
+
+func (br byRenameDepth) Len() int      { return len(br) }
+func (br byRenameDepth) Swap(i, j int) { br[i], br[j] = br[j], br[i] }
+func (br byRenameDepth) Less(i, j int) bool {
+	return strings.Count(br[i].to, "/") < strings.Count(br[j].to, "/")
+}
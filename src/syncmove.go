@@ -0,0 +1,201 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// layoutOp is the minimal set of operations needed
+// to get a file from its current path to its desired one.
+type layoutOp struct {
+	file     *File
+	parentId string
+	current  string
+	desired  string
+	// reparent is set if the file has to change folders
+	reparent bool
+	// rename is set if the file's name has to change
+	rename bool
+}
+
+func (op *layoutOp) String() string {
+	desiredDir, desiredBase := remotePathSplit(op.desired)
+	switch {
+	case op.reparent && op.rename:
+		return fmt.Sprintf("move+rename %s -> %s", op.current, op.desired)
+	case op.reparent:
+		return fmt.Sprintf("move        %s -> %s/", op.current, desiredDir)
+	}
+	return fmt.Sprintf("rename      %s -> %s", op.current, desiredBase)
+}
+
+// SyncMove reorganizes existing files to match the layout described in
+// the file at layoutPath whose lines are tab separated pairs of
+//
+//	<current path>	<desired path>
+//
+// computing and applying only the reparents and renames that are needed.
+func (g *Commands) SyncMove(layoutPath string) error {
+	mappings, err := readMappingsFile(layoutPath, "#")
+	if err != nil {
+		return err
+	}
+
+	ops, err := g.planLayout(mappings)
+	if err != nil {
+		return err
+	}
+
+	if len(ops) < 1 {
+		g.log.Logln("Everything is already in place")
+		return nil
+	}
+
+	g.log.Logf("Plan (%d operations):\n", len(ops))
+	for _, op := range ops {
+		g.log.Logf("  %s\n", op)
+	}
+
+	if g.plan == nil && g.opts.canPrompt() && !promptForChanges() {
+		return nil
+	}
+
+	var composedError error = nil
+	for _, op := range ops {
+		if err := g.applyLayoutOp(op); err != nil {
+			message := fmt.Sprintf("sync-move: %s: %v", op.current, err)
+			composedError = reComposeError(composedError, message)
+		}
+	}
+
+	if err := g.flushPlan(); err != nil {
+		composedError = reComposeError(composedError, err.Error())
+	}
+	return composedError
+}
+
+func (g *Commands) planLayout(mappings []*mapping) (ops []*layoutOp, err error) {
+	claimed := make(map[string]string)
+	var composedError error = nil
+
+	for _, m := range mappings {
+		current := path.Clean(path.Join("/", m.from))
+		desired := path.Clean(path.Join("/", m.to))
+
+		if prev, taken := claimed[desired]; taken {
+			message := fmt.Sprintf("%s and %s both want to be at %s", prev, current, desired)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+		claimed[desired] = current
+
+		if current == desired {
+			continue
+		}
+		if rootLike(desired) {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s cannot be moved to root itself", current))
+			continue
+		}
+
+		file, findErr := g.rem.FindByPath(current)
+		if findErr != nil || file == nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", current, findErr))
+			continue
+		}
+
+		occupant, occErr := g.rem.FindByPath(desired)
+		if occErr != nil && occErr != ErrPathNotExists {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: %v", desired, occErr))
+			continue
+		}
+		if occupant != nil && occupant.Id != file.Id && !g.opts.Force {
+			message := fmt.Sprintf("%s already exists. Use `%s` flag to override this behaviour", desired, ForceKey)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+
+		currentDir, currentBase := g.pathSplitter(current)
+		desiredDir, desiredBase := g.pathSplitter(desired)
+
+		if strings.HasPrefix(desiredDir+"/", current+"/") {
+			message := fmt.Sprintf("%s cannot be nested into %s", current, desiredDir)
+			composedError = reComposeError(composedError, message)
+			continue
+		}
+
+		// Resolving the current parent upfront keeps later operations
+		// valid even after earlier ones have moved folders around.
+		parent, parErr := g.rem.FindByPath(currentDir)
+		if parErr != nil || parent == nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("%s: parent: %v", current, parErr))
+			continue
+		}
+
+		ops = append(ops, &layoutOp{
+			file:     file,
+			parentId: parent.Id,
+			current:  current,
+			desired:  desired,
+			reparent: currentDir != desiredDir,
+			rename:   currentBase != desiredBase,
+		})
+	}
+
+	return ops, composedError
+}
+
+func (g *Commands) applyLayoutOp(op *layoutOp) (err error) {
+	desiredDir, desiredBase := g.pathSplitter(op.desired)
+
+	auditOp := AuditMove
+	if !op.reparent {
+		auditOp = AuditRename
+	}
+	defer func() {
+		g.audit(auditOp, op.file, op.current, op.desired, err)
+	}()
+
+	g.plan.knowPath(op.file.Id, op.current)
+	g.plan.knowPath(op.parentId, g.parentPather(op.current))
+
+	if g.opts.Breadcrumb {
+		if bcErr := g.mut.setAppProperty(op.file.Id, OriginalPathKey, op.current); bcErr != nil {
+			g.log.LogErrf("sync-move: %s: could not record its original path: %v\n", op.current, bcErr)
+		}
+	}
+
+	if op.reparent {
+		newParent, err := g.remoteMkdirAll(desiredDir)
+		if err != nil {
+			return err
+		}
+		if err = g.mut.insertParent(op.file.Id, newParent.Id); err != nil {
+			return err
+		}
+		if err = g.mut.removeParent(op.file.Id, op.parentId); err != nil {
+			return err
+		}
+	}
+
+	if op.rename {
+		if _, err := g.mut.rename(op.file.Id, desiredBase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// destTemplatePlaceholders are the placeholders of destination templates
+// on top of those of partitions i.e {initial}, {year}, {month}, {day}, {ext}.
+var destTemplatePlaceholders = map[string]func(f *File) string{
+	"{name}": func(f *File) string { return f.Name },
+	"{base}": func(f *File) string {
+		ext := filepath.Ext(f.Name)
+		if ext == f.Name {
+			return f.Name
+		}
+		return strings.TrimSuffix(f.Name, ext)
+	},
+	"{mime}": func(f *File) string { return strings.Replace(f.MimeType, "/", "-", -1) },
+	"{owner}": func(f *File) string {
+		if len(f.OwnerNames) < 1 {
+			return "_"
+		}
+		return f.OwnerNames[0]
+	},
+}
+
+// destTemplate routes each copied file to the path, under root, that
+// its template evaluates to for the file e.g "{year}/{month}/{name}".
+type destTemplate struct {
+	root     string
+	template string
+}
+
+// parseDestTemplate checks that template only has known placeholders.
+// A template that ends in a "/" routes files into that folder by name.
+func parseDestTemplate(root, template string) (*destTemplate, error) {
+	if strings.HasSuffix(template, "/") {
+		template += "{name}"
+	}
+
+	for _, placeholder := range partitionPlaceholderRegexp.FindAllString(template, -1) {
+		_, isPartition := partitionPlaceholders[placeholder]
+		_, isDest := destTemplatePlaceholders[placeholder]
+		if !isPartition && !isDest {
+			return nil, fmt.Errorf("dest-template: unknown placeholder %s in %q", placeholder, template)
+		}
+	}
+
+	return &destTemplate{root: root, template: template}, nil
+}
+
+func (dt *destTemplate) eval(f *File) string {
+	evaluated := partitionPlaceholderRegexp.ReplaceAllStringFunc(dt.template, func(placeholder string) string {
+		if fn, ok := destTemplatePlaceholders[placeholder]; ok {
+			return fn(f)
+		}
+		return partitionPlaceholders[placeholder](f)
+	})
+	return path.Join(dt.root, evaluated)
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDestTemplate(t *testing.T) {
+	f := &File{
+		Name:       "Holiday.JPG",
+		MimeType:   "image/jpeg",
+		ModTime:    time.Date(2015, time.March, 7, 10, 0, 0, 0, time.UTC),
+		OwnerNames: []string{"Ann"},
+	}
+
+	tests := []struct {
+		root     string
+		template string
+		want     string
+	}{
+		{"Archive", "{year}/{month}/{name}", "Archive/2015/03/Holiday.JPG"},
+		{"Archive", "{year}/", "Archive/2015/Holiday.JPG"},
+		{"/Archive", "{ext}/{base}-{day}.{ext}", "/Archive/jpg/Holiday-07.jpg"},
+		{"Archive", "{mime}/{initial}/{name}", "Archive/image-jpeg/H/Holiday.JPG"},
+		{"Archive", "{owner}/{name}", "Archive/Ann/Holiday.JPG"},
+		{"Archive", "flat", "Archive/flat"},
+		{"Archive", "../{name}", "Holiday.JPG"},
+	}
+	for _, tt := range tests {
+		dt, err := parseDestTemplate(tt.root, tt.template)
+		if err != nil {
+			t.Errorf("%q: %v", tt.template, err)
+			continue
+		}
+		if got := dt.eval(f); got != tt.want {
+			t.Errorf("%q under %q = %q, want %q", tt.template, tt.root, got, tt.want)
+		}
+	}
+
+	unowned := &File{Name: ".bashrc"}
+	dt, err := parseDestTemplate("", "{owner}/{base}/{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dt.eval(unowned), "_/.bashrc/bashrc"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseDestTemplateErrors(t *testing.T) {
+	for _, template := range []string{"{year}/{nope}", "{NAME}", "{}"} {
+		if _, err := parseDestTemplate("Archive", template); err == nil {
+			t.Errorf("%q: expected an error", template)
+		}
+	}
+}
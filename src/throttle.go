@@ -0,0 +1,133 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// quotaCheckInterval is how stale the known free storage can get before it is checked again
+	quotaCheckInterval = 30 * time.Second
+	// throttledPace is the least time between copies once throttled
+	throttledPace = 2 * time.Second
+)
+
+// quotaGovernor paces copies once the free storage of the account drops below
+// a threshold, and pauses them altogether if there isn't space for the next one.
+// Between checks the free storage is estimated by deducting what was copied.
+type quotaGovernor struct {
+	sync.Mutex
+	// threshold is in bytes, unless percent is set in which case it is a
+	// percentage of the total storage
+	threshold float64
+	percent   bool
+
+	free      int64
+	total     int64
+	checkedAt time.Time
+	engaged   bool
+}
+
+// newQuotaGovernor parses spec, either a size e.g "5GB" or a
+// percentage of the total storage e.g "5%", as its threshold.
+func newQuotaGovernor(spec string) (*quotaGovernor, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasSuffix(spec, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			return nil, fmt.Errorf("%q is not a percentage e.g 5%%", spec)
+		}
+		return &quotaGovernor{threshold: percentage, percent: true}, nil
+	}
+
+	size, err := ParseByteSize(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &quotaGovernor{threshold: float64(size)}, nil
+}
+
+func (qg *quotaGovernor) thresholdBytes() int64 {
+	if qg.percent {
+		return int64(qg.threshold * float64(qg.total) / 100)
+	}
+	return int64(qg.threshold)
+}
+
+func (g *Commands) refreshQuota(qg *quotaGovernor) {
+	qg.checkedAt = time.Now()
+	about, err := g.rem.About()
+	if err != nil {
+		g.report.warn("Quota checks failed", "%v", err)
+		return
+	}
+	qg.total = about.QuotaBytesTotal
+	qg.free = about.QuotaBytesTotal - about.QuotaBytesUsed
+}
+
+// throttleOnLowQuota blocks before a copy of size bytes is made for as long as
+// the free storage calls for. Holding the governor throughout makes concurrent
+// copies take their turns, which is what paces them.
+func (g *Commands) throttleOnLowQuota(size int64) {
+	qg := g.quotaGovernor
+	// Planned copies don't use up any storage
+	if qg == nil || g.plan != nil {
+		return
+	}
+
+	qg.Lock()
+	defer qg.Unlock()
+
+	for {
+		if time.Since(qg.checkedAt) >= quotaCheckInterval {
+			g.refreshQuota(qg)
+		}
+		// Without a total, the quota is either unknown or unlimited
+		if qg.total < 1 {
+			return
+		}
+
+		threshold := qg.thresholdBytes()
+		if qg.free-size > threshold {
+			if qg.engaged {
+				qg.engaged = false
+				g.log.LogErrf("%s of storage free, copying at full pace again\n", prettyBytes(qg.free))
+			}
+			qg.free -= size
+			return
+		}
+
+		if !qg.engaged {
+			qg.engaged = true
+			g.log.LogErrf("%s of storage free, below %s, throttling copies\n", prettyBytes(qg.free), prettyBytes(threshold))
+			g.report.warn("Throttled on low quota", "%s free, below %s", prettyBytes(qg.free), prettyBytes(threshold))
+		}
+
+		if qg.free < size {
+			// Paused until space is freed up e.g by emptying the trash
+			time.Sleep(quotaCheckInterval)
+			continue
+		}
+
+		time.Sleep(throttledPace)
+		qg.free -= size
+		return
+	}
+}
@@ -52,7 +52,13 @@ func (g *Commands) Untrash(byId bool) (err error) {
 	return g.reduceForTrash(g.opts.Sources, &opt)
 }
 
-func (g *Commands) EmptyTrash() error {
+// EmptyTrash permanently deletes everything in the trash or, if olderThan
+// is set, only the items trashed before then e.g "30d".
+func (g *Commands) EmptyTrash(olderThan string) error {
+	if olderThan != "" {
+		return g.emptyTrashOlderThan(olderThan)
+	}
+
 	rootFile, err := g.rem.FindByPath("/")
 	if err != nil {
 		return err
@@ -166,6 +172,7 @@ func (g *Commands) trashByMatch(inTrash, permanent bool) error {
 		changes:   cl,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: false,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, _ := printChangeList(&clArg)
@@ -195,6 +202,15 @@ func (g *Commands) DeleteByMatch() error {
 }
 
 func (g *Commands) reduceForTrash(args []string, opt *trashOpt) error {
+	// Patterns are only matched against items that aren't already trashed
+	if opt.toTrash && !opt.byId {
+		expanded, err := g.expandSources(args)
+		if err != nil {
+			g.log.LogErrf("\033[91m%v\033[00m\n", err)
+		}
+		args = expanded
+	}
+
 	var cl []*Change
 	for _, relToRoot := range args {
 		c, cErr := g.trasher(relToRoot, opt)
@@ -210,6 +226,7 @@ func (g *Commands) reduceForTrash(args []string, opt *trashOpt) error {
 		changes:   cl,
 		noPrompt:  !g.opts.canPrompt(),
 		noClobber: false,
+		sizeOf:    g.sizeOf,
 	}
 
 	ok, _ := printChangeList(&clArg)
@@ -226,7 +243,7 @@ func (g *Commands) reduceForTrash(args []string, opt *trashOpt) error {
 }
 
 func (g *Commands) playTrashChangeList(cl []*Change, opt *trashOpt) (err error) {
-	trashSize, unTrashSize := reduceToSize(cl, SelectDest|SelectSrc)
+	trashSize, unTrashSize := reduceToSize(cl, SelectDest|SelectSrc, g.sizeOf)
 	g.taskStart(trashSize + unTrashSize)
 
 	var fn func(*Change) error
@@ -0,0 +1,215 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// trashedItem is an item in the trash as listed by Drive. The Drive client
+// in use predates the time items are trashed at so the listing is requested
+// directly.
+type trashedItem struct {
+	Id                string `json:"id"`
+	Title             string `json:"title"`
+	MimeType          string `json:"mimeType"`
+	FileSize          int64  `json:"fileSize,string"`
+	ExplicitlyTrashed bool   `json:"explicitlyTrashed"`
+	TrashedDate       string `json:"trashedDate"`
+}
+
+// trashEntry is an explicitly trashed item along with where it was.
+type trashEntry struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Id      string    `json:"id"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	Trashed time.Time `json:"trashed"`
+}
+
+type byTrashedTime []*trashEntry
+
+func (b byTrashedTime) Len() int      { return len(b) }
+func (b byTrashedTime) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byTrashedTime) Less(i, j int) bool {
+	return b[i].Trashed.After(b[j].Trashed)
+}
+
+// explicitlyTrashed lists the items that were trashed themselves, leaving
+// out those only in the trash because a folder they are in was trashed.
+func (r *Remote) explicitlyTrashed() ([]*trashedItem, error) {
+	var items []*trashedItem
+	pageToken := ""
+	for {
+		params := url.Values{}
+		params.Set("q", "trashed=true")
+		params.Set("maxResults", "1000")
+		params.Set("fields", "nextPageToken,items(id,title,mimeType,fileSize,explicitlyTrashed,trashedDate)")
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		resp, err := r.client.Get(r.service.BasePath + "files?" + params.Encode())
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items         []*trashedItem `json:"items"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		if err := decodeResponse(resp, &page); err != nil {
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			if item.ExplicitlyTrashed {
+				items = append(items, item)
+			}
+		}
+		if page.NextPageToken == "" {
+			return items, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// trashEntries are the explicitly trashed items at the paths they were
+// trashed from, most recently trashed first. Items trashed before Drive
+// recorded when have a zero trashed time and come last.
+func (g *Commands) trashEntries() ([]*trashEntry, error) {
+	items, err := g.rem.explicitlyTrashed()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*trashEntry
+	for _, item := range items {
+		p, err := g.rem.pathOf(item.Id)
+		if err != nil {
+			p = fmt.Sprintf("%s (%s)", item.Title, item.Id)
+		}
+		entry := &trashEntry{
+			Path:  p,
+			Name:  item.Title,
+			Id:    item.Id,
+			IsDir: item.MimeType == DriveFolderMimeType,
+			Size:  item.FileSize,
+		}
+		if item.TrashedDate != "" {
+			entry.Trashed = parseTimeAndRound(item.TrashedDate)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Stable(byTrashedTime(entries))
+	return entries, nil
+}
+
+func trashedTimeString(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format(time.RFC822)
+}
+
+// ListTrash lists the explicitly trashed items, most recently trashed first,
+// with the paths they were trashed from and when they were trashed.
+func (g *Commands) ListTrash() error {
+	entries, err := g.trashEntries()
+	if err != nil {
+		return fmt.Errorf("trash: %v", err)
+	}
+
+	if len(entries) < 1 && g.emitter == nil {
+		g.log.Logln("The trash is empty")
+		return nil
+	}
+
+	for _, entry := range entries {
+		if g.emitter != nil {
+			g.emitter.emit(entry)
+			continue
+		}
+		size := prettyBytes(entry.Size)
+		if entry.IsDir {
+			size = "-"
+		}
+		g.log.Logf("%-20s %-12s %s\n", trashedTimeString(entry.Trashed), size, entry.Path)
+	}
+	return nil
+}
+
+// emptyTrashOlderThan permanently deletes the items trashed before the cutoff
+// olderThan e.g "30d", along with the contents of trashed folders. Items not
+// known to have been trashed when are kept.
+func (g *Commands) emptyTrashOlderThan(olderThan string) error {
+	cutoff, err := parseCutoff(olderThan, time.Now())
+	if err != nil {
+		return fmt.Errorf("emptytrash: %v", err)
+	}
+
+	entries, err := g.trashEntries()
+	if err != nil {
+		return fmt.Errorf("emptytrash: %v", err)
+	}
+
+	g.report = newReport()
+	defer g.report.summarize(g.log)
+
+	var due []*trashEntry
+	for _, entry := range entries {
+		if entry.Trashed.IsZero() {
+			g.report.note("Kept, trashed at an unknown time", "%s", entry.Path)
+			continue
+		}
+		if entry.Trashed.Before(cutoff) {
+			due = append(due, entry)
+		}
+	}
+
+	if len(due) < 1 {
+		g.log.Logf("Nothing in the trash was trashed before %s\n", cutoff.Local().Format(time.RFC822))
+		return nil
+	}
+
+	for _, entry := range due {
+		g.log.Logf("delete %-20s %s\n", trashedTimeString(entry.Trashed), entry.Path)
+	}
+
+	if g.opts.canPrompt() {
+		g.log.Logf("This operation is irreversible. Delete these %d items for good! ", len(due))
+		if !promptForChanges() {
+			g.log.Logln("Aborted emptying trash")
+			return nil
+		}
+	}
+
+	var composedError error = nil
+	for _, entry := range due {
+		err := g.rem.Delete(entry.Id)
+		g.audit(AuditDelete, &File{Id: entry.Id, Name: entry.Name, IsDir: entry.IsDir, Size: entry.Size}, entry.Path, "", err)
+		if err != nil {
+			composedError = reComposeError(composedError, fmt.Sprintf("emptytrash: %s: %v", entry.Path, err))
+			continue
+		}
+		g.report.count("Deleted", "items")
+	}
+	return composedError
+}
@@ -0,0 +1,278 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+const DefaultTreeDiffDepth = 3
+
+// treeNode is an item of the simulated tree that a plan is applied to.
+type treeNode struct {
+	name    string
+	isDir   bool
+	parents map[string]bool
+}
+
+// simTree is the tree of items under some roots, as listed from the remote,
+// which the operations of a plan are then applied to without being made.
+type simTree struct {
+	nodes map[string]*treeNode
+	// roots are the ids of the roots and their paths
+	roots     []string
+	rootPaths map[string]string
+}
+
+func (st *simTree) node(id, name string) *treeNode {
+	n, ok := st.nodes[id]
+	if !ok {
+		n = &treeNode{name: name, parents: make(map[string]bool)}
+		st.nodes[id] = n
+	}
+	return n
+}
+
+// walkRemote lists the items under the folder with id, up to depth levels down.
+func (g *Commands) walkRemote(st *simTree, id string, depth int) {
+	if depth < 1 {
+		return
+	}
+	for child := range g.rem.findChildren(id, false) {
+		n := st.node(child.Id, child.Name)
+		n.isDir = child.IsDir
+		n.parents[id] = true
+		if child.IsDir {
+			g.walkRemote(st, child.Id, depth-1)
+		}
+	}
+}
+
+// apply simulates the operations of p on the tree. Items that the
+// tree doesn't have yet are named after their known paths, if any.
+func (st *simTree) apply(p *plan) {
+	named := func(id string) *treeNode {
+		return st.node(id, path.Base(p.paths[id]))
+	}
+
+	for _, op := range p.ops {
+		args := op.args
+		switch op.op {
+		case PlanOpMkdir:
+			n := st.node(args[0], args[2])
+			n.isDir = true
+			n.parents[args[1]] = true
+		case PlanOpCopy, PlanOpReupload:
+			st.node(args[0], args[3]).parents[args[2]] = true
+		case PlanOpInsertParent:
+			named(args[0]).parents[args[1]] = true
+		case PlanOpRemoveParent:
+			delete(named(args[0]).parents, args[1])
+		case PlanOpRename:
+			named(args[0]).name = args[1]
+		case PlanOpTrash:
+			delete(st.nodes, args[0])
+		}
+	}
+}
+
+// paths returns the paths of the items under the roots up to depth
+// levels down, mapped to whether they are folders.
+func (st *simTree) paths(depth int) map[string]bool {
+	children := make(map[string][]string)
+	for id, n := range st.nodes {
+		for parentId := range n.parents {
+			children[parentId] = append(children[parentId], id)
+		}
+	}
+
+	found := make(map[string]bool)
+	var walk func(id, p string, depth int)
+	walk = func(id, p string, depth int) {
+		if depth < 1 {
+			return
+		}
+		for _, childId := range children[id] {
+			child := st.nodes[childId]
+			childPath := path.Join(p, child.name)
+			found[childPath] = child.isDir
+			walk(childId, childPath, depth-1)
+		}
+	}
+
+	for _, rootId := range st.roots {
+		rootPath := st.rootPaths[rootId]
+		found[rootPath] = true
+		walk(rootId, rootPath, depth)
+	}
+	return found
+}
+
+func (st *simTree) snapshot() *simTree {
+	dup := &simTree{nodes: make(map[string]*treeNode), roots: st.roots, rootPaths: st.rootPaths}
+	for id, n := range st.nodes {
+		parents := make(map[string]bool)
+		for parentId := range n.parents {
+			parents[parentId] = true
+		}
+		dup.nodes[id] = &treeNode{name: n.name, isDir: n.isDir, parents: parents}
+	}
+	return dup
+}
+
+// byPathSegments sorts paths segment by segment so that
+// the items in a folder come right after the folder.
+type byPathSegments []string
+
+func (bps byPathSegments) Len() int      { return len(bps) }
+func (bps byPathSegments) Swap(i, j int) { bps[i], bps[j] = bps[j], bps[i] }
+func (bps byPathSegments) Less(i, j int) bool {
+	si, sj := strings.Split(bps[i], "/"), strings.Split(bps[j], "/")
+	for k := 0; k < len(si) && k < len(sj); k++ {
+		if si[k] != sj[k] {
+			return si[k] < sj[k]
+		}
+	}
+	return len(si) < len(sj)
+}
+
+// treeDiffRoots are the folders whose trees the operations of the current
+// command affect: the folders that the sources are in, and the destination.
+// Roots under other roots are left out as they are shown within those.
+func (g *Commands) treeDiffRoots() []string {
+	argc := len(g.opts.Sources)
+	if argc < 1 {
+		return nil
+	}
+
+	candidates := []string{path.Clean(g.opts.Sources[argc-1])}
+	for _, src := range g.opts.Sources[:argc-1] {
+		candidates = append(candidates, g.parentPather(path.Clean(src)))
+	}
+
+	var roots []string
+	for _, candidate := range candidates {
+		nested := false
+		for _, other := range candidates {
+			if other != candidate && (rootLike(other) || strings.HasPrefix(candidate, other+"/")) {
+				nested = true
+				break
+			}
+		}
+		if !nested && !hasString(roots, candidate) {
+			roots = append(roots, candidate)
+		}
+	}
+	return roots
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// logTreeDiff logs the trees affected by the plan, as they are and as they
+// would be once the plan is applied, merged into one tree in which added
+// items are marked with + and removed ones with -. Moved and renamed items
+// show as removed from where they were and added where they would end up.
+func (g *Commands) logTreeDiff() {
+	depth := g.opts.TreeDiffDepth
+	if depth < 1 {
+		depth = DefaultTreeDiffDepth
+	}
+
+	st := &simTree{nodes: make(map[string]*treeNode), rootPaths: make(map[string]string)}
+	for _, rootPath := range g.treeDiffRoots() {
+		root, err := g.rem.FindByPath(rootPath)
+		if err != nil || root == nil || !root.IsDir {
+			// Sources given by id have no known folder
+			continue
+		}
+		st.roots = append(st.roots, root.Id)
+		st.rootPaths[root.Id] = rootPath
+		g.walkRemote(st, root.Id, depth)
+	}
+
+	before := st.snapshot().paths(depth)
+	st.apply(g.plan)
+	after := st.paths(depth)
+
+	var merged []string
+	for p := range before {
+		merged = append(merged, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			merged = append(merged, p)
+		}
+	}
+	sort.Sort(byPathSegments(merged))
+
+	g.log.Logf("Tree diff, %d levels deep\n", depth)
+	added, removed := 0, 0
+	for _, p := range merged {
+		_, wasThere := before[p]
+		isDir, isThere := after[p]
+
+		marker := " "
+		switch {
+		case !wasThere:
+			marker = "+"
+			added += 1
+		case !isThere:
+			marker = "-"
+			isDir = before[p]
+			removed += 1
+		}
+
+		name, indent := p, ""
+		if !st.isRootPath(p) {
+			name = path.Base(p)
+			indent = strings.Repeat("  ", treeDepth(st, p))
+		}
+		if isDir && !rootLike(name) {
+			name += "/"
+		}
+		g.log.Logf("%s %s%s\n", marker, indent, name)
+	}
+	g.log.Logf("%d added, %d removed\n", added, removed)
+}
+
+func (st *simTree) isRootPath(p string) bool {
+	for _, rootPath := range st.rootPaths {
+		if rootPath == p {
+			return true
+		}
+	}
+	return false
+}
+
+// treeDepth is how many levels p is below the deepest root that it is under.
+func treeDepth(st *simTree, p string) int {
+	deepest := ""
+	for _, rootPath := range st.rootPaths {
+		if (rootLike(rootPath) || strings.HasPrefix(p, rootPath+"/")) && len(rootPath) >= len(deepest) {
+			deepest = rootPath
+		}
+	}
+	rel := strings.Trim(strings.TrimPrefix(p, deepest), "/")
+	return strings.Count(rel, "/") + 1
+}
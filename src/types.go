@@ -79,7 +79,12 @@ var opPrecedence = map[Operation]int{
 type File struct {
 	// AlternateLink opens the file in a relevant Google editor or viewer
 	AlternateLink string
-	BlobAt        string
+	// WebContentLink downloads the content of the file in a browser,
+	// it is unset for folders and Google-native documents
+	WebContentLink string
+	// EmbedLink is for embedding the file in a web page
+	EmbedLink string
+	BlobAt    string
 	// Copyable decides if the user has allowed for the file to be copied
 	Copyable           bool
 	ExportLinks        map[string]string
@@ -106,11 +111,32 @@ type File struct {
 	LastModifyingUsername string
 	OriginalFilename      string
 	Labels                *drive.FileLabels
+	// CreatedTime is when the file was created
+	CreatedTime time.Time
+	// WritersCanShare is unset if only the owners can share the file
+	WritersCanShare bool
+	// Editable is set if the authenticated user can edit the file, for
+	// folders that is whether items can be added to them.
+	Editable bool
+	// Processed is unset while Drive is yet to finish processing
+	// the file e.g transcoding a video or thumbnailing an image.
+	Processed bool
+	// Description is the description that the file was given
+	Description string
+	// Properties are the public properties of the file
+	// and those private to this app, by key
+	Properties map[string]string
+	// SymlinkTarget is the target of a local symlink that is
+	// pushed as is, its content is the target rather than the
+	// content of what it points to.
+	SymlinkTarget string
 }
 
 func NewRemoteFile(f *drive.File) *File {
-	return &File{
+	return uncompressed(&File{
 		AlternateLink:      f.AlternateLink,
+		WebContentLink:     f.WebContentLink,
+		EmbedLink:          f.EmbedLink,
 		BlobAt:             f.DownloadUrl,
 		Copyable:           f.Copyable,
 		Etag:               f.Etag,
@@ -132,7 +158,13 @@ func NewRemoteFile(f *drive.File) *File {
 		LastModifyingUsername: f.LastModifyingUserName,
 		OriginalFilename:      f.OriginalFilename,
 		Labels:                f.Labels,
-	}
+		Editable:              f.Editable,
+		WritersCanShare:       f.WritersCanShare,
+		CreatedTime:           parseTimeAndRound(f.CreatedDate),
+		Processed:             processingDone(f),
+		Description:           f.Description,
+		Properties:            propertyMap(f.Properties),
+	})
 }
 
 func DupFile(f *File) *File {
@@ -140,29 +172,10 @@ func DupFile(f *File) *File {
 		return f
 	}
 
-	return &File{
-		BlobAt:      f.BlobAt,
-		Etag:        f.Etag,
-		ExportLinks: f.ExportLinks,
-		Id:          f.Id,
-		IsDir:       f.IsDir,
-		Md5Checksum: f.Md5Checksum,
-		MimeType:    f.MimeType,
-		ModTime:     f.ModTime,
-		Copyable:    f.Copyable,
-		// We must convert each title to match that on the FS.
-		Name:               f.Name,
-		Size:               f.Size,
-		Shared:             f.Shared,
-		UserPermission:     f.UserPermission,
-		Version:            f.Version,
-		OwnerNames:         f.OwnerNames,
-		Permissions:        f.Permissions,
-		LastViewedByMeTime: f.LastViewedByMeTime,
-		Labels:             f.Labels,
-		AlternateLink:      f.AlternateLink,
-		OriginalFilename:   f.OriginalFilename,
-	}
+	// Every field is copied, fields added later included. Maps, slices
+	// and pointers such as Properties are shared with f.
+	dup := *f
+	return &dup
 }
 
 func NewLocalFile(absPath string, f os.FileInfo) *File {
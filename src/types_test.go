@@ -0,0 +1,76 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// filled sets every field of v, which is a pointer to a struct, to a
+// value other than its zero value.
+func filled(t *testing.T, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		name := v.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(name)
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			field.SetInt(int64(i + 1))
+		case reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+			field.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(name))
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Ptr:
+			field.Set(reflect.New(field.Type().Elem()))
+		case reflect.Struct:
+			if field.Type() != reflect.TypeOf(time.Time{}) {
+				t.Fatalf("%s: don't know how to fill a %v", name, field.Type())
+			}
+			field.Set(reflect.ValueOf(time.Unix(int64(i+1), 0)))
+		default:
+			t.Fatalf("%s: don't know how to fill a %v", name, field.Kind())
+		}
+	}
+}
+
+func TestDupFileCopiesEveryField(t *testing.T) {
+	f := &File{}
+	filled(t, reflect.ValueOf(f).Elem())
+
+	dup := DupFile(f)
+	if dup == f {
+		t.Fatalf("DupFile returned the same file")
+	}
+	got, want := reflect.ValueOf(dup).Elem(), reflect.ValueOf(f).Elem()
+	for i := 0; i < want.NumField(); i++ {
+		if !reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+			t.Errorf("%s wasn't copied", want.Type().Field(i).Name)
+		}
+	}
+
+	dup.Name = "other"
+	if f.Name == "other" {
+		t.Errorf("changing the duplicate changed the original")
+	}
+	if DupFile(nil) != nil {
+		t.Errorf("DupFile(nil) should be nil")
+	}
+}
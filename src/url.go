@@ -14,21 +14,112 @@
 
 package drive
 
-func (g *Commands) Url(byId bool) error {
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// LinkView opens the item in its Google editor or viewer
+	LinkView = "view"
+	// LinkDownload downloads the content of the file in a browser
+	LinkDownload = "download"
+	// LinkEmbed is for embedding the file in a web page
+	LinkEmbed = "embed"
+	LinkAll   = "all"
+)
+
+var linkTypes = []string{LinkView, LinkDownload, LinkEmbed}
+
+// urlEntry is the JSON form of the links of an item.
+type urlEntry struct {
+	Path     string `json:"path"`
+	Id       string `json:"id,omitempty"`
+	View     string `json:"view,omitempty"`
+	Download string `json:"download,omitempty"`
+	Embed    string `json:"embed,omitempty"`
+}
+
+// ParseLinkTypes checks the comma separated link types in spec,
+// "all" standing for all of them. An empty spec is the view link.
+func ParseLinkTypes(spec string) ([]string, error) {
+	var types []string
+	for _, linkType := range NonEmptyTrimmedStrings(strings.Split(spec, ",")...) {
+		switch linkType {
+		case LinkAll:
+			return linkTypes, nil
+		case LinkView, LinkDownload, LinkEmbed:
+			types = append(types, linkType)
+		default:
+			return nil, fmt.Errorf("unknown link type %q, expecting one of %s or %s",
+				linkType, strings.Join(linkTypes, ", "), LinkAll)
+		}
+	}
+	if len(types) < 1 {
+		types = []string{LinkView}
+	}
+	return types, nil
+}
+
+// fileLink is the link of linkType to f, if it has one.
+func fileLink(f *File, linkType string) string {
+	switch linkType {
+	case LinkDownload:
+		return f.WebContentLink
+	case LinkEmbed:
+		return f.EmbedLink
+	}
+	return f.Url()
+}
+
+// Url prints the links of types to each of opts.Sources,
+// or writes them as a line of JSON each if emitting JSON.
+func (g *Commands) Url(byId bool, types []string) error {
 	kvChan := g.urler(byId)
 
 	for kv := range kvChan {
-		switch kv.value.(type) {
-		case error:
+		f, ok := kv.value.(*File)
+		if !ok {
 			g.log.LogErrf("%s: %s\n", kv.key, kv.value)
-		default:
-			g.log.Logf("%s: %s\n", kv.key, kv.value)
+			continue
+		}
+
+		if g.emitter != nil {
+			entry := &urlEntry{Path: kv.key, Id: f.Id}
+			for _, linkType := range types {
+				switch linkType {
+				case LinkView:
+					entry.View = fileLink(f, linkType)
+				case LinkDownload:
+					entry.Download = fileLink(f, linkType)
+				case LinkEmbed:
+					entry.Embed = fileLink(f, linkType)
+				}
+			}
+			g.emitter.emit(entry)
+			continue
+		}
+
+		if len(types) == 1 {
+			g.log.Logf("%s: %s\n", kv.key, fileLink(f, types[0]))
+			continue
+		}
+
+		g.log.Logf("%s\n", kv.key)
+		for _, linkType := range types {
+			link := fileLink(f, linkType)
+			if link == "" {
+				link = "-"
+			}
+			g.log.Logf("  %-8s %s\n", linkType, link)
 		}
 	}
 
 	return nil
 }
 
+// urler resolves each of opts.Sources, sending
+// the file found or the error it failed with.
 func (g *Commands) urler(byId bool) (kvChan chan *keyValue) {
 	resolver := g.rem.FindByPath
 	if byId {
@@ -42,10 +133,13 @@ func (g *Commands) urler(byId bool) (kvChan chan *keyValue) {
 
 		for _, source := range g.opts.Sources {
 			f, err := resolver(source)
+			if err == nil && f == nil {
+				err = ErrPathNotExists
+			}
 
 			kv := keyValue{key: source, value: err}
 			if err == nil {
-				kv.value = f.Url()
+				kv.value = f
 			}
 
 			kvChan <- &kv
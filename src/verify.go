@@ -0,0 +1,154 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	CopyStatusSkipped            = "skipped"
+	CopyStatusVerified           = "verified"
+	CopyStatusVerificationFailed = "verification-failed"
+)
+
+// copyOutcome is what became of the copying of a single file.
+type copyOutcome struct {
+	SourceId string `json:"sourceId"`
+	Dest     string `json:"dest"`
+	CopyId   string `json:"copyId,omitempty"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+
+	src *File
+}
+
+// reconciliation is the summary of a copy and the verification of its copies.
+type reconciliation struct {
+	Copied             int            `json:"copied"`
+	Skipped            int            `json:"skipped"`
+	Verified           int            `json:"verified"`
+	VerificationFailed int            `json:"verificationFailed"`
+	Pass               bool           `json:"pass"`
+	Outcomes           []*copyOutcome `json:"outcomes"`
+}
+
+// copyLedger keeps track of the outcomes of the files copied, for verification.
+type copyLedger struct {
+	sync.Mutex
+	outcomes []*copyOutcome
+}
+
+// record notes the outcome of copying src to destPath. A nil copied
+// file means that src was skipped, for the reason in err if any.
+func (cl *copyLedger) record(src *File, destPath string, copied *File, err error) {
+	if cl == nil || src == nil || src.IsDir {
+		return
+	}
+
+	outcome := &copyOutcome{SourceId: src.Id, Dest: destPath, src: src}
+	switch {
+	case err != nil:
+		outcome.Status, outcome.Reason = CopyStatusSkipped, err.Error()
+	case copied == nil:
+		outcome.Status = CopyStatusSkipped
+	default:
+		outcome.CopyId = copied.Id
+	}
+
+	cl.Lock()
+	defer cl.Unlock()
+	cl.outcomes = append(cl.outcomes, outcome)
+}
+
+// verifyCopies reads back every copy made and checks that its content
+// matches that of its source. Google Docs files have no checksums so only
+// their being there can be checked.
+func (g *Commands) verifyCopies() *reconciliation {
+	rec := &reconciliation{Outcomes: g.copyLedger.outcomes}
+
+	for _, outcome := range rec.Outcomes {
+		if outcome.Status == CopyStatusSkipped {
+			rec.Skipped += 1
+			continue
+		}
+		rec.Copied += 1
+
+		g.pauseGate.wait()
+
+		copied, err := g.rem.FindById(outcome.CopyId)
+		switch {
+		case err != nil:
+			outcome.Reason = err.Error()
+		case copied.Md5Checksum != outcome.src.Md5Checksum:
+			outcome.Reason = fmt.Sprintf("checksum %q differs from the source's %q", copied.Md5Checksum, outcome.src.Md5Checksum)
+		case copied.Size != outcome.src.Size:
+			outcome.Reason = fmt.Sprintf("size %d differs from the source's %d", copied.Size, outcome.src.Size)
+		}
+
+		if outcome.Reason != "" {
+			outcome.Status = CopyStatusVerificationFailed
+			rec.VerificationFailed += 1
+			g.report.warn("Copies that failed verification", "%s: %s", outcome.Dest, outcome.Reason)
+			continue
+		}
+		outcome.Status = CopyStatusVerified
+		rec.Verified += 1
+	}
+
+	rec.Pass = rec.VerificationFailed == 0
+	return rec
+}
+
+// reconcile verifies the copies made, logs the reconciliation and writes it
+// out as JSON to opts.VerifyReportPath if set, failing unless all copies check out.
+func (g *Commands) reconcile() error {
+	rec := g.verifyCopies()
+
+	g.log.Logf("\nReconciliation\n")
+	g.log.Logf("  %-22s %d\n", "copied", rec.Copied)
+	g.log.Logf("  %-22s %d\n", "skipped", rec.Skipped)
+	g.log.Logf("  %-22s %d\n", "verified ok", rec.Verified)
+	g.log.Logf("  %-22s %d\n", "verification failed", rec.VerificationFailed)
+
+	verdict := "PASS"
+	if !rec.Pass {
+		verdict = "FAIL"
+	}
+	g.log.Logf("Verdict: %s\n", verdict)
+
+	if g.opts.VerifyReportPath != "" {
+		f, err := os.OpenFile(g.opts.VerifyReportPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, config.O_RWForAll)
+		if err != nil {
+			return fmt.Errorf("verify: report: %v", err)
+		}
+		defer f.Close()
+
+		enc := json.NewEncoder(f)
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("verify: report: %v", err)
+		}
+	}
+
+	if !rec.Pass {
+		return fmt.Errorf("verify: %d of %d copies failed verification", rec.VerificationFailed, rec.Copied)
+	}
+	return nil
+}
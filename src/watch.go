@@ -0,0 +1,109 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"time"
+)
+
+const (
+	DefaultPollInterval = 30 * time.Second
+)
+
+// WatchMove polls folder for items whose names match the regular expression
+// rule and moves them into dest as they arrive, until interrupted.
+// Every item is considered only once, even if moving it failed.
+func (g *Commands) WatchMove(folder, rule, dest string) error {
+	ruleRegexp, err := regexp.Compile(rule)
+	if err != nil {
+		return fmt.Errorf("watch: rule: %v", err)
+	}
+
+	watched, err := g.rem.FindByPath(folder)
+	if err != nil {
+		return fmt.Errorf("watch: %s: %v", folder, err)
+	}
+	if watched == nil || !watched.IsDir {
+		return fmt.Errorf("watch: %s: %v", folder, ErrPathNotDir)
+	}
+
+	destFile, err := g.rem.FindByPath(dest)
+	if err != nil {
+		return fmt.Errorf("watch: dest: %s: %v", dest, err)
+	}
+	if destFile == nil || !destFile.IsDir {
+		return fmt.Errorf("watch: dest: %s must be an existant folder", dest)
+	}
+	if destFile.Id == watched.Id {
+		return fmt.Errorf("watch: %s cannot be both the watched folder and dest", folder)
+	}
+
+	interval := g.opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	g.log.Logf("Watching %s every %v for items matching %q to move to %s. Interrupt to stop\n",
+		folder, interval, rule, dest)
+
+	processed := make(map[string]bool)
+	for {
+		g.watchMovePass(watched, folder, dest, ruleRegexp, processed)
+
+		select {
+		case <-interrupt:
+			g.log.Logln("\nStopped watching", folder)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *Commands) watchMovePass(watched *File, folder, dest string, rule *regexp.Regexp, processed map[string]bool) {
+	for child := range g.rem.findChildren(watched.Id, false) {
+		if child == nil || processed[child.Id] {
+			continue
+		}
+		processed[child.Id] = true
+
+		if !rule.MatchString(child.Name) {
+			continue
+		}
+
+		opt := moveOpt{
+			src:  path.Join(folder, child.Name),
+			dest: dest,
+			file: child,
+		}
+
+		if err := g.move(&opt); err != nil {
+			g.log.LogErrf("watch: move %s: %v\n", opt.src, err)
+			continue
+		}
+		g.log.Logf("%s %s -> %s\n", time.Now().Format(time.Kitchen), opt.src, dest)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package drive
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+var errXattrUnsupported = errors.New("extended attributes are unsupported on this system")
+
+// listXattrs reads the extended attributes of the file at p by name.
+func listXattrs(p string) (map[string][]byte, error) {
+	size, err := syscall.Listxattr(p, nil)
+	if err != nil || size < 1 {
+		if err == syscall.ENOTSUP {
+			err = errXattrUnsupported
+		}
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(p, buf); err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) < 1 {
+			continue
+		}
+		n, err := syscall.Getxattr(p, string(name), nil)
+		if err != nil {
+			return attrs, err
+		}
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(p, string(name), value); err != nil {
+			return attrs, err
+		}
+		attrs[string(name)] = value[:n]
+	}
+	return attrs, nil
+}
+
+func setXattr(p, name string, value []byte) error {
+	err := syscall.Setxattr(p, name, value, 0)
+	if err == syscall.ENOTSUP {
+		return errXattrUnsupported
+	}
+	return err
+}
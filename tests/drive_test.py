@@ -299,6 +299,61 @@ def test_move():
         expect_ne('', err)
         expect_eq(['/a', '/b', '/b/foo.txt', '/c', '/c/c.txt', '/c/foo.txt'], Drive.list(recursive=True))
 
+    with setup_files('move folder into same-named folder without merge',
+                     ['docs/a.txt', 'a'], ['b/docs/b.txt', 'b']):
+        Drive.run_fail('move', 'docs', 'b')
+        expect_eq(['/b', '/b/docs', '/b/docs/b.txt', '/docs', '/docs/a.txt'], Drive.list(recursive=True))
+
+    with setup_files('move folder merging into same-named folder',
+                     ['docs/a.txt', 'a'], ['docs/sub/c.txt', 'c'], ['b/docs/b.txt', 'b'], ['b/docs/sub/d.txt', 'd']):
+        Drive.run_ok('move', '-merge', 'docs', 'b')
+        expect_eq(['/b', '/b/docs', '/b/docs/a.txt', '/b/docs/b.txt',
+                   '/b/docs/sub', '/b/docs/sub/c.txt', '/b/docs/sub/d.txt'], Drive.list(recursive=True))
+        verify_files(['b/docs/a.txt', 'a'], ['b/docs/sub/c.txt', 'c'])
+
+    with setup_files('move folder merging with conflicting children',
+                     ['docs/a.txt', 'a'], ['docs/same.txt', 'src'], ['b/docs/same.txt', 'dest']):
+        _, _, err = Drive.run_ok('move', '-merge', 'docs', 'b')
+        expect_true('Merge conflicts left in place (1)' in err)
+        expect_eq(['/b', '/b/docs', '/b/docs/a.txt', '/b/docs/same.txt', '/docs', '/docs/same.txt'],
+                  Drive.list(recursive=True))
+        verify_files(['b/docs/same.txt', 'dest'], ['docs/same.txt', 'src'])
+
+
+def file_id(path):
+    _, out, _ = Drive.run_ok('stat', path)
+    return re.search(r'FileId\s+(\S+)', out).group(1)
+
+
+def test_copy():
+    with setup_files('copy folder',
+                     ['a/b/c.txt', 'c']):
+        Drive.run_ok('copy', '-r', 'a', 'z')
+        expect_eq(['/z', '/z/b', '/z/b/c.txt'], Drive.list('z', recursive=True))
+        verify_files(['z/b/c.txt', 'c'])
+
+    with setup_files('copy preserves empty folders',
+                     ['a/b/c.txt', 'c']):
+        Drive.run_ok('new', '-folder', 'a/empty', 'a/d/empty')
+        Drive.run_ok('copy', '-r', 'a', 'z')
+        expect_eq(['/z', '/z/b', '/z/b/c.txt', '/z/d', '/z/d/empty', '/z/empty'],
+                  Drive.list('z', recursive=True))
+
+    with setup_files('copy pruning empty folders',
+                     ['a/b/c.txt', 'c']):
+        Drive.run_ok('new', '-folder', 'a/empty', 'a/d/empty')
+        _, out, _ = Drive.run_ok('copy', '-r', '-prune-empty-dirs', 'a', 'z')
+        expect_true('Pruned empty folders (3)' in out)
+        expect_eq(['/z', '/z/b', '/z/b/c.txt'], Drive.list('z', recursive=True))
+
+    with setup_files('copy folder that is its own descendant',
+                     ['a/b/c.txt', 'c']):
+        # moving by id adds a parent without taking out the old one
+        Drive.run_ok('move', '-id', file_id('a'), 'a/b')
+        _, _, err = Drive.run_ok('copy', '-r', 'a', 'z')
+        expect_true('a -> b -> a' in err)
+        expect_eq(['/z', '/z/b', '/z/b/c.txt'], Drive.list('z', recursive=True))
+
 
 def test_stat():
     cases = [
@@ -361,6 +416,7 @@ def main():
     test_list()
     test_rename()
     test_move()
+    test_copy()
     test_stat()
     test_pull()
     test_trash()